@@ -11,12 +11,18 @@ import (
 	"golang.org/x/oauth2"
 
 	"chagnon.dev/budget-server/internal/domain/service"
+	"chagnon.dev/budget-server/internal/infrastructure/attachment"
 	"chagnon.dev/budget-server/internal/infrastructure/autoupdate"
+	"chagnon.dev/budget-server/internal/infrastructure/blobstore"
+	"chagnon.dev/budget-server/internal/infrastructure/changefeed"
 	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
 	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/infrastructure/idempotency"
 	"chagnon.dev/budget-server/internal/infrastructure/mailer"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/grpc"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/http"
+	"chagnon.dev/budget-server/internal/infrastructure/recurring"
+	"chagnon.dev/budget-server/internal/infrastructure/trash"
 	"chagnon.dev/budget-server/pkg/infrastructure/postgres"
 )
 
@@ -68,11 +74,31 @@ type DatabaseConfig struct {
 	SslMode string
 }
 
+type TrashConfig struct {
+	RetentionDays int
+	PurgeSchedule string
+}
+
+type IdempotencyConfig struct {
+	WindowHours   int
+	PurgeSchedule string
+}
+
+type AttachmentsConfig struct {
+	Directory     string
+	MaxFileSizeMb int
+	UserQuotaMb   int
+	SweepSchedule string
+}
+
 type ServerConfig struct {
-	Database  DatabaseConfig
-	Auth      AuthConfig
-	Mailer    MailerConfig
-	PublicUrl string
+	Database    DatabaseConfig
+	Auth        AuthConfig
+	Mailer      MailerConfig
+	Trash       TrashConfig
+	Idempotency IdempotencyConfig
+	Attachments AttachmentsConfig
+	PublicUrl   string
 }
 
 type Server struct {
@@ -96,6 +122,16 @@ func (s *Server) Serve(ctx context.Context) error {
 
 	repos := repository.NewRepository(dao.New(db), db)
 
+	changeBroker := changefeed.NewBroker(postgres.NewListener(
+		ctx,
+		s.config.Database.Host,
+		s.config.Database.User,
+		s.config.Database.Pass,
+		s.config.Database.Name,
+		s.config.Database.Port,
+		s.config.Database.SslMode,
+	))
+
 	var oidcConfig *oauth2.Config
 	var verifier *oidc.IDTokenVerifier
 	if s.config.Auth.Oidc.Enabled {
@@ -169,16 +205,46 @@ func (s *Server) Serve(ctx context.Context) error {
 		}
 	}
 
+	attachmentsDirectory := s.config.Attachments.Directory
+	if attachmentsDirectory == "" {
+		attachmentsDirectory = "/app/data/attachments"
+	}
+	maxFileSizeMb := s.config.Attachments.MaxFileSizeMb
+	if maxFileSizeMb == 0 {
+		maxFileSizeMb = 20
+	}
+	userQuotaMb := s.config.Attachments.UserQuotaMb
+	if userQuotaMb == 0 {
+		userQuotaMb = 1024
+	}
+	idempotencyWindowHours := s.config.Idempotency.WindowHours
+	if idempotencyWindowHours == 0 {
+		idempotencyWindowHours = 24
+	}
+	idempotencyWindow := time.Duration(idempotencyWindowHours) * time.Hour
+	attachmentStore, err := blobstore.NewLocal(attachmentsDirectory)
+	if err != nil {
+		return fmt.Errorf("setting up the attachment store: %s", err)
+	}
+
 	webServer := http.NewServer(
 		grpc.NewServerWithHandlers(
 			grpc.Services{
 				Account:          repos,
 				Category:         service.NewCategoryService(repos),
 				Currency:         repos,
-				Transaction:      repos,
+				Transaction:      service.NewTransactionService(repos),
 				ExchangeRate:     repos,
 				TransactionGroup: repos,
+				Tag:              repos,
+				Payee:            repos,
+				Rule:             repos,
+				User:             repos,
+				Budget:           service.NewBudgetService(repos),
+				Changes:          changeBroker,
+				Idempotency:      repos,
 			},
+			idempotencyWindow,
 		),
 		http.NewAuth(
 			repos,                            // userService
@@ -191,17 +257,68 @@ func (s *Server) Serve(ctx context.Context) error {
 			s.config.PublicUrl,               // serverPublicUrl
 			s.config.Auth.Oidc.ProviderUrl,   // oidcIssuer
 		),
+		http.NewAttachments(
+			repos,
+			attachmentStore,
+			int64(maxFileSizeMb)<<20,
+			int64(userQuotaMb)<<20,
+		),
 	)
 
 	exchangeRateAutoUpdater := autoupdate.NewAutoUpdater(ctx, repos, autoupdate.RunJavascript)
-	exchangeRateAutoUpdateScheduler, err := autoupdate.NewScheduler("0 6 * * *", exchangeRateAutoUpdater.NewRunner(ctx))
+	exchangeRateAutoUpdateScheduler, err := autoupdate.NewScheduler("exchange rate auto update", "0 6 * * *", exchangeRateAutoUpdater.NewRunner(ctx))
 	if err != nil {
 		return fmt.Errorf("setting up the exchange rate auto update scheduler: %s", err)
 	}
 
+	retentionDays := s.config.Trash.RetentionDays
+	if retentionDays == 0 {
+		retentionDays = 30
+	}
+	purgeSchedule := s.config.Trash.PurgeSchedule
+	if purgeSchedule == "" {
+		purgeSchedule = "0 4 * * *"
+	}
+	trashPurger := trash.NewPurger(repos, time.Duration(retentionDays)*24*time.Hour)
+	trashPurgeScheduler, err := autoupdate.NewScheduler("trash purge", purgeSchedule, trashPurger.NewRunner(ctx))
+	if err != nil {
+		return fmt.Errorf("setting up the trash purge scheduler: %s", err)
+	}
+
+	idempotencyPurgeSchedule := s.config.Idempotency.PurgeSchedule
+	if idempotencyPurgeSchedule == "" {
+		idempotencyPurgeSchedule = "15 4 * * *"
+	}
+	idempotencyPurger := idempotency.NewPurger(repos, idempotencyWindow)
+	idempotencyPurgeScheduler, err := autoupdate.NewScheduler("idempotency key purge", idempotencyPurgeSchedule, idempotencyPurger.NewRunner(ctx))
+	if err != nil {
+		return fmt.Errorf("setting up the idempotency key purge scheduler: %s", err)
+	}
+
+	recurringTransactionMaterializer := recurring.NewMaterializer(repos)
+	recurringTransactionScheduler, err := autoupdate.NewScheduler("recurring transactions", "0 * * * *", recurringTransactionMaterializer.NewRunner(ctx))
+	if err != nil {
+		return fmt.Errorf("setting up the recurring transaction scheduler: %s", err)
+	}
+
+	sweepSchedule := s.config.Attachments.SweepSchedule
+	if sweepSchedule == "" {
+		sweepSchedule = "30 4 * * *"
+	}
+	attachmentSweeper := attachment.NewSweeper(repos, attachmentStore)
+	attachmentSweepScheduler, err := autoupdate.NewScheduler("attachment sweep", sweepSchedule, attachmentSweeper.NewRunner(ctx))
+	if err != nil {
+		return fmt.Errorf("setting up the attachment sweep scheduler: %s", err)
+	}
+
 	rootSupervisor := suture.New("root", suture.Spec{})
 	rootSupervisor.Add(webServer)
 	rootSupervisor.Add(exchangeRateAutoUpdateScheduler)
+	rootSupervisor.Add(changeBroker)
+	rootSupervisor.Add(trashPurgeScheduler)
+	rootSupervisor.Add(idempotencyPurgeScheduler)
+	rootSupervisor.Add(recurringTransactionScheduler)
+	rootSupervisor.Add(attachmentSweepScheduler)
 	return rootSupervisor.Serve(ctx)
 }
 
@@ -54,6 +54,20 @@ type Config struct {
 		ReplyTo     string `mapstructure:"replyTo"`
 		UseMock     bool   `mapstructure:"useMock"`
 	} `mapstructure:"mailer"`
+	Trash struct {
+		RetentionDays int    `mapstructure:"retentionDays"`
+		PurgeSchedule string `mapstructure:"purgeSchedule"`
+	} `mapstructure:"trash"`
+	Idempotency struct {
+		WindowHours   int    `mapstructure:"windowHours"`
+		PurgeSchedule string `mapstructure:"purgeSchedule"`
+	} `mapstructure:"idempotency"`
+	Attachments struct {
+		Directory     string `mapstructure:"directory"`
+		MaxFileSizeMb int    `mapstructure:"maxFileSizeMb"`
+		UserQuotaMb   int    `mapstructure:"userQuotaMb"`
+		SweepSchedule string `mapstructure:"sweepSchedule"`
+	} `mapstructure:"attachments"`
 	Server struct {
 		PublicUrl string `mapstructure:"publicUrl"`
 	} `mapstructure:"server"`
@@ -106,6 +120,20 @@ var startCmd = &cobra.Command{
 				ReplyTo:     config.Mailer.ReplyTo,
 				UseMock:     config.Mailer.UseMock,
 			},
+			Trash: TrashConfig{
+				RetentionDays: config.Trash.RetentionDays,
+				PurgeSchedule: config.Trash.PurgeSchedule,
+			},
+			Idempotency: IdempotencyConfig{
+				WindowHours:   config.Idempotency.WindowHours,
+				PurgeSchedule: config.Idempotency.PurgeSchedule,
+			},
+			Attachments: AttachmentsConfig{
+				Directory:     config.Attachments.Directory,
+				MaxFileSizeMb: config.Attachments.MaxFileSizeMb,
+				UserQuotaMb:   config.Attachments.UserQuotaMb,
+				SweepSchedule: config.Attachments.SweepSchedule,
+			},
 			PublicUrl: config.Server.PublicUrl,
 		}}
 
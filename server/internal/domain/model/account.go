@@ -14,4 +14,5 @@ type Account struct {
 	IsMine               bool
 	Type                 string
 	FinancialInstitution string
+	Version              int
 }
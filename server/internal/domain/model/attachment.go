@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+type AttachmentID int
+
+// Attachment describes a file attached to a transaction, such as the photo of
+// a receipt. The bytes themselves are kept in the blob store.
+type Attachment struct {
+	ID        AttachmentID
+	Name      string
+	MimeType  string
+	Size      int64
+	SHA256    string
+	CreatedAt time.Time
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+type BudgetID int
+
+// BudgetPeriod is how long the amount of a budget lasts before it starts
+// over.
+type BudgetPeriod int
+
+const (
+	BudgetPeriodMonthly BudgetPeriod = iota
+	BudgetPeriodWeekly
+	BudgetPeriodYearly
+)
+
+// Start returns the first day of the period the date falls in. Weeks begin on
+// Monday. Days are calendar dates at midnight UTC.
+func (p BudgetPeriod) Start(date time.Time) (time.Time, error) {
+	switch p {
+	case BudgetPeriodMonthly:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	case BudgetPeriodWeekly:
+		sinceMonday := (int(date.Weekday()) + 6) % 7
+		return time.Date(date.Year(), date.Month(), date.Day()-sinceMonday, 0, 0, 0, 0, time.UTC), nil
+	case BudgetPeriodYearly:
+		return time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown BudgetPeriod %d", p)
+	}
+}
+
+// Next returns the first day of the period after the one starting on start.
+func (p BudgetPeriod) Next(start time.Time) (time.Time, error) {
+	switch p {
+	case BudgetPeriodMonthly:
+		return start.AddDate(0, 1, 0), nil
+	case BudgetPeriodWeekly:
+		return start.AddDate(0, 0, 7), nil
+	case BudgetPeriodYearly:
+		return start.AddDate(1, 0, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown BudgetPeriod %d", p)
+	}
+}
+
+// Budget is the amount planned to be spent on a category, its subcategories
+// included, in every period from StartsOn on, until a later budget of the
+// same category and period takes over.
+type Budget struct {
+	ID       BudgetID
+	Category CategoryID
+	Period   BudgetPeriod
+	// StartsOn is the first day of the first period the amount applies to.
+	StartsOn time.Time
+	Amount   int
+	Currency CurrencyID
+}
+
+// BudgetStatus compares a budget with what was spent on its category during
+// one period, in the currency of the budget.
+type BudgetStatus struct {
+	Budget Budget
+	// Spent is what went out for the category and its subcategories, less
+	// what came back in, such as refunds.
+	Spent     int64
+	Remaining int64
+	// MissingExchangeRate is set when some of the spending could not be
+	// converted to the currency of the budget and is left out of Spent.
+	MissingExchangeRate bool
+}
+
+// CategorySpending is what went out for a category, less what came in, on one
+// day in one currency. Line items count towards their own category.
+type CategorySpending struct {
+	Category Optional[CategoryID]
+	Currency CurrencyID
+	Day      time.Time
+	Amount   int64
+}
+
+// PeriodBudgetStatus is the status of the budgets in effect during a period.
+type PeriodBudgetStatus struct {
+	Start time.Time
+	// End is the first day of the next period.
+	End     time.Time
+	Budgets []BudgetStatus
+}
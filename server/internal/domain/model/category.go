@@ -11,4 +11,5 @@ type Category struct {
 	IconBackground string
 	FixedCost      bool
 	Ordering       float64
+	Version        int
 }
@@ -0,0 +1,30 @@
+package model
+
+// CategoryTemplate is a tree of categories to start from. Its categories go
+// under the root category of the user who applies it.
+type CategoryTemplate struct {
+	// ID names the built-in templates. Exported templates have none.
+	ID          string
+	Name        string
+	Description string
+	Categories  []CategoryTemplateNode
+}
+
+// CategoryTemplateNode is a category of a template, in the order it is shown
+// among its siblings.
+type CategoryTemplateNode struct {
+	Name           string
+	IconName       string
+	IconColor      string
+	IconBackground string
+	FixedCost      bool
+	Children       []CategoryTemplateNode
+}
+
+// CategoryTemplateResult is what applying a template did. Existing holds the
+// categories of the user the template matched by name, which are left as they
+// are.
+type CategoryTemplateResult struct {
+	Created  []CategoryID
+	Existing []CategoryID
+}
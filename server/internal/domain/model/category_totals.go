@@ -0,0 +1,81 @@
+package model
+
+import "time"
+
+// CategoryAmounts is what came in and went out under categories, split by
+// whether the categories are fixed costs. All amounts are positive.
+type CategoryAmounts struct {
+	FixedIncome     int64
+	VariableIncome  int64
+	FixedExpense    int64
+	VariableExpense int64
+}
+
+func (a CategoryAmounts) Income() int64 {
+	return a.FixedIncome + a.VariableIncome
+}
+
+func (a CategoryAmounts) Expense() int64 {
+	return a.FixedExpense + a.VariableExpense
+}
+
+func (a CategoryAmounts) IsZero() bool {
+	return a == CategoryAmounts{}
+}
+
+func (a CategoryAmounts) Add(other CategoryAmounts) CategoryAmounts {
+	return CategoryAmounts{
+		FixedIncome:     a.FixedIncome + other.FixedIncome,
+		VariableIncome:  a.VariableIncome + other.VariableIncome,
+		FixedExpense:    a.FixedExpense + other.FixedExpense,
+		VariableExpense: a.VariableExpense + other.VariableExpense,
+	}
+}
+
+// NetCategoryAmounts files the net amount of a category, positive when more
+// came in than went out, as income or as expense.
+func NetCategoryAmounts(net int64, fixedCost bool) CategoryAmounts {
+	switch {
+	case net > 0 && fixedCost:
+		return CategoryAmounts{FixedIncome: net}
+	case net > 0:
+		return CategoryAmounts{VariableIncome: net}
+	case fixedCost:
+		return CategoryAmounts{FixedExpense: -net}
+	default:
+		return CategoryAmounts{VariableExpense: -net}
+	}
+}
+
+// CategoryTotal is what happened under a category, on its own and along with
+// its subcategories.
+type CategoryTotal struct {
+	Category CategoryID
+	// Own nets what came in and went out under the category itself, then
+	// files it as income or expense, so a refund lowers the expense of its
+	// category instead of counting as income.
+	Own CategoryAmounts
+	// Subtree adds up the Own amounts of the category and its descendants.
+	Subtree CategoryAmounts
+}
+
+// CategoryTotals is what happened under each category during a period, in a
+// single currency. Categories with nothing in their subtree are left out.
+type CategoryTotals struct {
+	// Start and End are the first day of the period and the day after its
+	// last.
+	Start, End    time.Time
+	Categories    []CategoryTotal
+	Uncategorized CategoryAmounts
+	// MissingExchangeRate is set when some amounts could not be converted to
+	// the currency and are left out.
+	MissingExchangeRate bool
+}
+
+// CategoryTotalsReport holds the totals of a range of days and, when asked
+// for, those of each month it overlaps.
+type CategoryTotalsReport struct {
+	Currency CurrencyID
+	Total    CategoryTotals
+	Months   []CategoryTotals
+}
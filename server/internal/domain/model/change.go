@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+type ChangedEntity int
+
+const (
+	ChangedEntityTransaction ChangedEntity = iota
+	ChangedEntityAccount
+	ChangedEntityCategory
+	ChangedEntityCurrency
+	ChangedEntityExchangeRate
+	ChangedEntityTransactionGroup
+	ChangedEntityTag
+	ChangedEntityPayee
+	ChangedEntityRule
+	ChangedEntityBudget
+)
+
+type ChangeType int
+
+const (
+	ChangeTypeCreated ChangeType = iota
+	ChangeTypeUpdated
+	ChangeTypeDeleted
+)
+
+type ExchangeRateKey struct {
+	CurrencyA CurrencyID
+	CurrencyB CurrencyID
+	Date      time.Time
+}
+
+type Change struct {
+	Entity       ChangedEntity
+	Type         ChangeType
+	ID           int
+	ExchangeRate Optional[ExchangeRateKey]
+
+	// ResyncRequired is set when changes may have been missed, for instance
+	// after the database connection dropped. Clients should reload everything.
+	ResyncRequired bool
+}
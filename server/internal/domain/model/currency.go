@@ -15,4 +15,5 @@ type Currency struct {
 	Type                   string
 	DecimalPoints          int
 	RateAutoUpdateSettings RateAutoUpdateSettings
+	Version                int
 }
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// DuplicateCandidate is an existing transaction that looks like the same
+// real-world payment as another one: same account or shared group, close
+// amount and date, and a similar note.
+type DuplicateCandidate struct {
+	ID             TransactionID
+	Owner          Email
+	Amount         int
+	Currency       CurrencyID
+	Date           time.Time
+	Note           string
+	NoteSimilarity float64
+}
+
+// DuplicatePair is two existing transactions that are likely to be the same
+// payment entered twice, typically by two members of a group or once by hand
+// and once by an import. NoteSimilarity is set on both sides.
+type DuplicatePair struct {
+	First, Second DuplicateCandidate
+}
@@ -0,0 +1,199 @@
+package model
+
+import (
+	"time"
+)
+
+type BudgetTransferID int
+
+// EnvelopeSettings turn on envelope budgeting for a user. Envelopes are kept
+// by month, from the month of StartsOn on, in a single currency.
+type EnvelopeSettings struct {
+	Currency CurrencyID
+	// StartsOn is the first day of the first month of the ledger.
+	StartsOn time.Time
+}
+
+// Envelope holds the money set aside for a category and its subcategories,
+// down to the next envelope. Income envelopes hold nothing: what comes in
+// under their category goes to the money available to budget.
+type Envelope struct {
+	Category CategoryID
+	Income   bool
+}
+
+// EnvelopeAssignment is the money given to an envelope, out of the money
+// available to budget, in one month.
+type EnvelopeAssignment struct {
+	Category CategoryID
+	Month    time.Time
+	Amount   int
+}
+
+// BudgetTransfer moves money from one envelope to another in a month.
+type BudgetTransfer struct {
+	ID     BudgetTransferID
+	Month  time.Time
+	From   CategoryID
+	To     CategoryID
+	Amount int
+	Note   string
+}
+
+// EnvelopeLedgerEntry is the state of an envelope at the end of a month.
+// Amounts are in the currency of the envelopes.
+type EnvelopeLedgerEntry struct {
+	Category CategoryID
+	Month    time.Time
+	Income   bool
+	Assigned int64
+	// Transferred is what budget transfers brought in, less what they took
+	// out.
+	Transferred int64
+	// Activity is what came in under the categories of the envelope less
+	// what went out, so spending makes it negative.
+	Activity int64
+	// Balance is what the envelope holds: the balance of the previous month,
+	// which is negative after overspending, plus the assigned, transferred
+	// and activity of this one. It is always zero for income envelopes.
+	Balance int64
+	// MissingExchangeRate is set when some of the activity could not be
+	// converted to the currency of the envelopes and is left out.
+	MissingExchangeRate bool
+}
+
+// EnvelopeMonth is the state of all envelopes at the end of a month.
+type EnvelopeMonth struct {
+	Month    time.Time
+	Currency CurrencyID
+	// AvailableToBudget is what the income envelopes brought in up to this
+	// month, less what was assigned to the others.
+	AvailableToBudget int64
+	Envelopes         []EnvelopeLedgerEntry
+	Transfers         []BudgetTransfer
+}
+
+// MonthStart returns the first day of the month of a date.
+func MonthStart(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// EnvelopeLedger holds what the ledger of a range of months is computed from.
+type EnvelopeLedger struct {
+	// From and Through are the first days of the first and last months to
+	// compute.
+	From, Through time.Time
+	Currency      CurrencyID
+	Envelopes     []Envelope
+	Categories    []Category
+	// CarriedOver holds the balance of each envelope at the end of the month
+	// before From.
+	CarriedOver   map[CategoryID]int64
+	Assignments   []EnvelopeAssignment
+	Transfers     []BudgetTransfer
+	Spending      []CategorySpending
+	ExchangeRates *ExchangeRates
+}
+
+type envelopeMonth struct {
+	category CategoryID
+	month    time.Time
+}
+
+// Compute returns an entry for every envelope and every month of the range,
+// month after month.
+func (l EnvelopeLedger) Compute() []EnvelopeLedgerEntry {
+	envelopes := map[CategoryID]Envelope{}
+	for _, envelope := range l.Envelopes {
+		envelopes[envelope.Category] = envelope
+	}
+
+	parents := map[CategoryID]CategoryID{}
+	for _, category := range l.Categories {
+		parents[category.ID] = category.ParentId
+	}
+
+	assigned := map[envelopeMonth]int64{}
+	for _, assignment := range l.Assignments {
+		assigned[envelopeMonth{assignment.Category, MonthStart(assignment.Month)}] += int64(assignment.Amount)
+	}
+
+	transferred := map[envelopeMonth]int64{}
+	for _, transfer := range l.Transfers {
+		month := MonthStart(transfer.Month)
+		transferred[envelopeMonth{transfer.From, month}] -= int64(transfer.Amount)
+		transferred[envelopeMonth{transfer.To, month}] += int64(transfer.Amount)
+	}
+
+	activity := map[envelopeMonth]int64{}
+	missingExchangeRate := map[envelopeMonth]bool{}
+	for _, spent := range l.Spending {
+		category, isSome := spent.Category.Value()
+		if !isSome {
+			continue
+		}
+
+		envelope, found := envelopeOf(category, envelopes, parents)
+		if !found {
+			continue
+		}
+
+		key := envelopeMonth{envelope, MonthStart(spent.Day)}
+		converted, found := l.ExchangeRates.Convert(spent.Amount, spent.Currency, l.Currency, spent.Day)
+		if !found {
+			missingExchangeRate[key] = true
+			continue
+		}
+		activity[key] -= converted
+	}
+
+	balances := map[CategoryID]int64{}
+	for category, balance := range l.CarriedOver {
+		balances[category] = balance
+	}
+
+	entries := make([]EnvelopeLedgerEntry, 0)
+	for month := l.From; !month.After(l.Through); month = month.AddDate(0, 1, 0) {
+		for _, envelope := range l.Envelopes {
+			key := envelopeMonth{envelope.Category, month}
+			entry := EnvelopeLedgerEntry{
+				Category:            envelope.Category,
+				Month:               month,
+				Income:              envelope.Income,
+				Assigned:            assigned[key],
+				Transferred:         transferred[key],
+				Activity:            activity[key],
+				MissingExchangeRate: missingExchangeRate[key],
+			}
+
+			if !envelope.Income {
+				entry.Balance = balances[envelope.Category] + entry.Assigned + entry.Transferred + entry.Activity
+				balances[envelope.Category] = entry.Balance
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// envelopeOf finds the envelope the activity of a category goes to: its own,
+// or that of its closest ancestor with one.
+func envelopeOf(
+	category CategoryID,
+	envelopes map[CategoryID]Envelope,
+	parents map[CategoryID]CategoryID,
+) (CategoryID, bool) {
+	seen := map[CategoryID]bool{}
+	for category != 0 && !seen[category] {
+		if _, isEnvelope := envelopes[category]; isEnvelope {
+			return category, true
+		}
+
+		seen[category] = true
+		category = parents[category]
+	}
+
+	return 0, false
+}
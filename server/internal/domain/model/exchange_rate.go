@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"math"
+	"slices"
+	"time"
+)
 
 type ExchangeRate struct {
 	CurrencyA CurrencyID
@@ -8,3 +12,118 @@ type ExchangeRate struct {
 	Rate      float64
 	Date      time.Time
 }
+
+type currencyPair struct {
+	from, to CurrencyID
+}
+
+// ExchangeRates converts amounts between currencies with the rates a user
+// stored. A rate from A to B converts the smallest unit of A to that of B,
+// and serves the other way around as well.
+type ExchangeRates struct {
+	// byPair holds the rates of each pair, by date.
+	byPair map[currencyPair][]ExchangeRate
+	// others holds, for each currency, the currencies it has rates to.
+	others map[CurrencyID][]CurrencyID
+}
+
+func NewExchangeRates(rates []ExchangeRate) *ExchangeRates {
+	exchangeRates := &ExchangeRates{
+		byPair: map[currencyPair][]ExchangeRate{},
+		others: map[CurrencyID][]CurrencyID{},
+	}
+
+	for _, rate := range rates {
+		if rate.Rate <= 0 || rate.CurrencyA == rate.CurrencyB {
+			continue
+		}
+
+		exchangeRates.add(rate)
+		exchangeRates.add(ExchangeRate{
+			CurrencyA: rate.CurrencyB,
+			CurrencyB: rate.CurrencyA,
+			Rate:      1 / rate.Rate,
+			Date:      rate.Date,
+		})
+	}
+
+	for pair, rates := range exchangeRates.byPair {
+		slices.SortStableFunc(rates, func(a, b ExchangeRate) int {
+			return a.Date.Compare(b.Date)
+		})
+		exchangeRates.byPair[pair] = rates
+	}
+	for currency, others := range exchangeRates.others {
+		slices.Sort(others)
+		exchangeRates.others[currency] = others
+	}
+
+	return exchangeRates
+}
+
+func (e *ExchangeRates) add(rate ExchangeRate) {
+	pair := currencyPair{rate.CurrencyA, rate.CurrencyB}
+	if _, known := e.byPair[pair]; !known {
+		e.others[rate.CurrencyA] = append(e.others[rate.CurrencyA], rate.CurrencyB)
+	}
+
+	e.byPair[pair] = append(e.byPair[pair], rate)
+}
+
+// Rate returns the rate from one currency to another on the date. Between two
+// stored rates it is interpolated, as the front end does; before the first
+// or after the last, the closest one holds. Currencies without a rate between
+// them are converted through a third one they both have rates to, which is
+// how the rates kept up to date against the default currency link them. It
+// reports whether a rate was found.
+func (e *ExchangeRates) Rate(from, to CurrencyID, date time.Time) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+
+	if rate, found := e.directRate(from, to, date); found {
+		return rate, true
+	}
+
+	for _, through := range e.others[from] {
+		toThrough, _ := e.directRate(from, through, date)
+		if fromThrough, found := e.directRate(through, to, date); found {
+			return toThrough * fromThrough, true
+		}
+	}
+
+	return 0, false
+}
+
+// Convert converts an amount, in the smallest unit of its currency, to the
+// smallest unit of another. It reports whether a rate was found.
+func (e *ExchangeRates) Convert(amount int64, from, to CurrencyID, date time.Time) (int64, bool) {
+	rate, found := e.Rate(from, to, date)
+	if !found {
+		return 0, false
+	}
+
+	return int64(math.Round(float64(amount) * rate)), true
+}
+
+func (e *ExchangeRates) directRate(from, to CurrencyID, date time.Time) (float64, bool) {
+	rates := e.byPair[currencyPair{from, to}]
+	if len(rates) == 0 {
+		return 0, false
+	}
+
+	after, _ := slices.BinarySearchFunc(rates, date, func(rate ExchangeRate, date time.Time) int {
+		return rate.Date.Compare(date)
+	})
+	if after == 0 {
+		return rates[0].Rate, true
+	}
+	if after == len(rates) {
+		return rates[len(rates)-1].Rate, true
+	}
+
+	before := rates[after-1]
+	next := rates[after]
+	ratio := float64(date.Sub(before.Date)) / float64(next.Date.Sub(before.Date))
+	return before.Rate*(1-ratio) + next.Rate*ratio, true
+}
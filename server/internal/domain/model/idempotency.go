@@ -0,0 +1,10 @@
+package model
+
+// IdempotentRequest is the first request a user made with an idempotency key.
+type IdempotentRequest struct {
+	Method      string
+	RequestHash []byte
+	// Response is the encoded response to the request, and is None while the
+	// request is still being handled.
+	Response Optional[[]byte]
+}
@@ -0,0 +1,13 @@
+package model
+
+type PayeeID int
+
+// Payee is the merchant or person on the other side of a transaction. Its
+// aliases are the names it appears under on statements, so that
+// "AMZN Mktp CA*2K3" and "Amazon.ca" both resolve to the same payee.
+type Payee struct {
+	ID              PayeeID
+	Name            string
+	DefaultCategory Optional[CategoryID]
+	Aliases         []string
+}
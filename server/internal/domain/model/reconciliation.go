@@ -0,0 +1,22 @@
+package model
+
+// ReconciliationBalance compares, for one currency, the balance on a bank
+// statement with the balance computed from the account's initial value and
+// its cleared and reconciled transactions up to the statement date.
+type ReconciliationBalance struct {
+	Currency         CurrencyID
+	StatementBalance int
+	ClearedBalance   int
+}
+
+func (b ReconciliationBalance) Difference() int {
+	return b.StatementBalance - b.ClearedBalance
+}
+
+type ReconciliationResult struct {
+	Balances []ReconciliationBalance
+	// Reconciled is set when every balance matched and the cleared
+	// transactions were locked as reconciled.
+	Reconciled             bool
+	ReconciledTransactions int
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+type RecurringTransactionID int
+
+// RecurringTransaction is a template from which the scheduler materializes a
+// transaction on every occurrence of Schedule, a cron expression or an RRULE.
+type RecurringTransaction struct {
+	ID               RecurringTransactionID
+	Owner            Email
+	Amount           int
+	Currency         CurrencyID
+	Sender           Optional[AccountID]
+	Receiver         Optional[AccountID]
+	Category         Optional[CategoryID]
+	Note             string
+	ReceiverCurrency CurrencyID
+	ReceiverAmount   int
+	Schedule         string
+	StartsOn         time.Time
+	EndsOn           Optional[time.Time]
+	Paused           bool
+
+	// MaterializedThrough is the date up to which every occurrence has been
+	// turned into a transaction.
+	MaterializedThrough Optional[time.Time]
+}
@@ -0,0 +1,181 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+type RuleID int
+
+// RuleConditions are the checks a transaction has to pass for a rule to
+// apply. Only the conditions that are set are checked.
+type RuleConditions struct {
+	// NotePattern is a regular expression searched for in the note.
+	NotePattern Optional[string]
+	MinAmount   Optional[int]
+	MaxAmount   Optional[int]
+	// Account matches transactions sent from or received into the account.
+	Account  Optional[AccountID]
+	Currency Optional[CurrencyID]
+	Payee    Optional[PayeeID]
+}
+
+// RuleActions are the changes a rule makes to the transactions it matches.
+type RuleActions struct {
+	Category Optional[CategoryID]
+	// RewriteNote replaces the parts of the note matching the note pattern
+	// of the rule, and may refer to its capture groups as $1, $2 and so on.
+	// Without a note pattern, it replaces the whole note.
+	RewriteNote      Optional[string]
+	Tags             []TagID
+	TransactionGroup Optional[TransactionGroupID]
+}
+
+// Rule categorizes or rewrites transactions automatically. Rules run by
+// ascending priority; a matching rule with StopProcessing set keeps the rules
+// after it from running.
+type Rule struct {
+	ID             RuleID
+	Name           string
+	Priority       int
+	Enabled        bool
+	StopProcessing bool
+	Conditions     RuleConditions
+	Actions        RuleActions
+}
+
+// RuleTarget holds the fields of a transaction that rules look at or change.
+type RuleTarget struct {
+	Amount           int
+	Currency         CurrencyID
+	Sender           Optional[AccountID]
+	Receiver         Optional[AccountID]
+	Payee            Optional[PayeeID]
+	Note             string
+	Category         Optional[CategoryID]
+	Tags             []TagID
+	TransactionGroup Optional[TransactionGroupID]
+}
+
+// RuleOutcome describes what the rules changed, or would change, on an
+// existing transaction.
+type RuleOutcome struct {
+	Transaction TransactionID
+	Rules       []RuleID
+	Changes     []TransactionFieldChange
+}
+
+type compiledRule struct {
+	Rule
+	notePattern *regexp.Regexp
+}
+
+// RuleSet is a list of rules ready to be run against transactions.
+type RuleSet struct {
+	rules []compiledRule
+}
+
+// NewRuleSet compiles the note patterns of the rules, which run in the order
+// given.
+func NewRuleSet(rules []Rule) (RuleSet, error) {
+	compiled := make([]compiledRule, len(rules))
+	for i, rule := range rules {
+		compiled[i] = compiledRule{Rule: rule}
+
+		if pattern, isSome := rule.Conditions.NotePattern.Value(); isSome {
+			notePattern, err := regexp.Compile(pattern)
+			if err != nil {
+				return RuleSet{}, fmt.Errorf("compiling note pattern of rule %q: %w", rule.Name, err)
+			}
+
+			compiled[i].notePattern = notePattern
+		}
+	}
+
+	return RuleSet{rules: compiled}, nil
+}
+
+func (r *compiledRule) matches(target RuleTarget) bool {
+	conditions := r.Conditions
+
+	if r.notePattern != nil && !r.notePattern.MatchString(target.Note) {
+		return false
+	}
+
+	if minAmount, isSome := conditions.MinAmount.Value(); isSome && target.Amount < minAmount {
+		return false
+	}
+
+	if maxAmount, isSome := conditions.MaxAmount.Value(); isSome && target.Amount > maxAmount {
+		return false
+	}
+
+	if account, isSome := conditions.Account.Value(); isSome &&
+		target.Sender != Some(account) && target.Receiver != Some(account) {
+		return false
+	}
+
+	if currency, isSome := conditions.Currency.Value(); isSome && target.Currency != currency {
+		return false
+	}
+
+	if payee, isSome := conditions.Payee.Value(); isSome && target.Payee != Some(payee) {
+		return false
+	}
+
+	return true
+}
+
+// Apply runs the rules against the target and returns it rewritten, along
+// with the rules that matched. Each matching rule sees the note as rewritten
+// by the rules before it. The category and transaction group are set by the
+// first matching rule setting them, and only replace a value the target
+// already has when overwrite is set. Tags are added to those of the target.
+func (s RuleSet) Apply(target RuleTarget, overwrite bool) (RuleTarget, []RuleID) {
+	target.Tags = slices.Clone(target.Tags)
+
+	categorySet := !overwrite && target.Category.IsSome()
+	transactionGroupSet := !overwrite && target.TransactionGroup.IsSome()
+
+	matched := make([]RuleID, 0)
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if !rule.matches(target) {
+			continue
+		}
+
+		matched = append(matched, rule.ID)
+		actions := rule.Actions
+
+		if category, isSome := actions.Category.Value(); isSome && !categorySet {
+			target.Category = Some(category)
+			categorySet = true
+		}
+
+		if rewrite, isSome := actions.RewriteNote.Value(); isSome {
+			if rule.notePattern != nil {
+				target.Note = rule.notePattern.ReplaceAllString(target.Note, rewrite)
+			} else {
+				target.Note = rewrite
+			}
+		}
+
+		for _, tag := range actions.Tags {
+			if !slices.Contains(target.Tags, tag) {
+				target.Tags = append(target.Tags, tag)
+			}
+		}
+
+		if transactionGroup, isSome := actions.TransactionGroup.Value(); isSome && !transactionGroupSet {
+			target.TransactionGroup = Some(transactionGroup)
+			transactionGroupSet = true
+		}
+
+		if rule.StopProcessing {
+			break
+		}
+	}
+
+	return target, matched
+}
@@ -0,0 +1,10 @@
+package model
+
+type TagID int
+
+// Tag is a free-form label a user puts on transactions for views that cut
+// across the category tree, such as "vacation 2025" or "reimbursable".
+type Tag struct {
+	ID   TagID
+	Name string
+}
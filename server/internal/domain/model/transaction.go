@@ -34,6 +34,42 @@ type GroupedTransactionData struct {
 	SplitOverride    Optional[SplitOverride]
 }
 
+// TransactionLineItem is the part of a transaction's amount assigned to a
+// single category, such as the groceries on a receipt that also holds
+// household supplies. A transaction either has no line items or line items
+// whose amounts add up to its amount.
+type TransactionLineItem struct {
+	Category Optional[CategoryID]
+	Amount   int
+	Note     string
+}
+
+// TransactionStatus tracks whether a transaction was checked against a bank
+// statement. Reconciled transactions are locked against edits.
+type TransactionStatus int
+
+const (
+	TransactionStatusPending TransactionStatus = iota
+	TransactionStatusCleared
+	TransactionStatusReconciled
+)
+
+type AttributionState int
+
+const (
+	AttributionStatePending AttributionState = iota
+	AttributionStateAccepted
+	AttributionStateDisputed
+)
+
+// TransactionAttribution is set on a transaction recorded by a group member on
+// behalf of its owner, who paid it and can accept or dispute it.
+type TransactionAttribution struct {
+	RecordedBy    Optional[Email]
+	State         AttributionState
+	DisputeReason string
+}
+
 type Transaction struct {
 	ID                     TransactionID
 	Owner                  Email
@@ -48,4 +84,76 @@ type Transaction struct {
 	ReceiverAmount         int
 	FinancialIncomeData    Optional[FinancialIncomeData]
 	GroupedTransactionData Optional[GroupedTransactionData]
+	LineItems              []TransactionLineItem
+	Tags                   []TagID
+	Attachments            []Attachment
+	Status                 TransactionStatus
+	Attribution            Optional[TransactionAttribution]
+	Payee                  Optional[PayeeID]
+	Version                int
+}
+
+// TransactionCursor marks a position in the (date, id) ordering of a user's
+// transactions, newest first.
+type TransactionCursor struct {
+	Date time.Time
+	ID   TransactionID
+}
+
+// DeletedTransaction is a transaction sitting in its owner's trash. It can be
+// restored until it is purged, either explicitly or by the retention job.
+type DeletedTransaction struct {
+	Transaction
+	DeletedAt time.Time
+}
+
+type TransactionSearchField int
+
+const (
+	TransactionSearchFieldNote TransactionSearchField = iota
+	TransactionSearchFieldSender
+	TransactionSearchFieldReceiver
+	TransactionSearchFieldCategory
+	TransactionSearchFieldTransactionGroup
+)
+
+// TransactionSearchMatch is the text of a field that matched a search, with
+// each matching term wrapped between U+0002 and U+0003. Control characters
+// cannot clash with user text, unlike HTML tags or brackets.
+type TransactionSearchMatch struct {
+	Field     TransactionSearchField
+	Highlight string
+}
+
+type TransactionSearchResult struct {
+	Transaction
+	Rank    float64
+	Matches []TransactionSearchMatch
+}
+
+type TransactionHistoryAction int
+
+const (
+	TransactionHistoryActionCreated TransactionHistoryAction = iota
+	TransactionHistoryActionUpdated
+	TransactionHistoryActionDeleted
+	TransactionHistoryActionRestored
+)
+
+// TransactionFieldChange is the value of a single field before and after a
+// change, each encoded as JSON. A null value means the field was not set.
+type TransactionFieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// TransactionHistoryEntry records who changed a transaction and how. Entries
+// are append-only. ChangedBy is empty once the author's account is gone.
+type TransactionHistoryEntry struct {
+	ID        int64
+	ChangedBy Optional[Email]
+	ChangedAt time.Time
+	Action    TransactionHistoryAction
+	Changes   []TransactionFieldChange
 }
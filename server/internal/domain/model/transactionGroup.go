@@ -26,4 +26,5 @@ type TransactionGroup struct {
 	Category         Optional[CategoryID]
 	Members          []Member
 	Hidden           bool
+	Version          int
 }
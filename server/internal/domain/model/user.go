@@ -1,9 +1,40 @@
 package model
 
+import (
+	"fmt"
+	"time"
+)
+
 type Email string
 
 type UserParams struct {
 	Name                 string
 	DefaultCurrency      CurrencyID
 	HiddenDefaultAccount AccountID
+	Timezone             string
+}
+
+// LoadTimezone returns the location of an IANA timezone name, such as
+// "America/Montreal" or "UTC".
+func LoadTimezone(name string) (*time.Location, error) {
+	// The database resolves zone names on its own, and knows nothing of the
+	// server's local zone.
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+
+	return location, nil
+}
+
+// DateAtNoon places a calendar date at noon in the location. Transactions
+// that only have a date, such as the occurrences of a recurring transaction,
+// are stored that way so they stay on the same day when shown in a zone a
+// few hours away.
+func DateAtNoon(date time.Time, location *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, location)
 }
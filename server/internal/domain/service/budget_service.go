@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"github.com/google/uuid"
+)
+
+type budgetRepository interface {
+	GetAllBudgets(ctx context.Context, userId uuid.UUID) ([]model.Budget, error)
+	SetBudget(ctx context.Context, userId uuid.UUID, budget model.Budget) (model.BudgetID, error)
+	DeleteBudget(ctx context.Context, userId uuid.UUID, id model.BudgetID) error
+	GetAllCategories(ctx context.Context, userId uuid.UUID) ([]model.Category, error)
+	GetAllExchangeRate(ctx context.Context, userId uuid.UUID) ([]model.ExchangeRate, error)
+	GetCategorySpending(
+		ctx context.Context,
+		userId uuid.UUID,
+		from, to time.Time,
+		location *time.Location,
+	) ([]model.CategorySpending, error)
+	UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error)
+	GetEnvelopeSettings(ctx context.Context, userId uuid.UUID) (model.Optional[model.EnvelopeSettings], error)
+	SetEnvelopeSettings(ctx context.Context, userId uuid.UUID, settings model.EnvelopeSettings) error
+	DisableEnvelopes(ctx context.Context, userId uuid.UUID) error
+	GetEnvelopes(ctx context.Context, userId uuid.UUID) ([]model.Envelope, error)
+	SetEnvelope(ctx context.Context, userId uuid.UUID, envelope model.Envelope) error
+	RemoveEnvelope(ctx context.Context, userId uuid.UUID, category model.CategoryID) error
+	AssignToEnvelope(ctx context.Context, userId uuid.UUID, assignment model.EnvelopeAssignment) error
+	CreateBudgetTransfer(ctx context.Context, userId uuid.UUID, transfer model.BudgetTransfer) (model.BudgetTransferID, error)
+	DeleteBudgetTransfer(ctx context.Context, userId uuid.UUID, id model.BudgetTransferID) error
+	GetEnvelopeMonth(
+		ctx context.Context,
+		userId uuid.UUID,
+		month time.Time,
+		location *time.Location,
+	) (model.EnvelopeMonth, error)
+}
+
+// BudgetService keeps the amounts planned for each category and measures the
+// spending against them, either as plain budgets or as envelopes.
+type BudgetService struct {
+	budgetRepository budgetRepository
+}
+
+func NewBudgetService(budgetRepository budgetRepository) *BudgetService {
+	return &BudgetService{budgetRepository}
+}
+
+func (b *BudgetService) GetAllBudgets(ctx context.Context, userId uuid.UUID) ([]model.Budget, error) {
+	return b.budgetRepository.GetAllBudgets(ctx, userId)
+}
+
+// SetBudget sets the amount of a category from the period containing
+// budget.StartsOn on.
+func (b *BudgetService) SetBudget(ctx context.Context, userId uuid.UUID, budget model.Budget) (model.BudgetID, error) {
+	if budget.Amount < 0 {
+		return 0, invalid("amount", "cannot be negative")
+	}
+
+	startsOn, err := budget.Period.Start(budget.StartsOn)
+	if err != nil {
+		return 0, invalid("period", err.Error())
+	}
+	budget.StartsOn = startsOn
+
+	return b.budgetRepository.SetBudget(ctx, userId, budget)
+}
+
+func (b *BudgetService) DeleteBudget(ctx context.Context, userId uuid.UUID, id model.BudgetID) error {
+	return b.budgetRepository.DeleteBudget(ctx, userId, id)
+}
+
+// GetBudgetStatus compares the budgets in effect during the period containing
+// date with what was spent on their category subtrees. Periods begin and end
+// at midnight in the user's timezone, and spending is converted with the
+// exchange rate of the day it happened.
+func (b *BudgetService) GetBudgetStatus(
+	ctx context.Context,
+	userId uuid.UUID,
+	period model.BudgetPeriod,
+	date time.Time,
+) (model.PeriodBudgetStatus, error) {
+	start, err := period.Start(date)
+	if err != nil {
+		return model.PeriodBudgetStatus{}, invalid("period", err.Error())
+	}
+	end, err := period.Next(start)
+	if err != nil {
+		return model.PeriodBudgetStatus{}, invalid("period", err.Error())
+	}
+
+	status := model.PeriodBudgetStatus{Start: start, End: end}
+
+	budgets, err := b.budgetRepository.GetAllBudgets(ctx, userId)
+	if err != nil {
+		return model.PeriodBudgetStatus{}, err
+	}
+
+	inEffect := budgetsInEffect(budgets, period, start)
+	if len(inEffect) == 0 {
+		return status, nil
+	}
+
+	location, err := b.budgetRepository.UserLocation(ctx, userId)
+	if err != nil {
+		return model.PeriodBudgetStatus{}, err
+	}
+
+	spending, err := b.budgetRepository.GetCategorySpending(
+		ctx,
+		userId,
+		time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location),
+		time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, location),
+		location,
+	)
+	if err != nil {
+		return model.PeriodBudgetStatus{}, err
+	}
+
+	categories, err := b.budgetRepository.GetAllCategories(ctx, userId)
+	if err != nil {
+		return model.PeriodBudgetStatus{}, err
+	}
+
+	rates, err := b.budgetRepository.GetAllExchangeRate(ctx, userId)
+	if err != nil {
+		return model.PeriodBudgetStatus{}, err
+	}
+	exchangeRates := model.NewExchangeRates(rates)
+
+	children := childCategories(categories)
+	status.Budgets = make([]model.BudgetStatus, len(inEffect))
+	for i, budget := range inEffect {
+		status.Budgets[i] = budgetStatus(budget, categorySubtree(children, budget.Category), spending, exchangeRates)
+	}
+
+	return status, nil
+}
+
+// budgetsInEffect picks, for each category, the latest budget of the period
+// that started on or before start.
+func budgetsInEffect(budgets []model.Budget, period model.BudgetPeriod, start time.Time) []model.Budget {
+	latest := map[model.CategoryID]int{}
+	inEffect := make([]model.Budget, 0)
+	for _, budget := range budgets {
+		if budget.Period != period || budget.StartsOn.After(start) {
+			continue
+		}
+
+		if i, found := latest[budget.Category]; found {
+			if budget.StartsOn.After(inEffect[i].StartsOn) {
+				inEffect[i] = budget
+			}
+			continue
+		}
+
+		latest[budget.Category] = len(inEffect)
+		inEffect = append(inEffect, budget)
+	}
+
+	return inEffect
+}
+
+func budgetStatus(
+	budget model.Budget,
+	subtree map[model.CategoryID]bool,
+	spending []model.CategorySpending,
+	exchangeRates *model.ExchangeRates,
+) model.BudgetStatus {
+	status := model.BudgetStatus{Budget: budget}
+	for _, spent := range spending {
+		category, isSome := spent.Category.Value()
+		if !isSome || !subtree[category] {
+			continue
+		}
+
+		converted, found := exchangeRates.Convert(spent.Amount, spent.Currency, budget.Currency, spent.Day)
+		if !found {
+			status.MissingExchangeRate = true
+			continue
+		}
+		status.Spent += converted
+	}
+
+	status.Remaining = int64(budget.Amount) - status.Spent
+	return status
+}
+
+func childCategories(categories []model.Category) map[model.CategoryID][]model.CategoryID {
+	children := map[model.CategoryID][]model.CategoryID{}
+	for _, category := range categories {
+		if category.ParentId != 0 {
+			children[category.ParentId] = append(children[category.ParentId], category.ID)
+		}
+	}
+
+	return children
+}
+
+// categorySubtree returns the category along with all of its descendants.
+func categorySubtree(children map[model.CategoryID][]model.CategoryID, root model.CategoryID) map[model.CategoryID]bool {
+	subtree := map[model.CategoryID]bool{root: true}
+	pending := []model.CategoryID{root}
+	for len(pending) > 0 {
+		category := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for _, child := range children[category] {
+			if !subtree[child] {
+				subtree[child] = true
+				pending = append(pending, child)
+			}
+		}
+	}
+
+	return subtree
+}
+
+func (b *BudgetService) UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error) {
+	return b.budgetRepository.UserLocation(ctx, userId)
+}
@@ -1,8 +1,13 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"chagnon.dev/budget-server/internal/domain/model"
 	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
@@ -25,8 +30,40 @@ type categoryRepository interface {
 		id model.CategoryID,
 		fields repository.UpdateCategoryFields,
 	) error
+	DeleteCategory(
+		ctx context.Context,
+		userId uuid.UUID,
+		id, replacementId model.CategoryID,
+		deleteChildren bool,
+	) error
+	MergeCategories(ctx context.Context, userId uuid.UUID, sourceId, targetId model.CategoryID) error
+	MoveCategory(
+		ctx context.Context,
+		userId uuid.UUID,
+		id, parentId model.CategoryID,
+		position repository.CategoryPosition,
+	) error
+	ApplyCategoryTemplate(
+		ctx context.Context,
+		userId uuid.UUID,
+		template []model.CategoryTemplateNode,
+	) (model.CategoryTemplateResult, error)
+	GetAllExchangeRate(ctx context.Context, userId uuid.UUID) ([]model.ExchangeRate, error)
+	CheckCurrency(ctx context.Context, userId uuid.UUID, id model.CurrencyID) error
+	GetCategorySpending(
+		ctx context.Context,
+		userId uuid.UUID,
+		from, to time.Time,
+		location *time.Location,
+	) ([]model.CategorySpending, error)
+	UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error)
 }
 
+var ErrCategoryTemplateNotFound = errors.New("category template not found")
+
+// maxCategoryTemplateSize is the most categories a template can hold.
+const maxCategoryTemplateSize = 500
+
 type CategoryService struct {
 	categoryRepository categoryRepository
 }
@@ -114,3 +151,155 @@ func (a *CategoryService) UpdateCategory(
 		fields,
 	)
 }
+
+// DeleteCategory deletes a category. What referred to it, and to its children
+// when they go with it, is filed under replacementId; the root category,
+// which everything else descends from, stays.
+func (a *CategoryService) DeleteCategory(
+	ctx context.Context,
+	userId uuid.UUID,
+	id, replacementId model.CategoryID,
+	deleteChildren bool,
+) error {
+	return a.categoryRepository.DeleteCategory(ctx, userId, id, replacementId, deleteChildren)
+}
+
+func (a *CategoryService) MergeCategories(ctx context.Context, userId uuid.UUID, sourceId, targetId model.CategoryID) error {
+	return a.categoryRepository.MergeCategories(ctx, userId, sourceId, targetId)
+}
+
+// MoveCategory moves a category under parentId, next to one of its new
+// siblings. The parent has to be a category of the user outside of the
+// subtree being moved.
+func (a *CategoryService) MoveCategory(
+	ctx context.Context,
+	userId uuid.UUID,
+	id, parentId model.CategoryID,
+	position repository.CategoryPosition,
+) error {
+	return a.categoryRepository.MoveCategory(ctx, userId, id, parentId, position)
+}
+
+// GetCategoryTemplates returns the built-in templates.
+func (a *CategoryService) GetCategoryTemplates() []model.CategoryTemplate {
+	return builtInCategoryTemplates
+}
+
+func (a *CategoryService) GetCategoryTemplate(id string) (model.CategoryTemplate, error) {
+	for _, template := range builtInCategoryTemplates {
+		if template.ID == id {
+			return template, nil
+		}
+	}
+
+	return model.CategoryTemplate{}, ErrCategoryTemplateNotFound
+}
+
+// ApplyCategoryTemplate merges a template, built-in or exported by a user,
+// into the categories of the user. Categories the user already has by name
+// are reused rather than created twice.
+func (a *CategoryService) ApplyCategoryTemplate(
+	ctx context.Context,
+	userId uuid.UUID,
+	template model.CategoryTemplate,
+) (model.CategoryTemplateResult, error) {
+	size, err := checkCategoryTemplateNodes(template.Categories)
+	if err != nil {
+		return model.CategoryTemplateResult{}, err
+	}
+	if size > maxCategoryTemplateSize {
+		return model.CategoryTemplateResult{}, invalid("categories", fmt.Sprintf("cannot hold more than %d categories", maxCategoryTemplateSize))
+	}
+
+	// Seeds the root category of a new user, which the template goes under.
+	if _, err = a.GetAllCategories(ctx, userId); err != nil {
+		return model.CategoryTemplateResult{}, err
+	}
+
+	return a.categoryRepository.ApplyCategoryTemplate(ctx, userId, template.Categories)
+}
+
+// checkCategoryTemplateNodes checks that every category of a template has a
+// name, and counts them.
+func checkCategoryTemplateNodes(nodes []model.CategoryTemplateNode) (int, error) {
+	size := 0
+	for _, node := range nodes {
+		if strings.TrimSpace(node.Name) == "" {
+			return 0, invalid("categories", "every category needs a name")
+		}
+
+		children, err := checkCategoryTemplateNodes(node.Children)
+		if err != nil {
+			return 0, err
+		}
+		size += 1 + children
+	}
+
+	return size, nil
+}
+
+// ExportCategoryTemplate turns the categories of the user into a template
+// that another user can apply, in the order they are shown in.
+func (a *CategoryService) ExportCategoryTemplate(
+	ctx context.Context,
+	userId uuid.UUID,
+	name, description string,
+) (model.CategoryTemplate, error) {
+	if strings.TrimSpace(name) == "" {
+		return model.CategoryTemplate{}, invalid("name", "cannot be empty")
+	}
+
+	categories, err := a.categoryRepository.GetAllCategories(ctx, userId)
+	if err != nil {
+		return model.CategoryTemplate{}, err
+	}
+
+	slices.SortFunc(categories, func(x, y model.Category) int {
+		return cmp.Or(cmp.Compare(y.Ordering, x.Ordering), cmp.Compare(y.ID, x.ID))
+	})
+
+	children := map[model.CategoryID][]model.Category{}
+	var rootId model.CategoryID
+	for _, category := range categories {
+		if category.ParentId == 0 {
+			rootId = category.ID
+			continue
+		}
+		children[category.ParentId] = append(children[category.ParentId], category)
+	}
+
+	return model.CategoryTemplate{
+		Name:        name,
+		Description: description,
+		Categories:  categoryTemplateNodes(children, rootId, map[model.CategoryID]bool{}),
+	}, nil
+}
+
+func categoryTemplateNodes(
+	children map[model.CategoryID][]model.Category,
+	parentId model.CategoryID,
+	visited map[model.CategoryID]bool,
+) []model.CategoryTemplateNode {
+	nodes := make([]model.CategoryTemplateNode, 0, len(children[parentId]))
+	for _, category := range children[parentId] {
+		if visited[category.ID] {
+			continue
+		}
+		visited[category.ID] = true
+
+		nodes = append(nodes, model.CategoryTemplateNode{
+			Name:           category.Name,
+			IconName:       category.IconName,
+			IconColor:      category.IconColor,
+			IconBackground: category.IconBackground,
+			FixedCost:      category.FixedCost,
+			Children:       categoryTemplateNodes(children, category.ID, visited),
+		})
+	}
+
+	return nodes
+}
+
+func (a *CategoryService) UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error) {
+	return a.categoryRepository.UserLocation(ctx, userId)
+}
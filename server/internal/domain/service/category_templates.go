@@ -0,0 +1,177 @@
+package service
+
+import "chagnon.dev/budget-server/internal/domain/model"
+
+// The icons are names from the react-icons libraries the front-end loads, and
+// each group of categories shares the colors of its parent.
+type categoryColors struct {
+	icon, background string
+}
+
+var (
+	housingColors        = categoryColors{"rgb(62, 39, 35)", "rgb(255, 204, 128)"}
+	foodColors           = categoryColors{"rgb(27, 94, 32)", "rgb(200, 230, 201)"}
+	transportColors      = categoryColors{"rgb(13, 71, 161)", "rgb(187, 222, 251)"}
+	healthColors         = categoryColors{"rgb(183, 28, 28)", "rgb(255, 205, 210)"}
+	leisureColors        = categoryColors{"rgb(74, 20, 140)", "rgb(225, 190, 231)"}
+	shoppingColors       = categoryColors{"rgb(136, 14, 79)", "rgb(248, 187, 208)"}
+	incomeColors         = categoryColors{"rgb(0, 77, 64)", "rgb(178, 223, 219)"}
+	financeColors        = categoryColors{"rgb(38, 50, 56)", "rgb(207, 216, 220)"}
+	familyColors         = categoryColors{"rgb(230, 81, 0)", "rgb(255, 224, 178)"}
+	businessColors       = categoryColors{"rgb(26, 35, 126)", "rgb(197, 202, 233)"}
+	administrationColors = categoryColors{"rgb(66, 66, 66)", "rgb(238, 238, 238)"}
+)
+
+func templateCategory(
+	name, iconName string,
+	colors categoryColors,
+	fixedCost bool,
+	children ...model.CategoryTemplateNode,
+) model.CategoryTemplateNode {
+	return model.CategoryTemplateNode{
+		Name:           name,
+		IconName:       iconName,
+		IconColor:      colors.icon,
+		IconBackground: colors.background,
+		FixedCost:      fixedCost,
+		Children:       children,
+	}
+}
+
+var builtInCategoryTemplates = []model.CategoryTemplate{
+	{
+		ID:          "personal",
+		Name:        "Personal",
+		Description: "Everyday spending and income for one person.",
+		Categories: []model.CategoryTemplateNode{
+			templateCategory("Housing", "MdHome", housingColors, true,
+				templateCategory("Rent", "MdKey", housingColors, true),
+				templateCategory("Utilities", "MdElectricBolt", housingColors, true),
+				templateCategory("Internet", "MdWifi", housingColors, true),
+				templateCategory("Phone", "MdPhoneAndroid", housingColors, true),
+			),
+			templateCategory("Food", "MdRestaurant", foodColors, false,
+				templateCategory("Groceries", "MdLocalGroceryStore", foodColors, false),
+				templateCategory("Restaurants", "MdRestaurantMenu", foodColors, false),
+				templateCategory("Coffee", "MdLocalCafe", foodColors, false),
+			),
+			templateCategory("Transport", "MdDirectionsCar", transportColors, false,
+				templateCategory("Public transport", "MdDirectionsBus", transportColors, false),
+				templateCategory("Fuel", "MdLocalGasStation", transportColors, false),
+				templateCategory("Taxi", "MdLocalTaxi", transportColors, false),
+			),
+			templateCategory("Health", "MdHealthAndSafety", healthColors, false,
+				templateCategory("Pharmacy", "MdLocalPharmacy", healthColors, false),
+				templateCategory("Doctor", "MdLocalHospital", healthColors, false),
+				templateCategory("Sport", "MdFitnessCenter", healthColors, false),
+			),
+			templateCategory("Leisure", "MdCelebration", leisureColors, false,
+				templateCategory("Subscriptions", "MdSubscriptions", leisureColors, true),
+				templateCategory("Outings", "MdMovie", leisureColors, false),
+				templateCategory("Travel", "MdFlight", leisureColors, false),
+				templateCategory("Games", "MdSportsEsports", leisureColors, false),
+			),
+			templateCategory("Shopping", "MdShoppingBag", shoppingColors, false,
+				templateCategory("Clothes", "MdCheckroom", shoppingColors, false),
+				templateCategory("Electronics", "MdDevices", shoppingColors, false),
+				templateCategory("Gifts", "MdCardGiftcard", shoppingColors, false),
+			),
+			templateCategory("Income", "MdAttachMoney", incomeColors, false,
+				templateCategory("Salary", "MdPayments", incomeColors, false),
+				templateCategory("Refunds", "MdReplay", incomeColors, false),
+			),
+			templateCategory("Savings", "MdSavings", financeColors, false),
+		},
+	},
+	{
+		ID:          "household",
+		Name:        "Household",
+		Description: "Shared costs of a home and the people living in it.",
+		Categories: []model.CategoryTemplateNode{
+			templateCategory("Housing", "MdHome", housingColors, true,
+				templateCategory("Rent", "MdKey", housingColors, true),
+				templateCategory("Mortgage", "MdAccountBalance", housingColors, true),
+				templateCategory("Utilities", "MdElectricBolt", housingColors, true),
+				templateCategory("Internet", "MdWifi", housingColors, true),
+				templateCategory("Home insurance", "MdSecurity", housingColors, true),
+				templateCategory("Repairs", "MdHandyman", housingColors, false),
+				templateCategory("Furniture", "MdChair", housingColors, false),
+				templateCategory("Cleaning", "MdCleaningServices", housingColors, false),
+			),
+			templateCategory("Food", "MdRestaurant", foodColors, false,
+				templateCategory("Groceries", "MdLocalGroceryStore", foodColors, false),
+				templateCategory("Restaurants", "MdRestaurantMenu", foodColors, false),
+				templateCategory("Takeout", "MdDeliveryDining", foodColors, false),
+			),
+			templateCategory("Family", "MdFamilyRestroom", familyColors, false,
+				templateCategory("Childcare", "MdChildCare", familyColors, true),
+				templateCategory("School", "MdSchool", familyColors, false),
+				templateCategory("Pets", "MdPets", familyColors, false),
+				templateCategory("Allowances", "MdToys", familyColors, true),
+			),
+			templateCategory("Transport", "MdDirectionsCar", transportColors, false,
+				templateCategory("Car loan", "MdCarRental", transportColors, true),
+				templateCategory("Car insurance", "MdCarCrash", transportColors, true),
+				templateCategory("Fuel", "MdLocalGasStation", transportColors, false),
+				templateCategory("Maintenance", "MdCarRepair", transportColors, false),
+				templateCategory("Public transport", "MdDirectionsBus", transportColors, false),
+			),
+			templateCategory("Health", "MdHealthAndSafety", healthColors, false,
+				templateCategory("Health insurance", "MdMedicalServices", healthColors, true),
+				templateCategory("Pharmacy", "MdLocalPharmacy", healthColors, false),
+				templateCategory("Doctor", "MdLocalHospital", healthColors, false),
+			),
+			templateCategory("Leisure", "MdCelebration", leisureColors, false,
+				templateCategory("Subscriptions", "MdSubscriptions", leisureColors, true),
+				templateCategory("Outings", "MdMovie", leisureColors, false),
+				templateCategory("Holidays", "MdBeachAccess", leisureColors, false),
+			),
+			templateCategory("Income", "MdAttachMoney", incomeColors, false,
+				templateCategory("Salaries", "MdPayments", incomeColors, false),
+				templateCategory("Benefits", "MdVolunteerActivism", incomeColors, false),
+			),
+			templateCategory("Savings", "MdSavings", financeColors, false),
+		},
+	},
+	{
+		ID:          "freelancer",
+		Name:        "Freelancer",
+		Description: "Business income and expenses alongside personal ones.",
+		Categories: []model.CategoryTemplateNode{
+			templateCategory("Business income", "MdWork", incomeColors, false,
+				templateCategory("Client payments", "MdRequestQuote", incomeColors, false),
+				templateCategory("Royalties", "MdCopyright", incomeColors, false),
+			),
+			templateCategory("Business expenses", "MdBusinessCenter", businessColors, false,
+				templateCategory("Software", "MdCloud", businessColors, true),
+				templateCategory("Equipment", "MdComputer", businessColors, false),
+				templateCategory("Coworking", "MdMeetingRoom", businessColors, true),
+				templateCategory("Marketing", "MdCampaign", businessColors, false),
+				templateCategory("Business travel", "MdFlightTakeoff", businessColors, false),
+				templateCategory("Training", "MdSchool", businessColors, false),
+			),
+			templateCategory("Administration", "MdReceiptLong", administrationColors, false,
+				templateCategory("Taxes", "MdAccountBalance", administrationColors, false),
+				templateCategory("Social contributions", "MdGroups", administrationColors, true),
+				templateCategory("Accounting", "MdCalculate", administrationColors, true),
+				templateCategory("Bank fees", "MdCreditCard", administrationColors, true),
+				templateCategory("Professional insurance", "MdSecurity", administrationColors, true),
+			),
+			templateCategory("Housing", "MdHome", housingColors, true,
+				templateCategory("Rent", "MdKey", housingColors, true),
+				templateCategory("Utilities", "MdElectricBolt", housingColors, true),
+				templateCategory("Internet", "MdWifi", housingColors, true),
+			),
+			templateCategory("Food", "MdRestaurant", foodColors, false,
+				templateCategory("Groceries", "MdLocalGroceryStore", foodColors, false),
+				templateCategory("Restaurants", "MdRestaurantMenu", foodColors, false),
+			),
+			templateCategory("Health", "MdHealthAndSafety", healthColors, false),
+			templateCategory("Leisure", "MdCelebration", leisureColors, false),
+			templateCategory("Savings", "MdSavings", financeColors, false,
+				templateCategory("Tax reserve", "MdLock", financeColors, false),
+				templateCategory("Retirement", "MdElderly", financeColors, false),
+			),
+		},
+	},
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"github.com/google/uuid"
+)
+
+// GetCategoryTotals adds up what came in and went out under each category
+// from the day from through the day through, in the currency, and month by
+// month when monthly is set. Days begin and end at midnight in the user's
+// timezone, and amounts are converted with the exchange rate of their day.
+func (a *CategoryService) GetCategoryTotals(
+	ctx context.Context,
+	userId uuid.UUID,
+	from, through time.Time,
+	currency model.CurrencyID,
+	monthly bool,
+) (model.CategoryTotalsReport, error) {
+	if through.Before(from) {
+		return model.CategoryTotalsReport{}, invalid("to", "cannot be before from")
+	}
+	end := through.AddDate(0, 0, 1)
+
+	if err := a.categoryRepository.CheckCurrency(ctx, userId, currency); err != nil {
+		return model.CategoryTotalsReport{}, err
+	}
+
+	location, err := a.categoryRepository.UserLocation(ctx, userId)
+	if err != nil {
+		return model.CategoryTotalsReport{}, err
+	}
+
+	spending, err := a.categoryRepository.GetCategorySpending(
+		ctx,
+		userId,
+		time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, location),
+		time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, location),
+		location,
+	)
+	if err != nil {
+		return model.CategoryTotalsReport{}, err
+	}
+
+	categories, err := a.categoryRepository.GetAllCategories(ctx, userId)
+	if err != nil {
+		return model.CategoryTotalsReport{}, err
+	}
+
+	rates, err := a.categoryRepository.GetAllExchangeRate(ctx, userId)
+	if err != nil {
+		return model.CategoryTotalsReport{}, err
+	}
+
+	totals := categoryTotaler{
+		categories:    categories,
+		children:      childCategories(categories),
+		currency:      currency,
+		exchangeRates: model.NewExchangeRates(rates),
+	}
+
+	report := model.CategoryTotalsReport{
+		Currency: currency,
+		Total:    totals.sum(from, end, spending),
+	}
+	if !monthly {
+		return report, nil
+	}
+
+	byMonth := map[time.Time][]model.CategorySpending{}
+	for _, spent := range spending {
+		month := model.MonthStart(spent.Day)
+		byMonth[month] = append(byMonth[month], spent)
+	}
+
+	for month := model.MonthStart(from); month.Before(end); month = month.AddDate(0, 1, 0) {
+		start, stop := month, month.AddDate(0, 1, 0)
+		if start.Before(from) {
+			start = from
+		}
+		if stop.After(end) {
+			stop = end
+		}
+
+		report.Months = append(report.Months, totals.sum(start, stop, byMonth[month]))
+	}
+
+	return report, nil
+}
+
+type categoryTotaler struct {
+	categories    []model.Category
+	children      map[model.CategoryID][]model.CategoryID
+	currency      model.CurrencyID
+	exchangeRates *model.ExchangeRates
+}
+
+func (t categoryTotaler) sum(start, end time.Time, spending []model.CategorySpending) model.CategoryTotals {
+	totals := model.CategoryTotals{Start: start, End: end}
+
+	net := map[model.CategoryID]int64{}
+	var uncategorized int64
+	for _, spent := range spending {
+		converted, found := t.exchangeRates.Convert(spent.Amount, spent.Currency, t.currency, spent.Day)
+		if !found {
+			totals.MissingExchangeRate = true
+			continue
+		}
+
+		if category, isSome := spent.Category.Value(); isSome {
+			net[category] -= converted
+		} else {
+			uncategorized -= converted
+		}
+	}
+	totals.Uncategorized = model.NetCategoryAmounts(uncategorized, false)
+
+	own := map[model.CategoryID]model.CategoryAmounts{}
+	for _, category := range t.categories {
+		own[category.ID] = model.NetCategoryAmounts(net[category.ID], category.FixedCost)
+	}
+
+	subtrees := map[model.CategoryID]model.CategoryAmounts{}
+	var subtree func(category model.CategoryID) model.CategoryAmounts
+	subtree = func(category model.CategoryID) model.CategoryAmounts {
+		if amounts, done := subtrees[category]; done {
+			return amounts
+		}
+
+		// Marks the category first, so that a cycle ends here.
+		subtrees[category] = model.CategoryAmounts{}
+		amounts := own[category]
+		for _, child := range t.children[category] {
+			amounts = amounts.Add(subtree(child))
+		}
+		subtrees[category] = amounts
+
+		return amounts
+	}
+
+	for _, category := range t.categories {
+		amounts := subtree(category.ID)
+		if amounts.IsZero() {
+			continue
+		}
+
+		totals.Categories = append(totals.Categories, model.CategoryTotal{
+			Category: category.ID,
+			Own:      own[category.ID],
+			Subtree:  amounts,
+		})
+	}
+
+	return totals
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"github.com/google/uuid"
+)
+
+func (b *BudgetService) GetEnvelopeSettings(ctx context.Context, userId uuid.UUID) (model.Optional[model.EnvelopeSettings], error) {
+	return b.budgetRepository.GetEnvelopeSettings(ctx, userId)
+}
+
+// SetEnvelopeSettings turns on envelope budgeting from the month containing
+// settings.StartsOn.
+func (b *BudgetService) SetEnvelopeSettings(ctx context.Context, userId uuid.UUID, settings model.EnvelopeSettings) error {
+	settings.StartsOn = model.MonthStart(settings.StartsOn)
+	return b.budgetRepository.SetEnvelopeSettings(ctx, userId, settings)
+}
+
+func (b *BudgetService) DisableEnvelopes(ctx context.Context, userId uuid.UUID) error {
+	return b.budgetRepository.DisableEnvelopes(ctx, userId)
+}
+
+func (b *BudgetService) GetEnvelopes(ctx context.Context, userId uuid.UUID) ([]model.Envelope, error) {
+	return b.budgetRepository.GetEnvelopes(ctx, userId)
+}
+
+func (b *BudgetService) SetEnvelope(ctx context.Context, userId uuid.UUID, envelope model.Envelope) error {
+	return b.budgetRepository.SetEnvelope(ctx, userId, envelope)
+}
+
+func (b *BudgetService) RemoveEnvelope(ctx context.Context, userId uuid.UUID, category model.CategoryID) error {
+	return b.budgetRepository.RemoveEnvelope(ctx, userId, category)
+}
+
+// AssignToEnvelope sets what is given to an envelope in the month containing
+// assignment.Month. A negative amount gives money back to the pool available
+// to budget.
+func (b *BudgetService) AssignToEnvelope(ctx context.Context, userId uuid.UUID, assignment model.EnvelopeAssignment) error {
+	assignment.Month = model.MonthStart(assignment.Month)
+	return b.budgetRepository.AssignToEnvelope(ctx, userId, assignment)
+}
+
+// TransferBudget moves money from one envelope to another in the month
+// containing transfer.Month.
+func (b *BudgetService) TransferBudget(
+	ctx context.Context,
+	userId uuid.UUID,
+	transfer model.BudgetTransfer,
+) (model.BudgetTransferID, error) {
+	if transfer.Amount <= 0 {
+		return 0, invalid("amount", "must be positive")
+	}
+	if transfer.From == transfer.To {
+		return 0, invalid("to_category_id", "must differ from from_category_id")
+	}
+
+	transfer.Month = model.MonthStart(transfer.Month)
+	return b.budgetRepository.CreateBudgetTransfer(ctx, userId, transfer)
+}
+
+func (b *BudgetService) DeleteBudgetTransfer(ctx context.Context, userId uuid.UUID, id model.BudgetTransferID) error {
+	return b.budgetRepository.DeleteBudgetTransfer(ctx, userId, id)
+}
+
+// GetEnvelopeMonth returns the envelopes at the end of the month containing
+// date. Months begin and end at midnight in the user's timezone, and activity
+// is converted with the exchange rate of the day it happened.
+func (b *BudgetService) GetEnvelopeMonth(ctx context.Context, userId uuid.UUID, date time.Time) (model.EnvelopeMonth, error) {
+	location, err := b.budgetRepository.UserLocation(ctx, userId)
+	if err != nil {
+		return model.EnvelopeMonth{}, err
+	}
+
+	return b.budgetRepository.GetEnvelopeMonth(ctx, userId, model.MonthStart(date), location)
+}
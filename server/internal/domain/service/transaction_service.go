@@ -0,0 +1,713 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"github.com/google/uuid"
+)
+
+// ValidationError reports a transaction breaking one of the rules every
+// transaction has to follow.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+func invalid(field, reason string) error {
+	return &ValidationError{Field: field, Reason: reason}
+}
+
+type transactionRepository interface {
+	GetAllTransactions(ctx context.Context, userId uuid.UUID) ([]model.Transaction, error)
+	ListTransactions(
+		ctx context.Context,
+		userId uuid.UUID,
+		filter repository.TransactionFilter,
+		after model.Optional[model.TransactionCursor],
+		pageSize int,
+	) ([]model.Transaction, model.Optional[model.TransactionCursor], error)
+	SearchTransactions(
+		ctx context.Context,
+		userId uuid.UUID,
+		query string,
+		limit, offset int,
+	) ([]model.TransactionSearchResult, error)
+	CreateTransaction(
+		ctx context.Context,
+		userId uuid.UUID,
+		userEmail string,
+		ownerEmail string,
+		amount, receiverAmount int,
+		currencyId, receiverCurrencyId int,
+		senderAccountId, receiverAccountId model.Optional[int],
+		categoryId model.Optional[int],
+		date time.Time,
+		note string,
+		financialIncomeData model.Optional[model.FinancialIncomeData],
+		transactionGroupData model.Optional[model.GroupedTransactionData],
+		lineItems []model.TransactionLineItem,
+		tags []model.TagID,
+		payeeId model.Optional[model.PayeeID],
+		merchant string,
+		status model.TransactionStatus,
+		allowDuplicates bool,
+	) (model.TransactionID, error)
+	UpdateTransaction(
+		ctx context.Context,
+		userId uuid.UUID,
+		id model.TransactionID,
+		fields repository.UpdateTransactionFields,
+	) error
+	DeleteTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error
+	GetTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) (model.Transaction, error)
+	ListDeletedTransactions(ctx context.Context, userId uuid.UUID) ([]model.DeletedTransaction, error)
+	RestoreTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error
+	PurgeTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error
+	GetTransactionHistory(ctx context.Context, userId uuid.UUID, id model.TransactionID) ([]model.TransactionHistoryEntry, error)
+	FindDuplicateTransactions(ctx context.Context, userId uuid.UUID) ([]model.DuplicatePair, error)
+	SetTransactionAttributionState(
+		ctx context.Context,
+		userId uuid.UUID,
+		id model.TransactionID,
+		state model.AttributionState,
+		disputeReason string,
+	) error
+	BatchMutateTransactions(
+		ctx context.Context,
+		userId uuid.UUID,
+		userEmail string,
+		mutations []repository.TransactionMutation,
+	) ([]model.TransactionID, error)
+	CheckTransactionReferences(
+		ctx context.Context,
+		userId uuid.UUID,
+		references repository.TransactionReferences,
+	) (repository.TransactionReferencesFound, error)
+	GetAllRecurringTransactions(ctx context.Context, userId uuid.UUID) ([]model.RecurringTransaction, error)
+	GetRecurringTransaction(
+		ctx context.Context,
+		userId uuid.UUID,
+		id model.RecurringTransactionID,
+	) (model.RecurringTransaction, error)
+	CreateRecurringTransaction(
+		ctx context.Context,
+		userId uuid.UUID,
+		amount, receiverAmount int,
+		currencyId, receiverCurrencyId int,
+		senderAccountId, receiverAccountId model.Optional[int],
+		categoryId model.Optional[int],
+		note string,
+		schedule string,
+		startsOn time.Time,
+		endsOn model.Optional[time.Time],
+	) (model.RecurringTransactionID, error)
+	UpdateRecurringTransaction(
+		ctx context.Context,
+		userId uuid.UUID,
+		id model.RecurringTransactionID,
+		fields repository.UpdateRecurringTransactionFields,
+	) error
+	SetRecurringTransactionPaused(ctx context.Context, userId uuid.UUID, id model.RecurringTransactionID, paused bool) error
+	DeleteRecurringTransaction(ctx context.Context, userId uuid.UUID, id model.RecurringTransactionID) error
+	UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error)
+}
+
+// TransactionService checks the invariants of transactions, and of the
+// recurring transactions they are made from, before writing them.
+type TransactionService struct {
+	transactionRepository transactionRepository
+}
+
+func NewTransactionService(transactionRepository transactionRepository) *TransactionService {
+	return &TransactionService{transactionRepository}
+}
+
+// transactionInvariants holds the fields of a transaction the invariants are
+// about.
+type transactionInvariants struct {
+	amount, receiverAmount     int
+	currency, receiverCurrency int
+	sender, receiver           model.Optional[int]
+	splitOverride              model.Optional[model.SplitOverride]
+}
+
+func (t transactionInvariants) checkAccounts() error {
+	if t.sender.IsNone() && t.receiver.IsNone() {
+		return invalid("accounts", "a transaction needs a sender or a receiver account")
+	}
+
+	return nil
+}
+
+func (t transactionInvariants) checkAmounts() error {
+	if t.currency == t.receiverCurrency && t.amount != t.receiverAmount {
+		return invalid("receiver_amount", "has to equal the amount when both currencies are the same")
+	}
+
+	return nil
+}
+
+func (t transactionInvariants) checkSplit() error {
+	splitOverride, isSome := t.splitOverride.Value()
+	if !isSome {
+		return nil
+	}
+
+	total := 0
+	for _, member := range splitOverride.Members {
+		if value, isSome := member.SplitValue.Value(); isSome {
+			total += value
+		}
+	}
+
+	switch splitOverride.SplitTypeOverride {
+	case model.SplitTypeOverrideExactAmount:
+		if total != t.amount {
+			return invalid("split_override", fmt.Sprintf("exact amounts add up to %d instead of the amount, %d", total, t.amount))
+		}
+	case model.SplitTypeOverridePercentage:
+		if total != 100 {
+			return invalid("split_override", fmt.Sprintf("percentages add up to %d instead of 100", total))
+		}
+	}
+
+	return nil
+}
+
+// checkReferences checks that the accounts, category and currencies a
+// transaction refers to belong to the user.
+func (s *TransactionService) checkReferences(
+	ctx context.Context,
+	userId uuid.UUID,
+	references repository.TransactionReferences,
+) error {
+	found, err := s.transactionRepository.CheckTransactionReferences(ctx, userId, references)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !found.Sender:
+		return invalid("sender", "account not found")
+	case !found.Receiver:
+		return invalid("receiver", "account not found")
+	case !found.Category:
+		return invalid("category", "category not found")
+	case !found.Currencies:
+		return invalid("currency", "currency not found")
+	}
+
+	return nil
+}
+
+func (s *TransactionService) CreateTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	userEmail string,
+	ownerEmail string,
+	amount, receiverAmount int,
+	currencyId, receiverCurrencyId int,
+	senderAccountId, receiverAccountId model.Optional[int],
+	categoryId model.Optional[int],
+	date time.Time,
+	note string,
+	financialIncomeData model.Optional[model.FinancialIncomeData],
+	transactionGroupData model.Optional[model.GroupedTransactionData],
+	lineItems []model.TransactionLineItem,
+	tags []model.TagID,
+	payeeId model.Optional[model.PayeeID],
+	merchant string,
+	status model.TransactionStatus,
+	allowDuplicates bool,
+) (model.TransactionID, error) {
+	err := s.validateNewTransaction(ctx, userId, userEmail, repository.NewTransactionFields{
+		OwnerEmail:           ownerEmail,
+		Amount:               amount,
+		ReceiverAmount:       receiverAmount,
+		CurrencyId:           currencyId,
+		ReceiverCurrencyId:   receiverCurrencyId,
+		SenderAccountId:      senderAccountId,
+		ReceiverAccountId:    receiverAccountId,
+		CategoryId:           categoryId,
+		FinancialIncomeData:  financialIncomeData,
+		TransactionGroupData: transactionGroupData,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return s.transactionRepository.CreateTransaction(
+		ctx,
+		userId,
+		userEmail,
+		ownerEmail,
+		amount,
+		receiverAmount,
+		currencyId,
+		receiverCurrencyId,
+		senderAccountId,
+		receiverAccountId,
+		categoryId,
+		date,
+		note,
+		financialIncomeData,
+		transactionGroupData,
+		lineItems,
+		tags,
+		payeeId,
+		merchant,
+		status,
+		allowDuplicates,
+	)
+}
+
+func (s *TransactionService) validateNewTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	userEmail string,
+	fields repository.NewTransactionFields,
+) error {
+	transaction := transactionInvariants{
+		amount:           fields.Amount,
+		receiverAmount:   fields.ReceiverAmount,
+		currency:         fields.CurrencyId,
+		receiverCurrency: fields.ReceiverCurrencyId,
+		sender:           fields.SenderAccountId,
+		receiver:         fields.ReceiverAccountId,
+	}
+	if groupData, isSome := fields.TransactionGroupData.Value(); isSome {
+		transaction.splitOverride = groupData.SplitOverride
+	}
+
+	// A transaction recorded for another member is paid from the owner's
+	// default account, which the repository fills in.
+	if userEmail == fields.OwnerEmail {
+		if err := transaction.checkAccounts(); err != nil {
+			return err
+		}
+	}
+
+	if err := transaction.checkAmounts(); err != nil {
+		return err
+	}
+
+	if err := transaction.checkSplit(); err != nil {
+		return err
+	}
+
+	currencies := []model.CurrencyID{model.CurrencyID(fields.CurrencyId), model.CurrencyID(fields.ReceiverCurrencyId)}
+	if financialIncome, isSome := fields.FinancialIncomeData.Value(); isSome {
+		currencies = append(currencies, financialIncome.RelatedCurrency)
+	}
+
+	return s.checkReferences(ctx, userId, repository.TransactionReferences{
+		Sender:     accountIdToModel(fields.SenderAccountId),
+		Receiver:   accountIdToModel(fields.ReceiverAccountId),
+		Category:   categoryIdToModel(fields.CategoryId),
+		Currencies: currencies,
+	})
+}
+
+func (s *TransactionService) UpdateTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.TransactionID,
+	fields repository.UpdateTransactionFields,
+) error {
+	if err := s.validateTransactionUpdate(ctx, userId, id, fields); err != nil {
+		return err
+	}
+
+	return s.transactionRepository.UpdateTransaction(ctx, userId, id, fields)
+}
+
+// validateTransactionUpdate checks the invariants touched by an update
+// against the transaction as it would be after the update. Invariants the
+// update has nothing to do with are left alone, so that older transactions
+// breaking them can still be edited.
+func (s *TransactionService) validateTransactionUpdate(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.TransactionID,
+	fields repository.UpdateTransactionFields,
+) error {
+	accountsChanged := fields.SenderAccountId.IsSome() || fields.ReceiverAccountId.IsSome()
+	amountsChanged := fields.Amount.IsSome() || fields.ReceiverAmount.IsSome() ||
+		fields.CurrencyId.IsSome() || fields.ReceiverCurrencyId.IsSome()
+	splitChanged := fields.Amount.IsSome() || fields.UpdateTransactionGroupAdditionalData.IsSome()
+
+	if accountsChanged || amountsChanged || splitChanged {
+		current, err := s.transactionRepository.GetTransaction(ctx, userId, id)
+		if err != nil {
+			return err
+		}
+
+		transaction := updatedInvariants(current, fields)
+
+		if accountsChanged {
+			if err = transaction.checkAccounts(); err != nil {
+				return err
+			}
+		}
+
+		if amountsChanged {
+			if err = transaction.checkAmounts(); err != nil {
+				return err
+			}
+		}
+
+		if splitChanged {
+			if err = transaction.checkSplit(); err != nil {
+				return err
+			}
+		}
+	}
+
+	references := repository.TransactionReferences{}
+	if value, isSome := fields.SenderAccountId.Value(); isSome {
+		references.Sender = accountIdToModel(value)
+	}
+	if value, isSome := fields.ReceiverAccountId.Value(); isSome {
+		references.Receiver = accountIdToModel(value)
+	}
+	if value, isSome := fields.CategoryId.Value(); isSome {
+		references.Category = categoryIdToModel(value)
+	}
+	if value, isSome := fields.CurrencyId.Value(); isSome {
+		references.Currencies = append(references.Currencies, model.CurrencyID(value))
+	}
+	if value, isSome := fields.ReceiverCurrencyId.Value(); isSome {
+		references.Currencies = append(references.Currencies, model.CurrencyID(value))
+	}
+	if financialIncome, isSome := fields.UpdateFinancialIncomeAdditionalData.Value(); isSome {
+		if value, isSome := financialIncome.Value(); isSome {
+			if relatedCurrency, isSome := value.RelatedCurrencyId.Value(); isSome && relatedCurrency != 0 {
+				references.Currencies = append(references.Currencies, model.CurrencyID(relatedCurrency))
+			}
+		}
+	}
+
+	return s.checkReferences(ctx, userId, references)
+}
+
+// updatedInvariants applies an update to the invariant fields of a
+// transaction.
+func updatedInvariants(current model.Transaction, fields repository.UpdateTransactionFields) transactionInvariants {
+	transaction := transactionInvariants{
+		amount:           fields.Amount.ValueOr(current.Amount),
+		receiverAmount:   fields.ReceiverAmount.ValueOr(current.ReceiverAmount),
+		currency:         fields.CurrencyId.ValueOr(int(current.Currency)),
+		receiverCurrency: fields.ReceiverCurrencyId.ValueOr(int(current.ReceiverCurrency)),
+		sender:           fields.SenderAccountId.ValueOr(accountIdFromModel(current.Sender)),
+		receiver:         fields.ReceiverAccountId.ValueOr(accountIdFromModel(current.Receiver)),
+	}
+
+	if groupData, isSome := current.GroupedTransactionData.Value(); isSome {
+		transaction.splitOverride = groupData.SplitOverride
+	}
+
+	groupUpdate, isSome := fields.UpdateTransactionGroupAdditionalData.Value()
+	if !isSome {
+		return transaction
+	}
+
+	groupData, isSome := groupUpdate.Value()
+	if !isSome {
+		transaction.splitOverride = model.None[model.SplitOverride]()
+		return transaction
+	}
+
+	splitOverrideUpdate, isSome := groupData.SplitOverride.Value()
+	if !isSome {
+		return transaction
+	}
+
+	splitOverride, isSome := splitOverrideUpdate.Value()
+	if !isSome {
+		transaction.splitOverride = model.None[model.SplitOverride]()
+		return transaction
+	}
+
+	currentSplitOverride, _ := transaction.splitOverride.Value()
+	transaction.splitOverride = model.Some(model.SplitOverride{
+		SplitTypeOverride: splitOverride.SplitTypeOverride.ValueOr(currentSplitOverride.SplitTypeOverride),
+		Members:           splitOverride.Members.ValueOr(currentSplitOverride.Members),
+	})
+
+	return transaction
+}
+
+// BatchMutateTransactions checks every creation and update of the batch
+// before handing it to the repository. Updates are checked against the
+// transactions as they are before the batch.
+func (s *TransactionService) BatchMutateTransactions(
+	ctx context.Context,
+	userId uuid.UUID,
+	userEmail string,
+	mutations []repository.TransactionMutation,
+) ([]model.TransactionID, error) {
+	for i, mutation := range mutations {
+		var err error
+		if fields, isSome := mutation.Create.Value(); isSome {
+			err = s.validateNewTransaction(ctx, userId, userEmail, fields)
+		} else if update, isSome := mutation.Update.Value(); isSome {
+			err = s.validateTransactionUpdate(ctx, userId, update.ID, update.Fields)
+		}
+
+		if err != nil {
+			return nil, &repository.TransactionMutationError{Index: i, Err: err}
+		}
+	}
+
+	return s.transactionRepository.BatchMutateTransactions(ctx, userId, userEmail, mutations)
+}
+
+func (s *TransactionService) GetAllTransactions(ctx context.Context, userId uuid.UUID) ([]model.Transaction, error) {
+	return s.transactionRepository.GetAllTransactions(ctx, userId)
+}
+
+func (s *TransactionService) ListTransactions(
+	ctx context.Context,
+	userId uuid.UUID,
+	filter repository.TransactionFilter,
+	after model.Optional[model.TransactionCursor],
+	pageSize int,
+) ([]model.Transaction, model.Optional[model.TransactionCursor], error) {
+	return s.transactionRepository.ListTransactions(ctx, userId, filter, after, pageSize)
+}
+
+func (s *TransactionService) SearchTransactions(
+	ctx context.Context,
+	userId uuid.UUID,
+	query string,
+	limit, offset int,
+) ([]model.TransactionSearchResult, error) {
+	return s.transactionRepository.SearchTransactions(ctx, userId, query, limit, offset)
+}
+
+func (s *TransactionService) GetTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.TransactionID,
+) (model.Transaction, error) {
+	return s.transactionRepository.GetTransaction(ctx, userId, id)
+}
+
+func (s *TransactionService) DeleteTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error {
+	return s.transactionRepository.DeleteTransaction(ctx, userId, id)
+}
+
+func (s *TransactionService) ListDeletedTransactions(ctx context.Context, userId uuid.UUID) ([]model.DeletedTransaction, error) {
+	return s.transactionRepository.ListDeletedTransactions(ctx, userId)
+}
+
+func (s *TransactionService) RestoreTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error {
+	return s.transactionRepository.RestoreTransaction(ctx, userId, id)
+}
+
+func (s *TransactionService) PurgeTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error {
+	return s.transactionRepository.PurgeTransaction(ctx, userId, id)
+}
+
+func (s *TransactionService) GetTransactionHistory(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.TransactionID,
+) ([]model.TransactionHistoryEntry, error) {
+	return s.transactionRepository.GetTransactionHistory(ctx, userId, id)
+}
+
+func (s *TransactionService) FindDuplicateTransactions(ctx context.Context, userId uuid.UUID) ([]model.DuplicatePair, error) {
+	return s.transactionRepository.FindDuplicateTransactions(ctx, userId)
+}
+
+func (s *TransactionService) SetTransactionAttributionState(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.TransactionID,
+	state model.AttributionState,
+	disputeReason string,
+) error {
+	return s.transactionRepository.SetTransactionAttributionState(ctx, userId, id, state, disputeReason)
+}
+
+func (s *TransactionService) UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error) {
+	return s.transactionRepository.UserLocation(ctx, userId)
+}
+
+func (s *TransactionService) GetAllRecurringTransactions(ctx context.Context, userId uuid.UUID) ([]model.RecurringTransaction, error) {
+	return s.transactionRepository.GetAllRecurringTransactions(ctx, userId)
+}
+
+// CreateRecurringTransaction checks a recurring transaction against the same
+// invariants as the transactions it turns into, since they are written
+// without further checks when they fall due.
+func (s *TransactionService) CreateRecurringTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	amount, receiverAmount int,
+	currencyId, receiverCurrencyId int,
+	senderAccountId, receiverAccountId model.Optional[int],
+	categoryId model.Optional[int],
+	note string,
+	schedule string,
+	startsOn time.Time,
+	endsOn model.Optional[time.Time],
+) (model.RecurringTransactionID, error) {
+	transaction := transactionInvariants{
+		amount:           amount,
+		receiverAmount:   receiverAmount,
+		currency:         currencyId,
+		receiverCurrency: receiverCurrencyId,
+		sender:           senderAccountId,
+		receiver:         receiverAccountId,
+	}
+
+	if err := transaction.checkAccounts(); err != nil {
+		return 0, err
+	}
+
+	if err := transaction.checkAmounts(); err != nil {
+		return 0, err
+	}
+
+	err := s.checkReferences(ctx, userId, repository.TransactionReferences{
+		Sender:     accountIdToModel(senderAccountId),
+		Receiver:   accountIdToModel(receiverAccountId),
+		Category:   categoryIdToModel(categoryId),
+		Currencies: []model.CurrencyID{model.CurrencyID(currencyId), model.CurrencyID(receiverCurrencyId)},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return s.transactionRepository.CreateRecurringTransaction(
+		ctx,
+		userId,
+		amount,
+		receiverAmount,
+		currencyId,
+		receiverCurrencyId,
+		senderAccountId,
+		receiverAccountId,
+		categoryId,
+		note,
+		schedule,
+		startsOn,
+		endsOn,
+	)
+}
+
+// UpdateRecurringTransaction checks the invariants touched by an update
+// against the recurring transaction as it would be after the update, as
+// UpdateTransaction does.
+func (s *TransactionService) UpdateRecurringTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.RecurringTransactionID,
+	fields repository.UpdateRecurringTransactionFields,
+) error {
+	accountsChanged := fields.SenderAccountId.IsSome() || fields.ReceiverAccountId.IsSome()
+	amountsChanged := fields.Amount.IsSome() || fields.ReceiverAmount.IsSome() ||
+		fields.CurrencyId.IsSome() || fields.ReceiverCurrencyId.IsSome()
+
+	if accountsChanged || amountsChanged {
+		current, err := s.transactionRepository.GetRecurringTransaction(ctx, userId, id)
+		if err != nil {
+			return err
+		}
+
+		transaction := transactionInvariants{
+			amount:           fields.Amount.ValueOr(current.Amount),
+			receiverAmount:   fields.ReceiverAmount.ValueOr(current.ReceiverAmount),
+			currency:         fields.CurrencyId.ValueOr(int(current.Currency)),
+			receiverCurrency: fields.ReceiverCurrencyId.ValueOr(int(current.ReceiverCurrency)),
+			sender:           fields.SenderAccountId.ValueOr(accountIdFromModel(current.Sender)),
+			receiver:         fields.ReceiverAccountId.ValueOr(accountIdFromModel(current.Receiver)),
+		}
+
+		if accountsChanged {
+			if err = transaction.checkAccounts(); err != nil {
+				return err
+			}
+		}
+
+		if amountsChanged {
+			if err = transaction.checkAmounts(); err != nil {
+				return err
+			}
+		}
+	}
+
+	references := repository.TransactionReferences{}
+	if value, isSome := fields.SenderAccountId.Value(); isSome {
+		references.Sender = accountIdToModel(value)
+	}
+	if value, isSome := fields.ReceiverAccountId.Value(); isSome {
+		references.Receiver = accountIdToModel(value)
+	}
+	if value, isSome := fields.CategoryId.Value(); isSome {
+		references.Category = categoryIdToModel(value)
+	}
+	if value, isSome := fields.CurrencyId.Value(); isSome {
+		references.Currencies = append(references.Currencies, model.CurrencyID(value))
+	}
+	if value, isSome := fields.ReceiverCurrencyId.Value(); isSome {
+		references.Currencies = append(references.Currencies, model.CurrencyID(value))
+	}
+
+	if err := s.checkReferences(ctx, userId, references); err != nil {
+		return err
+	}
+
+	return s.transactionRepository.UpdateRecurringTransaction(ctx, userId, id, fields)
+}
+
+func (s *TransactionService) SetRecurringTransactionPaused(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.RecurringTransactionID,
+	paused bool,
+) error {
+	return s.transactionRepository.SetRecurringTransactionPaused(ctx, userId, id, paused)
+}
+
+func (s *TransactionService) DeleteRecurringTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.RecurringTransactionID,
+) error {
+	return s.transactionRepository.DeleteRecurringTransaction(ctx, userId, id)
+}
+
+func accountIdToModel(id model.Optional[int]) model.Optional[model.AccountID] {
+	if value, isSome := id.Value(); isSome {
+		return model.Some(model.AccountID(value))
+	}
+
+	return model.None[model.AccountID]()
+}
+
+func accountIdFromModel(id model.Optional[model.AccountID]) model.Optional[int] {
+	if value, isSome := id.Value(); isSome {
+		return model.Some(int(value))
+	}
+
+	return model.None[int]()
+}
+
+func categoryIdToModel(id model.Optional[int]) model.Optional[model.CategoryID] {
+	if value, isSome := id.Value(); isSome {
+		return model.Some(model.CategoryID(value))
+	}
+
+	return model.None[model.CategoryID]()
+}
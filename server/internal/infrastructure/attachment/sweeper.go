@@ -0,0 +1,63 @@
+package attachment
+
+import (
+	"context"
+	"fmt"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/blobstore"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/logging"
+)
+
+// maxSweptPerRun bounds the work of a single run; whatever is left is picked
+// up by the next one.
+const maxSweptPerRun = 1000
+
+type attachmentRepository interface {
+	GetDetachedAttachments(ctx context.Context, maxCount int) ([]repository.DetachedAttachment, error)
+	DeleteDetachedAttachment(ctx context.Context, id model.AttachmentID) error
+}
+
+// Sweeper deletes the bytes of the attachments that were removed or whose
+// transaction was purged, then forgets about them.
+type Sweeper struct {
+	attachmentRepository attachmentRepository
+	store                blobstore.Store
+}
+
+func NewSweeper(attachmentRepository attachmentRepository, store blobstore.Store) *Sweeper {
+	return &Sweeper{
+		attachmentRepository: attachmentRepository,
+		store:                store,
+	}
+}
+
+func (s *Sweeper) NewRunner(ctx context.Context) func() error {
+	return func() error {
+		logger := logging.FromContext(ctx)
+
+		detached, err := s.attachmentRepository.GetDetachedAttachments(ctx, maxSweptPerRun)
+		if err != nil {
+			return fmt.Errorf("getting detached attachments: %s", err)
+		}
+
+		swept := 0
+		for _, attachment := range detached {
+			if err := s.store.Delete(ctx, attachment.StorageKey); err != nil {
+				logger.Error("deleting attachment blob", "error", err, "storageKey", attachment.StorageKey)
+				continue
+			}
+
+			if err := s.attachmentRepository.DeleteDetachedAttachment(ctx, attachment.ID); err != nil {
+				logger.Error("deleting detached attachment", "error", err, "attachmentId", attachment.ID)
+				continue
+			}
+
+			swept++
+		}
+
+		logger.Info("Swept detached attachments", "count", swept, "failed", len(detached)-swept)
+		return nil
+	}
+}
@@ -10,11 +10,12 @@ import (
 )
 
 type Scheduler struct {
+	name     string
 	schedule cron.Schedule
 	job      func() error
 }
 
-func NewScheduler(expr string, job func() error) (*Scheduler, error) {
+func NewScheduler(name, expr string, job func() error) (*Scheduler, error) {
 	parser := cron.NewParser(
 		cron.SecondOptional |
 			cron.Minute |
@@ -28,7 +29,7 @@ func NewScheduler(expr string, job func() error) (*Scheduler, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Scheduler{schedule: schedule, job: job}, nil
+	return &Scheduler{name: name, schedule: schedule, job: job}, nil
 }
 
 func (c *Scheduler) Serve(ctx context.Context) error {
@@ -39,7 +40,7 @@ func (c *Scheduler) Serve(ctx context.Context) error {
 		next := c.schedule.Next(now)
 		timer := time.NewTimer(time.Until(next))
 
-		logger.Info("scheduled next exchange rate auto update", "timerDuration", time.Until(next).String())
+		logger.Info("scheduled next run", "job", c.name, "timerDuration", time.Until(next).String())
 
 		select {
 		case <-ctx.Done():
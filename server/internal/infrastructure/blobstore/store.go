@@ -0,0 +1,18 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps opaque blobs of bytes under keys chosen by the caller. Keys are
+// made of letters, digits, dashes and underscores.
+type Store interface {
+	Put(ctx context.Context, key string, content io.Reader) error
+	// Get fails with ErrNotFound when there is no blob under key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
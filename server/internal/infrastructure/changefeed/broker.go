@@ -0,0 +1,213 @@
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/logging"
+)
+
+// channel must match the one the triggers in 021-change-notifications.sql
+// publish to.
+const channel = "budgeteer_changes"
+
+const (
+	subscriberBufferSize = 64
+	listenerPingInterval = 90 * time.Second
+)
+
+type notification struct {
+	Entity  string   `json:"entity"`
+	Op      string   `json:"op"`
+	ID      int      `json:"id"`
+	UserIds []string `json:"user_ids"`
+	Emails  []string `json:"emails"`
+
+	// Only set for exchange rates.
+	A    int    `json:"a"`
+	B    int    `json:"b"`
+	Date string `json:"date"`
+}
+
+type subscriber struct {
+	userId string
+	email  string
+	events chan model.Change
+}
+
+// Broker relays the change notifications published by the database triggers
+// to the subscribers they concern. It is meant to run under the root
+// supervisor.
+type Broker struct {
+	listener *pq.Listener
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+func NewBroker(listener *pq.Listener) *Broker {
+	return &Broker{
+		listener:    listener,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe registers interest in the changes visible to the given user. The
+// returned channel is closed when the subscriber falls too far behind, in
+// which case the client should reconnect and reload its data. The returned
+// function must be called once the subscription is no longer needed.
+func (b *Broker) Subscribe(userId uuid.UUID, email string) (<-chan model.Change, func()) {
+	sub := &subscriber{
+		userId: userId.String(),
+		email:  email,
+		events: make(chan model.Change, subscriberBufferSize),
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub.events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.drop(sub)
+	}
+}
+
+func (b *Broker) Serve(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+
+	if err := b.listener.Listen(channel); err != nil && err != pq.ErrChannelAlreadyOpen {
+		return fmt.Errorf("listening to %s: %w", channel, err)
+	}
+	defer func() {
+		if err := b.listener.Unlisten(channel); err != nil {
+			logger.Error(fmt.Sprintf("unlistening from %s: %v", channel, err))
+		}
+	}()
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-b.listener.Notify:
+			if n == nil {
+				logger.Warn("change feed connection re-established; asking subscribers to resync")
+				b.broadcast(func(*subscriber) bool { return true }, model.Change{ResyncRequired: true})
+				continue
+			}
+
+			change, recipients, err := decodeNotification(n.Extra)
+			if err != nil {
+				logger.Error("decoding change notification", "error", err, "payload", n.Extra)
+				continue
+			}
+			b.broadcast(recipients, change)
+		case <-ticker.C:
+			go func() {
+				if err := b.listener.Ping(); err != nil {
+					logger.Warn("pinging change feed connection", "error", err)
+				}
+			}()
+		}
+	}
+}
+
+func (b *Broker) broadcast(recipients func(*subscriber) bool, change model.Change) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		if !recipients(sub) {
+			continue
+		}
+
+		select {
+		case sub.events <- change:
+		default:
+			// Never block the feed on a slow client: drop it so it reconnects
+			// and reloads instead of silently missing changes.
+			b.drop(sub)
+		}
+	}
+}
+
+// drop must be called with b.mu held.
+func (b *Broker) drop(sub *subscriber) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
+
+func decodeNotification(payload string) (model.Change, func(*subscriber) bool, error) {
+	var n notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return model.Change{}, nil, fmt.Errorf("unmarshalling payload: %w", err)
+	}
+
+	change := model.Change{ID: n.ID}
+
+	switch n.Entity {
+	case "transaction":
+		change.Entity = model.ChangedEntityTransaction
+	case "account":
+		change.Entity = model.ChangedEntityAccount
+	case "category":
+		change.Entity = model.ChangedEntityCategory
+	case "currency":
+		change.Entity = model.ChangedEntityCurrency
+	case "exchange_rate":
+		change.Entity = model.ChangedEntityExchangeRate
+		date, err := time.Parse("2006-01-02", n.Date)
+		if err != nil {
+			return model.Change{}, nil, fmt.Errorf("parsing exchange rate date: %w", err)
+		}
+		change.ExchangeRate = model.Some(model.ExchangeRateKey{
+			CurrencyA: model.CurrencyID(n.A),
+			CurrencyB: model.CurrencyID(n.B),
+			Date:      date,
+		})
+	case "transaction_group":
+		change.Entity = model.ChangedEntityTransactionGroup
+	case "tag":
+		change.Entity = model.ChangedEntityTag
+	case "payee":
+		change.Entity = model.ChangedEntityPayee
+	case "rule":
+		change.Entity = model.ChangedEntityRule
+	case "budget":
+		change.Entity = model.ChangedEntityBudget
+	default:
+		return model.Change{}, nil, fmt.Errorf("unknown entity %q", n.Entity)
+	}
+
+	switch n.Op {
+	case "insert":
+		change.Type = model.ChangeTypeCreated
+	case "update":
+		change.Type = model.ChangeTypeUpdated
+	case "delete":
+		change.Type = model.ChangeTypeDeleted
+	default:
+		return model.Change{}, nil, fmt.Errorf("unknown operation %q", n.Op)
+	}
+
+	recipients := func(sub *subscriber) bool {
+		return slices.Contains(n.UserIds, sub.userId) || slices.Contains(n.Emails, sub.email)
+	}
+
+	return change, recipients, nil
+}
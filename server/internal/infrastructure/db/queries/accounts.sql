@@ -1,5 +1,5 @@
 -- name: GetAllAccounts :many
-SELECT id, name, is_mine, type, financial_institution
+SELECT id, name, is_mine, type, financial_institution, version
 FROM accounts
 WHERE user_id = sqlc.arg(user_id);
 
@@ -23,6 +23,14 @@ SET
     END
 WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
 
+-- name: GetAccountVersion :one
+-- Locks the account until the end of the transaction, so it cannot change
+-- between the version check and the update.
+SELECT version
+FROM accounts
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+FOR UPDATE;
+
 -- name: GetAllAccountCurrencies :many
 SELECT
     a.id AS account_id,
@@ -0,0 +1,56 @@
+-- name: LockUserAttachments :exec
+SELECT pg_advisory_xact_lock(hashtextextended('transaction_attachments:' || sqlc.arg(user_id)::text, 0));
+
+-- name: GetAttachmentUsage :one
+SELECT COALESCE(SUM(size), 0)::bigint AS usage
+FROM transaction_attachments
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: IsTransactionAttachable :one
+SELECT EXISTS (
+    SELECT 1
+    FROM transactions t
+    WHERE t.id = sqlc.arg(transaction_id)
+      AND t.user_id = sqlc.arg(user_id)
+      AND t.deleted_at IS NULL
+) AS attachable;
+
+-- name: CreateAttachment :one
+INSERT INTO transaction_attachments (user_id, transaction_id, name, mime_type, size, sha256, storage_key)
+VALUES (
+           sqlc.arg(user_id),
+           sqlc.arg(transaction_id),
+           sqlc.arg(name),
+           sqlc.arg(mime_type),
+           sqlc.arg(size),
+           sqlc.arg(sha256),
+           sqlc.arg(storage_key)
+       )
+RETURNING id;
+
+-- name: GetAttachment :one
+SELECT id, transaction_id, name, mime_type, size, sha256, storage_key, created_at
+FROM transaction_attachments
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND transaction_id IS NOT NULL;
+
+-- name: DetachAttachment :one
+UPDATE transaction_attachments
+SET transaction_id = NULL
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND transaction_id IS NOT NULL
+RETURNING storage_key;
+
+-- name: GetDetachedAttachments :many
+SELECT id, storage_key
+FROM transaction_attachments
+WHERE transaction_id IS NULL
+ORDER BY id
+LIMIT sqlc.arg(max_count);
+
+-- name: DeleteDetachedAttachment :exec
+DELETE FROM transaction_attachments
+WHERE id = sqlc.arg(id)
+  AND transaction_id IS NULL;
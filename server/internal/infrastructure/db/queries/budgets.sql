@@ -0,0 +1,115 @@
+-- name: GetAllBudgets :many
+SELECT id, category_id, period, starts_on, amount, currency_id
+FROM budgets
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY category_id, period, starts_on;
+
+-- name: SetBudget :one
+-- Budgets are owned by the user of their category, and set in one of their
+-- currencies; nothing is written otherwise.
+INSERT INTO budgets (user_id, category_id, period, starts_on, amount, currency_id)
+SELECT sqlc.arg(user_id), sqlc.arg(category_id), sqlc.arg(period), sqlc.arg(starts_on), sqlc.arg(amount), sqlc.arg(currency_id)
+WHERE EXISTS (
+    SELECT 1
+    FROM categories c
+    WHERE c.id = sqlc.arg(category_id)
+      AND c.user_id = sqlc.arg(user_id)
+) AND EXISTS (
+    SELECT 1
+    FROM currencies cu
+    WHERE cu.id = sqlc.arg(currency_id)
+      AND cu.user_id = sqlc.arg(user_id)
+)
+ON CONFLICT (category_id, period, starts_on)
+    DO UPDATE
+    SET amount = EXCLUDED.amount,
+        currency_id = EXCLUDED.currency_id
+RETURNING id;
+
+-- name: GetFoldedBudgets :many
+-- Combines the budgets of the categories with those of the replacement. From
+-- every day one of them starts on, the amount is the sum of those in effect on
+-- that day. currencies counts the currencies of those budgets, which cannot
+-- be added up when there is more than one.
+WITH involved AS (
+    SELECT category_id, period, starts_on, amount, currency_id
+    FROM budgets
+    WHERE category_id = ANY(sqlc.arg(category_ids)::int[])
+       OR category_id = sqlc.arg(replacement_id)::int
+), starts AS (
+    SELECT DISTINCT period, starts_on
+    FROM involved
+)
+SELECT
+    s.period,
+    s.starts_on,
+    SUM(e.amount)::int AS amount,
+    MIN(e.currency_id)::int AS currency_id,
+    COUNT(DISTINCT e.currency_id)::int AS currencies
+FROM starts s
+    CROSS JOIN LATERAL (
+        SELECT DISTINCT ON (i.category_id) i.amount, i.currency_id
+        FROM involved i
+        WHERE i.period = s.period
+          AND i.starts_on <= s.starts_on
+        ORDER BY i.category_id, i.starts_on DESC
+    ) e
+GROUP BY s.period, s.starts_on
+ORDER BY s.period, s.starts_on;
+
+-- name: DeleteBudget :execrows
+DELETE FROM budgets
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: GetCategorySpending :many
+-- Sums, by category, currency and day of the user's timezone, what went out
+-- of the user's accounts less what came into them in the range. Transfers
+-- between their own accounts are neither. Line items count towards their own
+-- category, in the currency of the transaction.
+WITH moves AS (
+    SELECT
+        t.id,
+        t.category,
+        t.currency,
+        t.amount,
+        t.receiver_currency,
+        t.receiver_amount,
+        (t.date AT TIME ZONE sqlc.arg(timezone)::text)::date AS day,
+        COALESCE(s.is_mine, FALSE) AND NOT COALESCE(r.is_mine, FALSE) AS outgoing
+    FROM transactions t
+        LEFT OUTER JOIN accounts s ON s.id = t.sender
+        LEFT OUTER JOIN accounts r ON r.id = t.receiver
+    WHERE t.user_id = sqlc.arg(user_id)
+      AND t.deleted_at IS NULL
+      AND t.date >= sqlc.arg(from_date)
+      AND t.date < sqlc.arg(to_date)
+      AND COALESCE(s.is_mine, FALSE) <> COALESCE(r.is_mine, FALSE)
+), parts AS (
+    SELECT
+        li.category,
+        m.currency,
+        m.day,
+        CASE WHEN m.outgoing THEN li.amount ELSE -li.amount END AS amount
+    FROM moves m
+        JOIN transaction_line_items li ON li.transaction_id = m.id
+    UNION ALL
+    SELECT
+        m.category,
+        CASE WHEN m.outgoing THEN m.currency ELSE m.receiver_currency END,
+        m.day,
+        CASE WHEN m.outgoing THEN m.amount ELSE -m.receiver_amount END
+    FROM moves m
+    WHERE NOT EXISTS (
+        SELECT 1
+        FROM transaction_line_items li
+        WHERE li.transaction_id = m.id
+    )
+)
+SELECT
+    category,
+    currency::int AS currency,
+    day::date AS day,
+    SUM(amount)::bigint AS amount
+FROM parts
+GROUP BY category, currency, day;
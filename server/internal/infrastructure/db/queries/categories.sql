@@ -4,7 +4,7 @@ VALUES (sqlc.arg(name), sqlc.arg(parent), sqlc.arg(icon_name), sqlc.arg(icon_col
 RETURNING id;
 
 -- name: GetAllCategories :many
-SELECT id, name, parent, icon_name, icon_color, icon_background, fixed_costs, ordering
+SELECT id, name, parent, icon_name, icon_color, icon_background, fixed_costs, ordering, version
 FROM categories
 WHERE user_id = sqlc.arg(user_id);
 
@@ -22,3 +22,111 @@ SET
     fixed_costs = COALESCE(sqlc.narg(fixed_costs), fixed_costs),
     ordering = COALESCE(sqlc.narg(ordering), ordering)
 WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: GetCategoryVersion :one
+-- Locks the category until the end of the transaction, so it cannot change
+-- between the version check and the update.
+SELECT version
+FROM categories
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+FOR UPDATE;
+
+-- name: CategoryExists :one
+SELECT EXISTS (
+    SELECT 1
+    FROM categories
+    WHERE id = sqlc.arg(id)
+      AND user_id = sqlc.arg(user_id)
+);
+
+-- name: LockCategory :one
+-- Locks the category until the end of the transaction and returns its parent,
+-- which is NULL for the root category.
+SELECT parent
+FROM categories
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+FOR UPDATE;
+
+-- name: LockRootCategory :one
+-- Locks the root category of the user until the end of the transaction and
+-- returns its id.
+SELECT id
+FROM categories
+WHERE user_id = sqlc.arg(user_id) AND parent IS NULL
+FOR UPDATE;
+
+-- name: GetCategorySubtree :many
+-- Returns the category along with all of its descendants.
+WITH RECURSIVE subtree AS (
+    SELECT c.id
+    FROM categories c
+    WHERE c.id = sqlc.arg(id)
+      AND c.user_id = sqlc.arg(user_id)
+    UNION
+    SELECT c.id
+    FROM categories c
+        JOIN subtree s ON c.parent = s.id
+    WHERE c.user_id = sqlc.arg(user_id)
+)
+SELECT id
+FROM subtree;
+
+-- name: ReparentCategoryChildren :exec
+UPDATE categories
+SET parent = sqlc.arg(new_parent)::int
+WHERE parent = sqlc.arg(id)::int AND user_id = sqlc.arg(user_id);
+
+-- name: ReassignCategories :exec
+-- Files everything that refers to the categories under the replacement
+-- instead, ahead of deleting them.
+WITH moved_transactions AS (
+    UPDATE transactions
+    SET category = sqlc.arg(replacement_id)::int
+    WHERE category = ANY(sqlc.arg(category_ids)::int[])
+), moved_line_items AS (
+    UPDATE transaction_line_items
+    SET category = sqlc.arg(replacement_id)::int
+    WHERE category = ANY(sqlc.arg(category_ids)::int[])
+), moved_recurring_transactions AS (
+    UPDATE recurring_transactions
+    SET category = sqlc.arg(replacement_id)::int
+    WHERE category = ANY(sqlc.arg(category_ids)::int[])
+), moved_group_defaults AS (
+    UPDATE user_transaction_group
+    SET category_id = sqlc.arg(replacement_id)::int
+    WHERE category_id = ANY(sqlc.arg(category_ids)::int[])
+), moved_payee_defaults AS (
+    UPDATE payees
+    SET default_category_id = sqlc.arg(replacement_id)::int
+    WHERE default_category_id = ANY(sqlc.arg(category_ids)::int[])
+)
+UPDATE transaction_rules
+SET set_category_id = sqlc.arg(replacement_id)::int
+WHERE set_category_id = ANY(sqlc.arg(category_ids)::int[]);
+
+-- name: DeleteCategories :exec
+DELETE FROM categories
+WHERE id = ANY(sqlc.arg(ids)::int[]) AND user_id = sqlc.arg(user_id);
+
+-- name: GetSiblingCategories :many
+-- Locks the children of the parent, leaving out the category being moved, in
+-- the order they are shown in: highest ordering first.
+SELECT id, ordering
+FROM categories
+WHERE parent = sqlc.arg(parent)::int
+  AND user_id = sqlc.arg(user_id)
+  AND id <> sqlc.arg(moved_id)
+ORDER BY ordering DESC, id DESC
+FOR UPDATE;
+
+-- name: MoveCategory :exec
+UPDATE categories
+SET parent = sqlc.arg(parent)::int,
+    ordering = sqlc.arg(ordering)
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: SetCategoryOrderings :exec
+UPDATE categories c
+SET ordering = o.ordering
+FROM unnest(sqlc.arg(ids)::int[], sqlc.arg(orderings)::double precision[]) AS o(id, ordering)
+WHERE c.id = o.id AND c.user_id = sqlc.arg(user_id);
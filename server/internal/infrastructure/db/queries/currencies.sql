@@ -9,13 +9,21 @@ FROM currencies c
     JOIN users u ON c.user_id = u.id
 WHERE c.id = sqlc.arg(currency_id);
 
+-- name: CurrencyExists :one
+SELECT EXISTS (
+    SELECT 1
+    FROM currencies
+    WHERE id = sqlc.arg(id)
+      AND user_id = sqlc.arg(user_id)
+);
+
 -- name: GetAllCurrencies :many
-SELECT id, name, symbol, risk, type, decimal_points, rate_fetch_script, auto_update
+SELECT id, name, symbol, risk, type, decimal_points, rate_fetch_script, auto_update, version
 FROM currencies
 WHERE user_id = sqlc.arg(user_id);
 
 -- name: GetAllWithAutoUpdate :many
-SELECT id, name, symbol, risk, type, decimal_points, rate_fetch_script, auto_update
+SELECT id, name, symbol, risk, type, decimal_points, rate_fetch_script, auto_update, version
 FROM currencies
 WHERE auto_update = true
 LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
@@ -31,3 +39,11 @@ SET
     rate_fetch_script = COALESCE(sqlc.narg(rate_fetch_script), rate_fetch_script),
     auto_update = COALESCE(sqlc.narg(auto_update), auto_update)
 WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: GetCurrencyVersion :one
+-- Locks the currency until the end of the transaction, so it cannot change
+-- between the version check and the update.
+SELECT version
+FROM currencies
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+FOR UPDATE;
@@ -0,0 +1,93 @@
+-- name: FindDuplicateCandidates :many
+SELECT
+    t.id,
+    COALESCE(u.email, 'TBD') AS owner,
+    t.amount,
+    t.currency,
+    t.date,
+    t.note,
+    similarity(t.note, sqlc.arg(note)::text)::float8 AS note_similarity
+FROM transactions t
+    LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
+    LEFT OUTER JOIN users u ON u.id = t.user_id
+WHERE t.deleted_at IS NULL
+  AND t.currency = sqlc.arg(currency)
+  AND abs(t.amount - sqlc.arg(amount)::int) <= greatest(t.amount, sqlc.arg(amount)::int) * sqlc.arg(amount_tolerance_percent)::int / 100
+  AND abs(extract(epoch FROM t.date - sqlc.arg(date)::timestamptz)) <= sqlc.arg(max_day_distance)::int * 86400
+  AND (
+    (
+        t.user_id = sqlc.arg(user_id)
+        AND (t.sender = sqlc.narg(sender) OR t.receiver = sqlc.narg(receiver))
+    )
+    OR ttg.transaction_group_id IN (
+        SELECT utg.transaction_group_id
+        FROM user_transaction_group utg
+            JOIN users gu ON gu.email = utg.user_email
+        WHERE gu.id = sqlc.arg(user_id)
+          AND utg.transaction_group_id = sqlc.narg(transaction_group_id)
+    )
+  )
+  AND (
+    t.note = ''
+    OR sqlc.arg(note)::text = ''
+    OR similarity(t.note, sqlc.arg(note)::text) >= sqlc.arg(min_note_similarity)::float8
+  )
+ORDER BY note_similarity DESC, abs(extract(epoch FROM t.date - sqlc.arg(date)::timestamptz)), t.id
+LIMIT sqlc.arg(max_count);
+
+-- name: FindDuplicateTransactionPairs :many
+WITH visible AS (
+    SELECT
+        t.id,
+        t.user_id,
+        COALESCE(u.email, 'TBD') AS owner,
+        t.amount,
+        t.currency,
+        t.sender,
+        t.receiver,
+        t.date,
+        t.note,
+        ttg.transaction_group_id
+    FROM transactions t
+        LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
+        LEFT OUTER JOIN users u ON u.id = t.user_id
+    WHERE t.deleted_at IS NULL
+      AND (
+        t.user_id = sqlc.arg(user_id)
+        OR ttg.transaction_group_id IN (
+            SELECT utg.transaction_group_id
+            FROM user_transaction_group utg
+                JOIN users gu ON gu.email = utg.user_email
+            WHERE gu.id = sqlc.arg(user_id)
+        )
+      )
+)
+SELECT
+    a.id AS first_id,
+    a.owner AS first_owner,
+    a.amount AS first_amount,
+    a.date AS first_date,
+    a.note AS first_note,
+    b.id AS second_id,
+    b.owner AS second_owner,
+    b.amount AS second_amount,
+    b.date AS second_date,
+    b.note AS second_note,
+    a.currency,
+    similarity(a.note, b.note)::float8 AS note_similarity
+FROM visible a
+    JOIN visible b ON a.id < b.id
+        AND a.currency = b.currency
+        AND abs(extract(epoch FROM a.date - b.date)) <= sqlc.arg(max_day_distance)::int * 86400
+WHERE abs(a.amount - b.amount) <= greatest(a.amount, b.amount) * sqlc.arg(amount_tolerance_percent)::int / 100
+  AND (
+    (a.user_id = b.user_id AND (a.sender = b.sender OR a.receiver = b.receiver))
+    OR a.transaction_group_id = b.transaction_group_id
+  )
+  AND (
+    a.note = ''
+    OR b.note = ''
+    OR similarity(a.note, b.note) >= sqlc.arg(min_note_similarity)::float8
+  )
+ORDER BY a.date DESC, a.id, b.id
+LIMIT sqlc.arg(max_count);
@@ -0,0 +1,195 @@
+-- name: GetEnvelopeSettings :one
+SELECT currency_id, starts_on
+FROM envelope_budgets
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: SetEnvelopeSettings :one
+-- Starts the ledger over, from starts_on on. The currency has to be one of
+-- the user's; nothing is written otherwise.
+INSERT INTO envelope_budgets (user_id, currency_id, starts_on, stale_from, computed_through)
+SELECT sqlc.arg(user_id), sqlc.arg(currency_id), sqlc.arg(starts_on), sqlc.arg(starts_on), NULL
+WHERE EXISTS (
+    SELECT 1
+    FROM currencies c
+    WHERE c.id = sqlc.arg(currency_id)
+      AND c.user_id = sqlc.arg(user_id)
+)
+ON CONFLICT (user_id)
+    DO UPDATE
+    SET currency_id = EXCLUDED.currency_id,
+        starts_on = EXCLUDED.starts_on,
+        stale_from = EXCLUDED.stale_from,
+        computed_through = NULL
+RETURNING user_id;
+
+-- name: DeleteEnvelopeSettings :execrows
+DELETE FROM envelope_budgets
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: LockEnvelopeLedger :one
+-- Locks the ledger of the user until the end of the transaction. Writes that
+-- make it stale wait for the lock, so they are never lost by a refresh.
+SELECT currency_id, starts_on, stale_from, computed_through
+FROM envelope_budgets
+WHERE user_id = sqlc.arg(user_id)
+FOR UPDATE;
+
+-- name: SetEnvelopeLedgerComputed :exec
+UPDATE envelope_budgets
+SET stale_from = NULL,
+    computed_through = sqlc.arg(computed_through)
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: DeleteEnvelopeLedger :exec
+DELETE FROM envelope_ledger
+WHERE user_id = sqlc.arg(user_id)
+  AND month >= sqlc.arg(from_month);
+
+-- name: InsertEnvelopeLedger :exec
+INSERT INTO envelope_ledger (user_id, category_id, month, income, assigned, transferred, activity, balance, missing_exchange_rate)
+SELECT sqlc.arg(user_id), e.category_id, e.month, e.income, e.assigned, e.transferred, e.activity, e.balance, e.missing_exchange_rate
+FROM unnest(
+    sqlc.arg(category_ids)::int[],
+    sqlc.arg(months)::date[],
+    sqlc.arg(incomes)::boolean[],
+    sqlc.arg(assigned)::bigint[],
+    sqlc.arg(transferred)::bigint[],
+    sqlc.arg(activities)::bigint[],
+    sqlc.arg(balances)::bigint[],
+    sqlc.arg(missing_exchange_rates)::boolean[]
+) AS e(category_id, month, income, assigned, transferred, activity, balance, missing_exchange_rate);
+
+-- name: GetEnvelopeBalances :many
+SELECT category_id, balance
+FROM envelope_ledger
+WHERE user_id = sqlc.arg(user_id)
+  AND month = sqlc.arg(month);
+
+-- name: GetEnvelopeLedgerMonth :many
+SELECT category_id, income, assigned, transferred, activity, balance, missing_exchange_rate
+FROM envelope_ledger
+WHERE user_id = sqlc.arg(user_id)
+  AND month = sqlc.arg(month)
+ORDER BY category_id;
+
+-- name: GetAvailableToBudget :one
+-- What the income envelopes brought in up to the month, less what was
+-- assigned to the others.
+SELECT COALESCE(SUM(CASE WHEN income THEN activity ELSE -assigned END), 0)::bigint
+FROM envelope_ledger
+WHERE user_id = sqlc.arg(user_id)
+  AND month <= sqlc.arg(month);
+
+-- name: GetEnvelopes :many
+SELECT category_id, income
+FROM envelopes
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY category_id;
+
+-- name: SetEnvelope :execrows
+INSERT INTO envelopes (category_id, user_id, income)
+SELECT sqlc.arg(category_id), sqlc.arg(user_id), sqlc.arg(income)
+WHERE EXISTS (
+    SELECT 1
+    FROM categories c
+    WHERE c.id = sqlc.arg(category_id)
+      AND c.user_id = sqlc.arg(user_id)
+)
+ON CONFLICT (category_id)
+    DO UPDATE
+    SET income = EXCLUDED.income;
+
+-- name: DeleteEnvelope :execrows
+DELETE FROM envelopes
+WHERE category_id = sqlc.arg(category_id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: SpendingEnvelopeExists :one
+SELECT EXISTS (
+    SELECT 1
+    FROM envelopes
+    WHERE category_id = sqlc.arg(category_id)
+      AND user_id = sqlc.arg(user_id)
+      AND NOT income
+);
+
+-- name: SetEnvelopeAssignment :exec
+INSERT INTO envelope_assignments (category_id, month, user_id, amount)
+VALUES (sqlc.arg(category_id), sqlc.arg(month), sqlc.arg(user_id), sqlc.arg(amount))
+ON CONFLICT (category_id, month)
+    DO UPDATE
+    SET amount = EXCLUDED.amount;
+
+-- name: GetEnvelopeAssignments :many
+SELECT category_id, month, amount
+FROM envelope_assignments
+WHERE user_id = sqlc.arg(user_id)
+  AND month >= sqlc.arg(from_month)
+  AND month <= sqlc.arg(through_month);
+
+-- name: CreateBudgetTransfer :one
+INSERT INTO budget_transfers (user_id, month, from_category_id, to_category_id, amount, note)
+VALUES (sqlc.arg(user_id), sqlc.arg(month), sqlc.arg(from_category_id), sqlc.arg(to_category_id), sqlc.arg(amount), sqlc.arg(note))
+RETURNING id;
+
+-- name: DeleteBudgetTransfer :execrows
+DELETE FROM budget_transfers
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: GetBudgetTransfers :many
+SELECT id, month, from_category_id, to_category_id, amount, note
+FROM budget_transfers
+WHERE user_id = sqlc.arg(user_id)
+  AND month >= sqlc.arg(from_month)
+  AND month <= sqlc.arg(through_month)
+ORDER BY month, created_at, id;
+
+-- name: EnvelopeIncomeConflict :one
+-- Says whether the categories and the replacement have both income and
+-- spending envelopes, which cannot be folded into one.
+SELECT COUNT(DISTINCT income) > 1
+FROM envelopes
+WHERE category_id = ANY(sqlc.arg(category_ids)::int[])
+   OR category_id = sqlc.arg(replacement_id)::int;
+
+-- name: CreateReplacementEnvelope :exec
+-- Gives the replacement an envelope when one of the categories has one.
+INSERT INTO envelopes (category_id, user_id, income)
+SELECT sqlc.arg(replacement_id)::int, user_id, bool_or(income)
+FROM envelopes
+WHERE category_id = ANY(sqlc.arg(category_ids)::int[])
+GROUP BY user_id
+ON CONFLICT (category_id) DO NOTHING;
+
+-- name: FoldEnvelopeAssignments :exec
+INSERT INTO envelope_assignments (category_id, month, user_id, amount)
+SELECT sqlc.arg(replacement_id)::int, month, user_id, SUM(amount)::int
+FROM envelope_assignments
+WHERE category_id = ANY(sqlc.arg(category_ids)::int[])
+GROUP BY month, user_id
+ON CONFLICT (category_id, month)
+    DO UPDATE
+    SET amount = envelope_assignments.amount + EXCLUDED.amount;
+
+-- name: ReassignBudgetTransfers :exec
+-- Transfers between the categories and the replacement would go from the
+-- folded envelope to itself, so they go away instead.
+WITH dropped AS (
+    DELETE FROM budget_transfers
+    WHERE from_category_id = ANY(sqlc.arg(category_ids)::int[] || sqlc.arg(replacement_id)::int)
+      AND to_category_id = ANY(sqlc.arg(category_ids)::int[] || sqlc.arg(replacement_id)::int)
+    RETURNING id
+)
+UPDATE budget_transfers
+SET
+    from_category_id = CASE
+        WHEN from_category_id = ANY(sqlc.arg(category_ids)::int[]) THEN sqlc.arg(replacement_id)::int
+        ELSE from_category_id
+    END,
+    to_category_id = CASE
+        WHEN to_category_id = ANY(sqlc.arg(category_ids)::int[]) THEN sqlc.arg(replacement_id)::int
+        ELSE to_category_id
+    END
+WHERE (from_category_id = ANY(sqlc.arg(category_ids)::int[]) OR to_category_id = ANY(sqlc.arg(category_ids)::int[]))
+  AND id NOT IN (SELECT id FROM dropped);
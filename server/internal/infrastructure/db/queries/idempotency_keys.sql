@@ -0,0 +1,32 @@
+-- name: ReserveIdempotencyKey :execrows
+-- A key whose window is over is free to be used again.
+INSERT INTO idempotency_keys (user_id, key, method, request_hash)
+VALUES (sqlc.arg(user_id), sqlc.arg(key), sqlc.arg(method), sqlc.arg(request_hash))
+ON CONFLICT (user_id, key) DO UPDATE
+    SET method = EXCLUDED.method,
+        request_hash = EXCLUDED.request_hash,
+        response = NULL,
+        created_at = now()
+    WHERE idempotency_keys.created_at < sqlc.arg(expired_before);
+
+-- name: GetIdempotencyKey :one
+SELECT method, request_hash, response
+FROM idempotency_keys
+WHERE user_id = sqlc.arg(user_id)
+  AND key = sqlc.arg(key);
+
+-- name: SaveIdempotentResponse :execrows
+UPDATE idempotency_keys
+SET response = sqlc.arg(response)
+WHERE user_id = sqlc.arg(user_id)
+  AND key = sqlc.arg(key);
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE user_id = sqlc.arg(user_id)
+  AND key = sqlc.arg(key)
+  AND response IS NULL;
+
+-- name: PurgeIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE created_at < sqlc.arg(created_before);
@@ -0,0 +1,65 @@
+-- name: GetAllPayees :many
+SELECT
+    p.id,
+    p.name,
+    p.default_category_id,
+    ARRAY(
+        SELECT pa.pattern
+        FROM payee_aliases pa
+        WHERE pa.payee_id = p.id
+        ORDER BY pa.normalized_pattern
+    )::text[] AS aliases
+FROM payees p
+WHERE p.user_id = sqlc.arg(user_id)
+ORDER BY lower(p.name);
+
+-- name: GetPayee :one
+SELECT id, name, default_category_id
+FROM payees
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: CreatePayee :one
+INSERT INTO payees (user_id, name, default_category_id)
+VALUES (sqlc.arg(user_id), sqlc.arg(name), sqlc.narg(default_category_id))
+RETURNING id;
+
+-- name: UpdatePayee :execrows
+UPDATE payees
+SET name                = sqlc.arg(name),
+    default_category_id = sqlc.narg(default_category_id)
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: DeletePayee :execrows
+DELETE FROM payees
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: DeletePayeeAliases :exec
+DELETE FROM payee_aliases
+WHERE payee_id = sqlc.arg(payee_id);
+
+-- name: AddPayeeAliases :exec
+INSERT INTO payee_aliases (payee_id, pattern)
+SELECT sqlc.arg(payee_id), pattern
+FROM unnest(sqlc.arg(patterns)::text[]) AS pattern
+WHERE normalize_payee_name(pattern) <> ''
+ON CONFLICT (payee_id, normalized_pattern) DO NOTHING;
+
+-- name: ResolvePayee :one
+-- The payee named exactly like the merchant wins, then the one with the
+-- longest matching alias.
+SELECT p.id, p.name, p.default_category_id
+FROM payees p
+    LEFT OUTER JOIN payee_aliases pa ON pa.payee_id = p.id
+WHERE p.user_id = sqlc.arg(user_id)
+  AND (
+      normalize_payee_name(p.name) = normalize_payee_name(sqlc.arg(merchant))
+      OR pa.normalized_pattern = normalize_payee_name(sqlc.arg(merchant))
+      OR normalize_payee_name(sqlc.arg(merchant)) LIKE pa.normalized_pattern || ' %'
+  )
+ORDER BY normalize_payee_name(p.name) = normalize_payee_name(sqlc.arg(merchant)) DESC,
+         length(pa.normalized_pattern) DESC NULLS LAST,
+         p.id
+LIMIT 1;
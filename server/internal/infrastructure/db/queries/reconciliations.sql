@@ -0,0 +1,111 @@
+-- name: LockAccount :one
+SELECT id
+FROM accounts
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+FOR UPDATE;
+
+-- name: GetClearedBalances :many
+-- The statement date is a day in the user's timezone, and covers every
+-- transaction made before that day ends.
+WITH statement_end AS (
+    SELECT ((sqlc.arg(statement_date)::date + 1)::timestamp AT TIME ZONE u.timezone) AS ends_at
+    FROM users u
+    WHERE u.id = sqlc.arg(user_id)
+), movements AS (
+    SELECT ac.currency_id, ac.value::bigint AS amount
+    FROM accountcurrencies ac
+    WHERE ac.account_id = sqlc.arg(account_id)
+    UNION ALL
+    SELECT t.receiver_currency, t.receiver_amount::bigint
+    FROM transactions t
+    WHERE t.receiver = sqlc.arg(account_id)
+      AND t.user_id = sqlc.arg(user_id)
+      AND t.deleted_at IS NULL
+      AND t.status <> 'PENDING'
+      AND t.date < (SELECT ends_at FROM statement_end)
+    UNION ALL
+    SELECT t.currency, -t.amount::bigint
+    FROM transactions t
+    WHERE t.sender = sqlc.arg(account_id)
+      AND t.user_id = sqlc.arg(user_id)
+      AND t.deleted_at IS NULL
+      AND t.status <> 'PENDING'
+      AND t.date < (SELECT ends_at FROM statement_end)
+)
+SELECT
+    currency_id::int AS currency_id,
+    SUM(amount)::bigint AS cleared_balance
+FROM movements
+GROUP BY currency_id
+ORDER BY currency_id;
+
+-- name: MarkTransactionsReconciled :many
+-- The statement date is a day in the user's timezone, and covers every
+-- transaction made before that day ends.
+WITH statement_end AS (
+    SELECT ((sqlc.arg(statement_date)::date + 1)::timestamp AT TIME ZONE u.timezone) AS ends_at
+    FROM users u
+    WHERE u.id = sqlc.arg(user_id)
+)
+UPDATE transactions
+SET status = 'RECONCILED'
+WHERE (sender = sqlc.arg(account_id) OR receiver = sqlc.arg(account_id))
+  AND user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL
+  AND status = 'CLEARED'
+  AND date < (SELECT ends_at FROM statement_end)
+RETURNING id;
+
+-- name: CreateReconciliation :one
+INSERT INTO reconciliations (user_id, account_id, statement_date)
+VALUES (sqlc.arg(user_id), sqlc.arg(account_id), sqlc.arg(statement_date))
+RETURNING id;
+
+-- name: CreateReconciliationBalance :exec
+INSERT INTO reconciliation_balances (reconciliation_id, currency_id, statement_balance)
+VALUES (sqlc.arg(reconciliation_id), sqlc.arg(currency_id), sqlc.arg(statement_balance));
+
+-- name: LinkReconciliationTransactions :exec
+INSERT INTO reconciliation_transactions (reconciliation_id, transaction_id)
+SELECT sqlc.arg(reconciliation_id), unnest(sqlc.arg(transaction_ids)::int[]);
+
+-- name: GetLatestReconciliation :one
+SELECT id
+FROM reconciliations
+WHERE account_id = sqlc.arg(account_id)
+  AND user_id = sqlc.arg(user_id)
+ORDER BY statement_date DESC, id DESC
+LIMIT 1;
+
+-- name: ReconciliationCoveredByOtherAccounts :one
+-- Says whether a transaction of the reconciliation falls within a statement
+-- of the other account it moves money to or from, whose balance may have
+-- counted it.
+SELECT EXISTS (
+    SELECT 1
+    FROM reconciliation_transactions rt
+        JOIN reconciliations reconciliation ON reconciliation.id = rt.reconciliation_id
+        JOIN transactions t ON t.id = rt.transaction_id
+        JOIN reconciliations other ON other.account_id IN (t.sender, t.receiver)
+            AND other.account_id <> reconciliation.account_id
+            AND other.user_id = reconciliation.user_id
+        JOIN users u ON u.id = other.user_id
+    WHERE rt.reconciliation_id = sqlc.arg(id)
+      AND t.date < ((other.statement_date + 1)::timestamp AT TIME ZONE u.timezone)
+);
+
+-- name: UnmarkReconciledTransactions :many
+UPDATE transactions
+SET status = 'CLEARED'
+WHERE id IN (
+    SELECT transaction_id
+    FROM reconciliation_transactions
+    WHERE reconciliation_id = sqlc.arg(reconciliation_id)
+)
+  AND status = 'RECONCILED'
+RETURNING id;
+
+-- name: DeleteReconciliation :exec
+DELETE FROM reconciliations
+WHERE id = sqlc.arg(id);
@@ -0,0 +1,156 @@
+-- name: GetAllRecurringTransactions :many
+SELECT
+    rt.id,
+    COALESCE(u.email, 'TBD') as owner,
+    rt.amount,
+    rt.currency,
+    rt.sender,
+    rt.receiver,
+    rt.category,
+    rt.note,
+    rt.receiver_currency,
+    rt.receiver_amount,
+    rt.schedule,
+    rt.starts_on,
+    rt.ends_on,
+    rt.paused,
+    rt.materialized_through
+FROM recurring_transactions rt
+    LEFT OUTER JOIN users u ON u.id = rt.user_id
+WHERE rt.user_id = sqlc.arg(user_id)
+ORDER BY rt.id;
+
+-- name: GetRecurringTransaction :one
+SELECT
+    rt.id,
+    COALESCE(u.email, 'TBD') as owner,
+    rt.amount,
+    rt.currency,
+    rt.sender,
+    rt.receiver,
+    rt.category,
+    rt.note,
+    rt.receiver_currency,
+    rt.receiver_amount,
+    rt.schedule,
+    rt.starts_on,
+    rt.ends_on,
+    rt.paused,
+    rt.materialized_through
+FROM recurring_transactions rt
+    LEFT OUTER JOIN users u ON u.id = rt.user_id
+WHERE rt.id = sqlc.arg(id)
+  AND rt.user_id = sqlc.arg(user_id);
+
+-- name: GetDueRecurringTransactions :many
+-- Schedules run on the days of the owner's timezone, so what is due depends
+-- on the date it is there now.
+SELECT
+    rt.id,
+    rt.user_id,
+    COALESCE(u.email, 'TBD') as owner,
+    rt.amount,
+    rt.currency,
+    rt.sender,
+    rt.receiver,
+    rt.category,
+    rt.note,
+    rt.receiver_currency,
+    rt.receiver_amount,
+    rt.schedule,
+    rt.starts_on,
+    rt.ends_on,
+    rt.paused,
+    rt.materialized_through,
+    COALESCE(u.timezone, 'UTC') AS timezone
+FROM recurring_transactions rt
+    LEFT OUTER JOIN users u ON u.id = rt.user_id
+WHERE NOT rt.paused
+  AND rt.starts_on <= (sqlc.arg(now)::timestamptz AT TIME ZONE COALESCE(u.timezone, 'UTC'))::date
+  AND (rt.ends_on IS NULL OR rt.materialized_through IS NULL OR rt.materialized_through < rt.ends_on)
+  AND (rt.materialized_through IS NULL OR rt.materialized_through < (sqlc.arg(now)::timestamptz AT TIME ZONE COALESCE(u.timezone, 'UTC'))::date)
+ORDER BY rt.id;
+
+-- name: CreateRecurringTransaction :one
+INSERT INTO recurring_transactions (user_id, amount, currency, sender, receiver, category, note, receiver_currency, receiver_amount, schedule, starts_on, ends_on)
+VALUES (
+           sqlc.arg(user_id),
+           sqlc.arg(amount),
+           sqlc.arg(currency),
+           sqlc.arg(sender),
+           sqlc.arg(receiver),
+           sqlc.arg(category),
+           sqlc.arg(note),
+           sqlc.arg(receiver_currency),
+           sqlc.arg(receiver_amount),
+           sqlc.arg(schedule),
+           sqlc.arg(starts_on),
+           sqlc.arg(ends_on)
+       )
+RETURNING id;
+
+-- name: UpdateRecurringTransaction :execrows
+UPDATE recurring_transactions
+SET
+    amount = COALESCE(sqlc.narg(amount), amount),
+    currency = COALESCE(sqlc.narg(currency), currency),
+    sender = CASE
+        WHEN sqlc.arg(update_sender)::boolean THEN sqlc.narg(sender)
+        ELSE sender
+    END,
+    receiver = CASE
+        WHEN sqlc.arg(update_receiver)::boolean THEN sqlc.narg(receiver)
+        ELSE receiver
+    END,
+    category = CASE
+        WHEN sqlc.arg(update_category)::boolean THEN sqlc.narg(category)
+        ELSE category
+    END,
+    note = COALESCE(sqlc.narg(note), note),
+    receiver_currency = COALESCE(sqlc.narg(receiver_currency), receiver_currency),
+    receiver_amount = COALESCE(sqlc.narg(receiver_amount), receiver_amount),
+    schedule = COALESCE(sqlc.narg(schedule), schedule),
+    ends_on = CASE
+        WHEN sqlc.arg(update_ends_on)::boolean THEN sqlc.narg(ends_on)
+        ELSE ends_on
+    END
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: SetRecurringTransactionPaused :execrows
+-- Occurrences falling while a rule is paused are not back-filled on resume.
+-- Yesterday is that of the owner's timezone, as for the due occurrences.
+UPDATE recurring_transactions
+SET
+    paused = sqlc.arg(paused),
+    materialized_through = CASE
+        WHEN paused AND NOT sqlc.arg(paused)::boolean
+            THEN GREATEST(
+                materialized_through,
+                (now() AT TIME ZONE COALESCE(
+                    (SELECT u.timezone FROM users u WHERE u.id = recurring_transactions.user_id),
+                    'UTC'
+                ))::date - 1
+            )
+        ELSE materialized_through
+    END
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: DeleteRecurringTransaction :execrows
+DELETE FROM recurring_transactions
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: ClaimRecurringTransactionOccurrence :execrows
+INSERT INTO recurring_transaction_occurrences (recurring_transaction_id, occurrence_date)
+VALUES (sqlc.arg(recurring_transaction_id), sqlc.arg(occurrence_date))
+ON CONFLICT DO NOTHING;
+
+-- name: SetRecurringTransactionOccurrenceTransaction :exec
+UPDATE recurring_transaction_occurrences
+SET transaction_id = sqlc.arg(transaction_id)
+WHERE recurring_transaction_id = sqlc.arg(recurring_transaction_id)
+  AND occurrence_date = sqlc.arg(occurrence_date);
+
+-- name: AdvanceRecurringTransaction :exec
+UPDATE recurring_transactions
+SET materialized_through = GREATEST(materialized_through, sqlc.arg(materialized_through)::date)
+WHERE id = sqlc.arg(id);
@@ -0,0 +1,146 @@
+-- name: GetAllRules :many
+SELECT
+    r.id,
+    r.name,
+    r.priority,
+    r.enabled,
+    r.stop_processing,
+    r.note_pattern,
+    r.min_amount,
+    r.max_amount,
+    r.account_id,
+    r.currency_id,
+    r.payee_id,
+    r.set_category_id,
+    r.rewrite_note,
+    r.set_transaction_group_id,
+    ARRAY(
+        SELECT rt.tag_id
+        FROM transaction_rule_tags rt
+        WHERE rt.rule_id = r.id
+        ORDER BY rt.tag_id
+    )::int[] AS tags
+FROM transaction_rules r
+WHERE r.user_id = sqlc.arg(user_id)
+ORDER BY r.priority, r.id;
+
+-- name: CreateRule :one
+INSERT INTO transaction_rules (
+    user_id, name, priority, enabled, stop_processing,
+    note_pattern, min_amount, max_amount, account_id, currency_id, payee_id,
+    set_category_id, rewrite_note, set_transaction_group_id
+)
+VALUES (
+    sqlc.arg(user_id),
+    sqlc.arg(name),
+    sqlc.arg(priority),
+    sqlc.arg(enabled),
+    sqlc.arg(stop_processing),
+    sqlc.narg(note_pattern),
+    sqlc.narg(min_amount),
+    sqlc.narg(max_amount),
+    sqlc.narg(account_id),
+    sqlc.narg(currency_id),
+    sqlc.narg(payee_id),
+    sqlc.narg(set_category_id),
+    sqlc.narg(rewrite_note),
+    sqlc.narg(set_transaction_group_id)
+)
+RETURNING id;
+
+-- name: UpdateRule :execrows
+UPDATE transaction_rules
+SET name                     = sqlc.arg(name),
+    priority                 = sqlc.arg(priority),
+    enabled                  = sqlc.arg(enabled),
+    stop_processing          = sqlc.arg(stop_processing),
+    note_pattern             = sqlc.narg(note_pattern),
+    min_amount               = sqlc.narg(min_amount),
+    max_amount               = sqlc.narg(max_amount),
+    account_id               = sqlc.narg(account_id),
+    currency_id              = sqlc.narg(currency_id),
+    payee_id                 = sqlc.narg(payee_id),
+    set_category_id          = sqlc.narg(set_category_id),
+    rewrite_note             = sqlc.narg(rewrite_note),
+    set_transaction_group_id = sqlc.narg(set_transaction_group_id)
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: DeleteRule :execrows
+DELETE FROM transaction_rules
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: ReorderRules :execrows
+-- Rules take the priority of their position in the list.
+UPDATE transaction_rules r
+SET priority = o.position
+FROM unnest(sqlc.arg(ids)::int[]) WITH ORDINALITY AS o(id, position)
+WHERE r.id = o.id
+  AND r.user_id = sqlc.arg(user_id);
+
+-- name: DeleteRuleTags :exec
+DELETE FROM transaction_rule_tags
+WHERE rule_id = sqlc.arg(rule_id);
+
+-- name: AddRuleTags :exec
+INSERT INTO transaction_rule_tags (rule_id, tag_id)
+SELECT sqlc.arg(rule_id), tag_id
+FROM unnest(sqlc.arg(tag_ids)::int[]) AS tag_id;
+
+-- name: CheckRuleReferences :one
+-- Reports, for each reference a rule makes, whether it is missing or is
+-- something the user can use.
+SELECT
+    (sqlc.narg(account_id)::int IS NULL OR EXISTS (
+        SELECT 1 FROM accounts a WHERE a.id = sqlc.narg(account_id) AND a.user_id = sqlc.arg(user_id)
+    ))::boolean AS account_found,
+    (sqlc.narg(currency_id)::int IS NULL OR EXISTS (
+        SELECT 1 FROM currencies c WHERE c.id = sqlc.narg(currency_id) AND c.user_id = sqlc.arg(user_id)
+    ))::boolean AS currency_found,
+    (sqlc.narg(payee_id)::int IS NULL OR EXISTS (
+        SELECT 1 FROM payees p WHERE p.id = sqlc.narg(payee_id) AND p.user_id = sqlc.arg(user_id)
+    ))::boolean AS payee_found,
+    (sqlc.narg(category_id)::int IS NULL OR EXISTS (
+        SELECT 1 FROM categories c WHERE c.id = sqlc.narg(category_id) AND c.user_id = sqlc.arg(user_id)
+    ))::boolean AS category_found,
+    (sqlc.narg(transaction_group_id)::int IS NULL OR EXISTS (
+        SELECT 1
+        FROM user_transaction_group utg
+            JOIN users u ON u.email = utg.user_email
+        WHERE utg.transaction_group_id = sqlc.narg(transaction_group_id)
+          AND u.id = sqlc.arg(user_id)
+          AND utg.locked
+    ))::boolean AS transaction_group_found,
+    (
+        SELECT count(*)
+        FROM tags t
+        WHERE t.id = ANY(sqlc.arg(tag_ids)::int[])
+          AND t.user_id = sqlc.arg(user_id)
+    )::int AS tags_found;
+
+-- name: GetRuleTargets :many
+-- The transactions rules can be applied to after the fact: the user's own,
+-- outside the trash and not locked by a reconciliation.
+SELECT
+    t.id,
+    t.amount,
+    t.currency,
+    t.sender,
+    t.receiver,
+    t.payee_id,
+    t.note,
+    t.category,
+    ARRAY(
+        SELECT tt.tag_id
+        FROM transaction_tags tt
+        WHERE tt.transaction_id = t.id
+        ORDER BY tt.tag_id
+    )::int[] AS tags,
+    ttg.transaction_group_id
+FROM transactions t
+    LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
+WHERE t.user_id = sqlc.arg(user_id)
+  AND t.deleted_at IS NULL
+  AND t.status <> 'RECONCILED'
+ORDER BY t.date DESC, t.id DESC;
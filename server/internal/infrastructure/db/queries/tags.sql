@@ -0,0 +1,36 @@
+-- name: GetAllTags :many
+SELECT id, name
+FROM tags
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY lower(name);
+
+-- name: GetTag :one
+SELECT id, name
+FROM tags
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: CreateTag :one
+INSERT INTO tags (user_id, name)
+VALUES (sqlc.arg(user_id), sqlc.arg(name))
+RETURNING id;
+
+-- name: RenameTag :execrows
+UPDATE tags
+SET name = sqlc.arg(name)
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: DeleteTags :execrows
+DELETE FROM tags
+WHERE id = ANY(sqlc.arg(ids)::int[])
+  AND user_id = sqlc.arg(user_id);
+
+-- name: RetagTransactions :exec
+INSERT INTO transaction_tags (transaction_id, tag_id)
+SELECT DISTINCT tt.transaction_id, sqlc.arg(target_id)::int
+FROM transaction_tags tt
+    JOIN tags t ON t.id = tt.tag_id
+WHERE tt.tag_id = ANY(sqlc.arg(source_ids)::int[])
+  AND t.user_id = sqlc.arg(user_id)
+ON CONFLICT (transaction_id, tag_id) DO NOTHING;
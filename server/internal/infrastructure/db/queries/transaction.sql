@@ -25,7 +25,8 @@ SELECT
 FROM transactions t
          LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
          LEFT OUTER JOIN users u ON u.id = t.user_id
-WHERE t.id = sqlc.arg(transaction_id);
+WHERE t.id = sqlc.arg(transaction_id)
+  AND t.deleted_at IS NULL;
 
 -- name: GetAllTransactions :many
 SELECT
@@ -60,16 +61,209 @@ SELECT
             )
             ELSE '[]'::jsonb
         END
-    ) AS transaction_group_member_values
+    ) AS transaction_group_member_values,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'category', li.category,
+                                       'amount', li.amount,
+                                       'note', li.note
+                               )
+                               ORDER BY li.position
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_line_items li
+        WHERE li.transaction_id = t.id
+    ) AS line_items,
+    ARRAY(
+        SELECT tt.tag_id
+        FROM transaction_tags tt
+        WHERE tt.transaction_id = t.id
+        ORDER BY tt.tag_id
+    )::int[] AS tags,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'id', ta.id,
+                                       'name', ta.name,
+                                       'mime_type', ta.mime_type,
+                                       'size', ta.size,
+                                       'sha256', ta.sha256,
+                                       'created_at', ta.created_at
+                               )
+                               ORDER BY ta.id
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_attachments ta
+        WHERE ta.transaction_id = t.id
+    ) AS attachments,
+    t.status,
+    (
+        SELECT json_build_object(
+                   'recorded_by', ru.email,
+                   'state', ta.state,
+                   'dispute_reason', ta.dispute_reason
+               )::jsonb
+        FROM transaction_attributions ta
+            LEFT OUTER JOIN users ru ON ru.id = ta.recorded_by
+        WHERE ta.transaction_id = t.id
+    ) AS attribution,
+    t.payee_id AS payee,
+    t.version
 FROM transactions t
     LEFT OUTER JOIN financialincomes fi ON t.id = fi.transaction_id
     LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
     LEFT OUTER JOIN users u ON u.id = t.user_id
 WHERE t.user_id = sqlc.arg(user_id)
+  AND t.deleted_at IS NULL
 ORDER BY date DESC;
 
+-- name: ListTransactions :many
+WITH RECURSIVE selected_categories AS (
+    SELECT c.id
+    FROM categories c
+    WHERE c.user_id = sqlc.arg(user_id)
+      AND c.id = ANY(sqlc.arg(category_ids)::int[])
+    UNION
+    SELECT c.id
+    FROM categories c
+        JOIN selected_categories sc ON c.parent = sc.id
+    WHERE sqlc.arg(include_category_subtrees)::boolean
+      AND c.user_id = sqlc.arg(user_id)
+)
+SELECT
+    t.id,
+    COALESCE(u.email, 'TBD') as owner,
+    t.amount,
+    t.currency,
+    t.sender,
+    t.receiver,
+    t.category,
+    t.date,
+    t.note,
+    t.receiver_currency,
+    t.receiver_amount,
+    fi.related_currency_id,
+    ttg.transaction_group_id,
+    ttg.split_type_override as transaction_group_split_type_override,
+    (
+        CASE WHEN ttg.split_type_override IS NOT NULL
+            THEN (
+                SELECT COALESCE(
+                               json_agg(
+                                       json_build_object(
+                                               'user_email', ttgus.user_email,
+                                               'split_value', ttgus.split_value
+                                       )
+                               )::jsonb,
+                               '[]'::jsonb
+                       )
+                FROM transaction_transaction_group_user_split ttgus
+                WHERE ttgus.transaction_id = t.id
+            )
+            ELSE '[]'::jsonb
+        END
+    ) AS transaction_group_member_values,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'category', li.category,
+                                       'amount', li.amount,
+                                       'note', li.note
+                               )
+                               ORDER BY li.position
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_line_items li
+        WHERE li.transaction_id = t.id
+    ) AS line_items,
+    ARRAY(
+        SELECT tt.tag_id
+        FROM transaction_tags tt
+        WHERE tt.transaction_id = t.id
+        ORDER BY tt.tag_id
+    )::int[] AS tags,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'id', ta.id,
+                                       'name', ta.name,
+                                       'mime_type', ta.mime_type,
+                                       'size', ta.size,
+                                       'sha256', ta.sha256,
+                                       'created_at', ta.created_at
+                               )
+                               ORDER BY ta.id
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_attachments ta
+        WHERE ta.transaction_id = t.id
+    ) AS attachments,
+    t.status,
+    (
+        SELECT json_build_object(
+                   'recorded_by', ru.email,
+                   'state', ta.state,
+                   'dispute_reason', ta.dispute_reason
+               )::jsonb
+        FROM transaction_attributions ta
+            LEFT OUTER JOIN users ru ON ru.id = ta.recorded_by
+        WHERE ta.transaction_id = t.id
+    ) AS attribution,
+    t.payee_id AS payee,
+    t.version
+FROM transactions t
+    LEFT OUTER JOIN financialincomes fi ON t.id = fi.transaction_id
+    LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
+    LEFT OUTER JOIN users u ON u.id = t.user_id
+WHERE t.user_id = sqlc.arg(user_id)
+  AND t.deleted_at IS NULL
+  AND (sqlc.narg(from_date)::timestamptz IS NULL OR t.date >= sqlc.narg(from_date)::timestamptz)
+  AND (sqlc.narg(to_date)::timestamptz IS NULL OR t.date <= sqlc.narg(to_date)::timestamptz)
+  AND (
+      COALESCE(array_length(sqlc.arg(account_ids)::int[], 1), 0) = 0
+      OR t.sender = ANY(sqlc.arg(account_ids)::int[])
+      OR t.receiver = ANY(sqlc.arg(account_ids)::int[])
+  )
+  AND (
+      COALESCE(array_length(sqlc.arg(category_ids)::int[], 1), 0) = 0
+      OR t.category IN (SELECT id FROM selected_categories)
+  )
+  AND (
+      sqlc.narg(currency)::int IS NULL
+      OR t.currency = sqlc.narg(currency)::int
+      OR t.receiver_currency = sqlc.narg(currency)::int
+  )
+  AND (sqlc.narg(transaction_group_id)::int IS NULL OR ttg.transaction_group_id = sqlc.narg(transaction_group_id)::int)
+  AND (sqlc.narg(min_amount)::int IS NULL OR t.amount >= sqlc.narg(min_amount)::int)
+  AND (sqlc.narg(max_amount)::int IS NULL OR t.amount <= sqlc.narg(max_amount)::int)
+  AND (sqlc.narg(note_contains)::text IS NULL OR position(lower(sqlc.narg(note_contains)::text) in lower(t.note)) > 0)
+  AND (
+      COALESCE(array_length(sqlc.arg(tag_ids)::int[], 1), 0) = 0
+      OR EXISTS (
+          SELECT 1
+          FROM transaction_tags tt
+          WHERE tt.transaction_id = t.id
+            AND tt.tag_id = ANY(sqlc.arg(tag_ids)::int[])
+      )
+  )
+  AND (
+      sqlc.narg(cursor_date)::timestamptz IS NULL
+      OR (t.date, t.id) < (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int)
+  )
+ORDER BY t.date DESC, t.id DESC
+LIMIT sqlc.arg(page_size);
+
 -- name: CreateTransaction :one
-INSERT INTO transactions (user_id, amount, currency, sender, receiver, category, date, note, receiver_currency, receiver_amount)
+INSERT INTO transactions (user_id, amount, currency, sender, receiver, category, date, note, receiver_currency, receiver_amount, status, payee_id)
 VALUES (
            sqlc.arg(user_id),
            sqlc.arg(amount),
@@ -80,7 +274,9 @@ VALUES (
            sqlc.arg(date),
            sqlc.arg(note),
            sqlc.arg(receiver_currency),
-           sqlc.arg(receiver_amount)
+           sqlc.arg(receiver_amount),
+           sqlc.arg(status),
+           sqlc.narg(payee_id)
        )
 RETURNING id;
 
@@ -104,14 +300,171 @@ SET
     date = COALESCE(sqlc.narg(date), date),
     note = COALESCE(sqlc.narg(note), note),
     receiver_currency = COALESCE(sqlc.narg(receiver_currency), receiver_currency),
-    receiver_amount = COALESCE(sqlc.narg(receiver_amount), receiver_amount)
+    receiver_amount = COALESCE(sqlc.narg(receiver_amount), receiver_amount),
+    status = COALESCE(sqlc.narg(status), status),
+    payee_id = CASE
+        WHEN sqlc.arg(update_payee)::boolean THEN sqlc.narg(payee_id)
+        ELSE payee_id
+    END
 WHERE t.id = sqlc.arg(id)
   AND t.user_id = sqlc.arg(user_id)
+  AND t.deleted_at IS NULL
 RETURNING t.id;
 
--- name: DeleteTransaction :execrows
+-- name: TrashTransaction :execrows
+UPDATE transactions
+SET deleted_at = now()
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL;
+
+-- name: RestoreTransaction :execrows
+UPDATE transactions
+SET deleted_at = NULL
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND deleted_at IS NOT NULL;
+
+-- name: PurgeTransaction :execrows
 DELETE FROM transactions
-WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND deleted_at IS NOT NULL;
+
+-- name: PurgeTrashedTransactions :execrows
+DELETE FROM transactions
+WHERE deleted_at IS NOT NULL
+  AND deleted_at < sqlc.arg(trashed_before)::timestamptz;
+
+-- name: ListDeletedTransactions :many
+SELECT
+    t.id,
+    COALESCE(u.email, 'TBD') as owner,
+    t.amount,
+    t.currency,
+    t.sender,
+    t.receiver,
+    t.category,
+    t.date,
+    t.note,
+    t.receiver_currency,
+    t.receiver_amount,
+    fi.related_currency_id,
+    ttg.transaction_group_id,
+    ttg.split_type_override as transaction_group_split_type_override,
+    (
+        CASE WHEN ttg.split_type_override IS NOT NULL
+            THEN (
+                SELECT COALESCE(
+                               json_agg(
+                                       json_build_object(
+                                               'user_email', ttgus.user_email,
+                                               'split_value', ttgus.split_value
+                                       )
+                               )::jsonb,
+                               '[]'::jsonb
+                       )
+                FROM transaction_transaction_group_user_split ttgus
+                WHERE ttgus.transaction_id = t.id
+            )
+            ELSE '[]'::jsonb
+        END
+    ) AS transaction_group_member_values,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'category', li.category,
+                                       'amount', li.amount,
+                                       'note', li.note
+                               )
+                               ORDER BY li.position
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_line_items li
+        WHERE li.transaction_id = t.id
+    ) AS line_items,
+    ARRAY(
+        SELECT tt.tag_id
+        FROM transaction_tags tt
+        WHERE tt.transaction_id = t.id
+        ORDER BY tt.tag_id
+    )::int[] AS tags,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'id', ta.id,
+                                       'name', ta.name,
+                                       'mime_type', ta.mime_type,
+                                       'size', ta.size,
+                                       'sha256', ta.sha256,
+                                       'created_at', ta.created_at
+                               )
+                               ORDER BY ta.id
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_attachments ta
+        WHERE ta.transaction_id = t.id
+    ) AS attachments,
+    t.status,
+    (
+        SELECT json_build_object(
+                   'recorded_by', ru.email,
+                   'state', ta.state,
+                   'dispute_reason', ta.dispute_reason
+               )::jsonb
+        FROM transaction_attributions ta
+            LEFT OUTER JOIN users ru ON ru.id = ta.recorded_by
+        WHERE ta.transaction_id = t.id
+    ) AS attribution,
+    t.payee_id AS payee,
+    t.version,
+    t.deleted_at::timestamptz AS deleted_at
+FROM transactions t
+    LEFT OUTER JOIN financialincomes fi ON t.id = fi.transaction_id
+    LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
+    LEFT OUTER JOIN users u ON u.id = t.user_id
+WHERE t.user_id = sqlc.arg(user_id)
+  AND t.deleted_at IS NOT NULL
+ORDER BY t.deleted_at DESC, t.id DESC;
+
+-- name: DeleteTransactionLineItems :exec
+DELETE FROM transaction_line_items
+WHERE transaction_id = sqlc.arg(transaction_id);
+
+-- name: CreateTransactionLineItem :exec
+INSERT INTO transaction_line_items (transaction_id, position, category, amount, note)
+VALUES (
+           sqlc.arg(transaction_id),
+           sqlc.arg(position),
+           sqlc.narg(category),
+           sqlc.arg(amount),
+           sqlc.arg(note)
+       );
+
+-- name: DeleteTransactionTags :exec
+DELETE FROM transaction_tags
+WHERE transaction_id = sqlc.arg(transaction_id);
+
+-- name: AddTransactionTags :execrows
+INSERT INTO transaction_tags (transaction_id, tag_id)
+SELECT sqlc.arg(transaction_id), t.id
+FROM tags t
+WHERE t.id = ANY(sqlc.arg(tag_ids)::int[])
+  AND t.user_id = sqlc.arg(user_id);
+
+-- name: GetTransactionLineItemsTotal :one
+SELECT
+    t.amount,
+    COUNT(li.transaction_id)::int AS line_item_count,
+    COALESCE(SUM(li.amount), 0)::int AS line_item_total
+FROM transactions t
+    LEFT OUTER JOIN transaction_line_items li ON li.transaction_id = t.id
+WHERE t.id = sqlc.arg(transaction_id)
+GROUP BY t.id, t.amount;
 
 -- name: UpsertFinancialIncome :one
 INSERT INTO financialincomes as fi (transaction_id, related_currency_id)
@@ -186,3 +539,169 @@ returning transaction_id;
 DELETE FROM transaction_transaction_group_user_split
 WHERE transaction_id = sqlc.arg(transaction_id) AND user_email = sqlc.arg(user_email)
 returning user_email;
+
+-- name: SearchTransactions :many
+WITH search AS (
+    SELECT
+        websearch_to_tsquery('budgeteer_search', sqlc.arg(query)::text) AS q,
+        'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', HighlightAll=true' AS headline_options
+),
+matching_accounts AS (
+    SELECT a.id, a.name
+    FROM accounts a, search s
+    WHERE a.user_id = sqlc.arg(user_id)
+      AND to_tsvector('budgeteer_search', a.name) @@ s.q
+),
+matching_categories AS (
+    SELECT c.id, c.name
+    FROM categories c, search s
+    WHERE c.user_id = sqlc.arg(user_id)
+      AND to_tsvector('budgeteer_search', c.name) @@ s.q
+),
+matching_groups AS (
+    SELECT tg.id, COALESCE(utg.name_override, tg.name) AS name
+    FROM transaction_group tg
+        JOIN user_transaction_group utg ON utg.transaction_group_id = tg.id
+        JOIN users u ON u.email = utg.user_email,
+        search s
+    WHERE u.id = sqlc.arg(user_id)
+      AND to_tsvector('budgeteer_search', COALESCE(utg.name_override, tg.name)) @@ s.q
+)
+SELECT
+    t.id,
+    COALESCE(u.email, 'TBD') as owner,
+    t.amount,
+    t.currency,
+    t.sender,
+    t.receiver,
+    t.category,
+    t.date,
+    t.note,
+    t.receiver_currency,
+    t.receiver_amount,
+    fi.related_currency_id,
+    ttg.transaction_group_id,
+    ttg.split_type_override as transaction_group_split_type_override,
+    (
+        CASE WHEN ttg.split_type_override IS NOT NULL
+            THEN (
+                SELECT COALESCE(
+                               json_agg(
+                                       json_build_object(
+                                               'user_email', ttgus.user_email,
+                                               'split_value', ttgus.split_value
+                                       )
+                               )::jsonb,
+                               '[]'::jsonb
+                       )
+                FROM transaction_transaction_group_user_split ttgus
+                WHERE ttgus.transaction_id = t.id
+            )
+            ELSE '[]'::jsonb
+        END
+    ) AS transaction_group_member_values,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'category', li.category,
+                                       'amount', li.amount,
+                                       'note', li.note
+                               )
+                               ORDER BY li.position
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_line_items li
+        WHERE li.transaction_id = t.id
+    ) AS line_items,
+    ARRAY(
+        SELECT tt.tag_id
+        FROM transaction_tags tt
+        WHERE tt.transaction_id = t.id
+        ORDER BY tt.tag_id
+    )::int[] AS tags,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'id', ta.id,
+                                       'name', ta.name,
+                                       'mime_type', ta.mime_type,
+                                       'size', ta.size,
+                                       'sha256', ta.sha256,
+                                       'created_at', ta.created_at
+                               )
+                               ORDER BY ta.id
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_attachments ta
+        WHERE ta.transaction_id = t.id
+    ) AS attachments,
+    t.status,
+    (
+        SELECT json_build_object(
+                   'recorded_by', ru.email,
+                   'state', ta.state,
+                   'dispute_reason', ta.dispute_reason
+               )::jsonb
+        FROM transaction_attributions ta
+            LEFT OUTER JOIN users ru ON ru.id = ta.recorded_by
+        WHERE ta.transaction_id = t.id
+    ) AS attribution,
+    t.payee_id AS payee,
+    t.version,
+    (
+        ts_rank(to_tsvector('budgeteer_search', t.note), s.q)
+        + CASE WHEN sender_match.id IS NOT NULL OR receiver_match.id IS NOT NULL THEN 0.5 ELSE 0 END
+        + CASE WHEN category_match.id IS NOT NULL THEN 0.3 ELSE 0 END
+        + CASE WHEN group_match.id IS NOT NULL THEN 0.3 ELSE 0 END
+    )::real AS rank,
+    COALESCE(CASE WHEN to_tsvector('budgeteer_search', t.note) @@ s.q
+        THEN ts_headline('budgeteer_search', t.note, s.q, s.headline_options) END, '')::text AS note_highlight,
+    COALESCE(ts_headline('budgeteer_search', sender_match.name, s.q, s.headline_options), '')::text AS sender_highlight,
+    COALESCE(ts_headline('budgeteer_search', receiver_match.name, s.q, s.headline_options), '')::text AS receiver_highlight,
+    COALESCE(ts_headline('budgeteer_search', category_match.name, s.q, s.headline_options), '')::text AS category_highlight,
+    COALESCE(ts_headline('budgeteer_search', group_match.name, s.q, s.headline_options), '')::text AS transaction_group_highlight
+FROM transactions t
+    CROSS JOIN search s
+    LEFT OUTER JOIN financialincomes fi ON t.id = fi.transaction_id
+    LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
+    LEFT OUTER JOIN users u ON u.id = t.user_id
+    LEFT OUTER JOIN matching_accounts sender_match ON sender_match.id = t.sender
+    LEFT OUTER JOIN matching_accounts receiver_match ON receiver_match.id = t.receiver
+    LEFT OUTER JOIN matching_categories category_match ON category_match.id = t.category
+    LEFT OUTER JOIN matching_groups group_match ON group_match.id = ttg.transaction_group_id
+WHERE t.user_id = sqlc.arg(user_id)
+  AND t.deleted_at IS NULL
+  AND (
+      to_tsvector('budgeteer_search', t.note) @@ s.q
+      OR sender_match.id IS NOT NULL
+      OR receiver_match.id IS NOT NULL
+      OR category_match.id IS NOT NULL
+      OR group_match.id IS NOT NULL
+  )
+ORDER BY rank DESC, t.date DESC, t.id DESC
+LIMIT sqlc.arg(page_size)
+OFFSET sqlc.arg(page_offset);
+
+-- name: CheckTransactionReferences :one
+-- Reports, for the accounts and category a transaction refers to, whether
+-- they are missing or belong to the user, and how many of its currencies do.
+SELECT
+    (sqlc.narg(sender_id)::int IS NULL OR EXISTS (
+        SELECT 1 FROM accounts a WHERE a.id = sqlc.narg(sender_id) AND a.user_id = sqlc.arg(user_id)
+    ))::boolean AS sender_found,
+    (sqlc.narg(receiver_id)::int IS NULL OR EXISTS (
+        SELECT 1 FROM accounts a WHERE a.id = sqlc.narg(receiver_id) AND a.user_id = sqlc.arg(user_id)
+    ))::boolean AS receiver_found,
+    (sqlc.narg(category_id)::int IS NULL OR EXISTS (
+        SELECT 1 FROM categories c WHERE c.id = sqlc.narg(category_id) AND c.user_id = sqlc.arg(user_id)
+    ))::boolean AS category_found,
+    (
+        SELECT count(*)
+        FROM currencies c
+        WHERE c.id = ANY(sqlc.arg(currency_ids)::int[])
+          AND c.user_id = sqlc.arg(user_id)
+    )::int AS currencies_found;
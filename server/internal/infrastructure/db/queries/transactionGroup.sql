@@ -8,6 +8,7 @@ SELECT
     utg.currency_id,
     utg.split_value,
     utg.hidden,
+    tg.version,
     (
         SELECT json_agg(
                        json_build_object(
@@ -99,6 +100,16 @@ SET
     split_type = COALESCE(sqlc.narg(split_type), split_type)
 WHERE tg2.id = sqlc.arg(transaction_group_id);
 
+-- name: GetTransactionGroupVersion :one
+-- Locks the transaction group until the end of the transaction, so it cannot
+-- change between the version check and the update.
+SELECT tg.version
+FROM transaction_group tg
+         JOIN user_transaction_group utg ON utg.transaction_group_id = tg.id
+WHERE tg.id = sqlc.arg(transaction_group_id)
+  AND utg.user_email = sqlc.arg(user_email)
+FOR UPDATE OF tg;
+
 
 
 -- name: UpsertTransactionGroupMember :execrows
@@ -0,0 +1,37 @@
+-- name: GetAttributionPayer :one
+SELECT u.id, u.hidden_default_account
+FROM users u
+    JOIN user_transaction_group payer_utg ON payer_utg.user_email = u.email
+    JOIN user_transaction_group recorder_utg ON recorder_utg.transaction_group_id = payer_utg.transaction_group_id
+    JOIN users recorder ON recorder.email = recorder_utg.user_email
+WHERE u.email = sqlc.arg(payer_email)
+  AND payer_utg.transaction_group_id = sqlc.arg(transaction_group_id)
+  AND payer_utg.locked
+  AND recorder.id = sqlc.arg(recorder_id)
+  AND recorder_utg.locked;
+
+-- name: GetMatchingCurrency :one
+SELECT pc.id
+FROM currencies c
+    JOIN currencies pc ON pc.name = c.name
+WHERE c.id = sqlc.arg(currency_id)
+  AND c.user_id = sqlc.arg(user_id)
+  AND pc.user_id = sqlc.arg(payer_id);
+
+-- name: CreateTransactionAttribution :exec
+INSERT INTO transaction_attributions (transaction_id, recorded_by)
+VALUES (sqlc.arg(transaction_id), sqlc.arg(recorded_by));
+
+-- name: SetTransactionAttributionState :one
+UPDATE transaction_attributions ta
+SET state          = sqlc.arg(state),
+    dispute_reason = sqlc.arg(dispute_reason),
+    updated_at     = now()
+FROM transactions t,
+     transaction_attributions previous
+WHERE ta.transaction_id = t.id
+  AND previous.transaction_id = ta.transaction_id
+  AND t.id = sqlc.arg(transaction_id)
+  AND t.user_id = sqlc.arg(user_id)
+  AND t.deleted_at IS NULL
+RETURNING previous.state;
@@ -0,0 +1,127 @@
+-- name: GetTransactionSnapshot :one
+SELECT
+    t.id,
+    COALESCE(u.email, 'TBD') as owner,
+    t.amount,
+    t.currency,
+    t.sender,
+    t.receiver,
+    t.category,
+    t.date,
+    t.note,
+    t.receiver_currency,
+    t.receiver_amount,
+    fi.related_currency_id,
+    ttg.transaction_group_id,
+    ttg.split_type_override as transaction_group_split_type_override,
+    (
+        CASE WHEN ttg.split_type_override IS NOT NULL
+            THEN (
+                SELECT COALESCE(
+                               json_agg(
+                                       json_build_object(
+                                               'user_email', ttgus.user_email,
+                                               'split_value', ttgus.split_value
+                                       )
+                               )::jsonb,
+                               '[]'::jsonb
+                       )
+                FROM transaction_transaction_group_user_split ttgus
+                WHERE ttgus.transaction_id = t.id
+            )
+            ELSE '[]'::jsonb
+        END
+    ) AS transaction_group_member_values,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'category', li.category,
+                                       'amount', li.amount,
+                                       'note', li.note
+                               )
+                               ORDER BY li.position
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_line_items li
+        WHERE li.transaction_id = t.id
+    ) AS line_items,
+    ARRAY(
+        SELECT tt.tag_id
+        FROM transaction_tags tt
+        WHERE tt.transaction_id = t.id
+        ORDER BY tt.tag_id
+    )::int[] AS tags,
+    (
+        SELECT COALESCE(
+                       json_agg(
+                               json_build_object(
+                                       'id', ta.id,
+                                       'name', ta.name,
+                                       'mime_type', ta.mime_type,
+                                       'size', ta.size,
+                                       'sha256', ta.sha256,
+                                       'created_at', ta.created_at
+                               )
+                               ORDER BY ta.id
+                       )::jsonb,
+                       '[]'::jsonb
+               )
+        FROM transaction_attachments ta
+        WHERE ta.transaction_id = t.id
+    ) AS attachments,
+    t.status,
+    (
+        SELECT json_build_object(
+                   'recorded_by', ru.email,
+                   'state', ta.state,
+                   'dispute_reason', ta.dispute_reason
+               )::jsonb
+        FROM transaction_attributions ta
+            LEFT OUTER JOIN users ru ON ru.id = ta.recorded_by
+        WHERE ta.transaction_id = t.id
+    ) AS attribution,
+    t.payee_id AS payee,
+    t.version
+FROM transactions t
+    LEFT OUTER JOIN financialincomes fi ON t.id = fi.transaction_id
+    LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
+    LEFT OUTER JOIN users u ON u.id = t.user_id
+WHERE t.id = sqlc.arg(id)
+  AND t.user_id = sqlc.arg(user_id)
+FOR UPDATE OF t;
+
+-- name: CreateTransactionHistoryEntry :exec
+INSERT INTO transaction_history (transaction_id, changed_by, action, changes)
+VALUES (sqlc.arg(transaction_id), sqlc.arg(changed_by), sqlc.arg(action), sqlc.arg(changes));
+
+-- name: CanViewTransaction :one
+SELECT EXISTS (
+    SELECT 1
+    FROM transactions t
+        LEFT OUTER JOIN transaction_transaction_group ttg ON t.id = ttg.transaction_id
+    WHERE t.id = sqlc.arg(transaction_id)
+      AND (
+        t.user_id = sqlc.arg(user_id)
+        OR EXISTS (
+            SELECT 1
+            FROM user_transaction_group utg
+                JOIN users u ON u.email = utg.user_email
+            WHERE utg.transaction_group_id = ttg.transaction_group_id
+              AND u.id = sqlc.arg(user_id)
+        )
+      )
+)::boolean AS can_view;
+
+-- name: GetTransactionHistory :many
+SELECT
+    h.id,
+    u.email AS changed_by,
+    h.changed_at,
+    h.action,
+    h.changes
+FROM transaction_history h
+    LEFT OUTER JOIN users u ON u.id = h.changed_by
+WHERE h.transaction_id = sqlc.arg(transaction_id)
+ORDER BY h.id;
@@ -34,6 +34,16 @@ ON CONFLICT (oidc_sub)
 RETURNING u.id;
 
 -- name: GetUserParams :one
-SELECT default_currency, hidden_default_account, username
+SELECT default_currency, hidden_default_account, username, timezone
 FROM users
 WHERE id = sqlc.arg(user_id);
+
+-- name: GetUserTimezone :one
+SELECT timezone
+FROM users
+WHERE id = sqlc.arg(user_id);
+
+-- name: SetUserTimezone :execrows
+UPDATE users
+SET timezone = sqlc.arg(timezone)
+WHERE id = sqlc.arg(user_id);
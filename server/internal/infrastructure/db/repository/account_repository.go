@@ -7,6 +7,7 @@ import (
 
 	"chagnon.dev/budget-server/internal/domain/model"
 	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
 	"github.com/google/uuid"
 )
 
@@ -64,6 +65,7 @@ func (r *Repository) GetAllAccountsWithCurrencyIDs(ctx context.Context, userId u
 			IsMine:               accountDao.IsMine,
 			Type:                 accountType,
 			FinancialInstitution: financialInstitution,
+			Version:              int(accountDao.Version),
 		}
 	}
 
@@ -138,6 +140,9 @@ type UpdateAccountFields struct {
 	InitialsAmounts                   *[]model.Balance
 	IsMine                            *bool
 	AccountType, FinancialInstitution *string
+	// ExpectedVersion, when set, refuses the update with ErrVersionConflict
+	// if the account is no longer at that version.
+	ExpectedVersion *int
 }
 
 func (u *UpdateAccountFields) nullName() sql.NullString {
@@ -189,14 +194,34 @@ func (r *Repository) UpdateAccount(
 	userId uuid.UUID,
 	id model.AccountID,
 	fields UpdateAccountFields,
-) error {
+) (err error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("account update rollback error: %v", rbErr))
+			}
+		}
+	}()
+
 	queries := r.queries.WithTx(tx)
 
+	if fields.ExpectedVersion != nil {
+		err = checkVersion(*fields.ExpectedVersion, ErrAccountNotFound, func() (int32, error) {
+			return queries.GetAccountVersion(ctx, &dao.GetAccountVersionParams{
+				ID:     int32(id),
+				UserID: userId,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	err = queries.UpdateAccount(
 		ctx, &dao.UpdateAccountParams{
 			UserID:                     userId,
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAttachmentNotFound      = errors.New("attachment not found")
+	ErrAttachmentQuotaExceeded = errors.New("attachment storage quota exceeded")
+)
+
+type NewAttachmentFields struct {
+	Name       string
+	MimeType   string
+	Size       int64
+	SHA256     string
+	StorageKey string
+}
+
+// StoredAttachment is an attachment along with the key of its bytes in the
+// blob store, which never leaves the server.
+type StoredAttachment struct {
+	model.Attachment
+	TransactionID model.TransactionID
+	StorageKey    string
+}
+
+// CreateAttachment records an uploaded file against one of the user's
+// transactions, unless it would take the user's attachments over quota bytes.
+// Creations are serialized per user so that concurrent uploads cannot both
+// squeeze under the quota.
+func (r *Repository) CreateAttachment(
+	ctx context.Context,
+	userId uuid.UUID,
+	transactionId model.TransactionID,
+	fields NewAttachmentFields,
+	quota int64,
+) (attachmentId model.AttachmentID, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("attachment creation rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	if err = queries.LockUserAttachments(ctx, userId.String()); err != nil {
+		err = fmt.Errorf("locking user attachments: %w", err)
+		return
+	}
+
+	attachable, err := queries.IsTransactionAttachable(ctx, &dao.IsTransactionAttachableParams{
+		TransactionID: int32(transactionId),
+		UserID:        userId,
+	})
+	if err != nil {
+		err = fmt.Errorf("checking transaction: %w", err)
+		return
+	}
+	if !attachable {
+		err = ErrTransactionNotFound
+		return
+	}
+
+	usage, err := queries.GetAttachmentUsage(ctx, userId)
+	if err != nil {
+		err = fmt.Errorf("getting attachment usage: %w", err)
+		return
+	}
+	if usage+fields.Size > quota {
+		err = ErrAttachmentQuotaExceeded
+		return
+	}
+
+	id, err := queries.CreateAttachment(ctx, &dao.CreateAttachmentParams{
+		UserID:        userId,
+		TransactionID: sql.NullInt32{Valid: true, Int32: int32(transactionId)},
+		Name:          fields.Name,
+		MimeType:      fields.MimeType,
+		Size:          fields.Size,
+		Sha256:        fields.SHA256,
+		StorageKey:    fields.StorageKey,
+	})
+	if err != nil {
+		err = fmt.Errorf("creating attachment: %w", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("committing transaction: %w", err)
+		return
+	}
+
+	return model.AttachmentID(id), nil
+}
+
+func (r *Repository) GetAttachment(ctx context.Context, userId uuid.UUID, id model.AttachmentID) (StoredAttachment, error) {
+	attachmentDao, err := r.queries.GetAttachment(ctx, &dao.GetAttachmentParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return StoredAttachment{}, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return StoredAttachment{}, fmt.Errorf("getting attachment: %w", err)
+	}
+
+	return StoredAttachment{
+		Attachment: model.Attachment{
+			ID:        model.AttachmentID(attachmentDao.ID),
+			Name:      attachmentDao.Name,
+			MimeType:  attachmentDao.MimeType,
+			Size:      attachmentDao.Size,
+			SHA256:    attachmentDao.Sha256,
+			CreatedAt: attachmentDao.CreatedAt,
+		},
+		TransactionID: model.TransactionID(attachmentDao.TransactionID.Int32),
+		StorageKey:    attachmentDao.StorageKey,
+	}, nil
+}
+
+// DetachAttachment removes the attachment from its transaction and returns the
+// key of its bytes. The row stays until the bytes are deleted, through
+// DeleteDetachedAttachment, so that they are never left without a trace.
+func (r *Repository) DetachAttachment(ctx context.Context, userId uuid.UUID, id model.AttachmentID) (string, error) {
+	storageKey, err := r.queries.DetachAttachment(ctx, &dao.DetachAttachmentParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrAttachmentNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("detaching attachment: %w", err)
+	}
+
+	return storageKey, nil
+}
+
+// DetachedAttachment is an attachment whose transaction was deleted or that
+// was removed, waiting for its bytes to be deleted.
+type DetachedAttachment struct {
+	ID         model.AttachmentID
+	StorageKey string
+}
+
+func (r *Repository) GetDetachedAttachments(ctx context.Context, maxCount int) ([]DetachedAttachment, error) {
+	rows, err := r.queries.GetDetachedAttachments(ctx, int32(maxCount))
+	if err != nil {
+		return nil, fmt.Errorf("getting detached attachments: %w", err)
+	}
+
+	attachments := make([]DetachedAttachment, len(rows))
+	for i, row := range rows {
+		attachments[i] = DetachedAttachment{
+			ID:         model.AttachmentID(row.ID),
+			StorageKey: row.StorageKey,
+		}
+	}
+
+	return attachments, nil
+}
+
+func (r *Repository) DeleteDetachedAttachment(ctx context.Context, id model.AttachmentID) error {
+	if err := r.queries.DeleteDetachedAttachment(ctx, int32(id)); err != nil {
+		return fmt.Errorf("deleting detached attachment: %w", err)
+	}
+
+	return nil
+}
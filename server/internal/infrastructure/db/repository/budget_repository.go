@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"github.com/google/uuid"
+)
+
+var ErrBudgetNotFound = errors.New("budget not found")
+
+func BudgetPeriodFromDao(period dao.BudgetPeriod) (model.BudgetPeriod, error) {
+	switch period {
+	case dao.BudgetPeriodMONTHLY:
+		return model.BudgetPeriodMonthly, nil
+	case dao.BudgetPeriodWEEKLY:
+		return model.BudgetPeriodWeekly, nil
+	case dao.BudgetPeriodYEARLY:
+		return model.BudgetPeriodYearly, nil
+	default:
+		return model.BudgetPeriodMonthly, fmt.Errorf("unknown BudgetPeriod %s", period)
+	}
+}
+
+func BudgetPeriodToDao(period model.BudgetPeriod) (dao.BudgetPeriod, error) {
+	switch period {
+	case model.BudgetPeriodMonthly:
+		return dao.BudgetPeriodMONTHLY, nil
+	case model.BudgetPeriodWeekly:
+		return dao.BudgetPeriodWEEKLY, nil
+	case model.BudgetPeriodYearly:
+		return dao.BudgetPeriodYEARLY, nil
+	default:
+		return dao.BudgetPeriodMONTHLY, fmt.Errorf("unknown BudgetPeriod %d", period)
+	}
+}
+
+func (r *Repository) GetAllBudgets(ctx context.Context, userId uuid.UUID) ([]model.Budget, error) {
+	budgetsDao, err := r.queries.GetAllBudgets(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	budgets := make([]model.Budget, len(budgetsDao))
+	for i, budgetDao := range budgetsDao {
+		period, err := BudgetPeriodFromDao(budgetDao.Period)
+		if err != nil {
+			return nil, err
+		}
+
+		budgets[i] = model.Budget{
+			ID:       model.BudgetID(budgetDao.ID),
+			Category: model.CategoryID(budgetDao.CategoryID),
+			Period:   period,
+			StartsOn: budgetDao.StartsOn,
+			Amount:   int(budgetDao.Amount),
+			Currency: model.CurrencyID(budgetDao.CurrencyID),
+		}
+	}
+
+	return budgets, nil
+}
+
+// SetBudget creates the budget of a category for the periods from
+// budget.StartsOn on, or replaces the one starting on the same period.
+func (r *Repository) SetBudget(ctx context.Context, userId uuid.UUID, budget model.Budget) (model.BudgetID, error) {
+	period, err := BudgetPeriodToDao(budget.Period)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := r.queries.SetBudget(ctx, &dao.SetBudgetParams{
+		UserID:     userId,
+		CategoryID: int32(budget.Category),
+		Period:     period,
+		StartsOn:   budget.StartsOn,
+		Amount:     int32(budget.Amount),
+		CurrencyID: int32(budget.Currency),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, r.missingBudgetTarget(ctx, userId, budget.Category)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("setting budget: %w", err)
+	}
+
+	return model.BudgetID(id), nil
+}
+
+// missingBudgetTarget tells which of the category or the currency of a budget
+// kept it from being written.
+func (r *Repository) missingBudgetTarget(ctx context.Context, userId uuid.UUID, category model.CategoryID) error {
+	categoryExists, err := r.queries.CategoryExists(ctx, &dao.CategoryExistsParams{
+		ID:     int32(category),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("checking budget category: %w", err)
+	}
+	if !categoryExists {
+		return ErrCategoryNotFound
+	}
+
+	return ErrCurrencyNotFound
+}
+
+// foldCategoryBudgets adds the budgets of removed categories to those of
+// their replacement, period by period: from every day one of them starts on,
+// the replacement plans the sum of what they all planned then.
+func foldCategoryBudgets(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	removed []int32,
+	replacementId model.CategoryID,
+) error {
+	folded, err := queries.GetFoldedBudgets(ctx, &dao.GetFoldedBudgetsParams{
+		CategoryIds:   removed,
+		ReplacementID: int32(replacementId),
+	})
+	if err != nil {
+		return fmt.Errorf("folding budgets: %w", err)
+	}
+
+	for _, budget := range folded {
+		if budget.Currencies > 1 {
+			return ErrBudgetsConflict
+		}
+	}
+
+	for _, budget := range folded {
+		_, err = queries.SetBudget(ctx, &dao.SetBudgetParams{
+			UserID:     userId,
+			CategoryID: int32(replacementId),
+			Period:     budget.Period,
+			StartsOn:   budget.StartsOn,
+			Amount:     budget.Amount,
+			CurrencyID: budget.CurrencyID,
+		})
+		if err != nil {
+			return fmt.Errorf("setting folded budget: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteBudget(ctx context.Context, userId uuid.UUID, id model.BudgetID) error {
+	deleted, err := r.queries.DeleteBudget(ctx, &dao.DeleteBudgetParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting budget: %w", err)
+	}
+	if deleted == 0 {
+		return ErrBudgetNotFound
+	}
+
+	return nil
+}
+
+// GetCategorySpending sums what was spent on each category between from and
+// to, by currency and by day of the location.
+func (r *Repository) GetCategorySpending(
+	ctx context.Context,
+	userId uuid.UUID,
+	from, to time.Time,
+	location *time.Location,
+) ([]model.CategorySpending, error) {
+	spendingDao, err := r.queries.GetCategorySpending(ctx, &dao.GetCategorySpendingParams{
+		Timezone: location.String(),
+		UserID:   userId,
+		FromDate: from,
+		ToDate:   to,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting category spending: %w", err)
+	}
+
+	return categorySpendingFromDao(spendingDao), nil
+}
+
+func categorySpendingFromDao(spendingDao []dao.GetCategorySpendingRow) []model.CategorySpending {
+	spending := make([]model.CategorySpending, len(spendingDao))
+	for i, row := range spendingDao {
+		spending[i] = model.CategorySpending{
+			Category: nullCategoryToModel(row.Category),
+			Currency: model.CurrencyID(row.Currency),
+			Day:      row.Day,
+			Amount:   row.Amount,
+		}
+	}
+
+	return spending
+}
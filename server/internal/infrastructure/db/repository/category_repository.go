@@ -3,18 +3,37 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	"chagnon.dev/budget-server/internal/domain/model"
 	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
 	"github.com/google/uuid"
 )
 
+var (
+	ErrRootCategory          = errors.New("the root category cannot be moved or removed")
+	ErrReplacedBySubcategory = errors.New("a category cannot be replaced by itself or a subcategory that goes away with it")
+	ErrCategoryCycle         = errors.New("a category cannot be moved under itself or one of its subcategories")
+	ErrSiblingNotFound       = errors.New("the sibling is not a child of the new parent")
+	// ErrBudgetsConflict is returned when the budgets or envelopes of removed
+	// categories cannot be folded into those of their replacement.
+	ErrBudgetsConflict = errors.New("the budgets or envelopes of the categories cannot be combined with those of the replacement")
+)
+
 func (r *Repository) GetAllCategories(ctx context.Context, userId uuid.UUID) ([]model.Category, error) {
 	categoriesDao, err := r.queries.GetAllCategories(ctx, userId)
 	if err != nil {
 		return nil, err
 	}
 
+	return categoriesFromDao(categoriesDao), nil
+}
+
+func categoriesFromDao(categoriesDao []dao.GetAllCategoriesRow) []model.Category {
 	categories := make([]model.Category, len(categoriesDao))
 	for i, categoryDao := range categoriesDao {
 		var parentId model.CategoryID
@@ -31,10 +50,11 @@ func (r *Repository) GetAllCategories(ctx context.Context, userId uuid.UUID) ([]
 			IconBackground: categoryDao.IconBackground,
 			FixedCost:      categoryDao.FixedCosts,
 			Ordering:       categoryDao.Ordering,
+			Version:        int(categoryDao.Version),
 		}
 	}
 
-	return categories, nil
+	return categories
 }
 
 func (r *Repository) CreateCategory(
@@ -72,6 +92,9 @@ type UpdateCategoryFields struct {
 	ParentId                                  *int
 	FixedCosts                                *bool
 	Ordering                                  *float64
+	// ExpectedVersion, when set, refuses the update with ErrVersionConflict
+	// if the category is no longer at that version.
+	ExpectedVersion *int
 }
 
 func (u *UpdateCategoryFields) nullName() sql.NullString {
@@ -156,8 +179,46 @@ func (r *Repository) UpdateCategory(
 	userId uuid.UUID,
 	id model.CategoryID,
 	fields UpdateCategoryFields,
-) error {
-	return r.queries.UpdateCategory(
+) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("category update rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	if fields.ExpectedVersion != nil {
+		err = checkVersion(*fields.ExpectedVersion, ErrCategoryNotFound, func() (int32, error) {
+			return queries.GetCategoryVersion(ctx, &dao.GetCategoryVersionParams{
+				ID:     int32(id),
+				UserID: userId,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if fields.ParentId != nil && *fields.ParentId != 0 {
+		if err = lockCategoryTree(ctx, queries, userId); err != nil {
+			return err
+		}
+
+		err = checkCategoryParent(ctx, queries, userId, id, model.CategoryID(*fields.ParentId))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = queries.UpdateCategory(
 		ctx, &dao.UpdateCategoryParams{
 			UserID:         userId,
 			ID:             int32(id),
@@ -171,4 +232,528 @@ func (r *Repository) UpdateCategory(
 			Ordering:       fields.nullOrdering(),
 		},
 	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// childCategories says what happens to the children of a removed category.
+type childCategories int
+
+const (
+	// moveChildrenUp re-parents the children onto the parent of the removed
+	// category.
+	moveChildrenUp childCategories = iota
+	// moveChildrenToReplacement re-parents the children onto the category
+	// replacing the removed one.
+	moveChildrenToReplacement
+	// removeChildren removes the whole subtree along with the category.
+	removeChildren
+)
+
+// DeleteCategory deletes a category, filing everything that referred to it
+// under replacementId. Its children move up to its parent, unless
+// deleteChildren is set, in which case they are deleted too and what referred
+// to them goes to replacementId as well.
+func (r *Repository) DeleteCategory(
+	ctx context.Context,
+	userId uuid.UUID,
+	id, replacementId model.CategoryID,
+	deleteChildren bool,
+) error {
+	children := moveChildrenUp
+	if deleteChildren {
+		children = removeChildren
+	}
+
+	return r.removeCategory(ctx, userId, id, replacementId, children)
+}
+
+// MergeCategories folds sourceId into targetId: everything that referred to
+// the source, its children included, now belongs to the target, and the
+// source is deleted.
+func (r *Repository) MergeCategories(ctx context.Context, userId uuid.UUID, sourceId, targetId model.CategoryID) error {
+	return r.removeCategory(ctx, userId, sourceId, targetId, moveChildrenToReplacement)
+}
+
+func (r *Repository) removeCategory(
+	ctx context.Context,
+	userId uuid.UUID,
+	id, replacementId model.CategoryID,
+	children childCategories,
+) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("category removal rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	if err = lockCategoryTree(ctx, queries, userId); err != nil {
+		return err
+	}
+
+	parent, err := queries.LockCategory(ctx, &dao.LockCategoryParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCategoryNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("locking category: %w", err)
+	}
+	if !parent.Valid {
+		return ErrRootCategory
+	}
+
+	replacementExists, err := queries.CategoryExists(ctx, &dao.CategoryExistsParams{
+		ID:     int32(replacementId),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("checking replacement category: %w", err)
+	}
+	if !replacementExists {
+		return ErrCategoryNotFound
+	}
+
+	subtree, err := queries.GetCategorySubtree(ctx, &dao.GetCategorySubtreeParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("getting category subtree: %w", err)
+	}
+
+	removed := []int32{int32(id)}
+	if children == removeChildren {
+		removed = subtree
+	}
+
+	// Children moving onto the replacement would end up their own ancestors
+	// if the replacement was one of them.
+	unavailable := removed
+	if children == moveChildrenToReplacement {
+		unavailable = subtree
+	}
+	if slices.Contains(unavailable, int32(replacementId)) {
+		return ErrReplacedBySubcategory
+	}
+
+	// Budgets and envelopes go away with their category, so what they held
+	// is added to those of the replacement first.
+	if err = foldCategoryBudgets(ctx, queries, userId, removed, replacementId); err != nil {
+		return err
+	}
+
+	if err = foldCategoryEnvelopes(ctx, queries, removed, replacementId); err != nil {
+		return err
+	}
+
+	if children != removeChildren {
+		newParent := parent.Int32
+		if children == moveChildrenToReplacement {
+			newParent = int32(replacementId)
+		}
+
+		err = queries.ReparentCategoryChildren(ctx, &dao.ReparentCategoryChildrenParams{
+			NewParent: newParent,
+			ID:        int32(id),
+			UserID:    userId,
+		})
+		if err != nil {
+			return fmt.Errorf("re-parenting child categories: %w", err)
+		}
+	}
+
+	err = queries.ReassignCategories(ctx, &dao.ReassignCategoriesParams{
+		ReplacementID: int32(replacementId),
+		CategoryIds:   removed,
+	})
+	if err != nil {
+		return fmt.Errorf("reassigning categories: %w", err)
+	}
+
+	err = queries.DeleteCategories(ctx, &dao.DeleteCategoriesParams{
+		Ids:    removed,
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting categories: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// lockCategoryTree serializes the changes to the shape of the category tree
+// of a user by locking its root. Without it, two moves checked against the
+// tree as it was before either of them could together commit a cycle.
+func lockCategoryTree(ctx context.Context, queries *dao.Queries, userId uuid.UUID) error {
+	_, err := queries.LockRootCategory(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("locking root category: %w", err)
+	}
+
+	return nil
+}
+
+// checkCategoryParent makes sure parentId is a category of the user that can
+// take id as a child without creating a cycle.
+func checkCategoryParent(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	id, parentId model.CategoryID,
+) error {
+	parentExists, err := queries.CategoryExists(ctx, &dao.CategoryExistsParams{
+		ID:     int32(parentId),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("checking parent category: %w", err)
+	}
+	if !parentExists {
+		return ErrCategoryNotFound
+	}
+
+	subtree, err := queries.GetCategorySubtree(ctx, &dao.GetCategorySubtreeParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("getting category subtree: %w", err)
+	}
+	if slices.Contains(subtree, int32(parentId)) {
+		return ErrCategoryCycle
+	}
+
+	return nil
+}
+
+// CategoryPosition places a moved category among its new siblings. With
+// neither set, the category goes last.
+type CategoryPosition struct {
+	Before, After model.Optional[model.CategoryID]
+	// ExpectedVersion, when set, refuses the move with ErrVersionConflict if
+	// the category is no longer at that version.
+	ExpectedVersion *int
+}
+
+const (
+	// categoryOrderingStep is the distance between siblings after they are
+	// rebalanced, and the one a category placed first or last keeps from its
+	// neighbour.
+	categoryOrderingStep = 1024.0
+	// minCategoryOrderingGap is the smallest distance between two siblings
+	// that is still split in two; below it, the siblings are rebalanced.
+	minCategoryOrderingGap = 1e-6
+)
+
+// MoveCategory moves a category under parentId, at the given position among
+// the children of parentId. When there is no room left between the siblings
+// it goes in between, their orderings are spread out again.
+func (r *Repository) MoveCategory(
+	ctx context.Context,
+	userId uuid.UUID,
+	id, parentId model.CategoryID,
+	position CategoryPosition,
+) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("category move rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	if err = lockCategoryTree(ctx, queries, userId); err != nil {
+		return err
+	}
+
+	parent, err := queries.LockCategory(ctx, &dao.LockCategoryParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCategoryNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("locking category: %w", err)
+	}
+	if !parent.Valid {
+		return ErrRootCategory
+	}
+
+	if position.ExpectedVersion != nil {
+		err = checkVersion(*position.ExpectedVersion, ErrCategoryNotFound, func() (int32, error) {
+			return queries.GetCategoryVersion(ctx, &dao.GetCategoryVersionParams{
+				ID:     int32(id),
+				UserID: userId,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = checkCategoryParent(ctx, queries, userId, id, parentId)
+	if err != nil {
+		return err
+	}
+
+	siblings, err := queries.GetSiblingCategories(ctx, &dao.GetSiblingCategoriesParams{
+		Parent:  int32(parentId),
+		UserID:  userId,
+		MovedID: int32(id),
+	})
+	if err != nil {
+		return fmt.Errorf("getting sibling categories: %w", err)
+	}
+
+	index, err := categoryInsertionIndex(siblings, position)
+	if err != nil {
+		return err
+	}
+
+	ordering, fits := orderingBetween(siblings, index)
+	if !fits {
+		err = rebalanceCategoryOrderings(ctx, queries, userId, siblings, index)
+		if err != nil {
+			return err
+		}
+		ordering = float64(len(siblings)-index) * categoryOrderingStep
+	}
+
+	err = queries.MoveCategory(ctx, &dao.MoveCategoryParams{
+		Parent:   int32(parentId),
+		Ordering: ordering,
+		ID:       int32(id),
+		UserID:   userId,
+	})
+	if err != nil {
+		return fmt.Errorf("moving category: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// categoryInsertionIndex finds where the moved category goes among its
+// siblings, which are in the order they are shown in.
+func categoryInsertionIndex(siblings []dao.GetSiblingCategoriesRow, position CategoryPosition) (int, error) {
+	sibling, after := position.After.Value()
+	if !after {
+		var before bool
+		sibling, before = position.Before.Value()
+		if !before {
+			return len(siblings), nil
+		}
+	}
+
+	for i, candidate := range siblings {
+		if candidate.ID == int32(sibling) {
+			if after {
+				return i + 1, nil
+			}
+			return i, nil
+		}
+	}
+
+	return 0, ErrSiblingNotFound
+}
+
+// orderingBetween picks an ordering for a category inserted at index, between
+// the sibling shown before it, which has a higher ordering, and the one shown
+// after it. It reports whether there was room for one.
+func orderingBetween(siblings []dao.GetSiblingCategoriesRow, index int) (float64, bool) {
+	hasPrevious := index > 0
+	hasNext := index < len(siblings)
+
+	switch {
+	case !hasPrevious && !hasNext:
+		return 0, true
+	case !hasPrevious:
+		next := siblings[index].Ordering
+		ordering := next + categoryOrderingStep
+		return ordering, ordering > next
+	case !hasNext:
+		previous := siblings[index-1].Ordering
+		ordering := previous - categoryOrderingStep
+		return ordering, ordering < previous
+	}
+
+	previous, next := siblings[index-1].Ordering, siblings[index].Ordering
+	if previous-next < minCategoryOrderingGap {
+		return 0, false
+	}
+
+	ordering := next + (previous-next)/2
+	return ordering, next < ordering && ordering < previous
+}
+
+// rebalanceCategoryOrderings spreads the siblings out evenly, leaving the slot
+// at index for the moved category.
+func rebalanceCategoryOrderings(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	siblings []dao.GetSiblingCategoriesRow,
+	index int,
+) error {
+	ids := make([]int32, len(siblings))
+	orderings := make([]float64, len(siblings))
+	for i, sibling := range siblings {
+		slot := i
+		if i >= index {
+			slot++
+		}
+
+		ids[i] = sibling.ID
+		orderings[i] = float64(len(siblings)-slot) * categoryOrderingStep
+	}
+
+	err := queries.SetCategoryOrderings(ctx, &dao.SetCategoryOrderingsParams{
+		Ids:       ids,
+		Orderings: orderings,
+		UserID:    userId,
+	})
+	if err != nil {
+		return fmt.Errorf("rebalancing category orderings: %w", err)
+	}
+
+	return nil
+}
+
+// ApplyCategoryTemplate adds the categories of a template under the root
+// category. A category of the template whose name the user already has, in
+// any case and anywhere in the tree, is not created again: the categories
+// under it in the template go under the existing one instead. New categories
+// go after their siblings, in the order of the template.
+func (r *Repository) ApplyCategoryTemplate(
+	ctx context.Context,
+	userId uuid.UUID,
+	template []model.CategoryTemplateNode,
+) (result model.CategoryTemplateResult, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.CategoryTemplateResult{}, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("category template rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	// Applying the same template twice at once would create everything
+	// twice, so concurrent applications wait for each other on the root
+	// before looking at the categories.
+	rootId, err := queries.LockRootCategory(ctx, userId)
+	if err != nil {
+		return model.CategoryTemplateResult{}, fmt.Errorf("locking root category: %w", err)
+	}
+
+	categoriesDao, err := queries.GetAllCategories(ctx, userId)
+	if err != nil {
+		return model.CategoryTemplateResult{}, fmt.Errorf("getting categories: %w", err)
+	}
+
+	byName := map[string]int32{}
+	for _, category := range categoriesDao {
+		byName[categoryNameKey(category.Name)] = category.ID
+	}
+
+	// The children of each parent something was added to, in the order they
+	// are shown in.
+	siblingsByParent := map[int32][]dao.GetSiblingCategoriesRow{}
+
+	var apply func(nodes []model.CategoryTemplateNode, parentId int32) error
+	apply = func(nodes []model.CategoryTemplateNode, parentId int32) error {
+		for _, node := range nodes {
+			id, exists := byName[categoryNameKey(node.Name)]
+			if exists {
+				result.Existing = append(result.Existing, model.CategoryID(id))
+			} else {
+				siblings, found := siblingsByParent[parentId]
+				if !found {
+					siblings, err = queries.GetSiblingCategories(ctx, &dao.GetSiblingCategoriesParams{
+						Parent: parentId,
+						UserID: userId,
+					})
+					if err != nil {
+						return fmt.Errorf("getting sibling categories: %w", err)
+					}
+				}
+
+				ordering, fits := orderingBetween(siblings, len(siblings))
+				if !fits {
+					err = rebalanceCategoryOrderings(ctx, queries, userId, siblings, len(siblings))
+					if err != nil {
+						return err
+					}
+					for i := range siblings {
+						siblings[i].Ordering = float64(len(siblings)-i) * categoryOrderingStep
+					}
+					ordering = 0
+				}
+
+				id, err = queries.CreateCategory(ctx, &dao.CreateCategoryParams{
+					Name:           node.Name,
+					Parent:         sql.NullInt32{Int32: parentId, Valid: true},
+					IconName:       node.IconName,
+					IconColor:      node.IconColor,
+					IconBackground: node.IconBackground,
+					UserID:         userId,
+					FixedCosts:     node.FixedCost,
+					Ordering:       ordering,
+				})
+				if err != nil {
+					return fmt.Errorf("creating category %q: %w", node.Name, err)
+				}
+
+				byName[categoryNameKey(node.Name)] = id
+				siblingsByParent[parentId] = append(siblings, dao.GetSiblingCategoriesRow{ID: id, Ordering: ordering})
+				result.Created = append(result.Created, model.CategoryID(id))
+			}
+
+			if err = apply(node.Children, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err = apply(template, rootId); err != nil {
+		return model.CategoryTemplateResult{}, err
+	}
+
+	return result, tx.Commit()
+}
+
+// categoryNameKey is what two category names have in common when they name
+// the same category.
+func categoryNameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
 }
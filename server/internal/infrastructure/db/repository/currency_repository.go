@@ -3,6 +3,7 @@ package repository
 import (
 	"chagnon.dev/budget-server/internal/domain/model"
 	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
 	"github.com/google/uuid"
 
 	"context"
@@ -29,12 +30,30 @@ func (r *Repository) GetAllCurrencies(ctx context.Context, userId uuid.UUID) ([]
 				Script:  currencyDao.RateFetchScript,
 				Enabled: currencyDao.AutoUpdate,
 			},
+			Version: int(currencyDao.Version),
 		}
 	}
 
 	return currencies, nil
 }
 
+// CheckCurrency returns ErrCurrencyNotFound unless the currency is one of the
+// user's.
+func (r *Repository) CheckCurrency(ctx context.Context, userId uuid.UUID, id model.CurrencyID) error {
+	exists, err := r.queries.CurrencyExists(ctx, &dao.CurrencyExistsParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("checking currency: %w", err)
+	}
+	if !exists {
+		return ErrCurrencyNotFound
+	}
+
+	return nil
+}
+
 func (r *Repository) CreateCurrency(
 	ctx context.Context,
 	userId uuid.UUID,
@@ -66,6 +85,9 @@ type UpdateCurrencyFields struct {
 	DecimalPoints            *int
 	RateAutoUpdateScript     *string
 	RateAutoUpdateEnabled    *bool
+	// ExpectedVersion, when set, refuses the update with ErrVersionConflict
+	// if the currency is no longer at that version.
+	ExpectedVersion *int
 }
 
 func (u *UpdateCurrencyFields) nullName() sql.NullString {
@@ -150,8 +172,35 @@ func (r *Repository) UpdateCurrency(
 	userId uuid.UUID,
 	id model.CurrencyID,
 	fields UpdateCurrencyFields,
-) error {
-	return r.queries.UpdateCurrency(
+) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("currency update rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	if fields.ExpectedVersion != nil {
+		err = checkVersion(*fields.ExpectedVersion, ErrCurrencyNotFound, func() (int32, error) {
+			return queries.GetCurrencyVersion(ctx, &dao.GetCurrencyVersionParams{
+				ID:     int32(id),
+				UserID: userId,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = queries.UpdateCurrency(
 		ctx, &dao.UpdateCurrencyParams{
 			Name:            fields.nullName(),
 			Symbol:          fields.nullSymbol(),
@@ -164,6 +213,11 @@ func (r *Repository) UpdateCurrency(
 			UserID:          userId,
 		},
 	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *Repository) SetDefaultCurrency(
@@ -215,6 +269,7 @@ func (r *Repository) GetAllWithAutoUpdate(ctx context.Context, pageNumber, pageS
 				Script:  currencyDao.RateFetchScript,
 				Enabled: currencyDao.AutoUpdate,
 			},
+			Version: int(currencyDao.Version),
 		}
 	}
 
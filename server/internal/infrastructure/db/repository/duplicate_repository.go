@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"github.com/google/uuid"
+)
+
+const (
+	duplicateMaxDayDistance         = 3
+	duplicateAmountTolerancePercent = 1
+	duplicateMinNoteSimilarity      = 0.3
+	maxDuplicateCandidates          = 5
+	maxDuplicatePairs               = 200
+)
+
+// DuplicateTransactionError is returned when creating a transaction that
+// looks like one already recorded. Creating it anyway requires setting
+// NewTransactionFields.AllowDuplicates.
+type DuplicateTransactionError struct {
+	Candidates []model.DuplicateCandidate
+}
+
+func (e *DuplicateTransactionError) Error() string {
+	return fmt.Sprintf("possible duplicate of %d existing transaction(s)", len(e.Candidates))
+}
+
+// findDuplicateCandidates returns the existing transactions, among the user's
+// own and those of the groups they are in, that the new one may duplicate.
+func findDuplicateCandidates(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	fields NewTransactionFields,
+) ([]model.DuplicateCandidate, error) {
+	sender := sql.NullInt32{Valid: false}
+	if value, isSome := fields.SenderAccountId.Value(); isSome {
+		sender = sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	receiver := sql.NullInt32{Valid: false}
+	if value, isSome := fields.ReceiverAccountId.Value(); isSome {
+		receiver = sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	transactionGroup := sql.NullInt32{Valid: false}
+	if value, isSome := fields.TransactionGroupData.Value(); isSome {
+		transactionGroup = sql.NullInt32{Valid: true, Int32: int32(value.TransactionGroup)}
+	}
+
+	rows, err := queries.FindDuplicateCandidates(ctx, &dao.FindDuplicateCandidatesParams{
+		Note:                   fields.Note,
+		Currency:               int32(fields.CurrencyId),
+		Amount:                 int32(fields.Amount),
+		AmountTolerancePercent: duplicateAmountTolerancePercent,
+		Date:                   fields.Date,
+		MaxDayDistance:         duplicateMaxDayDistance,
+		UserID:                 userId,
+		Sender:                 sender,
+		Receiver:               receiver,
+		TransactionGroupID:     transactionGroup,
+		MinNoteSimilarity:      duplicateMinNoteSimilarity,
+		MaxCount:               maxDuplicateCandidates,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("finding duplicate candidates: %w", err)
+	}
+
+	candidates := make([]model.DuplicateCandidate, len(rows))
+	for i, row := range rows {
+		candidates[i] = model.DuplicateCandidate{
+			ID:             model.TransactionID(row.ID),
+			Owner:          model.Email(row.Owner),
+			Amount:         int(row.Amount),
+			Currency:       model.CurrencyID(row.Currency),
+			Date:           row.Date,
+			Note:           row.Note,
+			NoteSimilarity: row.NoteSimilarity,
+		}
+	}
+
+	return candidates, nil
+}
+
+// FindDuplicateTransactions scans the transactions the user can see for
+// pairs that are likely to be the same payment entered twice, most recent
+// first.
+func (r *Repository) FindDuplicateTransactions(ctx context.Context, userId uuid.UUID) ([]model.DuplicatePair, error) {
+	rows, err := r.queries.FindDuplicateTransactionPairs(ctx, &dao.FindDuplicateTransactionPairsParams{
+		UserID:                 userId,
+		MaxDayDistance:         duplicateMaxDayDistance,
+		AmountTolerancePercent: duplicateAmountTolerancePercent,
+		MinNoteSimilarity:      duplicateMinNoteSimilarity,
+		MaxCount:               maxDuplicatePairs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("finding duplicate transactions: %w", err)
+	}
+
+	pairs := make([]model.DuplicatePair, len(rows))
+	for i, row := range rows {
+		pairs[i] = model.DuplicatePair{
+			First: model.DuplicateCandidate{
+				ID:             model.TransactionID(row.FirstID),
+				Owner:          model.Email(row.FirstOwner),
+				Amount:         int(row.FirstAmount),
+				Currency:       model.CurrencyID(row.Currency),
+				Date:           row.FirstDate,
+				Note:           row.FirstNote,
+				NoteSimilarity: row.NoteSimilarity,
+			},
+			Second: model.DuplicateCandidate{
+				ID:             model.TransactionID(row.SecondID),
+				Owner:          model.Email(row.SecondOwner),
+				Amount:         int(row.SecondAmount),
+				Currency:       model.CurrencyID(row.Currency),
+				Date:           row.SecondDate,
+				Note:           row.SecondNote,
+				NoteSimilarity: row.NoteSimilarity,
+			},
+		}
+	}
+
+	return pairs, nil
+}
@@ -0,0 +1,543 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEnvelopesDisabled      = errors.New("envelope budgeting is not enabled")
+	ErrMonthBeforeEnvelopes   = errors.New("the month is before the first month of the envelopes")
+	ErrEnvelopeNotFound       = errors.New("envelope not found")
+	ErrBudgetTransferNotFound = errors.New("budget transfer not found")
+)
+
+func (r *Repository) GetEnvelopeSettings(ctx context.Context, userId uuid.UUID) (model.Optional[model.EnvelopeSettings], error) {
+	settings, err := r.queries.GetEnvelopeSettings(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.None[model.EnvelopeSettings](), nil
+	}
+	if err != nil {
+		return model.Optional[model.EnvelopeSettings]{}, fmt.Errorf("getting envelope settings: %w", err)
+	}
+
+	return model.Some(model.EnvelopeSettings{
+		Currency: model.CurrencyID(settings.CurrencyID),
+		StartsOn: settings.StartsOn,
+	}), nil
+}
+
+// SetEnvelopeSettings turns on envelope budgeting, or changes its currency or
+// first month. Either way the ledger is computed again from the start.
+func (r *Repository) SetEnvelopeSettings(ctx context.Context, userId uuid.UUID, settings model.EnvelopeSettings) error {
+	_, err := r.queries.SetEnvelopeSettings(ctx, &dao.SetEnvelopeSettingsParams{
+		UserID:     userId,
+		CurrencyID: int32(settings.Currency),
+		StartsOn:   settings.StartsOn,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCurrencyNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("setting envelope settings: %w", err)
+	}
+
+	return nil
+}
+
+// DisableEnvelopes turns off envelope budgeting. The envelopes, assignments
+// and transfers are kept for when it is turned on again.
+func (r *Repository) DisableEnvelopes(ctx context.Context, userId uuid.UUID) error {
+	deleted, err := r.queries.DeleteEnvelopeSettings(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("deleting envelope settings: %w", err)
+	}
+	if deleted == 0 {
+		return ErrEnvelopesDisabled
+	}
+
+	return nil
+}
+
+func (r *Repository) GetEnvelopes(ctx context.Context, userId uuid.UUID) ([]model.Envelope, error) {
+	envelopesDao, err := r.queries.GetEnvelopes(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("getting envelopes: %w", err)
+	}
+
+	return envelopesFromDao(envelopesDao), nil
+}
+
+func envelopesFromDao(envelopesDao []dao.GetEnvelopesRow) []model.Envelope {
+	envelopes := make([]model.Envelope, len(envelopesDao))
+	for i, envelopeDao := range envelopesDao {
+		envelopes[i] = model.Envelope{
+			Category: model.CategoryID(envelopeDao.CategoryID),
+			Income:   envelopeDao.Income,
+		}
+	}
+
+	return envelopes
+}
+
+// SetEnvelope gives a category an envelope, or changes whether it is an income
+// envelope.
+func (r *Repository) SetEnvelope(ctx context.Context, userId uuid.UUID, envelope model.Envelope) error {
+	written, err := r.queries.SetEnvelope(ctx, &dao.SetEnvelopeParams{
+		CategoryID: int32(envelope.Category),
+		UserID:     userId,
+		Income:     envelope.Income,
+	})
+	if err != nil {
+		return fmt.Errorf("setting envelope: %w", err)
+	}
+	if written == 0 {
+		return ErrCategoryNotFound
+	}
+
+	return nil
+}
+
+// RemoveEnvelope removes the envelope of a category along with what was
+// assigned and transferred to it. What happens under the category goes to
+// the envelope of its closest ancestor from then on.
+func (r *Repository) RemoveEnvelope(ctx context.Context, userId uuid.UUID, category model.CategoryID) error {
+	deleted, err := r.queries.DeleteEnvelope(ctx, &dao.DeleteEnvelopeParams{
+		CategoryID: int32(category),
+		UserID:     userId,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting envelope: %w", err)
+	}
+	if deleted == 0 {
+		return ErrEnvelopeNotFound
+	}
+
+	return nil
+}
+
+// AssignToEnvelope sets what is given to an envelope in a month, replacing
+// what was given before. Income envelopes take no assignments.
+func (r *Repository) AssignToEnvelope(ctx context.Context, userId uuid.UUID, assignment model.EnvelopeAssignment) error {
+	if err := checkSpendingEnvelope(ctx, r.queries, userId, assignment.Category); err != nil {
+		return err
+	}
+
+	err := r.queries.SetEnvelopeAssignment(ctx, &dao.SetEnvelopeAssignmentParams{
+		CategoryID: int32(assignment.Category),
+		Month:      assignment.Month,
+		UserID:     userId,
+		Amount:     int32(assignment.Amount),
+	})
+	if err != nil {
+		return fmt.Errorf("setting envelope assignment: %w", err)
+	}
+
+	return nil
+}
+
+// CreateBudgetTransfer moves money between two envelopes in a month. Income
+// envelopes hold no money to move.
+func (r *Repository) CreateBudgetTransfer(
+	ctx context.Context,
+	userId uuid.UUID,
+	transfer model.BudgetTransfer,
+) (model.BudgetTransferID, error) {
+	for _, category := range []model.CategoryID{transfer.From, transfer.To} {
+		if err := checkSpendingEnvelope(ctx, r.queries, userId, category); err != nil {
+			return 0, err
+		}
+	}
+
+	id, err := r.queries.CreateBudgetTransfer(ctx, &dao.CreateBudgetTransferParams{
+		UserID:         userId,
+		Month:          transfer.Month,
+		FromCategoryID: int32(transfer.From),
+		ToCategoryID:   int32(transfer.To),
+		Amount:         int32(transfer.Amount),
+		Note:           transfer.Note,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("creating budget transfer: %w", err)
+	}
+
+	return model.BudgetTransferID(id), nil
+}
+
+func checkSpendingEnvelope(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	category model.CategoryID,
+) error {
+	exists, err := queries.SpendingEnvelopeExists(ctx, &dao.SpendingEnvelopeExistsParams{
+		CategoryID: int32(category),
+		UserID:     userId,
+	})
+	if err != nil {
+		return fmt.Errorf("checking envelope: %w", err)
+	}
+	if !exists {
+		return ErrEnvelopeNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteBudgetTransfer(ctx context.Context, userId uuid.UUID, id model.BudgetTransferID) error {
+	deleted, err := r.queries.DeleteBudgetTransfer(ctx, &dao.DeleteBudgetTransferParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting budget transfer: %w", err)
+	}
+	if deleted == 0 {
+		return ErrBudgetTransferNotFound
+	}
+
+	return nil
+}
+
+// GetEnvelopeMonth returns the envelopes at the end of a month. The ledger is
+// brought up to date first, but only from the first month that changed since
+// it was last computed, or from the month after the last one it holds.
+func (r *Repository) GetEnvelopeMonth(
+	ctx context.Context,
+	userId uuid.UUID,
+	month time.Time,
+	location *time.Location,
+) (envelopeMonth model.EnvelopeMonth, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.EnvelopeMonth{}, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("envelope month rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	ledger, err := queries.LockEnvelopeLedger(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.EnvelopeMonth{}, ErrEnvelopesDisabled
+	}
+	if err != nil {
+		return model.EnvelopeMonth{}, fmt.Errorf("locking envelope ledger: %w", err)
+	}
+
+	month = model.MonthStart(month)
+	startsOn := model.MonthStart(ledger.StartsOn)
+	if month.Before(startsOn) {
+		return model.EnvelopeMonth{}, ErrMonthBeforeEnvelopes
+	}
+
+	from := startsOn
+	if ledger.ComputedThrough.Valid {
+		computedThrough := model.MonthStart(ledger.ComputedThrough.Time)
+		from = computedThrough.AddDate(0, 1, 0)
+		if ledger.StaleFrom.Valid && !ledger.StaleFrom.Time.After(computedThrough) {
+			from = model.MonthStart(ledger.StaleFrom.Time)
+			if from.Before(startsOn) {
+				from = startsOn
+			}
+		}
+	} else {
+		// The settings changed, and the ledger may still hold months before
+		// the new first one.
+		err = queries.DeleteEnvelopeLedger(ctx, &dao.DeleteEnvelopeLedgerParams{
+			UserID:    userId,
+			FromMonth: time.Time{},
+		})
+		if err != nil {
+			return model.EnvelopeMonth{}, fmt.Errorf("deleting envelope ledger: %w", err)
+		}
+	}
+
+	if !from.After(month) {
+		err = computeEnvelopeLedger(ctx, queries, userId, model.CurrencyID(ledger.CurrencyID), startsOn, from, month, location)
+		if err != nil {
+			return model.EnvelopeMonth{}, err
+		}
+	}
+
+	envelopeMonth, err = readEnvelopeMonth(ctx, queries, userId, model.CurrencyID(ledger.CurrencyID), month)
+	if err != nil {
+		return model.EnvelopeMonth{}, err
+	}
+
+	return envelopeMonth, tx.Commit()
+}
+
+// computeEnvelopeLedger computes the ledger from the month from through the
+// month through, on top of the balances it holds for the month before, and
+// replaces the entries from the month from on with them.
+func computeEnvelopeLedger(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	currency model.CurrencyID,
+	startsOn, from, through time.Time,
+	location *time.Location,
+) error {
+	envelopesDao, err := queries.GetEnvelopes(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("getting envelopes: %w", err)
+	}
+
+	categoriesDao, err := queries.GetAllCategories(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("getting categories: %w", err)
+	}
+
+	ratesDao, err := queries.GetAllExchangeRates(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("getting exchange rates: %w", err)
+	}
+
+	carriedOver := map[model.CategoryID]int64{}
+	if from.After(startsOn) {
+		balances, err := queries.GetEnvelopeBalances(ctx, &dao.GetEnvelopeBalancesParams{
+			UserID: userId,
+			Month:  from.AddDate(0, -1, 0),
+		})
+		if err != nil {
+			return fmt.Errorf("getting envelope balances: %w", err)
+		}
+		for _, balance := range balances {
+			carriedOver[model.CategoryID(balance.CategoryID)] = balance.Balance
+		}
+	}
+
+	assignmentsDao, err := queries.GetEnvelopeAssignments(ctx, &dao.GetEnvelopeAssignmentsParams{
+		UserID:       userId,
+		FromMonth:    from,
+		ThroughMonth: through,
+	})
+	if err != nil {
+		return fmt.Errorf("getting envelope assignments: %w", err)
+	}
+
+	assignments := make([]model.EnvelopeAssignment, len(assignmentsDao))
+	for i, assignmentDao := range assignmentsDao {
+		assignments[i] = model.EnvelopeAssignment{
+			Category: model.CategoryID(assignmentDao.CategoryID),
+			Month:    assignmentDao.Month,
+			Amount:   int(assignmentDao.Amount),
+		}
+	}
+
+	transfers, err := getBudgetTransfers(ctx, queries, userId, from, through)
+	if err != nil {
+		return err
+	}
+
+	end := through.AddDate(0, 1, 0)
+	spendingDao, err := queries.GetCategorySpending(ctx, &dao.GetCategorySpendingParams{
+		Timezone: location.String(),
+		UserID:   userId,
+		FromDate: time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, location),
+		ToDate:   time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, location),
+	})
+	if err != nil {
+		return fmt.Errorf("getting category spending: %w", err)
+	}
+
+	entries := model.EnvelopeLedger{
+		From:          from,
+		Through:       through,
+		Currency:      currency,
+		Envelopes:     envelopesFromDao(envelopesDao),
+		Categories:    categoriesFromDao(categoriesDao),
+		CarriedOver:   carriedOver,
+		Assignments:   assignments,
+		Transfers:     transfers,
+		Spending:      categorySpendingFromDao(spendingDao),
+		ExchangeRates: model.NewExchangeRates(exchangeRatesFromDao(ratesDao)),
+	}.Compute()
+
+	params := &dao.InsertEnvelopeLedgerParams{
+		UserID:               userId,
+		CategoryIds:          make([]int32, len(entries)),
+		Months:               make([]time.Time, len(entries)),
+		Incomes:              make([]bool, len(entries)),
+		Assigned:             make([]int64, len(entries)),
+		Transferred:          make([]int64, len(entries)),
+		Activities:           make([]int64, len(entries)),
+		Balances:             make([]int64, len(entries)),
+		MissingExchangeRates: make([]bool, len(entries)),
+	}
+	for i, entry := range entries {
+		params.CategoryIds[i] = int32(entry.Category)
+		params.Months[i] = entry.Month
+		params.Incomes[i] = entry.Income
+		params.Assigned[i] = entry.Assigned
+		params.Transferred[i] = entry.Transferred
+		params.Activities[i] = entry.Activity
+		params.Balances[i] = entry.Balance
+		params.MissingExchangeRates[i] = entry.MissingExchangeRate
+	}
+
+	err = queries.DeleteEnvelopeLedger(ctx, &dao.DeleteEnvelopeLedgerParams{
+		UserID:    userId,
+		FromMonth: from,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting envelope ledger: %w", err)
+	}
+
+	if err = queries.InsertEnvelopeLedger(ctx, params); err != nil {
+		return fmt.Errorf("inserting envelope ledger: %w", err)
+	}
+
+	err = queries.SetEnvelopeLedgerComputed(ctx, &dao.SetEnvelopeLedgerComputedParams{
+		ComputedThrough: sql.NullTime{Time: through, Valid: true},
+		UserID:          userId,
+	})
+	if err != nil {
+		return fmt.Errorf("setting envelope ledger computed: %w", err)
+	}
+
+	return nil
+}
+
+func readEnvelopeMonth(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	currency model.CurrencyID,
+	month time.Time,
+) (model.EnvelopeMonth, error) {
+	entriesDao, err := queries.GetEnvelopeLedgerMonth(ctx, &dao.GetEnvelopeLedgerMonthParams{
+		UserID: userId,
+		Month:  month,
+	})
+	if err != nil {
+		return model.EnvelopeMonth{}, fmt.Errorf("getting envelope ledger: %w", err)
+	}
+
+	available, err := queries.GetAvailableToBudget(ctx, &dao.GetAvailableToBudgetParams{
+		UserID: userId,
+		Month:  month,
+	})
+	if err != nil {
+		return model.EnvelopeMonth{}, fmt.Errorf("getting available to budget: %w", err)
+	}
+
+	transfers, err := getBudgetTransfers(ctx, queries, userId, month, month)
+	if err != nil {
+		return model.EnvelopeMonth{}, err
+	}
+
+	envelopes := make([]model.EnvelopeLedgerEntry, len(entriesDao))
+	for i, entryDao := range entriesDao {
+		envelopes[i] = model.EnvelopeLedgerEntry{
+			Category:            model.CategoryID(entryDao.CategoryID),
+			Month:               month,
+			Income:              entryDao.Income,
+			Assigned:            entryDao.Assigned,
+			Transferred:         entryDao.Transferred,
+			Activity:            entryDao.Activity,
+			Balance:             entryDao.Balance,
+			MissingExchangeRate: entryDao.MissingExchangeRate,
+		}
+	}
+
+	return model.EnvelopeMonth{
+		Month:             month,
+		Currency:          currency,
+		AvailableToBudget: available,
+		Envelopes:         envelopes,
+		Transfers:         transfers,
+	}, nil
+}
+
+func getBudgetTransfers(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	from, through time.Time,
+) ([]model.BudgetTransfer, error) {
+	transfersDao, err := queries.GetBudgetTransfers(ctx, &dao.GetBudgetTransfersParams{
+		UserID:       userId,
+		FromMonth:    from,
+		ThroughMonth: through,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting budget transfers: %w", err)
+	}
+
+	transfers := make([]model.BudgetTransfer, len(transfersDao))
+	for i, transferDao := range transfersDao {
+		transfers[i] = model.BudgetTransfer{
+			ID:     model.BudgetTransferID(transferDao.ID),
+			Month:  transferDao.Month,
+			From:   model.CategoryID(transferDao.FromCategoryID),
+			To:     model.CategoryID(transferDao.ToCategoryID),
+			Amount: int(transferDao.Amount),
+			Note:   transferDao.Note,
+		}
+	}
+
+	return transfers, nil
+}
+
+// foldCategoryEnvelopes moves the envelopes of removed categories into that of
+// their replacement, which gets one if it had none: their assignments add up,
+// and their transfers now go to or from it.
+func foldCategoryEnvelopes(
+	ctx context.Context,
+	queries *dao.Queries,
+	removed []int32,
+	replacementId model.CategoryID,
+) error {
+	conflict, err := queries.EnvelopeIncomeConflict(ctx, &dao.EnvelopeIncomeConflictParams{
+		CategoryIds:   removed,
+		ReplacementID: int32(replacementId),
+	})
+	if err != nil {
+		return fmt.Errorf("checking envelopes: %w", err)
+	}
+	if conflict {
+		return ErrBudgetsConflict
+	}
+
+	err = queries.CreateReplacementEnvelope(ctx, &dao.CreateReplacementEnvelopeParams{
+		ReplacementID: int32(replacementId),
+		CategoryIds:   removed,
+	})
+	if err != nil {
+		return fmt.Errorf("creating replacement envelope: %w", err)
+	}
+
+	err = queries.FoldEnvelopeAssignments(ctx, &dao.FoldEnvelopeAssignmentsParams{
+		ReplacementID: int32(replacementId),
+		CategoryIds:   removed,
+	})
+	if err != nil {
+		return fmt.Errorf("folding envelope assignments: %w", err)
+	}
+
+	err = queries.ReassignBudgetTransfers(ctx, &dao.ReassignBudgetTransfersParams{
+		CategoryIds:   removed,
+		ReplacementID: int32(replacementId),
+	})
+	if err != nil {
+		return fmt.Errorf("reassigning budget transfers: %w", err)
+	}
+
+	return nil
+}
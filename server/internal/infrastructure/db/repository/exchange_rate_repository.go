@@ -16,6 +16,10 @@ func (r *Repository) GetAllExchangeRate(ctx context.Context, userId uuid.UUID) (
 		return nil, err
 	}
 
+	return exchangeRatesFromDao(exchangeRatesDao), nil
+}
+
+func exchangeRatesFromDao(exchangeRatesDao []dao.GetAllExchangeRatesRow) []model.ExchangeRate {
 	exchangeRates := make([]model.ExchangeRate, 0)
 	for _, exchangeRateDao := range exchangeRatesDao {
 		exchangeRates = append(exchangeRates, model.ExchangeRate{
@@ -26,7 +30,7 @@ func (r *Repository) GetAllExchangeRate(ctx context.Context, userId uuid.UUID) (
 		})
 	}
 
-	return exchangeRates, nil
+	return exchangeRates
 }
 
 type InitialExchangeRate struct {
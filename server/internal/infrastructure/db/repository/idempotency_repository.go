@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"github.com/google/uuid"
+)
+
+var ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+
+// ReserveIdempotencyKey claims the key for a request, unless the user already
+// used it for a request made after expiredBefore. It reports whether the key
+// was claimed.
+func (r *Repository) ReserveIdempotencyKey(
+	ctx context.Context,
+	userId uuid.UUID,
+	key, method string,
+	requestHash []byte,
+	expiredBefore time.Time,
+) (bool, error) {
+	reserved, err := r.queries.ReserveIdempotencyKey(ctx, &dao.ReserveIdempotencyKeyParams{
+		UserID:        userId,
+		Key:           key,
+		Method:        method,
+		RequestHash:   requestHash,
+		ExpiredBefore: expiredBefore,
+	})
+	if err != nil {
+		return false, fmt.Errorf("reserving idempotency key: %w", err)
+	}
+
+	return reserved > 0, nil
+}
+
+func (r *Repository) GetIdempotentRequest(ctx context.Context, userId uuid.UUID, key string) (model.IdempotentRequest, error) {
+	row, err := r.queries.GetIdempotencyKey(ctx, &dao.GetIdempotencyKeyParams{
+		UserID: userId,
+		Key:    key,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.IdempotentRequest{}, ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return model.IdempotentRequest{}, fmt.Errorf("getting idempotency key: %w", err)
+	}
+
+	response := model.None[[]byte]()
+	if row.Response != nil {
+		response = model.Some(row.Response)
+	}
+
+	return model.IdempotentRequest{
+		Method:      row.Method,
+		RequestHash: row.RequestHash,
+		Response:    response,
+	}, nil
+}
+
+// SaveIdempotentResponse keeps the response to the request that reserved the
+// key, to be replayed when the key is used again.
+func (r *Repository) SaveIdempotentResponse(ctx context.Context, userId uuid.UUID, key string, response []byte) error {
+	saved, err := r.queries.SaveIdempotentResponse(ctx, &dao.SaveIdempotentResponseParams{
+		Response: response,
+		UserID:   userId,
+		Key:      key,
+	})
+	if err != nil {
+		return fmt.Errorf("saving idempotent response: %w", err)
+	}
+	if saved == 0 {
+		return ErrIdempotencyKeyNotFound
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey frees a key whose request failed, so that retrying it
+// does the work again instead of replaying the failure.
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, userId uuid.UUID, key string) error {
+	err := r.queries.ReleaseIdempotencyKey(ctx, &dao.ReleaseIdempotencyKeyParams{
+		UserID: userId,
+		Key:    key,
+	})
+	if err != nil {
+		return fmt.Errorf("releasing idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes, for every user, the keys used before
+// createdBefore, and returns how many were deleted.
+func (r *Repository) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time) (int, error) {
+	purged, err := r.queries.PurgeIdempotencyKeys(ctx, createdBefore)
+	if err != nil {
+		return 0, fmt.Errorf("purging idempotency keys: %w", err)
+	}
+
+	return int(purged), nil
+}
@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPayeeNotFound    = errors.New("payee not found")
+	ErrPayeeNameTaken   = errors.New("a payee with this name already exists")
+	ErrCategoryNotFound = errors.New("category not found")
+)
+
+func (r *Repository) GetAllPayees(ctx context.Context, userId uuid.UUID) ([]model.Payee, error) {
+	payeesDao, err := r.queries.GetAllPayees(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	payees := make([]model.Payee, len(payeesDao))
+	for i, payeeDao := range payeesDao {
+		payees[i] = model.Payee{
+			ID:              model.PayeeID(payeeDao.ID),
+			Name:            payeeDao.Name,
+			DefaultCategory: nullCategoryToModel(payeeDao.DefaultCategoryID),
+			Aliases:         payeeDao.Aliases,
+		}
+	}
+
+	return payees, nil
+}
+
+func (r *Repository) CreatePayee(
+	ctx context.Context,
+	userId uuid.UUID,
+	name string,
+	defaultCategory model.Optional[model.CategoryID],
+	aliases []string,
+) (createdPayeeId model.PayeeID, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("payee creation rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	category, err := payeeDefaultCategory(ctx, queries, userId, defaultCategory)
+	if err != nil {
+		return 0, err
+	}
+
+	payeeId, err := queries.CreatePayee(ctx, &dao.CreatePayeeParams{
+		UserID:            userId,
+		Name:              name,
+		DefaultCategoryID: category,
+	})
+	if isUniqueViolation(err) {
+		return 0, ErrPayeeNameTaken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("creating payee: %w", err)
+	}
+
+	if len(aliases) > 0 {
+		err = queries.AddPayeeAliases(ctx, &dao.AddPayeeAliasesParams{PayeeID: payeeId, Patterns: aliases})
+		if err != nil {
+			return 0, fmt.Errorf("adding payee aliases: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return model.PayeeID(payeeId), nil
+}
+
+// UpdatePayee replaces the name, default category and aliases of a payee.
+func (r *Repository) UpdatePayee(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.PayeeID,
+	name string,
+	defaultCategory model.Optional[model.CategoryID],
+	aliases []string,
+) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("payee update rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	category, err := payeeDefaultCategory(ctx, queries, userId, defaultCategory)
+	if err != nil {
+		return err
+	}
+
+	updated, err := queries.UpdatePayee(ctx, &dao.UpdatePayeeParams{
+		Name:              name,
+		DefaultCategoryID: category,
+		ID:                int32(id),
+		UserID:            userId,
+	})
+	if isUniqueViolation(err) {
+		return ErrPayeeNameTaken
+	}
+	if err != nil {
+		return fmt.Errorf("updating payee: %w", err)
+	}
+	if updated == 0 {
+		return ErrPayeeNotFound
+	}
+
+	if err = queries.DeletePayeeAliases(ctx, int32(id)); err != nil {
+		return fmt.Errorf("deleting payee aliases: %w", err)
+	}
+
+	if len(aliases) > 0 {
+		err = queries.AddPayeeAliases(ctx, &dao.AddPayeeAliasesParams{PayeeID: int32(id), Patterns: aliases})
+		if err != nil {
+			return fmt.Errorf("adding payee aliases: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePayee deletes the payee. Its transactions are kept without a payee.
+func (r *Repository) DeletePayee(ctx context.Context, userId uuid.UUID, id model.PayeeID) error {
+	deleted, err := r.queries.DeletePayee(ctx, &dao.DeletePayeeParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting payee: %w", err)
+	}
+	if deleted == 0 {
+		return ErrPayeeNotFound
+	}
+
+	return nil
+}
+
+// ResolvePayee finds the payee a merchant name as printed on a statement
+// belongs to, either by its name or by one of its aliases.
+func (r *Repository) ResolvePayee(ctx context.Context, userId uuid.UUID, merchant string) (model.Optional[model.Payee], error) {
+	return resolvePayee(ctx, r.queries, userId, merchant)
+}
+
+func resolvePayee(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	merchant string,
+) (model.Optional[model.Payee], error) {
+	row, err := queries.ResolvePayee(ctx, &dao.ResolvePayeeParams{
+		UserID:   userId,
+		Merchant: merchant,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.None[model.Payee](), nil
+	}
+	if err != nil {
+		return model.None[model.Payee](), fmt.Errorf("resolving payee: %w", err)
+	}
+
+	return model.Some(model.Payee{
+		ID:              model.PayeeID(row.ID),
+		Name:            row.Name,
+		DefaultCategory: nullCategoryToModel(row.DefaultCategoryID),
+	}), nil
+}
+
+// transactionPayee returns the payee of a new transaction: the one given,
+// or else the one the merchant name resolves to, if any.
+func transactionPayee(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	payeeId model.Optional[model.PayeeID],
+	merchant string,
+) (model.Optional[model.Payee], error) {
+	if id, isSome := payeeId.Value(); isSome {
+		payee, err := getPayee(ctx, queries, userId, id)
+		if err != nil {
+			return model.None[model.Payee](), err
+		}
+
+		return model.Some(payee), nil
+	}
+
+	if strings.TrimSpace(merchant) == "" {
+		return model.None[model.Payee](), nil
+	}
+
+	return resolvePayee(ctx, queries, userId, merchant)
+}
+
+// getPayee returns the payee with the given id, failing with ErrPayeeNotFound
+// if it does not belong to the user.
+func getPayee(ctx context.Context, queries *dao.Queries, userId uuid.UUID, id model.PayeeID) (model.Payee, error) {
+	row, err := queries.GetPayee(ctx, &dao.GetPayeeParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Payee{}, ErrPayeeNotFound
+	}
+	if err != nil {
+		return model.Payee{}, fmt.Errorf("getting payee: %w", err)
+	}
+
+	return model.Payee{
+		ID:              model.PayeeID(row.ID),
+		Name:            row.Name,
+		DefaultCategory: nullCategoryToModel(row.DefaultCategoryID),
+	}, nil
+}
+
+func payeeDefaultCategory(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	defaultCategory model.Optional[model.CategoryID],
+) (sql.NullInt32, error) {
+	categoryId, isSome := defaultCategory.Value()
+	if !isSome {
+		return sql.NullInt32{Valid: false}, nil
+	}
+
+	exists, err := queries.CategoryExists(ctx, &dao.CategoryExistsParams{
+		ID:     int32(categoryId),
+		UserID: userId,
+	})
+	if err != nil {
+		return sql.NullInt32{}, fmt.Errorf("checking default category: %w", err)
+	}
+	if !exists {
+		return sql.NullInt32{}, ErrCategoryNotFound
+	}
+
+	return sql.NullInt32{Valid: true, Int32: int32(categoryId)}, nil
+}
+
+func nullCategoryToModel(category sql.NullInt32) model.Optional[model.CategoryID] {
+	if !category.Valid {
+		return model.None[model.CategoryID]()
+	}
+
+	return model.Some(model.CategoryID(category.Int32))
+}
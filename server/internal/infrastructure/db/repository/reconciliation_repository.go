@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAccountNotFound        = errors.New("account not found")
+	ErrReconciliationNotFound = errors.New("the account has no reconciliation")
+	// ErrReconciledElsewhere is returned when undoing a reconciliation would
+	// unlock transactions the statement of another account may have counted.
+	ErrReconciledElsewhere = errors.New("transactions of the reconciliation are covered by a reconciliation of another account")
+)
+
+// ReconcileAccount compares the statement balances of an account with its
+// initial balances plus its cleared transactions up to the statement date. A
+// currency missing from the statement is expected to have a zero balance.
+// When every balance matches, and unless dryRun is set, the cleared
+// transactions are marked as reconciled and the statement is recorded.
+func (r *Repository) ReconcileAccount(
+	ctx context.Context,
+	userId uuid.UUID,
+	accountId model.AccountID,
+	statementDate time.Time,
+	statementBalances []model.Balance,
+	dryRun bool,
+) (result model.ReconciliationResult, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil || dryRun || !result.Reconciled {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("account reconciliation rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	// Locking the account keeps two reconciliations of the same account from
+	// interleaving.
+	_, err = queries.LockAccount(ctx, &dao.LockAccountParams{
+		ID:     int32(accountId),
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrAccountNotFound
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("locking account: %w", err)
+		return
+	}
+
+	clearedBalances, err := queries.GetClearedBalances(ctx, &dao.GetClearedBalancesParams{
+		AccountID:     int32(accountId),
+		UserID:        userId,
+		StatementDate: statementDate,
+	})
+	if err != nil {
+		err = fmt.Errorf("computing cleared balances: %w", err)
+		return
+	}
+
+	balances := make([]model.ReconciliationBalance, 0, len(statementBalances))
+	for _, statementBalance := range statementBalances {
+		balances = append(balances, model.ReconciliationBalance{
+			Currency:         model.CurrencyID(statementBalance.CurrencyId),
+			StatementBalance: statementBalance.Value,
+		})
+	}
+
+	for _, clearedBalance := range clearedBalances {
+		i := slices.IndexFunc(balances, func(balance model.ReconciliationBalance) bool {
+			return balance.Currency == model.CurrencyID(clearedBalance.CurrencyID)
+		})
+		if i < 0 {
+			balances = append(balances, model.ReconciliationBalance{Currency: model.CurrencyID(clearedBalance.CurrencyID)})
+			i = len(balances) - 1
+		}
+
+		balances[i].ClearedBalance = int(clearedBalance.ClearedBalance)
+	}
+
+	result.Balances = balances
+
+	for _, balance := range balances {
+		if balance.Difference() != 0 {
+			return result, nil
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	reconciledIds, err := queries.MarkTransactionsReconciled(ctx, &dao.MarkTransactionsReconciledParams{
+		AccountID:     int32(accountId),
+		UserID:        userId,
+		StatementDate: statementDate,
+	})
+	if err != nil {
+		err = fmt.Errorf("marking transactions as reconciled: %w", err)
+		return
+	}
+
+	statusChange := []FieldChangeValue{{
+		Field:  "status",
+		Before: []byte(`"cleared"`),
+		After:  []byte(`"reconciled"`),
+	}}
+	for _, id := range reconciledIds {
+		err = recordTransactionHistory(ctx, queries, userId, model.TransactionID(id), historyActionUpdated, statusChange)
+		if err != nil {
+			return
+		}
+	}
+
+	reconciliationId, err := queries.CreateReconciliation(ctx, &dao.CreateReconciliationParams{
+		UserID:        userId,
+		AccountID:     int32(accountId),
+		StatementDate: statementDate,
+	})
+	if err != nil {
+		err = fmt.Errorf("recording reconciliation: %w", err)
+		return
+	}
+
+	err = queries.LinkReconciliationTransactions(ctx, &dao.LinkReconciliationTransactionsParams{
+		ReconciliationID: reconciliationId,
+		TransactionIds:   reconciledIds,
+	})
+	if err != nil {
+		err = fmt.Errorf("recording reconciled transactions: %w", err)
+		return
+	}
+
+	for _, balance := range balances {
+		err = queries.CreateReconciliationBalance(ctx, &dao.CreateReconciliationBalanceParams{
+			ReconciliationID: reconciliationId,
+			CurrencyID:       int32(balance.Currency),
+			StatementBalance: int64(balance.StatementBalance),
+		})
+		if err != nil {
+			err = fmt.Errorf("recording reconciliation balance: %w", err)
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("committing transaction: %w", err)
+		return
+	}
+
+	result.Reconciled = true
+	result.ReconciledTransactions = len(reconciledIds)
+
+	return result, nil
+}
+
+// UndoReconciliation undoes the latest reconciliation of an account: the
+// transactions it locked are cleared again, which their history records, and
+// the statement it checked is forgotten. It returns how many transactions
+// were unlocked.
+func (r *Repository) UndoReconciliation(
+	ctx context.Context,
+	userId uuid.UUID,
+	accountId model.AccountID,
+) (unlocked int, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("reconciliation undo rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	// Same lock as ReconcileAccount, so a reconciliation cannot be undone
+	// while another one of the account is recorded.
+	_, err = queries.LockAccount(ctx, &dao.LockAccountParams{
+		ID:     int32(accountId),
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrAccountNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("locking account: %w", err)
+	}
+
+	reconciliationId, err := queries.GetLatestReconciliation(ctx, &dao.GetLatestReconciliationParams{
+		AccountID: int32(accountId),
+		UserID:    userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrReconciliationNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting latest reconciliation: %w", err)
+	}
+
+	coveredElsewhere, err := queries.ReconciliationCoveredByOtherAccounts(ctx, reconciliationId)
+	if err != nil {
+		return 0, fmt.Errorf("checking other reconciliations: %w", err)
+	}
+	if coveredElsewhere {
+		return 0, ErrReconciledElsewhere
+	}
+
+	unlockedIds, err := queries.UnmarkReconciledTransactions(ctx, reconciliationId)
+	if err != nil {
+		return 0, fmt.Errorf("clearing reconciled transactions: %w", err)
+	}
+
+	statusChange := []FieldChangeValue{{
+		Field:  "status",
+		Before: []byte(`"reconciled"`),
+		After:  []byte(`"cleared"`),
+	}}
+	for _, id := range unlockedIds {
+		err = recordTransactionHistory(ctx, queries, userId, model.TransactionID(id), historyActionUpdated, statusChange)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = queries.DeleteReconciliation(ctx, reconciliationId); err != nil {
+		return 0, fmt.Errorf("deleting reconciliation: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return len(unlockedIds), nil
+}
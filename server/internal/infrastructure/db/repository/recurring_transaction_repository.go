@@ -0,0 +1,476 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
+	"github.com/google/uuid"
+)
+
+var ErrRecurringTransactionNotFound = errors.New("recurring transaction not found")
+
+func recurringTransactionFromDao(rowDao dao.GetAllRecurringTransactionsRow) model.RecurringTransaction {
+	sender := model.None[model.AccountID]()
+	if rowDao.Sender.Valid {
+		sender = model.Some(model.AccountID(rowDao.Sender.Int32))
+	}
+
+	receiver := model.None[model.AccountID]()
+	if rowDao.Receiver.Valid {
+		receiver = model.Some(model.AccountID(rowDao.Receiver.Int32))
+	}
+
+	category := model.None[model.CategoryID]()
+	if rowDao.Category.Valid {
+		category = model.Some(model.CategoryID(rowDao.Category.Int32))
+	}
+
+	endsOn := model.None[time.Time]()
+	if rowDao.EndsOn.Valid {
+		endsOn = model.Some(rowDao.EndsOn.Time)
+	}
+
+	materializedThrough := model.None[time.Time]()
+	if rowDao.MaterializedThrough.Valid {
+		materializedThrough = model.Some(rowDao.MaterializedThrough.Time)
+	}
+
+	return model.RecurringTransaction{
+		ID:                  model.RecurringTransactionID(rowDao.ID),
+		Owner:               model.Email(rowDao.Owner),
+		Amount:              int(rowDao.Amount),
+		Currency:            model.CurrencyID(rowDao.Currency),
+		Sender:              sender,
+		Receiver:            receiver,
+		Category:            category,
+		Note:                rowDao.Note,
+		ReceiverCurrency:    model.CurrencyID(rowDao.ReceiverCurrency),
+		ReceiverAmount:      int(rowDao.ReceiverAmount),
+		Schedule:            rowDao.Schedule,
+		StartsOn:            rowDao.StartsOn,
+		EndsOn:              endsOn,
+		Paused:              rowDao.Paused,
+		MaterializedThrough: materializedThrough,
+	}
+}
+
+func (r *Repository) GetAllRecurringTransactions(ctx context.Context, userId uuid.UUID) ([]model.RecurringTransaction, error) {
+	rowsDao, err := r.queries.GetAllRecurringTransactions(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("getting recurring transactions: %w", err)
+	}
+
+	recurringTransactions := make([]model.RecurringTransaction, len(rowsDao))
+	for i, rowDao := range rowsDao {
+		recurringTransactions[i] = recurringTransactionFromDao(rowDao)
+	}
+
+	return recurringTransactions, nil
+}
+
+func (r *Repository) GetRecurringTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.RecurringTransactionID,
+) (model.RecurringTransaction, error) {
+	rowDao, err := r.queries.GetRecurringTransaction(ctx, &dao.GetRecurringTransactionParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.RecurringTransaction{}, ErrRecurringTransactionNotFound
+	}
+	if err != nil {
+		return model.RecurringTransaction{}, fmt.Errorf("getting recurring transaction: %w", err)
+	}
+
+	return recurringTransactionFromDao(dao.GetAllRecurringTransactionsRow(rowDao)), nil
+}
+
+// DueRecurringTransaction is a recurring transaction that may have occurrences
+// left to materialize, along with the user who owns it and their timezone.
+type DueRecurringTransaction struct {
+	model.RecurringTransaction
+	UserID   uuid.UUID
+	Location *time.Location
+}
+
+func (r *Repository) GetDueRecurringTransactions(ctx context.Context, now time.Time) ([]DueRecurringTransaction, error) {
+	rowsDao, err := r.queries.GetDueRecurringTransactions(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("getting due recurring transactions: %w", err)
+	}
+
+	recurringTransactions := make([]DueRecurringTransaction, len(rowsDao))
+	for i, rowDao := range rowsDao {
+		location, err := model.LoadTimezone(rowDao.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("loading timezone of recurring transaction %d: %w", rowDao.ID, err)
+		}
+
+		recurringTransactions[i] = DueRecurringTransaction{
+			RecurringTransaction: recurringTransactionFromDao(dao.GetAllRecurringTransactionsRow{
+				ID:                  rowDao.ID,
+				Owner:               rowDao.Owner,
+				Amount:              rowDao.Amount,
+				Currency:            rowDao.Currency,
+				Sender:              rowDao.Sender,
+				Receiver:            rowDao.Receiver,
+				Category:            rowDao.Category,
+				Note:                rowDao.Note,
+				ReceiverCurrency:    rowDao.ReceiverCurrency,
+				ReceiverAmount:      rowDao.ReceiverAmount,
+				Schedule:            rowDao.Schedule,
+				StartsOn:            rowDao.StartsOn,
+				EndsOn:              rowDao.EndsOn,
+				Paused:              rowDao.Paused,
+				MaterializedThrough: rowDao.MaterializedThrough,
+			}),
+			UserID:   rowDao.UserID,
+			Location: location,
+		}
+	}
+
+	return recurringTransactions, nil
+}
+
+func (r *Repository) CreateRecurringTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	amount, receiverAmount int,
+	currencyId, receiverCurrencyId int,
+	senderAccountId, receiverAccountId model.Optional[int],
+	categoryId model.Optional[int],
+	note string,
+	schedule string,
+	startsOn time.Time,
+	endsOn model.Optional[time.Time],
+) (model.RecurringTransactionID, error) {
+	sender := sql.NullInt32{Valid: false}
+	if value, isSome := senderAccountId.Value(); isSome {
+		sender = sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	receiver := sql.NullInt32{Valid: false}
+	if value, isSome := receiverAccountId.Value(); isSome {
+		receiver = sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	category := sql.NullInt32{Valid: false}
+	if value, isSome := categoryId.Value(); isSome {
+		category = sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	ends := sql.NullTime{Valid: false}
+	if value, isSome := endsOn.Value(); isSome {
+		ends = sql.NullTime{Valid: true, Time: value}
+	}
+
+	id, err := r.queries.CreateRecurringTransaction(ctx, &dao.CreateRecurringTransactionParams{
+		UserID:           userId,
+		Amount:           int32(amount),
+		Currency:         int32(currencyId),
+		Sender:           sender,
+		Receiver:         receiver,
+		Category:         category,
+		Note:             note,
+		ReceiverCurrency: int32(receiverCurrencyId),
+		ReceiverAmount:   int32(receiverAmount),
+		Schedule:         schedule,
+		StartsOn:         startsOn,
+		EndsOn:           ends,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("creating recurring transaction: %w", err)
+	}
+
+	return model.RecurringTransactionID(id), nil
+}
+
+type UpdateRecurringTransactionFields struct {
+	Amount, ReceiverAmount             model.Optional[int]
+	CurrencyId, ReceiverCurrencyId     model.Optional[int]
+	SenderAccountId, ReceiverAccountId model.Optional[model.Optional[int]]
+	CategoryId                         model.Optional[model.Optional[int]]
+	Note                               model.Optional[string]
+	Schedule                           model.Optional[string]
+	EndsOn                             model.Optional[model.Optional[time.Time]]
+}
+
+func (u *UpdateRecurringTransactionFields) nullAmount() sql.NullInt32 {
+	if value, isSome := u.Amount.Value(); isSome {
+		return sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (u *UpdateRecurringTransactionFields) nullReceiverAmount() sql.NullInt32 {
+	if value, isSome := u.ReceiverAmount.Value(); isSome {
+		return sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (u *UpdateRecurringTransactionFields) nullCurrencyId() sql.NullInt32 {
+	if value, isSome := u.CurrencyId.Value(); isSome && value != 0 {
+		return sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (u *UpdateRecurringTransactionFields) nullReceiverCurrencyId() sql.NullInt32 {
+	if value, isSome := u.ReceiverCurrencyId.Value(); isSome && value != 0 {
+		return sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (u *UpdateRecurringTransactionFields) nullSenderAccountId() sql.NullInt32 {
+	if optionalValue, isSome := u.SenderAccountId.Value(); isSome {
+		if value, isSome := optionalValue.Value(); isSome {
+			return sql.NullInt32{Valid: true, Int32: int32(value)}
+		}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (u *UpdateRecurringTransactionFields) nullReceiverAccountId() sql.NullInt32 {
+	if optionalValue, isSome := u.ReceiverAccountId.Value(); isSome {
+		if value, isSome := optionalValue.Value(); isSome {
+			return sql.NullInt32{Valid: true, Int32: int32(value)}
+		}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (u *UpdateRecurringTransactionFields) nullCategoryId() sql.NullInt32 {
+	if optionalValue, isSome := u.CategoryId.Value(); isSome {
+		if value, isSome := optionalValue.Value(); isSome {
+			return sql.NullInt32{Valid: true, Int32: int32(value)}
+		}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (u *UpdateRecurringTransactionFields) nullNote() sql.NullString {
+	if value, isSome := u.Note.Value(); isSome {
+		return sql.NullString{Valid: true, String: value}
+	}
+
+	return sql.NullString{Valid: false}
+}
+
+func (u *UpdateRecurringTransactionFields) nullSchedule() sql.NullString {
+	if value, isSome := u.Schedule.Value(); isSome {
+		return sql.NullString{Valid: true, String: value}
+	}
+
+	return sql.NullString{Valid: false}
+}
+
+func (u *UpdateRecurringTransactionFields) nullEndsOn() sql.NullTime {
+	if optionalValue, isSome := u.EndsOn.Value(); isSome {
+		if value, isSome := optionalValue.Value(); isSome {
+			return sql.NullTime{Valid: true, Time: value}
+		}
+	}
+
+	return sql.NullTime{Valid: false}
+}
+
+func (r *Repository) UpdateRecurringTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.RecurringTransactionID,
+	fields UpdateRecurringTransactionFields,
+) error {
+	updated, err := r.queries.UpdateRecurringTransaction(ctx, &dao.UpdateRecurringTransactionParams{
+		Amount:           fields.nullAmount(),
+		Currency:         fields.nullCurrencyId(),
+		UpdateSender:     fields.SenderAccountId.IsSome(),
+		Sender:           fields.nullSenderAccountId(),
+		UpdateReceiver:   fields.ReceiverAccountId.IsSome(),
+		Receiver:         fields.nullReceiverAccountId(),
+		UpdateCategory:   fields.CategoryId.IsSome(),
+		Category:         fields.nullCategoryId(),
+		Note:             fields.nullNote(),
+		ReceiverCurrency: fields.nullReceiverCurrencyId(),
+		ReceiverAmount:   fields.nullReceiverAmount(),
+		Schedule:         fields.nullSchedule(),
+		UpdateEndsOn:     fields.EndsOn.IsSome(),
+		EndsOn:           fields.nullEndsOn(),
+		ID:               int32(id),
+		UserID:           userId,
+	})
+	if err != nil {
+		return fmt.Errorf("updating recurring transaction: %w", err)
+	}
+
+	if updated == 0 {
+		return ErrRecurringTransactionNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) SetRecurringTransactionPaused(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.RecurringTransactionID,
+	paused bool,
+) error {
+	updated, err := r.queries.SetRecurringTransactionPaused(ctx, &dao.SetRecurringTransactionPausedParams{
+		Paused: paused,
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("pausing recurring transaction: %w", err)
+	}
+
+	if updated == 0 {
+		return ErrRecurringTransactionNotFound
+	}
+
+	return nil
+}
+
+// DeleteRecurringTransaction deletes the rule. Transactions it already
+// generated are kept.
+func (r *Repository) DeleteRecurringTransaction(ctx context.Context, userId uuid.UUID, id model.RecurringTransactionID) error {
+	deleted, err := r.queries.DeleteRecurringTransaction(ctx, &dao.DeleteRecurringTransactionParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting recurring transaction: %w", err)
+	}
+
+	if deleted == 0 {
+		return ErrRecurringTransactionNotFound
+	}
+
+	return nil
+}
+
+// MaterializeRecurringTransactionOccurrence creates the transaction for one
+// occurrence of a recurring transaction, unless that occurrence was already
+// generated. It reports whether a transaction was created.
+func (r *Repository) MaterializeRecurringTransactionOccurrence(
+	ctx context.Context,
+	recurringTransaction DueRecurringTransaction,
+	occurrence time.Time,
+) (created bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("recurring transaction occurrence rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	claimed, err := queries.ClaimRecurringTransactionOccurrence(ctx, &dao.ClaimRecurringTransactionOccurrenceParams{
+		RecurringTransactionID: int32(recurringTransaction.ID),
+		OccurrenceDate:         occurrence,
+	})
+	if err != nil {
+		err = fmt.Errorf("claiming occurrence: %w", err)
+		return
+	}
+
+	if claimed > 0 {
+		sender := model.None[int]()
+		if value, isSome := recurringTransaction.Sender.Value(); isSome {
+			sender = model.Some(int(value))
+		}
+
+		receiver := model.None[int]()
+		if value, isSome := recurringTransaction.Receiver.Value(); isSome {
+			receiver = model.Some(int(value))
+		}
+
+		category := model.None[int]()
+		if value, isSome := recurringTransaction.Category.Value(); isSome {
+			category = model.Some(int(value))
+		}
+
+		var transactionId model.TransactionID
+		transactionId, err = createTransaction(ctx, queries, recurringTransaction.UserID, string(recurringTransaction.Owner), NewTransactionFields{
+			OwnerEmail:         string(recurringTransaction.Owner),
+			Amount:             recurringTransaction.Amount,
+			ReceiverAmount:     recurringTransaction.ReceiverAmount,
+			CurrencyId:         int(recurringTransaction.Currency),
+			ReceiverCurrencyId: int(recurringTransaction.ReceiverCurrency),
+			SenderAccountId:    sender,
+			ReceiverAccountId:  receiver,
+			CategoryId:         category,
+			Date:               model.DateAtNoon(occurrence, recurringTransaction.Location),
+			Note:               recurringTransaction.Note,
+			// Occurrences are expected to look alike.
+			AllowDuplicates: true,
+		})
+		if err != nil {
+			return
+		}
+
+		err = queries.SetRecurringTransactionOccurrenceTransaction(ctx, &dao.SetRecurringTransactionOccurrenceTransactionParams{
+			TransactionID:          sql.NullInt32{Valid: true, Int32: int32(transactionId)},
+			RecurringTransactionID: int32(recurringTransaction.ID),
+			OccurrenceDate:         occurrence,
+		})
+		if err != nil {
+			err = fmt.Errorf("linking occurrence to its transaction: %w", err)
+			return
+		}
+	}
+
+	err = queries.AdvanceRecurringTransaction(ctx, &dao.AdvanceRecurringTransactionParams{
+		MaterializedThrough: occurrence,
+		ID:                  int32(recurringTransaction.ID),
+	})
+	if err != nil {
+		err = fmt.Errorf("advancing recurring transaction: %w", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("committing transaction: %w", err)
+		return
+	}
+
+	return claimed > 0, nil
+}
+
+// AdvanceRecurringTransaction records that every occurrence up to the given
+// date has been materialized.
+func (r *Repository) AdvanceRecurringTransaction(ctx context.Context, id model.RecurringTransactionID, materializedThrough time.Time) error {
+	err := r.queries.AdvanceRecurringTransaction(ctx, &dao.AdvanceRecurringTransactionParams{
+		MaterializedThrough: materializedThrough,
+		ID:                  int32(id),
+	})
+	if err != nil {
+		return fmt.Errorf("advancing recurring transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,596 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRuleNotFound             = errors.New("rule not found")
+	ErrRuleListedTwice          = errors.New("a rule is listed twice")
+	ErrRuleWithoutCondition     = errors.New("a rule needs at least one condition")
+	ErrRuleWithoutAction        = errors.New("a rule needs at least one action")
+	ErrInvalidNotePattern       = errors.New("invalid note pattern")
+	ErrCurrencyNotFound         = errors.New("currency not found")
+	ErrTransactionGroupNotFound = errors.New("transaction group not found")
+)
+
+func ruleFromDao(ruleDao dao.GetAllRulesRow) model.Rule {
+	tags := make([]model.TagID, len(ruleDao.Tags))
+	for i, tagId := range ruleDao.Tags {
+		tags[i] = model.TagID(tagId)
+	}
+
+	return model.Rule{
+		ID:             model.RuleID(ruleDao.ID),
+		Name:           ruleDao.Name,
+		Priority:       int(ruleDao.Priority),
+		Enabled:        ruleDao.Enabled,
+		StopProcessing: ruleDao.StopProcessing,
+		Conditions: model.RuleConditions{
+			NotePattern: nullStringToModel(ruleDao.NotePattern),
+			MinAmount:   nullIntToModel[int](ruleDao.MinAmount),
+			MaxAmount:   nullIntToModel[int](ruleDao.MaxAmount),
+			Account:     nullIntToModel[model.AccountID](ruleDao.AccountID),
+			Currency:    nullIntToModel[model.CurrencyID](ruleDao.CurrencyID),
+			Payee:       nullIntToModel[model.PayeeID](ruleDao.PayeeID),
+		},
+		Actions: model.RuleActions{
+			Category:         nullIntToModel[model.CategoryID](ruleDao.SetCategoryID),
+			RewriteNote:      nullStringToModel(ruleDao.RewriteNote),
+			Tags:             tags,
+			TransactionGroup: nullIntToModel[model.TransactionGroupID](ruleDao.SetTransactionGroupID),
+		},
+	}
+}
+
+func nullIntToModel[T ~int](value sql.NullInt32) model.Optional[T] {
+	if !value.Valid {
+		return model.None[T]()
+	}
+
+	return model.Some(T(value.Int32))
+}
+
+func nullStringToModel(value sql.NullString) model.Optional[string] {
+	if !value.Valid {
+		return model.None[string]()
+	}
+
+	return model.Some(value.String)
+}
+
+func nullIntFromModel[T ~int](value model.Optional[T]) sql.NullInt32 {
+	if v, isSome := value.Value(); isSome {
+		return sql.NullInt32{Valid: true, Int32: int32(v)}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func nullStringFromModel(value model.Optional[string]) sql.NullString {
+	if v, isSome := value.Value(); isSome {
+		return sql.NullString{Valid: true, String: v}
+	}
+
+	return sql.NullString{Valid: false}
+}
+
+func (r *Repository) GetAllRules(ctx context.Context, userId uuid.UUID) ([]model.Rule, error) {
+	return getAllRules(ctx, r.queries, userId)
+}
+
+func getAllRules(ctx context.Context, queries *dao.Queries, userId uuid.UUID) ([]model.Rule, error) {
+	rulesDao, err := queries.GetAllRules(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("getting rules: %w", err)
+	}
+
+	rules := make([]model.Rule, len(rulesDao))
+	for i, ruleDao := range rulesDao {
+		rules[i] = ruleFromDao(ruleDao)
+	}
+
+	return rules, nil
+}
+
+// checkRule validates a rule before saving it: it has to check and change
+// something, its note pattern has to compile, and everything it refers to
+// has to belong to the user.
+func checkRule(ctx context.Context, queries *dao.Queries, userId uuid.UUID, rule model.Rule) ([]int32, error) {
+	conditions := rule.Conditions
+	if conditions.NotePattern.IsNone() && conditions.MinAmount.IsNone() && conditions.MaxAmount.IsNone() &&
+		conditions.Account.IsNone() && conditions.Currency.IsNone() && conditions.Payee.IsNone() {
+		return nil, ErrRuleWithoutCondition
+	}
+
+	actions := rule.Actions
+	if actions.Category.IsNone() && actions.RewriteNote.IsNone() && len(actions.Tags) == 0 &&
+		actions.TransactionGroup.IsNone() {
+		return nil, ErrRuleWithoutAction
+	}
+
+	if _, err := model.NewRuleSet([]model.Rule{rule}); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidNotePattern, errors.Unwrap(err))
+	}
+
+	tagIds := make([]int32, len(actions.Tags))
+	for i, tagId := range actions.Tags {
+		tagIds[i] = int32(tagId)
+	}
+	tagIds = uniqueIds(tagIds)
+
+	found, err := queries.CheckRuleReferences(ctx, &dao.CheckRuleReferencesParams{
+		AccountID:          nullIntFromModel(conditions.Account),
+		UserID:             userId,
+		CurrencyID:         nullIntFromModel(conditions.Currency),
+		PayeeID:            nullIntFromModel(conditions.Payee),
+		CategoryID:         nullIntFromModel(actions.Category),
+		TransactionGroupID: nullIntFromModel(actions.TransactionGroup),
+		TagIds:             tagIds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checking rule references: %w", err)
+	}
+
+	switch {
+	case !found.AccountFound:
+		return nil, ErrAccountNotFound
+	case !found.CurrencyFound:
+		return nil, ErrCurrencyNotFound
+	case !found.PayeeFound:
+		return nil, ErrPayeeNotFound
+	case !found.CategoryFound:
+		return nil, ErrCategoryNotFound
+	case !found.TransactionGroupFound:
+		return nil, ErrTransactionGroupNotFound
+	case int(found.TagsFound) != len(tagIds):
+		return nil, ErrTagNotFound
+	}
+
+	return tagIds, nil
+}
+
+func (r *Repository) CreateRule(ctx context.Context, userId uuid.UUID, rule model.Rule) (createdRuleId model.RuleID, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("rule creation rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	tagIds, err := checkRule(ctx, queries, userId, rule)
+	if err != nil {
+		return 0, err
+	}
+
+	ruleId, err := queries.CreateRule(ctx, &dao.CreateRuleParams{
+		UserID:                userId,
+		Name:                  rule.Name,
+		Priority:              int32(rule.Priority),
+		Enabled:               rule.Enabled,
+		StopProcessing:        rule.StopProcessing,
+		NotePattern:           nullStringFromModel(rule.Conditions.NotePattern),
+		MinAmount:             nullIntFromModel(rule.Conditions.MinAmount),
+		MaxAmount:             nullIntFromModel(rule.Conditions.MaxAmount),
+		AccountID:             nullIntFromModel(rule.Conditions.Account),
+		CurrencyID:            nullIntFromModel(rule.Conditions.Currency),
+		PayeeID:               nullIntFromModel(rule.Conditions.Payee),
+		SetCategoryID:         nullIntFromModel(rule.Actions.Category),
+		RewriteNote:           nullStringFromModel(rule.Actions.RewriteNote),
+		SetTransactionGroupID: nullIntFromModel(rule.Actions.TransactionGroup),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("creating rule: %w", err)
+	}
+
+	if len(tagIds) > 0 {
+		if err = queries.AddRuleTags(ctx, &dao.AddRuleTagsParams{RuleID: ruleId, TagIds: tagIds}); err != nil {
+			return 0, fmt.Errorf("adding rule tags: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return model.RuleID(ruleId), nil
+}
+
+// UpdateRule replaces every setting, condition and action of a rule.
+func (r *Repository) UpdateRule(ctx context.Context, userId uuid.UUID, rule model.Rule) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("rule update rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	tagIds, err := checkRule(ctx, queries, userId, rule)
+	if err != nil {
+		return err
+	}
+
+	updated, err := queries.UpdateRule(ctx, &dao.UpdateRuleParams{
+		Name:                  rule.Name,
+		Priority:              int32(rule.Priority),
+		Enabled:               rule.Enabled,
+		StopProcessing:        rule.StopProcessing,
+		NotePattern:           nullStringFromModel(rule.Conditions.NotePattern),
+		MinAmount:             nullIntFromModel(rule.Conditions.MinAmount),
+		MaxAmount:             nullIntFromModel(rule.Conditions.MaxAmount),
+		AccountID:             nullIntFromModel(rule.Conditions.Account),
+		CurrencyID:            nullIntFromModel(rule.Conditions.Currency),
+		PayeeID:               nullIntFromModel(rule.Conditions.Payee),
+		SetCategoryID:         nullIntFromModel(rule.Actions.Category),
+		RewriteNote:           nullStringFromModel(rule.Actions.RewriteNote),
+		SetTransactionGroupID: nullIntFromModel(rule.Actions.TransactionGroup),
+		ID:                    int32(rule.ID),
+		UserID:                userId,
+	})
+	if err != nil {
+		return fmt.Errorf("updating rule: %w", err)
+	}
+	if updated == 0 {
+		return ErrRuleNotFound
+	}
+
+	if err = queries.DeleteRuleTags(ctx, int32(rule.ID)); err != nil {
+		return fmt.Errorf("deleting rule tags: %w", err)
+	}
+
+	if len(tagIds) > 0 {
+		if err = queries.AddRuleTags(ctx, &dao.AddRuleTagsParams{RuleID: int32(rule.ID), TagIds: tagIds}); err != nil {
+			return fmt.Errorf("adding rule tags: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteRule(ctx context.Context, userId uuid.UUID, id model.RuleID) error {
+	deleted, err := r.queries.DeleteRule(ctx, &dao.DeleteRuleParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting rule: %w", err)
+	}
+	if deleted == 0 {
+		return ErrRuleNotFound
+	}
+
+	return nil
+}
+
+// ReorderRules gives the rules priorities following their order in the list.
+// Rules left out of the list keep their priority.
+func (r *Repository) ReorderRules(ctx context.Context, userId uuid.UUID, ids []model.RuleID) error {
+	seen := make(map[model.RuleID]bool, len(ids))
+	ruleIds := make([]int32, len(ids))
+	for i, id := range ids {
+		if seen[id] {
+			return ErrRuleListedTwice
+		}
+
+		seen[id] = true
+		ruleIds[i] = int32(id)
+	}
+
+	reordered, err := r.queries.ReorderRules(ctx, &dao.ReorderRulesParams{
+		Ids:    ruleIds,
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("reordering rules: %w", err)
+	}
+	if int(reordered) != len(ruleIds) {
+		return ErrRuleNotFound
+	}
+
+	return nil
+}
+
+// ruleSetFor returns the rules to run: the given ones, whether enabled or
+// not, or every enabled rule of the user when none are given.
+func ruleSetFor(ctx context.Context, queries *dao.Queries, userId uuid.UUID, ids []model.RuleID) (model.RuleSet, error) {
+	rules, err := getAllRules(ctx, queries, userId)
+	if err != nil {
+		return model.RuleSet{}, err
+	}
+
+	selected := make([]model.Rule, 0, len(rules))
+	for _, rule := range rules {
+		if (len(ids) == 0 && rule.Enabled) || slices.Contains(ids, rule.ID) {
+			selected = append(selected, rule)
+		}
+	}
+
+	for _, id := range ids {
+		if !slices.ContainsFunc(selected, func(rule model.Rule) bool { return rule.ID == id }) {
+			return model.RuleSet{}, ErrRuleNotFound
+		}
+	}
+
+	return model.NewRuleSet(selected)
+}
+
+// applyRulesToNewTransaction runs the enabled rules of the user on a
+// transaction about to be created. Rules only fill in the category and
+// transaction group when the transaction has none.
+func applyRulesToNewTransaction(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	fields NewTransactionFields,
+) (NewTransactionFields, error) {
+	ruleSet, err := ruleSetFor(ctx, queries, userId, nil)
+	if err != nil {
+		return fields, err
+	}
+
+	category := model.None[model.CategoryID]()
+	if value, isSome := fields.CategoryId.Value(); isSome {
+		category = model.Some(model.CategoryID(value))
+	}
+
+	transactionGroup := model.None[model.TransactionGroupID]()
+	if value, isSome := fields.TransactionGroupData.Value(); isSome {
+		transactionGroup = model.Some(value.TransactionGroup)
+	}
+
+	target, matched := ruleSet.Apply(model.RuleTarget{
+		Amount:           fields.Amount,
+		Currency:         model.CurrencyID(fields.CurrencyId),
+		Sender:           optionalIntToModel[model.AccountID](fields.SenderAccountId),
+		Receiver:         optionalIntToModel[model.AccountID](fields.ReceiverAccountId),
+		Payee:            fields.PayeeId,
+		Note:             fields.Note,
+		Category:         category,
+		Tags:             fields.Tags,
+		TransactionGroup: transactionGroup,
+	}, false)
+	if len(matched) == 0 {
+		return fields, nil
+	}
+
+	if value, isSome := target.Category.Value(); isSome {
+		fields.CategoryId = model.Some(int(value))
+	}
+
+	if value, isSome := target.TransactionGroup.Value(); isSome && fields.TransactionGroupData.IsNone() {
+		fields.TransactionGroupData = model.Some(model.GroupedTransactionData{TransactionGroup: value})
+	}
+
+	fields.Note = target.Note
+	fields.Tags = target.Tags
+
+	return fields, nil
+}
+
+func optionalIntToModel[T ~int](value model.Optional[int]) model.Optional[T] {
+	if v, isSome := value.Value(); isSome {
+		return model.Some(T(v))
+	}
+
+	return model.None[T]()
+}
+
+type ruleChange struct {
+	outcome model.RuleOutcome
+	fields  UpdateTransactionFields
+}
+
+// evaluateRules runs rules against the existing transactions of the user and
+// returns the ones they would change.
+func evaluateRules(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	ids []model.RuleID,
+	overwrite bool,
+) ([]ruleChange, error) {
+	ruleSet, err := ruleSetFor(ctx, queries, userId, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := queries.GetRuleTargets(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("getting transactions to run rules on: %w", err)
+	}
+
+	changes := make([]ruleChange, 0)
+	for _, row := range rows {
+		tags := make([]model.TagID, len(row.Tags))
+		for i, tagId := range row.Tags {
+			tags[i] = model.TagID(tagId)
+		}
+
+		before := model.RuleTarget{
+			Amount:           int(row.Amount),
+			Currency:         model.CurrencyID(row.Currency),
+			Sender:           nullIntToModel[model.AccountID](row.Sender),
+			Receiver:         nullIntToModel[model.AccountID](row.Receiver),
+			Payee:            nullIntToModel[model.PayeeID](row.PayeeID),
+			Note:             row.Note,
+			Category:         nullIntToModel[model.CategoryID](row.Category),
+			Tags:             tags,
+			TransactionGroup: nullIntToModel[model.TransactionGroupID](row.TransactionGroupID),
+		}
+
+		after, matched := ruleSet.Apply(before, overwrite)
+		if len(matched) == 0 {
+			continue
+		}
+
+		fieldChanges := make([]model.TransactionFieldChange, 0)
+		var fields UpdateTransactionFields
+
+		for _, value := range []struct {
+			field         string
+			before, after any
+		}{
+			{"category", before.Category, after.Category},
+			{"note", before.Note, after.Note},
+			{"tags", before.Tags, after.Tags},
+			{"transaction_group", before.TransactionGroup, after.TransactionGroup},
+		} {
+			beforeJson, err := json.Marshal(value.before)
+			if err != nil {
+				return nil, fmt.Errorf("encoding previous %s: %w", value.field, err)
+			}
+
+			afterJson, err := json.Marshal(value.after)
+			if err != nil {
+				return nil, fmt.Errorf("encoding new %s: %w", value.field, err)
+			}
+
+			if bytes.Equal(beforeJson, afterJson) {
+				continue
+			}
+
+			fieldChanges = append(fieldChanges, model.TransactionFieldChange{
+				Field:  value.field,
+				Before: string(beforeJson),
+				After:  string(afterJson),
+			})
+
+			switch value.field {
+			case "category":
+				fields.CategoryId = model.Some(optionalToInt(after.Category))
+			case "note":
+				fields.Note = model.Some(after.Note)
+			case "tags":
+				fields.Tags = model.Some(after.Tags)
+			case "transaction_group":
+				if transactionGroup, isSome := after.TransactionGroup.Value(); isSome {
+					fields.UpdateTransactionGroupAdditionalData = model.Some(model.Some(UpdateTransactionGroupAdditionalData{
+						TransactionGroupId: model.Some(int(transactionGroup)),
+					}))
+				}
+			}
+		}
+
+		if len(fieldChanges) == 0 {
+			continue
+		}
+
+		changes = append(changes, ruleChange{
+			outcome: model.RuleOutcome{
+				Transaction: model.TransactionID(row.ID),
+				Rules:       matched,
+				Changes:     fieldChanges,
+			},
+			fields: fields,
+		})
+	}
+
+	return changes, nil
+}
+
+func optionalToInt[T ~int](value model.Optional[T]) model.Optional[int] {
+	if v, isSome := value.Value(); isSome {
+		return model.Some(int(v))
+	}
+
+	return model.None[int]()
+}
+
+// PreviewRules reports which existing transactions the rules would change,
+// and how, without changing them. With no rule ids, every enabled rule runs.
+// Unless overwrite is set, rules only fill in a missing category or
+// transaction group.
+func (r *Repository) PreviewRules(
+	ctx context.Context,
+	userId uuid.UUID,
+	ids []model.RuleID,
+	overwrite bool,
+) ([]model.RuleOutcome, error) {
+	changes, err := evaluateRules(ctx, r.queries, userId, ids, overwrite)
+	if err != nil {
+		return nil, err
+	}
+
+	outcomes := make([]model.RuleOutcome, len(changes))
+	for i, change := range changes {
+		outcomes[i] = change.outcome
+	}
+
+	return outcomes, nil
+}
+
+// ApplyRules runs the rules against the existing transactions of the user,
+// as PreviewRules describes, and saves the changes. Every changed transaction
+// gets an entry in its history.
+func (r *Repository) ApplyRules(
+	ctx context.Context,
+	userId uuid.UUID,
+	ids []model.RuleID,
+	overwrite bool,
+) (outcomes []model.RuleOutcome, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("rule application rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	changes, err := evaluateRules(ctx, queries, userId, ids, overwrite)
+	if err != nil {
+		return nil, err
+	}
+
+	outcomes = make([]model.RuleOutcome, len(changes))
+	for i, change := range changes {
+		if err = updateTransaction(ctx, queries, userId, change.outcome.Transaction, change.fields); err != nil {
+			return nil, fmt.Errorf("applying rules to transaction %d: %w", change.outcome.Transaction, err)
+		}
+
+		outcomes[i] = change.outcome
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return outcomes, nil
+}
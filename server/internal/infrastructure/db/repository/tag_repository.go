@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTagNotFound  = errors.New("tag not found")
+	ErrTagNameTaken = errors.New("a tag with this name already exists")
+)
+
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+func (r *Repository) GetAllTags(ctx context.Context, userId uuid.UUID) ([]model.Tag, error) {
+	tagsDao, err := r.queries.GetAllTags(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]model.Tag, len(tagsDao))
+	for i, tagDao := range tagsDao {
+		tags[i] = model.Tag{
+			ID:   model.TagID(tagDao.ID),
+			Name: tagDao.Name,
+		}
+	}
+
+	return tags, nil
+}
+
+func (r *Repository) CreateTag(ctx context.Context, userId uuid.UUID, name string) (model.TagID, error) {
+	tagId, err := r.queries.CreateTag(ctx, &dao.CreateTagParams{
+		UserID: userId,
+		Name:   name,
+	})
+	if isUniqueViolation(err) {
+		return 0, ErrTagNameTaken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("creating tag: %w", err)
+	}
+
+	return model.TagID(tagId), nil
+}
+
+func (r *Repository) RenameTag(ctx context.Context, userId uuid.UUID, id model.TagID, name string) error {
+	affected, err := r.queries.RenameTag(ctx, &dao.RenameTagParams{
+		Name:   name,
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if isUniqueViolation(err) {
+		return ErrTagNameTaken
+	}
+	if err != nil {
+		return fmt.Errorf("renaming tag: %w", err)
+	}
+	if affected == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// MergeTags moves every transaction tagged with one of the sources onto the
+// target, then deletes the sources.
+func (r *Repository) MergeTags(
+	ctx context.Context,
+	userId uuid.UUID,
+	sourceIds []model.TagID,
+	targetId model.TagID,
+) (err error) {
+	sources := make([]int32, 0, len(sourceIds))
+	for _, id := range sourceIds {
+		if id != targetId {
+			sources = append(sources, int32(id))
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("tag merge rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	_, err = queries.GetTag(ctx, &dao.GetTagParams{ID: int32(targetId), UserID: userId})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrTagNotFound
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("getting merge target tag: %w", err)
+		return
+	}
+
+	err = queries.RetagTransactions(ctx, &dao.RetagTransactionsParams{
+		TargetID:  int32(targetId),
+		SourceIds: sources,
+		UserID:    userId,
+	})
+	if err != nil {
+		err = fmt.Errorf("retagging transactions: %w", err)
+		return
+	}
+
+	deleted, err := queries.DeleteTags(ctx, &dao.DeleteTagsParams{Ids: sources, UserID: userId})
+	if err != nil {
+		err = fmt.Errorf("deleting merged tags: %w", err)
+		return
+	}
+	if int(deleted) != len(uniqueIds(sources)) {
+		err = ErrTagNotFound
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("committing transaction: %w", err)
+		return
+	}
+
+	return nil
+}
+
+// DeleteTag deletes the tag and removes it from every transaction.
+func (r *Repository) DeleteTag(ctx context.Context, userId uuid.UUID, id model.TagID) error {
+	deleted, err := r.queries.DeleteTags(ctx, &dao.DeleteTagsParams{
+		Ids:    []int32{int32(id)},
+		UserID: userId,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting tag: %w", err)
+	}
+	if deleted == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// replaceTransactionTags sets the tags of a transaction, failing with
+// ErrTagNotFound if one of them does not belong to the user.
+func replaceTransactionTags(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	transactionId int32,
+	tagIds []model.TagID,
+) error {
+	if err := queries.DeleteTransactionTags(ctx, transactionId); err != nil {
+		return fmt.Errorf("deleting transaction tags: %w", err)
+	}
+
+	ids := make([]int32, len(tagIds))
+	for i, id := range tagIds {
+		ids[i] = int32(id)
+	}
+	ids = uniqueIds(ids)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	added, err := queries.AddTransactionTags(ctx, &dao.AddTransactionTagsParams{
+		TransactionID: transactionId,
+		TagIds:        ids,
+		UserID:        userId,
+	})
+	if err != nil {
+		return fmt.Errorf("adding transaction tags: %w", err)
+	}
+	if int(added) != len(ids) {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+func uniqueIds(ids []int32) []int32 {
+	seen := make(map[int32]bool, len(ids))
+	unique := make([]int32, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"chagnon.dev/budget-server/internal/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	attributionStatePending  = "pending"
+	attributionStateAccepted = "accepted"
+	attributionStateDisputed = "disputed"
+)
+
+var (
+	// ErrForeignOwnerFields is returned when a transaction recorded for another
+	// member references data that only its recorder can see.
+	ErrForeignOwnerFields = errors.New("a transaction recorded for another member cannot set accounts, a category, a payee, line items or tags")
+	ErrForeignCurrency    = errors.New("the owner of the transaction has no currency with the same name")
+	ErrPendingAttribution = errors.New("an attribution can only be accepted or disputed")
+	ErrNotAttributed      = errors.New("transaction was not recorded by another member")
+)
+
+func AttributionStateFromDao(state string) (value model.AttributionState, err error) {
+	switch state {
+	case attributionStatePending:
+		return model.AttributionStatePending, nil
+	case attributionStateAccepted:
+		return model.AttributionStateAccepted, nil
+	case attributionStateDisputed:
+		return model.AttributionStateDisputed, nil
+	default:
+		return value, fmt.Errorf("unknown AttributionState %s", state)
+	}
+}
+
+func AttributionStateToDao(state model.AttributionState) (value string, err error) {
+	switch state {
+	case model.AttributionStatePending:
+		return attributionStatePending, nil
+	case model.AttributionStateAccepted:
+		return attributionStateAccepted, nil
+	case model.AttributionStateDisputed:
+		return attributionStateDisputed, nil
+	default:
+		return value, fmt.Errorf("unknown AttributionState %d", state)
+	}
+}
+
+type AttributionValue struct {
+	RecordedBy    *string `json:"recorded_by"`
+	State         string  `json:"state"`
+	DisputeReason string  `json:"dispute_reason"`
+}
+
+func attributionFromDao(attributionDao json.RawMessage) (model.Optional[model.TransactionAttribution], error) {
+	if attributionDao == nil {
+		return model.None[model.TransactionAttribution](), nil
+	}
+
+	var value AttributionValue
+	if err := json.Unmarshal(attributionDao, &value); err != nil {
+		return model.None[model.TransactionAttribution](), fmt.Errorf("parsing transaction attribution: %s", err)
+	}
+
+	state, err := AttributionStateFromDao(value.State)
+	if err != nil {
+		return model.None[model.TransactionAttribution](), err
+	}
+
+	recordedBy := model.None[model.Email]()
+	if value.RecordedBy != nil {
+		recordedBy = model.Some(model.Email(*value.RecordedBy))
+	}
+
+	return model.Some(model.TransactionAttribution{
+		RecordedBy:    recordedBy,
+		State:         state,
+		DisputeReason: value.DisputeReason,
+	}), nil
+}
+
+// attributeToOwner checks that a transaction recorded by a user for another
+// member can be attributed to that member, and rewrites its fields in terms
+// of the owner's data. Both have to have joined the transaction's group. The
+// transaction is paid from the owner's hidden default account, and its
+// currencies are matched to the owner's by name.
+func attributeToOwner(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	fields NewTransactionFields,
+) (uuid.UUID, NewTransactionFields, error) {
+	groupData, isSome := fields.TransactionGroupData.Value()
+	if !isSome {
+		return uuid.UUID{}, fields, ErrForeignOwner
+	}
+
+	if fields.SenderAccountId.IsSome() || fields.ReceiverAccountId.IsSome() || fields.CategoryId.IsSome() ||
+		fields.PayeeId.IsSome() || len(fields.LineItems) > 0 || len(fields.Tags) > 0 {
+		return uuid.UUID{}, fields, ErrForeignOwnerFields
+	}
+
+	owner, err := queries.GetAttributionPayer(ctx, &dao.GetAttributionPayerParams{
+		PayerEmail:         fields.OwnerEmail,
+		TransactionGroupID: int32(groupData.TransactionGroup),
+		RecorderID:         userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.UUID{}, fields, ErrForeignOwner
+	}
+	if err != nil {
+		return uuid.UUID{}, fields, fmt.Errorf("getting transaction owner: %w", err)
+	}
+
+	currencyId, err := matchingCurrency(ctx, queries, userId, owner.ID, fields.CurrencyId)
+	if err != nil {
+		return uuid.UUID{}, fields, err
+	}
+
+	receiverCurrencyId := currencyId
+	if fields.ReceiverCurrencyId != fields.CurrencyId {
+		receiverCurrencyId, err = matchingCurrency(ctx, queries, userId, owner.ID, fields.ReceiverCurrencyId)
+		if err != nil {
+			return uuid.UUID{}, fields, err
+		}
+	}
+
+	if financialIncome, isSome := fields.FinancialIncomeData.Value(); isSome {
+		relatedCurrency, err := matchingCurrency(ctx, queries, userId, owner.ID, int(financialIncome.RelatedCurrency))
+		if err != nil {
+			return uuid.UUID{}, fields, err
+		}
+
+		fields.FinancialIncomeData = model.Some(model.FinancialIncomeData{
+			RelatedCurrency: model.CurrencyID(relatedCurrency),
+		})
+	}
+
+	fields.CurrencyId = currencyId
+	fields.ReceiverCurrencyId = receiverCurrencyId
+	if owner.HiddenDefaultAccount.Valid {
+		fields.SenderAccountId = model.Some(int(owner.HiddenDefaultAccount.Int32))
+	}
+
+	return owner.ID, fields, nil
+}
+
+func matchingCurrency(ctx context.Context, queries *dao.Queries, userId, ownerId uuid.UUID, currencyId int) (int, error) {
+	matchingId, err := queries.GetMatchingCurrency(ctx, &dao.GetMatchingCurrencyParams{
+		CurrencyID: int32(currencyId),
+		UserID:     userId,
+		PayerID:    ownerId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrForeignCurrency
+	}
+	if err != nil {
+		return 0, fmt.Errorf("matching currency of transaction owner: %w", err)
+	}
+
+	return int(matchingId), nil
+}
+
+// SetTransactionAttributionState lets the owner of a transaction recorded by
+// another member accept it or dispute it, with a reason. The dispute reason
+// is cleared when accepting.
+func (r *Repository) SetTransactionAttributionState(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.TransactionID,
+	state model.AttributionState,
+	disputeReason string,
+) (err error) {
+	if state == model.AttributionStatePending {
+		return ErrPendingAttribution
+	}
+
+	stateDao, err := AttributionStateToDao(state)
+	if err != nil {
+		return err
+	}
+
+	if state == model.AttributionStateAccepted {
+		disputeReason = ""
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("transaction attribution rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	previousState, err := queries.SetTransactionAttributionState(ctx, &dao.SetTransactionAttributionStateParams{
+		State:         stateDao,
+		DisputeReason: disputeReason,
+		TransactionID: int32(id),
+		UserID:        userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err = getTransactionSnapshot(ctx, queries, userId, id); err != nil {
+			return err
+		}
+
+		return ErrNotAttributed
+	}
+	if err != nil {
+		return fmt.Errorf("setting transaction attribution state: %w", err)
+	}
+
+	if previousState != stateDao {
+		before, err := json.Marshal(previousState)
+		if err != nil {
+			return fmt.Errorf("encoding previous attribution state: %w", err)
+		}
+
+		after, err := json.Marshal(stateDao)
+		if err != nil {
+			return fmt.Errorf("encoding new attribution state: %w", err)
+		}
+
+		err = recordTransactionHistory(ctx, queries, userId, id, historyActionUpdated, []FieldChangeValue{{
+			Field:  "attribution",
+			Before: before,
+			After:  after,
+		}})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
@@ -104,6 +104,7 @@ func (r *Repository) GetUserTransactionGroups(ctx context.Context, userEmail str
 			Category:         category,
 			Members:          members,
 			Hidden:           transactionGroupDao.Hidden,
+			Version:          int(transactionGroupDao.Version),
 		}
 	}
 
@@ -179,6 +180,9 @@ type UpdateTransactionGroupFields struct {
 	CategoryId model.Optional[model.CategoryID]
 	Members    model.Optional[[]UpdateTransactionGroupMembersField]
 	Hidden     model.Optional[bool]
+	// ExpectedVersion, when set, refuses the update with ErrVersionConflict
+	// if the transaction group is no longer at that version.
+	ExpectedVersion model.Optional[int]
 }
 
 func (u *UpdateTransactionGroupFields) nullName() sql.NullString {
@@ -242,6 +246,18 @@ func (r *Repository) UpdateTransactionGroup(
 		}
 	}()
 
+	if expectedVersion, isSome := fields.ExpectedVersion.Value(); isSome {
+		err = checkVersion(expectedVersion, ErrTransactionGroupNotFound, func() (int32, error) {
+			return r.queries.WithTx(tx).GetTransactionGroupVersion(ctx, &dao.GetTransactionGroupVersionParams{
+				TransactionGroupID: int32(id),
+				UserEmail:          email,
+			})
+		})
+		if err != nil {
+			return
+		}
+	}
+
 	previousMembers, err := r.queries.WithTx(tx).GetTransactionGroupMembers(ctx, int32(id))
 	if err != nil {
 		err = fmt.Errorf("getting previous transaction group members: %w", err)
@@ -0,0 +1,347 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/dao"
+	"github.com/google/uuid"
+)
+
+const (
+	historyActionCreated  = "created"
+	historyActionUpdated  = "updated"
+	historyActionDeleted  = "deleted"
+	historyActionRestored = "restored"
+)
+
+func transactionHistoryActionFromDao(action string) (value model.TransactionHistoryAction, err error) {
+	switch action {
+	case historyActionCreated:
+		return model.TransactionHistoryActionCreated, nil
+	case historyActionUpdated:
+		return model.TransactionHistoryActionUpdated, nil
+	case historyActionDeleted:
+		return model.TransactionHistoryActionDeleted, nil
+	case historyActionRestored:
+		return model.TransactionHistoryActionRestored, nil
+	default:
+		return value, fmt.Errorf("unknown TransactionHistoryAction %s", action)
+	}
+}
+
+type FieldChangeValue struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+type transactionFieldValue struct {
+	field string
+	value any
+}
+
+func splitTypeOverrideName(splitType model.SplitTypeOverride) string {
+	switch splitType {
+	case model.SplitTypeOverrideEqual:
+		return "equal"
+	case model.SplitTypeOverridePercentage:
+		return "percentage"
+	case model.SplitTypeOverrideShare:
+		return "shares"
+	case model.SplitTypeOverrideExactAmount:
+		return "exact_amount"
+	default:
+		return fmt.Sprintf("unknown (%d)", splitType)
+	}
+}
+
+func transactionStatusName(status model.TransactionStatus) string {
+	switch status {
+	case model.TransactionStatusPending:
+		return "pending"
+	case model.TransactionStatusCleared:
+		return "cleared"
+	case model.TransactionStatusReconciled:
+		return "reconciled"
+	default:
+		return fmt.Sprintf("unknown (%d)", status)
+	}
+}
+
+// transactionFieldValues lists the fields of a transaction tracked by its
+// history, in the order changes are reported. Attachments are left out as
+// they are added and removed on their own.
+func transactionFieldValues(transaction model.Transaction) []transactionFieldValue {
+	relatedCurrency := model.None[model.CurrencyID]()
+	if financialIncome, isSome := transaction.FinancialIncomeData.Value(); isSome {
+		relatedCurrency = model.Some(financialIncome.RelatedCurrency)
+	}
+
+	transactionGroup := model.None[model.TransactionGroupID]()
+	splitType := model.None[string]()
+	var splitMembers []MemberValueOverride
+	if groupData, isSome := transaction.GroupedTransactionData.Value(); isSome {
+		transactionGroup = model.Some(groupData.TransactionGroup)
+
+		if splitOverride, isSome := groupData.SplitOverride.Value(); isSome {
+			splitType = model.Some(splitTypeOverrideName(splitOverride.SplitTypeOverride))
+
+			splitMembers = make([]MemberValueOverride, len(splitOverride.Members))
+			for i, member := range splitOverride.Members {
+				splitMembers[i] = MemberValueOverride{UserEmail: string(member.Email)}
+				if value, isSome := member.SplitValue.Value(); isSome {
+					splitMembers[i].SplitValue = &value
+				}
+			}
+
+			// Members come back in no particular order.
+			slices.SortFunc(splitMembers, func(a, b MemberValueOverride) int {
+				return strings.Compare(a.UserEmail, b.UserEmail)
+			})
+		}
+	}
+
+	lineItems := make([]LineItemValue, len(transaction.LineItems))
+	for i, lineItem := range transaction.LineItems {
+		lineItems[i] = LineItemValue{Amount: lineItem.Amount, Note: lineItem.Note}
+		if category, isSome := lineItem.Category.Value(); isSome {
+			categoryId := int(category)
+			lineItems[i].Category = &categoryId
+		}
+	}
+
+	return []transactionFieldValue{
+		{"amount", transaction.Amount},
+		{"currency", transaction.Currency},
+		{"sender", transaction.Sender},
+		{"receiver", transaction.Receiver},
+		{"category", transaction.Category},
+		{"date", transaction.Date},
+		{"note", transaction.Note},
+		{"receiver_currency", transaction.ReceiverCurrency},
+		{"receiver_amount", transaction.ReceiverAmount},
+		{"financial_income_related_currency", relatedCurrency},
+		{"transaction_group", transactionGroup},
+		{"split_type_override", splitType},
+		{"split_members", splitMembers},
+		{"line_items", lineItems},
+		{"tags", transaction.Tags},
+		{"status", transactionStatusName(transaction.Status)},
+		{"payee", transaction.Payee},
+	}
+}
+
+// diffTransactions returns the tracked fields whose value differs between
+// the two versions of a transaction.
+func diffTransactions(before, after model.Transaction) ([]FieldChangeValue, error) {
+	beforeValues := transactionFieldValues(before)
+	afterValues := transactionFieldValues(after)
+
+	changes := make([]FieldChangeValue, 0)
+	for i := range afterValues {
+		beforeJson, err := json.Marshal(beforeValues[i].value)
+		if err != nil {
+			return nil, fmt.Errorf("encoding previous %s: %w", beforeValues[i].field, err)
+		}
+
+		afterJson, err := json.Marshal(afterValues[i].value)
+		if err != nil {
+			return nil, fmt.Errorf("encoding new %s: %w", afterValues[i].field, err)
+		}
+
+		if !bytes.Equal(beforeJson, afterJson) {
+			changes = append(changes, FieldChangeValue{
+				Field:  afterValues[i].field,
+				Before: beforeJson,
+				After:  afterJson,
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// getTransactionSnapshot reads a transaction owned by the user and locks its
+// row until the end of the database transaction bound to the queries.
+func getTransactionSnapshot(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	id model.TransactionID,
+) (model.Transaction, error) {
+	row, err := queries.GetTransactionSnapshot(ctx, &dao.GetTransactionSnapshotParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Transaction{}, ErrTransactionNotFound
+	}
+	if err != nil {
+		return model.Transaction{}, fmt.Errorf("getting transaction snapshot: %w", err)
+	}
+
+	return transactionFromDao(dao.GetAllTransactionsRow(row))
+}
+
+func recordTransactionHistory(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	id model.TransactionID,
+	action string,
+	changes []FieldChangeValue,
+) error {
+	if changes == nil {
+		changes = make([]FieldChangeValue, 0)
+	}
+
+	changesJson, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("encoding transaction changes: %w", err)
+	}
+
+	err = queries.CreateTransactionHistoryEntry(ctx, &dao.CreateTransactionHistoryEntryParams{
+		TransactionID: int32(id),
+		ChangedBy:     uuid.NullUUID{Valid: true, UUID: userId},
+		Action:        action,
+		Changes:       changesJson,
+	})
+	if err != nil {
+		return fmt.Errorf("recording transaction history: %w", err)
+	}
+
+	return nil
+}
+
+// recordTransactionCreation records every tracked field of a newly created
+// transaction as a change from null, so the first entry shows what the
+// transaction looked like originally. The user creating it may differ from
+// its owner when recording it on their behalf.
+func recordTransactionCreation(
+	ctx context.Context,
+	queries *dao.Queries,
+	ownerId, userId uuid.UUID,
+	id model.TransactionID,
+) error {
+	created, err := getTransactionSnapshot(ctx, queries, ownerId, id)
+	if err != nil {
+		return err
+	}
+
+	values := transactionFieldValues(created)
+	changes := make([]FieldChangeValue, len(values))
+	for i, value := range values {
+		valueJson, err := json.Marshal(value.value)
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", value.field, err)
+		}
+
+		changes[i] = FieldChangeValue{
+			Field:  value.field,
+			Before: json.RawMessage("null"),
+			After:  valueJson,
+		}
+	}
+
+	return recordTransactionHistory(ctx, queries, userId, id, historyActionCreated, changes)
+}
+
+// trashTransaction moves a transaction to the trash and records it in its
+// history. It reports whether the transaction was found.
+func trashTransaction(ctx context.Context, queries *dao.Queries, userId uuid.UUID, id model.TransactionID) (bool, error) {
+	transaction, err := getTransactionSnapshot(ctx, queries, userId, id)
+	if errors.Is(err, ErrTransactionNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if transaction.Status == model.TransactionStatusReconciled {
+		return false, ErrTransactionReconciled
+	}
+
+	trashed, err := queries.TrashTransaction(ctx, &dao.TrashTransactionParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		return false, fmt.Errorf("trashing transaction: %w", err)
+	}
+
+	if trashed == 0 {
+		return false, nil
+	}
+
+	return true, recordTransactionHistory(ctx, queries, userId, id, historyActionDeleted, nil)
+}
+
+// GetTransactionHistory returns the history of a transaction, oldest entry
+// first. Members of the transaction's group can read it as well as its owner.
+func (r *Repository) GetTransactionHistory(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.TransactionID,
+) ([]model.TransactionHistoryEntry, error) {
+	canView, err := r.queries.CanViewTransaction(ctx, &dao.CanViewTransactionParams{
+		TransactionID: int32(id),
+		UserID:        userId,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checking transaction access: %w", err)
+	}
+
+	if !canView {
+		return nil, ErrTransactionNotFound
+	}
+
+	rows, err := r.queries.GetTransactionHistory(ctx, int32(id))
+	if err != nil {
+		return nil, fmt.Errorf("getting transaction history: %w", err)
+	}
+
+	entries := make([]model.TransactionHistoryEntry, len(rows))
+	for i, row := range rows {
+		action, err := transactionHistoryActionFromDao(row.Action)
+		if err != nil {
+			return nil, err
+		}
+
+		var changesDao []FieldChangeValue
+		if err := json.Unmarshal(row.Changes, &changesDao); err != nil {
+			return nil, fmt.Errorf("parsing transaction history changes: %s", err)
+		}
+
+		changes := make([]model.TransactionFieldChange, len(changesDao))
+		for j, change := range changesDao {
+			changes[j] = model.TransactionFieldChange{
+				Field:  change.Field,
+				Before: string(change.Before),
+				After:  string(change.After),
+			}
+		}
+
+		changedBy := model.None[model.Email]()
+		if row.ChangedBy.Valid {
+			changedBy = model.Some(model.Email(row.ChangedBy.String))
+		}
+
+		entries[i] = model.TransactionHistoryEntry{
+			ID:        row.ID,
+			ChangedBy: changedBy,
+			ChangedAt: row.ChangedAt,
+			Action:    action,
+			Changes:   changes,
+		}
+	}
+
+	return entries, nil
+}
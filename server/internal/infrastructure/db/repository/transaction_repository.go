@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"slices"
 	"time"
@@ -44,11 +45,211 @@ func SplitTypeOverrideToDao(splitType model.SplitTypeOverride) (value dao.Transa
 	}
 }
 
+func TransactionStatusFromDao(transactionStatus dao.TransactionStatus) (value model.TransactionStatus, err error) {
+	switch transactionStatus {
+	case dao.TransactionStatusPENDING:
+		return model.TransactionStatusPending, nil
+	case dao.TransactionStatusCLEARED:
+		return model.TransactionStatusCleared, nil
+	case dao.TransactionStatusRECONCILED:
+		return model.TransactionStatusReconciled, nil
+	default:
+		return value, fmt.Errorf("unknown TransactionStatus %s", transactionStatus)
+	}
+}
+
+func TransactionStatusToDao(transactionStatus model.TransactionStatus) (value dao.TransactionStatus, err error) {
+	switch transactionStatus {
+	case model.TransactionStatusPending:
+		return dao.TransactionStatusPENDING, nil
+	case model.TransactionStatusCleared:
+		return dao.TransactionStatusCLEARED, nil
+	case model.TransactionStatusReconciled:
+		return dao.TransactionStatusRECONCILED, nil
+	default:
+		return value, fmt.Errorf("unknown TransactionStatus %d", transactionStatus)
+	}
+}
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrForeignOwner        = errors.New("can only create a transaction for another member of a group both have joined")
+	ErrLineItemsMismatch   = errors.New("line items must add up to the transaction amount")
+	// ErrTransactionReconciled is returned when editing or deleting a
+	// transaction locked by a reconciliation, its status included. Only
+	// undoing the reconciliation unlocks it.
+	ErrTransactionReconciled = errors.New("transaction is reconciled")
+	ErrReconciledStatus      = errors.New("only a reconciliation can mark a transaction as reconciled")
+)
+
 type MemberValueOverride struct {
 	UserEmail  string `json:"user_email"`
 	SplitValue *int   `json:"split_value"`
 }
 
+type AttachmentValue struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LineItemValue struct {
+	Category *int   `json:"category"`
+	Amount   int    `json:"amount"`
+	Note     string `json:"note"`
+}
+
+func transactionFromDao(transactionDao dao.GetAllTransactionsRow) (model.Transaction, error) {
+	sender := model.None[model.AccountID]()
+	if transactionDao.Sender.Valid {
+		sender = model.Some(model.AccountID(transactionDao.Sender.Int32))
+	}
+
+	receiver := model.None[model.AccountID]()
+	if transactionDao.Receiver.Valid {
+		receiver = model.Some(model.AccountID(transactionDao.Receiver.Int32))
+	}
+
+	category := model.None[model.CategoryID]()
+	if transactionDao.Category.Valid {
+		category = model.Some(model.CategoryID(transactionDao.Category.Int32))
+	}
+
+	financialIncomeData := model.None[model.FinancialIncomeData]()
+	if transactionDao.RelatedCurrencyID.Valid {
+		financialIncomeData = model.Some(model.FinancialIncomeData{
+			RelatedCurrency: model.CurrencyID(transactionDao.RelatedCurrencyID.Int32),
+		})
+	}
+
+	transactionGroupData := model.None[model.GroupedTransactionData]()
+	if transactionDao.TransactionGroupID.Valid {
+		splitOverride := model.None[model.SplitOverride]()
+		if transactionDao.TransactionGroupSplitTypeOverride.Valid {
+			splitTypeOverride, err := SplitTypeOverrideFromDao(transactionDao.TransactionGroupSplitTypeOverride.TransactionSplitType)
+			if err != nil {
+				return model.Transaction{}, err
+			}
+
+			var membersDao []MemberValueOverride
+			if transactionDao.TransactionGroupMemberValues != nil {
+				if err := json.Unmarshal(transactionDao.TransactionGroupMemberValues, &membersDao); err != nil {
+					return model.Transaction{}, fmt.Errorf("parsing members while assembling transaction split override values for members: %s", err)
+				}
+			}
+
+			members := make([]model.MemberSplitValue, len(membersDao))
+			for i, memberDao := range membersDao {
+				splitValue := model.None[int]()
+				if memberDao.SplitValue != nil {
+					splitValue = model.Some(*memberDao.SplitValue)
+				}
+
+				members[i] = model.MemberSplitValue{
+					Email:      model.Email(memberDao.UserEmail),
+					SplitValue: splitValue,
+				}
+			}
+
+			splitOverride = model.Some(model.SplitOverride{
+				SplitTypeOverride: splitTypeOverride,
+				Members:           members,
+			})
+		}
+
+		transactionGroupData = model.Some(model.GroupedTransactionData{
+			TransactionGroup: model.TransactionGroupID(transactionDao.TransactionGroupID.Int32),
+			SplitOverride:    splitOverride,
+		})
+	}
+
+	var lineItemsDao []LineItemValue
+	if transactionDao.LineItems != nil {
+		if err := json.Unmarshal(transactionDao.LineItems, &lineItemsDao); err != nil {
+			return model.Transaction{}, fmt.Errorf("parsing transaction line items: %s", err)
+		}
+	}
+
+	lineItems := make([]model.TransactionLineItem, len(lineItemsDao))
+	for i, lineItemDao := range lineItemsDao {
+		lineItemCategory := model.None[model.CategoryID]()
+		if lineItemDao.Category != nil {
+			lineItemCategory = model.Some(model.CategoryID(*lineItemDao.Category))
+		}
+
+		lineItems[i] = model.TransactionLineItem{
+			Category: lineItemCategory,
+			Amount:   lineItemDao.Amount,
+			Note:     lineItemDao.Note,
+		}
+	}
+
+	var attachmentsDao []AttachmentValue
+	if transactionDao.Attachments != nil {
+		if err := json.Unmarshal(transactionDao.Attachments, &attachmentsDao); err != nil {
+			return model.Transaction{}, fmt.Errorf("parsing transaction attachments: %s", err)
+		}
+	}
+
+	attachments := make([]model.Attachment, len(attachmentsDao))
+	for i, attachmentDao := range attachmentsDao {
+		attachments[i] = model.Attachment{
+			ID:        model.AttachmentID(attachmentDao.ID),
+			Name:      attachmentDao.Name,
+			MimeType:  attachmentDao.MimeType,
+			Size:      attachmentDao.Size,
+			SHA256:    attachmentDao.SHA256,
+			CreatedAt: attachmentDao.CreatedAt,
+		}
+	}
+
+	tags := make([]model.TagID, len(transactionDao.Tags))
+	for i, tagId := range transactionDao.Tags {
+		tags[i] = model.TagID(tagId)
+	}
+
+	status, err := TransactionStatusFromDao(transactionDao.Status)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	attribution, err := attributionFromDao(transactionDao.Attribution)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	payee := model.None[model.PayeeID]()
+	if transactionDao.Payee.Valid {
+		payee = model.Some(model.PayeeID(transactionDao.Payee.Int32))
+	}
+
+	return model.Transaction{
+		ID:                     model.TransactionID(transactionDao.ID),
+		Owner:                  model.Email(transactionDao.Owner),
+		Amount:                 int(transactionDao.Amount),
+		Currency:               model.CurrencyID(transactionDao.Currency),
+		Sender:                 sender,
+		Receiver:               receiver,
+		Category:               category,
+		Date:                   transactionDao.Date,
+		Note:                   transactionDao.Note,
+		ReceiverCurrency:       model.CurrencyID(transactionDao.ReceiverCurrency),
+		ReceiverAmount:         int(transactionDao.ReceiverAmount),
+		FinancialIncomeData:    financialIncomeData,
+		GroupedTransactionData: transactionGroupData,
+		LineItems:              lineItems,
+		Tags:                   tags,
+		Attachments:            attachments,
+		Status:                 status,
+		Attribution:            attribution,
+		Payee:                  payee,
+		Version:                int(transactionDao.Version),
+	}, nil
+}
+
 func (r *Repository) GetAllTransactions(ctx context.Context, userId uuid.UUID) ([]model.Transaction, error) {
 	transactionsDao, err := r.queries.GetAllTransactions(ctx, userId)
 	if err != nil {
@@ -57,87 +258,260 @@ func (r *Repository) GetAllTransactions(ctx context.Context, userId uuid.UUID) (
 
 	transactions := make([]model.Transaction, len(transactionsDao))
 	for i, transactionDao := range transactionsDao {
-		sender := model.None[model.AccountID]()
-		if transactionDao.Sender.Valid {
-			sender = model.Some(model.AccountID(transactionDao.Sender.Int32))
+		transactions[i], err = transactionFromDao(transactionDao)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		receiver := model.None[model.AccountID]()
-		if transactionDao.Receiver.Valid {
-			receiver = model.Some(model.AccountID(transactionDao.Receiver.Int32))
-		}
+	return transactions, nil
+}
+
+type TransactionFilter struct {
+	From, To                model.Optional[time.Time]
+	AccountIds              []model.AccountID
+	CategoryIds             []model.CategoryID
+	IncludeCategorySubtrees bool
+	Currency                model.Optional[model.CurrencyID]
+	TransactionGroup        model.Optional[model.TransactionGroupID]
+	MinAmount, MaxAmount    model.Optional[int]
+	NoteContains            model.Optional[string]
+	TagIds                  []model.TagID
+}
+
+func (f *TransactionFilter) nullFrom() sql.NullTime {
+	if value, isSome := f.From.Value(); isSome {
+		return sql.NullTime{Valid: true, Time: value}
+	}
+
+	return sql.NullTime{Valid: false}
+}
+
+func (f *TransactionFilter) nullTo() sql.NullTime {
+	if value, isSome := f.To.Value(); isSome {
+		return sql.NullTime{Valid: true, Time: value}
+	}
+
+	return sql.NullTime{Valid: false}
+}
 
-		category := model.None[model.CategoryID]()
-		if transactionDao.Category.Valid {
-			category = model.Some(model.CategoryID(transactionDao.Category.Int32))
+func (f *TransactionFilter) accountIds() []int32 {
+	ids := make([]int32, len(f.AccountIds))
+	for i, id := range f.AccountIds {
+		ids[i] = int32(id)
+	}
+
+	return ids
+}
+
+func (f *TransactionFilter) categoryIds() []int32 {
+	ids := make([]int32, len(f.CategoryIds))
+	for i, id := range f.CategoryIds {
+		ids[i] = int32(id)
+	}
+
+	return ids
+}
+
+func (f *TransactionFilter) tagIds() []int32 {
+	ids := make([]int32, len(f.TagIds))
+	for i, id := range f.TagIds {
+		ids[i] = int32(id)
+	}
+
+	return ids
+}
+
+func (f *TransactionFilter) nullCurrency() sql.NullInt32 {
+	if value, isSome := f.Currency.Value(); isSome {
+		return sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (f *TransactionFilter) nullTransactionGroup() sql.NullInt32 {
+	if value, isSome := f.TransactionGroup.Value(); isSome {
+		return sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (f *TransactionFilter) nullMinAmount() sql.NullInt32 {
+	if value, isSome := f.MinAmount.Value(); isSome {
+		return sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (f *TransactionFilter) nullMaxAmount() sql.NullInt32 {
+	if value, isSome := f.MaxAmount.Value(); isSome {
+		return sql.NullInt32{Valid: true, Int32: int32(value)}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
+func (f *TransactionFilter) nullNoteContains() sql.NullString {
+	if value, isSome := f.NoteContains.Value(); isSome && value != "" {
+		return sql.NullString{Valid: true, String: value}
+	}
+
+	return sql.NullString{Valid: false}
+}
+
+// ListTransactions returns at most pageSize transactions matching the filter,
+// newest first, starting strictly after the given cursor. The returned cursor
+// is set when more transactions are available.
+func (r *Repository) ListTransactions(
+	ctx context.Context,
+	userId uuid.UUID,
+	filter TransactionFilter,
+	after model.Optional[model.TransactionCursor],
+	pageSize int,
+) ([]model.Transaction, model.Optional[model.TransactionCursor], error) {
+	cursorDate := sql.NullTime{Valid: false}
+	cursorId := sql.NullInt32{Valid: false}
+	if cursor, isSome := after.Value(); isSome {
+		cursorDate = sql.NullTime{Valid: true, Time: cursor.Date}
+		cursorId = sql.NullInt32{Valid: true, Int32: int32(cursor.ID)}
+	}
+
+	transactionsDao, err := r.queries.ListTransactions(ctx, &dao.ListTransactionsParams{
+		UserID:                  userId,
+		CategoryIds:             filter.categoryIds(),
+		IncludeCategorySubtrees: filter.IncludeCategorySubtrees,
+		FromDate:                filter.nullFrom(),
+		ToDate:                  filter.nullTo(),
+		AccountIds:              filter.accountIds(),
+		Currency:                filter.nullCurrency(),
+		TransactionGroupID:      filter.nullTransactionGroup(),
+		MinAmount:               filter.nullMinAmount(),
+		MaxAmount:               filter.nullMaxAmount(),
+		NoteContains:            filter.nullNoteContains(),
+		TagIds:                  filter.tagIds(),
+		CursorDate:              cursorDate,
+		CursorID:                cursorId,
+		PageSize:                int32(pageSize + 1),
+	})
+	if err != nil {
+		return nil, model.None[model.TransactionCursor](), fmt.Errorf("listing transactions: %w", err)
+	}
+
+	transactions := make([]model.Transaction, min(len(transactionsDao), pageSize))
+	for i, transactionDao := range transactionsDao {
+		if i >= pageSize {
+			break
 		}
 
-		financialIncomeData := model.None[model.FinancialIncomeData]()
-		if transactionDao.RelatedCurrencyID.Valid {
-			financialIncomeData = model.Some(model.FinancialIncomeData{
-				RelatedCurrency: model.CurrencyID(transactionDao.RelatedCurrencyID.Int32),
-			})
+		transactions[i], err = transactionFromDao(dao.GetAllTransactionsRow(transactionDao))
+		if err != nil {
+			return nil, model.None[model.TransactionCursor](), err
 		}
+	}
 
-		transactionGroupData := model.None[model.GroupedTransactionData]()
-		if transactionDao.TransactionGroupID.Valid {
-			splitOverride := model.None[model.SplitOverride]()
-			if transactionDao.TransactionGroupSplitTypeOverride.Valid {
-				splitTypeOverride, err := SplitTypeOverrideFromDao(transactionDao.TransactionGroupSplitTypeOverride.TransactionSplitType)
-				if err != nil {
-					return nil, err
-				}
+	next := model.None[model.TransactionCursor]()
+	if len(transactionsDao) > pageSize && len(transactions) > 0 {
+		last := transactions[len(transactions)-1]
+		next = model.Some(model.TransactionCursor{Date: last.Date, ID: last.ID})
+	}
 
-				var membersDao []MemberValueOverride
-				if transactionDao.TransactionGroupMemberValues != nil {
-					if err := json.Unmarshal(transactionDao.TransactionGroupMemberValues, &membersDao); err != nil {
-						return nil, fmt.Errorf("parsing members while assembling transaction split override values for members: %s", err)
-					}
-				}
+	return transactions, next, nil
+}
 
-				members := make([]model.MemberSplitValue, len(membersDao))
-				for i, memberDao := range membersDao {
-					splitValue := model.None[int]()
-					if memberDao.SplitValue != nil {
-						splitValue = model.Some(*memberDao.SplitValue)
-					}
+type NewTransactionFields struct {
+	OwnerEmail                         string
+	Amount, ReceiverAmount             int
+	CurrencyId, ReceiverCurrencyId     int
+	SenderAccountId, ReceiverAccountId model.Optional[int]
+	CategoryId                         model.Optional[int]
+	Date                               time.Time
+	Note                               string
+	FinancialIncomeData                model.Optional[model.FinancialIncomeData]
+	TransactionGroupData               model.Optional[model.GroupedTransactionData]
+	LineItems                          []model.TransactionLineItem
+	Tags                               []model.TagID
+	PayeeId                            model.Optional[model.PayeeID]
+	// Merchant is the name of the other party as printed on a statement.
+	// Without a PayeeId, it is resolved to a payee through payee aliases.
+	Merchant string
+	Status   model.TransactionStatus
+	// AllowDuplicates skips the check for likely duplicates of the new
+	// transaction among existing ones.
+	AllowDuplicates bool
+}
 
-					members[i] = model.MemberSplitValue{
-						Email:      model.Email(memberDao.UserEmail),
-						SplitValue: splitValue,
-					}
-				}
+// SearchTransactions returns the transactions whose note, accounts, category
+// or transaction group match the query, best matches first.
+func (r *Repository) SearchTransactions(
+	ctx context.Context,
+	userId uuid.UUID,
+	query string,
+	limit, offset int,
+) ([]model.TransactionSearchResult, error) {
+	rows, err := r.queries.SearchTransactions(ctx, &dao.SearchTransactionsParams{
+		Query:      query,
+		UserID:     userId,
+		PageSize:   int32(limit),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("searching transactions: %w", err)
+	}
 
-				splitOverride = model.Some(model.SplitOverride{
-					SplitTypeOverride: splitTypeOverride,
-					Members:           members,
-				})
-			}
+	results := make([]model.TransactionSearchResult, len(rows))
+	for i, row := range rows {
+		transaction, err := transactionFromDao(dao.GetAllTransactionsRow{
+			ID:                                row.ID,
+			Owner:                             row.Owner,
+			Amount:                            row.Amount,
+			Currency:                          row.Currency,
+			Sender:                            row.Sender,
+			Receiver:                          row.Receiver,
+			Category:                          row.Category,
+			Date:                              row.Date,
+			Note:                              row.Note,
+			ReceiverCurrency:                  row.ReceiverCurrency,
+			ReceiverAmount:                    row.ReceiverAmount,
+			RelatedCurrencyID:                 row.RelatedCurrencyID,
+			TransactionGroupID:                row.TransactionGroupID,
+			TransactionGroupSplitTypeOverride: row.TransactionGroupSplitTypeOverride,
+			TransactionGroupMemberValues:      row.TransactionGroupMemberValues,
+			LineItems:                         row.LineItems,
+			Tags:                              row.Tags,
+			Attachments:                       row.Attachments,
+			Status:                            row.Status,
+			Attribution:                       row.Attribution,
+			Payee:                             row.Payee,
+			Version:                           row.Version,
+		})
+		if err != nil {
+			return nil, err
+		}
 
-			transactionGroupData = model.Some(model.GroupedTransactionData{
-				TransactionGroup: model.TransactionGroupID(transactionDao.TransactionGroupID.Int32),
-				SplitOverride:    splitOverride,
-			})
+		matches := make([]model.TransactionSearchMatch, 0)
+		for _, match := range []model.TransactionSearchMatch{
+			{Field: model.TransactionSearchFieldNote, Highlight: row.NoteHighlight},
+			{Field: model.TransactionSearchFieldSender, Highlight: row.SenderHighlight},
+			{Field: model.TransactionSearchFieldReceiver, Highlight: row.ReceiverHighlight},
+			{Field: model.TransactionSearchFieldCategory, Highlight: row.CategoryHighlight},
+			{Field: model.TransactionSearchFieldTransactionGroup, Highlight: row.TransactionGroupHighlight},
+		} {
+			if match.Highlight != "" {
+				matches = append(matches, match)
+			}
 		}
 
-		transactions[i] = model.Transaction{
-			ID:                     model.TransactionID(transactionDao.ID),
-			Owner:                  model.Email(transactionDao.Owner),
-			Amount:                 int(transactionDao.Amount),
-			Currency:               model.CurrencyID(transactionDao.Currency),
-			Sender:                 sender,
-			Receiver:               receiver,
-			Category:               category,
-			Date:                   transactionDao.Date,
-			Note:                   transactionDao.Note,
-			ReceiverCurrency:       model.CurrencyID(transactionDao.ReceiverCurrency),
-			ReceiverAmount:         int(transactionDao.ReceiverAmount),
-			FinancialIncomeData:    financialIncomeData,
-			GroupedTransactionData: transactionGroupData,
+		results[i] = model.TransactionSearchResult{
+			Transaction: transaction,
+			Rank:        float64(row.Rank),
+			Matches:     matches,
 		}
 	}
 
-	return transactions, nil
+	return results, nil
 }
 
 func (r *Repository) CreateTransaction(
@@ -153,12 +527,13 @@ func (r *Repository) CreateTransaction(
 	note string,
 	financialIncomeData model.Optional[model.FinancialIncomeData],
 	transactionGroupData model.Optional[model.GroupedTransactionData],
+	lineItems []model.TransactionLineItem,
+	tags []model.TagID,
+	payeeId model.Optional[model.PayeeID],
+	merchant string,
+	status model.TransactionStatus,
+	allowDuplicates bool,
 ) (createdTransactionId model.TransactionID, err error) {
-	if userEmail != ownerEmail {
-		err = fmt.Errorf("can only create a transaction for self for now")
-		return
-	}
-
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return
@@ -172,102 +547,269 @@ func (r *Repository) CreateTransaction(
 		}
 	}()
 
+	createdTransactionId, err = createTransaction(ctx, r.queries.WithTx(tx), userId, userEmail, NewTransactionFields{
+		OwnerEmail:           ownerEmail,
+		Amount:               amount,
+		ReceiverAmount:       receiverAmount,
+		CurrencyId:           currencyId,
+		ReceiverCurrencyId:   receiverCurrencyId,
+		SenderAccountId:      senderAccountId,
+		ReceiverAccountId:    receiverAccountId,
+		CategoryId:           categoryId,
+		Date:                 date,
+		Note:                 note,
+		FinancialIncomeData:  financialIncomeData,
+		TransactionGroupData: transactionGroupData,
+		LineItems:            lineItems,
+		Tags:                 tags,
+		PayeeId:              payeeId,
+		Merchant:             merchant,
+		Status:               status,
+		AllowDuplicates:      allowDuplicates,
+	})
+	if err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("committing transaction: %w", err)
+		return
+	}
+
+	return createdTransactionId, nil
+}
+
+// createTransaction inserts a transaction and its additional data using the
+// given queries, which are expected to be bound to a database transaction.
+func createTransaction(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	userEmail string,
+	fields NewTransactionFields,
+) (model.TransactionID, error) {
+	ownerId := userId
+	isForeignOwner := userEmail != fields.OwnerEmail
+	if isForeignOwner {
+		var err error
+		ownerId, fields, err = attributeToOwner(ctx, queries, userId, fields)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if fields.Status == model.TransactionStatusReconciled {
+		return 0, ErrReconciledStatus
+	}
+
+	status, err := TransactionStatusToDao(fields.Status)
+	if err != nil {
+		return 0, err
+	}
+
+	payee, err := transactionPayee(ctx, queries, ownerId, fields.PayeeId, fields.Merchant)
+	if err != nil {
+		return 0, err
+	}
+
+	payeeId := sql.NullInt32{Valid: false}
+	if value, isSome := payee.Value(); isSome {
+		payeeId = sql.NullInt32{Valid: true, Int32: int32(value.ID)}
+		fields.PayeeId = model.Some(value.ID)
+
+		if defaultCategory, isSome := value.DefaultCategory.Value(); isSome && fields.CategoryId.IsNone() {
+			fields.CategoryId = model.Some(int(defaultCategory))
+		}
+	}
+
+	// Rules refer to the owner's data, which the recorder of a transaction for
+	// another member cannot see, so they only run on the user's own ones.
+	if !isForeignOwner {
+		fields, err = applyRulesToNewTransaction(ctx, queries, userId, fields)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if !fields.AllowDuplicates {
+		// The transaction is the owner's, so it is compared with theirs.
+		candidates, err := findDuplicateCandidates(ctx, queries, ownerId, fields)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(candidates) > 0 {
+			return 0, &DuplicateTransactionError{Candidates: candidates}
+		}
+	}
+
 	sender := sql.NullInt32{Valid: false}
-	if value, isSome := senderAccountId.Value(); isSome {
+	if value, isSome := fields.SenderAccountId.Value(); isSome {
 		sender = sql.NullInt32{Valid: true, Int32: int32(value)}
 	}
 
 	receiver := sql.NullInt32{Valid: false}
-	if value, isSome := receiverAccountId.Value(); isSome {
+	if value, isSome := fields.ReceiverAccountId.Value(); isSome {
 		receiver = sql.NullInt32{Valid: true, Int32: int32(value)}
 	}
 
 	category := sql.NullInt32{Valid: false}
-	if value, isSome := categoryId.Value(); isSome {
+	if value, isSome := fields.CategoryId.Value(); isSome {
 		category = sql.NullInt32{Valid: true, Int32: int32(value)}
 	}
 
-	transactionId, err := r.queries.WithTx(tx).CreateTransaction(
+	transactionId, err := queries.CreateTransaction(
 		ctx, &dao.CreateTransactionParams{
-			UserID:           userId,
-			Amount:           int32(amount),
-			Currency:         int32(currencyId),
+			UserID:           ownerId,
+			Amount:           int32(fields.Amount),
+			Currency:         int32(fields.CurrencyId),
 			Sender:           sender,
 			Receiver:         receiver,
 			Category:         category,
-			Date:             date,
-			Note:             note,
-			ReceiverCurrency: int32(receiverCurrencyId),
-			ReceiverAmount:   int32(receiverAmount),
+			Date:             fields.Date,
+			Note:             fields.Note,
+			ReceiverCurrency: int32(fields.ReceiverCurrencyId),
+			ReceiverAmount:   int32(fields.ReceiverAmount),
+			Status:           status,
+			PayeeID:          payeeId,
 		},
 	)
 	if err != nil {
-		err = fmt.Errorf("creating transaction: %w", err)
-		return
+		return 0, fmt.Errorf("creating transaction: %w", err)
 	}
 
-	if financialIncome, isSome := financialIncomeData.Value(); isSome {
-		_, queryErr := r.queries.WithTx(tx).UpsertFinancialIncome(ctx, &dao.UpsertFinancialIncomeParams{
+	if financialIncome, isSome := fields.FinancialIncomeData.Value(); isSome {
+		_, err = queries.UpsertFinancialIncome(ctx, &dao.UpsertFinancialIncomeParams{
 			TransactionID: transactionId,
 			RelatedCurrencyID: sql.NullInt32{
 				Int32: int32(financialIncome.RelatedCurrency),
 				Valid: true,
 			},
 		})
-		if queryErr != nil {
-			err = fmt.Errorf("creating financial income: %w", queryErr)
-			return
+		if err != nil {
+			return 0, fmt.Errorf("creating financial income: %w", err)
 		}
 	}
 
-	if transactionGroup, isSome := transactionGroupData.Value(); isSome {
+	if transactionGroup, isSome := fields.TransactionGroupData.Value(); isSome {
 		splitOverride, hasSplitOverride := transactionGroup.SplitOverride.Value()
 		var splitOverrideDao dao.TransactionSplitType
 		if hasSplitOverride {
 			splitOverrideDao, err = SplitTypeOverrideToDao(splitOverride.SplitTypeOverride)
 			if err != nil {
-				err = fmt.Errorf("converting split type override to dao: %w", err)
-				return
+				return 0, fmt.Errorf("converting split type override to dao: %w", err)
 			}
 		}
 
-		_, upsertErr := r.queries.WithTx(tx).UpsertGroupedTransaction(ctx, &dao.UpsertGroupedTransactionParams{
+		_, err = queries.UpsertGroupedTransaction(ctx, &dao.UpsertGroupedTransactionParams{
 			TransactionID:           transactionId,
 			TransactionGroupID:      sql.NullInt32{Valid: true, Int32: int32(transactionGroup.TransactionGroup)},
 			SplitTypeOverride:       dao.NullTransactionSplitType{Valid: hasSplitOverride, TransactionSplitType: splitOverrideDao},
-			TriggeredByOwner:        userEmail == ownerEmail,
+			TriggeredByOwner:        userEmail == fields.OwnerEmail,
 			UpdateSplitTypeOverride: hasSplitOverride,
 		})
 		if err != nil {
-			err = fmt.Errorf("upserting grouped transaction: %w", upsertErr)
-			return
+			return 0, fmt.Errorf("upserting grouped transaction: %w", err)
 		}
 
 		if hasSplitOverride {
 			for _, member := range splitOverride.Members {
 				splitValue, hasSplitValue := member.SplitValue.Value()
 
-				_, upsertErr := r.queries.WithTx(tx).UpsertGroupedTransactionMemberSplitValue(ctx, &dao.UpsertGroupedTransactionMemberSplitValueParams{
+				_, err = queries.UpsertGroupedTransactionMemberSplitValue(ctx, &dao.UpsertGroupedTransactionMemberSplitValueParams{
 					TransactionID: transactionId,
 					UserEmail:     string(member.Email),
 					SplitValue:    sql.NullInt32{Valid: hasSplitValue, Int32: int32(splitValue)},
 				})
 				if err != nil {
-					err = fmt.Errorf("upserting grouped transaction member split value: %w", upsertErr)
-					return
+					return 0, fmt.Errorf("upserting grouped transaction member split value: %w", err)
 				}
 			}
 		}
 	}
 
-	if err = tx.Commit(); err != nil {
-		err = fmt.Errorf("committing transaction: %w", err)
-		return
+	if len(fields.Tags) > 0 {
+		if err = replaceTransactionTags(ctx, queries, userId, transactionId, fields.Tags); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(fields.LineItems) > 0 {
+		if err = replaceTransactionLineItems(ctx, queries, transactionId, fields.LineItems); err != nil {
+			return 0, err
+		}
+
+		if err = checkTransactionLineItems(ctx, queries, transactionId); err != nil {
+			return 0, err
+		}
+	}
+
+	if isForeignOwner {
+		err = queries.CreateTransactionAttribution(ctx, &dao.CreateTransactionAttributionParams{
+			TransactionID: transactionId,
+			RecordedBy:    uuid.NullUUID{Valid: true, UUID: userId},
+		})
+		if err != nil {
+			return 0, fmt.Errorf("recording transaction attribution: %w", err)
+		}
+	}
+
+	if err = recordTransactionCreation(ctx, queries, ownerId, userId, model.TransactionID(transactionId)); err != nil {
+		return 0, err
 	}
 
 	return model.TransactionID(transactionId), nil
 }
 
+// replaceTransactionLineItems discards the line items of a transaction and
+// inserts the given ones in their place, keeping their order.
+func replaceTransactionLineItems(
+	ctx context.Context,
+	queries *dao.Queries,
+	transactionId int32,
+	lineItems []model.TransactionLineItem,
+) error {
+	if err := queries.DeleteTransactionLineItems(ctx, transactionId); err != nil {
+		return fmt.Errorf("deleting transaction line items: %w", err)
+	}
+
+	for i, lineItem := range lineItems {
+		category := sql.NullInt32{Valid: false}
+		if value, isSome := lineItem.Category.Value(); isSome {
+			category = sql.NullInt32{Valid: true, Int32: int32(value)}
+		}
+
+		err := queries.CreateTransactionLineItem(ctx, &dao.CreateTransactionLineItemParams{
+			TransactionID: transactionId,
+			Position:      int32(i),
+			Category:      category,
+			Amount:        int32(lineItem.Amount),
+			Note:          lineItem.Note,
+		})
+		if err != nil {
+			return fmt.Errorf("creating transaction line item: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkTransactionLineItems verifies, against the stored rows, that the line
+// items of a transaction add up to its amount. Running it after every write
+// catches an amount update that leaves the existing line items behind.
+func checkTransactionLineItems(ctx context.Context, queries *dao.Queries, transactionId int32) error {
+	total, err := queries.GetTransactionLineItemsTotal(ctx, transactionId)
+	if err != nil {
+		return fmt.Errorf("totalling transaction line items: %w", err)
+	}
+
+	if total.LineItemCount > 0 && total.LineItemTotal != total.Amount {
+		return ErrLineItemsMismatch
+	}
+
+	return nil
+}
+
 type UpdateFinancialIncomeAdditionalData struct {
 	RelatedCurrencyId model.Optional[int]
 }
@@ -317,6 +859,23 @@ type UpdateTransactionFields struct {
 	Note                                 model.Optional[string]
 	UpdateFinancialIncomeAdditionalData  model.Optional[model.Optional[UpdateFinancialIncomeAdditionalData]]
 	UpdateTransactionGroupAdditionalData model.Optional[model.Optional[UpdateTransactionGroupAdditionalData]]
+	LineItems                            model.Optional[[]model.TransactionLineItem]
+	Tags                                 model.Optional[[]model.TagID]
+	Status                               model.Optional[model.TransactionStatus]
+	PayeeId                              model.Optional[model.Optional[model.PayeeID]]
+	// ExpectedVersion, when set, refuses the update with ErrVersionConflict
+	// if the transaction is no longer at that version.
+	ExpectedVersion model.Optional[int]
+}
+
+func (u *UpdateTransactionFields) nullStatus() dao.NullTransactionStatus {
+	if value, isSome := u.Status.Value(); isSome {
+		if status, err := TransactionStatusToDao(value); err == nil {
+			return dao.NullTransactionStatus{Valid: true, TransactionStatus: status}
+		}
+	}
+
+	return dao.NullTransactionStatus{Valid: false}
 }
 
 func (u *UpdateTransactionFields) nullNote() sql.NullString {
@@ -397,6 +956,16 @@ func (u *UpdateTransactionFields) nullCategoryId() sql.NullInt32 {
 	return sql.NullInt32{Valid: false}
 }
 
+func (u *UpdateTransactionFields) nullPayeeId() sql.NullInt32 {
+	if optionalValue, isSome := u.PayeeId.Value(); isSome {
+		if value, isSome := optionalValue.Value(); isSome {
+			return sql.NullInt32{Valid: true, Int32: int32(value)}
+		}
+	}
+
+	return sql.NullInt32{Valid: false}
+}
+
 func (r *Repository) UpdateTransaction(
 	ctx context.Context,
 	userId uuid.UUID,
@@ -416,7 +985,59 @@ func (r *Repository) UpdateTransaction(
 		}
 	}()
 
-	_, err = r.queries.WithTx(tx).UpdateTransaction(
+	if err = updateTransaction(ctx, r.queries.WithTx(tx), userId, id, field); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("committing transaction: %w", err)
+		return
+	}
+
+	return
+}
+
+// updateTransaction applies the update using the given queries, which are
+// expected to be bound to a database transaction, and records the fields it
+// changed in the transaction's history.
+func updateTransaction(
+	ctx context.Context,
+	queries *dao.Queries,
+	userId uuid.UUID,
+	id model.TransactionID,
+	field UpdateTransactionFields,
+) (err error) {
+	before, err := getTransactionSnapshot(ctx, queries, userId, id)
+	if err != nil {
+		return
+	}
+
+	// The snapshot locks the transaction, so it stays at this version until
+	// the update is committed.
+	if expectedVersion, isSome := field.ExpectedVersion.Value(); isSome && before.Version != expectedVersion {
+		err = ErrVersionConflict
+		return
+	}
+
+	if status, isSome := field.Status.Value(); isSome && status == model.TransactionStatusReconciled {
+		err = ErrReconciledStatus
+		return
+	}
+
+	if before.Status == model.TransactionStatusReconciled {
+		err = ErrTransactionReconciled
+		return
+	}
+
+	if payee, isSome := field.PayeeId.Value(); isSome {
+		if payeeId, isSome := payee.Value(); isSome {
+			if _, err = getPayee(ctx, queries, userId, payeeId); err != nil {
+				return
+			}
+		}
+	}
+
+	_, err = queries.UpdateTransaction(
 		ctx, &dao.UpdateTransactionParams{
 			UserID:           userId,
 			ID:               int32(id),
@@ -432,8 +1053,15 @@ func (r *Repository) UpdateTransaction(
 			Note:             field.nullNote(),
 			ReceiverCurrency: field.nullReceiverCurrencyId(),
 			ReceiverAmount:   field.nullReceiverAmount(),
+			Status:           field.nullStatus(),
+			UpdatePayee:      field.PayeeId.IsSome(),
+			PayeeID:          field.nullPayeeId(),
 		},
 	)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrTransactionNotFound
+		return
+	}
 	if err != nil {
 		err = fmt.Errorf("updating transaction: %w", err)
 		return
@@ -441,7 +1069,7 @@ func (r *Repository) UpdateTransaction(
 
 	if updatingFinancialData, isSome := field.UpdateFinancialIncomeAdditionalData.Value(); isSome {
 		if financialDataFields, isSome := updatingFinancialData.Value(); isSome {
-			_, updateErr := r.queries.WithTx(tx).UpsertFinancialIncome(
+			_, updateErr := queries.UpsertFinancialIncome(
 				ctx, &dao.UpsertFinancialIncomeParams{
 					RelatedCurrencyID: financialDataFields.nullRelatedCurrencyId(),
 					TransactionID:     int32(id),
@@ -452,7 +1080,7 @@ func (r *Repository) UpdateTransaction(
 				return
 			}
 		} else {
-			_, deleteErr := r.queries.WithTx(tx).RemoveFinancialIncome(ctx, int32(id))
+			_, deleteErr := queries.RemoveFinancialIncome(ctx, int32(id))
 			if deleteErr != nil {
 				err = fmt.Errorf("deleting financial income: %w", deleteErr)
 				return
@@ -462,7 +1090,7 @@ func (r *Repository) UpdateTransaction(
 
 	if updatingTransactionGroupData, isSome := field.UpdateTransactionGroupAdditionalData.Value(); isSome {
 		if transactionGroupData, isSome := updatingTransactionGroupData.Value(); isSome {
-			previousTransaction, getTransactionError := r.queries.WithTx(tx).GetTransaction(ctx, int32(id))
+			previousTransaction, getTransactionError := queries.GetTransaction(ctx, int32(id))
 			if getTransactionError != nil {
 				err = fmt.Errorf("getting previous transaction: %w", getTransactionError)
 				return
@@ -481,7 +1109,7 @@ func (r *Repository) UpdateTransaction(
 				}
 			}
 
-			_, upsertErr := r.queries.WithTx(tx).UpsertGroupedTransaction(ctx, &dao.UpsertGroupedTransactionParams{
+			_, upsertErr := queries.UpsertGroupedTransaction(ctx, &dao.UpsertGroupedTransactionParams{
 				TransactionID:           int32(id),
 				TransactionGroupID:      transactionGroupId,
 				SplitTypeOverride:       splitTypeOverrideDao,
@@ -531,7 +1159,7 @@ func (r *Repository) UpdateTransaction(
 						continue
 					}
 
-					_, removalErr := r.queries.RemoveGroupedTransactionMember(ctx, &dao.RemoveGroupedTransactionMemberParams{
+					_, removalErr := queries.RemoveGroupedTransactionMember(ctx, &dao.RemoveGroupedTransactionMemberParams{
 						TransactionID: int32(id),
 						UserEmail:     previousMember.UserEmail,
 					})
@@ -550,7 +1178,7 @@ func (r *Repository) UpdateTransaction(
 						splitValue = sql.NullInt32{Valid: true, Int32: int32(value)}
 					}
 
-					_, upsertErr := r.queries.UpsertGroupedTransactionMemberSplitValue(ctx, &dao.UpsertGroupedTransactionMemberSplitValueParams{
+					_, upsertErr := queries.UpsertGroupedTransactionMemberSplitValue(ctx, &dao.UpsertGroupedTransactionMemberSplitValueParams{
 						SplitValue:    splitValue,
 						UserEmail:     string(newMember.Email),
 						TransactionID: int32(id),
@@ -562,32 +1190,323 @@ func (r *Repository) UpdateTransaction(
 				}
 			}
 		} else {
-			_, deleteErr := r.queries.DeleteGroupedTransaction(ctx, int32(id))
+			_, deleteErr := queries.DeleteGroupedTransaction(ctx, int32(id))
 			if deleteErr != nil {
-				err = fmt.Errorf("deleting grouped transaction data: %w", err)
+				err = fmt.Errorf("deleting grouped transaction data: %w", deleteErr)
 				return
 			}
 		}
 	}
 
+	if lineItems, isSome := field.LineItems.Value(); isSome {
+		if err = replaceTransactionLineItems(ctx, queries, int32(id), lineItems); err != nil {
+			return
+		}
+	}
+
+	if tags, isSome := field.Tags.Value(); isSome {
+		if err = replaceTransactionTags(ctx, queries, userId, int32(id), tags); err != nil {
+			return
+		}
+	}
+
+	if err = checkTransactionLineItems(ctx, queries, int32(id)); err != nil {
+		return
+	}
+
+	after, err := getTransactionSnapshot(ctx, queries, userId, id)
+	if err != nil {
+		return
+	}
+
+	changes, err := diffTransactions(before, after)
+	if err != nil || len(changes) == 0 {
+		return
+	}
+
+	return recordTransactionHistory(ctx, queries, userId, id, historyActionUpdated, changes)
+}
+
+// DeleteTransaction moves the transaction to its owner's trash. Its financial
+// income and group data are kept so that restoring it is lossless.
+func (r *Repository) DeleteTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("transaction delete rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	if _, err = trashTransaction(ctx, r.queries.WithTx(tx), userId, id); err != nil {
+		return
+	}
+
 	if err = tx.Commit(); err != nil {
 		err = fmt.Errorf("committing transaction: %w", err)
 		return
 	}
 
-	return
+	return nil
+}
+
+func (r *Repository) ListDeletedTransactions(ctx context.Context, userId uuid.UUID) ([]model.DeletedTransaction, error) {
+	transactionsDao, err := r.queries.ListDeletedTransactions(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("listing deleted transactions: %w", err)
+	}
+
+	transactions := make([]model.DeletedTransaction, len(transactionsDao))
+	for i, transactionDao := range transactionsDao {
+		transaction, err := transactionFromDao(dao.GetAllTransactionsRow{
+			ID:                                transactionDao.ID,
+			Owner:                             transactionDao.Owner,
+			Amount:                            transactionDao.Amount,
+			Currency:                          transactionDao.Currency,
+			Sender:                            transactionDao.Sender,
+			Receiver:                          transactionDao.Receiver,
+			Category:                          transactionDao.Category,
+			Date:                              transactionDao.Date,
+			Note:                              transactionDao.Note,
+			ReceiverCurrency:                  transactionDao.ReceiverCurrency,
+			ReceiverAmount:                    transactionDao.ReceiverAmount,
+			RelatedCurrencyID:                 transactionDao.RelatedCurrencyID,
+			TransactionGroupID:                transactionDao.TransactionGroupID,
+			TransactionGroupSplitTypeOverride: transactionDao.TransactionGroupSplitTypeOverride,
+			TransactionGroupMemberValues:      transactionDao.TransactionGroupMemberValues,
+			LineItems:                         transactionDao.LineItems,
+			Tags:                              transactionDao.Tags,
+			Attachments:                       transactionDao.Attachments,
+			Status:                            transactionDao.Status,
+			Attribution:                       transactionDao.Attribution,
+			Payee:                             transactionDao.Payee,
+			Version:                           transactionDao.Version,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		transactions[i] = model.DeletedTransaction{
+			Transaction: transaction,
+			DeletedAt:   transactionDao.DeletedAt,
+		}
+	}
+
+	return transactions, nil
+}
+
+func (r *Repository) RestoreTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("transaction restore rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	restored, err := queries.RestoreTransaction(ctx, &dao.RestoreTransactionParams{
+		ID:     int32(id),
+		UserID: userId,
+	})
+	if err != nil {
+		err = fmt.Errorf("restoring transaction: %w", err)
+		return
+	}
+
+	if restored == 0 {
+		err = ErrTransactionNotFound
+		return
+	}
+
+	if err = recordTransactionHistory(ctx, queries, userId, id, historyActionRestored, nil); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("committing transaction: %w", err)
+		return
+	}
+
+	return nil
 }
 
-func (r *Repository) DeleteTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error {
-	// Child rows in financialincomes and transaction_transaction_group (and its
-	// user-split table) are removed by ON DELETE CASCADE.
-	_, err := r.queries.DeleteTransaction(ctx, &dao.DeleteTransactionParams{
+// PurgeTransaction permanently deletes a trashed transaction. Child rows in
+// financialincomes and transaction_transaction_group (and its user-split
+// table) are removed by ON DELETE CASCADE.
+func (r *Repository) PurgeTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error {
+	purged, err := r.queries.PurgeTransaction(ctx, &dao.PurgeTransactionParams{
 		ID:     int32(id),
 		UserID: userId,
 	})
 	if err != nil {
-		return fmt.Errorf("deleting transaction: %w", err)
+		return fmt.Errorf("purging transaction: %w", err)
+	}
+
+	if purged == 0 {
+		return ErrTransactionNotFound
 	}
 
 	return nil
 }
+
+// PurgeTrashedTransactions permanently deletes, for every user, the
+// transactions trashed before the given time.
+func (r *Repository) PurgeTrashedTransactions(ctx context.Context, trashedBefore time.Time) (int, error) {
+	purged, err := r.queries.PurgeTrashedTransactions(ctx, trashedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("purging trashed transactions: %w", err)
+	}
+
+	return int(purged), nil
+}
+
+// TransactionMutation describes a single operation of a batch. Exactly one of
+// Create, Update or Delete is expected to be set.
+type TransactionMutation struct {
+	Create model.Optional[NewTransactionFields]
+	Update model.Optional[TransactionUpdate]
+	Delete model.Optional[model.TransactionID]
+}
+
+type TransactionUpdate struct {
+	ID     model.TransactionID
+	Fields UpdateTransactionFields
+}
+
+// TransactionMutationError identifies which mutation of a batch failed.
+type TransactionMutationError struct {
+	Index int
+	Err   error
+}
+
+func (e *TransactionMutationError) Error() string {
+	return fmt.Sprintf("mutation %d: %s", e.Index, e.Err)
+}
+
+func (e *TransactionMutationError) Unwrap() error {
+	return e.Err
+}
+
+// BatchMutateTransactions applies all mutations in order within a single
+// database transaction. It returns, for each mutation, the ID of the
+// transaction it affected. If any mutation fails, nothing is applied and the
+// returned error is a *TransactionMutationError.
+func (r *Repository) BatchMutateTransactions(
+	ctx context.Context,
+	userId uuid.UUID,
+	userEmail string,
+	mutations []TransactionMutation,
+) (ids []model.TransactionID, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning db transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logging.FromContext(ctx).Error(fmt.Sprintf("transaction batch rollback error: %v", rbErr))
+			}
+		}
+	}()
+
+	queries := r.queries.WithTx(tx)
+
+	ids = make([]model.TransactionID, len(mutations))
+	for i, mutation := range mutations {
+		var mutationErr error
+
+		if fields, isSome := mutation.Create.Value(); isSome {
+			ids[i], mutationErr = createTransaction(ctx, queries, userId, userEmail, fields)
+		} else if update, isSome := mutation.Update.Value(); isSome {
+			ids[i] = update.ID
+			mutationErr = updateTransaction(ctx, queries, userId, update.ID, update.Fields)
+		} else if id, isSome := mutation.Delete.Value(); isSome {
+			ids[i] = id
+			deleted, deleteErr := trashTransaction(ctx, queries, userId, id)
+			if deleteErr != nil {
+				mutationErr = deleteErr
+			} else if !deleted {
+				mutationErr = ErrTransactionNotFound
+			}
+		} else {
+			mutationErr = fmt.Errorf("empty mutation")
+		}
+
+		if mutationErr != nil {
+			err = &TransactionMutationError{Index: i, Err: mutationErr}
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("committing transaction: %w", err)
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetTransaction returns a transaction of the user, failing with
+// ErrTransactionNotFound if there is none with this id.
+func (r *Repository) GetTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) (model.Transaction, error) {
+	return getTransactionSnapshot(ctx, r.queries, userId, id)
+}
+
+// TransactionReferences are the accounts, category and currencies a
+// transaction refers to.
+type TransactionReferences struct {
+	Sender, Receiver model.Optional[model.AccountID]
+	Category         model.Optional[model.CategoryID]
+	Currencies       []model.CurrencyID
+}
+
+// TransactionReferencesFound reports which of the TransactionReferences
+// belong to the user. References that are not set count as found.
+type TransactionReferencesFound struct {
+	Sender, Receiver bool
+	Category         bool
+	Currencies       bool
+}
+
+func (r *Repository) CheckTransactionReferences(
+	ctx context.Context,
+	userId uuid.UUID,
+	references TransactionReferences,
+) (TransactionReferencesFound, error) {
+	currencyIds := make([]int32, len(references.Currencies))
+	for i, currencyId := range references.Currencies {
+		currencyIds[i] = int32(currencyId)
+	}
+	currencyIds = uniqueIds(currencyIds)
+
+	found, err := r.queries.CheckTransactionReferences(ctx, &dao.CheckTransactionReferencesParams{
+		SenderID:    nullIntFromModel(references.Sender),
+		UserID:      userId,
+		ReceiverID:  nullIntFromModel(references.Receiver),
+		CategoryID:  nullIntFromModel(references.Category),
+		CurrencyIds: currencyIds,
+	})
+	if err != nil {
+		return TransactionReferencesFound{}, fmt.Errorf("checking transaction references: %w", err)
+	}
+
+	return TransactionReferencesFound{
+		Sender:     found.SenderFound,
+		Receiver:   found.ReceiverFound,
+		Category:   found.CategoryFound,
+		Currencies: int(found.CurrenciesFound) == len(currencyIds),
+	}, nil
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	"chagnon.dev/budget-server/internal/domain/model"
@@ -10,6 +12,8 @@ import (
 	"github.com/google/uuid"
 )
 
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
 func (r *Repository) UpsertOidcUser(ctx context.Context, oidcSub, email, username string) (uuid.UUID, error) {
 	return r.queries.UpsertOidcUser(ctx, &dao.UpsertOidcUserParams{
 		Username: username,
@@ -54,9 +58,40 @@ func (r *Repository) UserParams(ctx context.Context, id uuid.UUID) (*model.UserP
 		Name:                 userParams.Username,
 		DefaultCurrency:      model.CurrencyID(defaultCurrency),
 		HiddenDefaultAccount: model.AccountID(hiddenDefaultAccount),
+		Timezone:             userParams.Timezone,
 	}, nil
 }
 
+// UserLocation returns the timezone of the user, in which their dates are
+// read and shown.
+func (r *Repository) UserLocation(ctx context.Context, id uuid.UUID) (*time.Location, error) {
+	timezone, err := r.queries.GetUserTimezone(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting user timezone: %w", err)
+	}
+
+	return model.LoadTimezone(timezone)
+}
+
+func (r *Repository) SetUserTimezone(ctx context.Context, id uuid.UUID, timezone string) error {
+	if _, err := model.LoadTimezone(timezone); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTimezone, err)
+	}
+
+	updated, err := r.queries.SetUserTimezone(ctx, &dao.SetUserTimezoneParams{
+		Timezone: timezone,
+		UserID:   id,
+	})
+	if err != nil {
+		return fmt.Errorf("setting user timezone: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("setting user timezone: user %s not found", id)
+	}
+
+	return nil
+}
+
 func (r *Repository) CanIssueGuestLoginNow(ctx context.Context, email string, ttl, cooldown time.Duration) (allowed bool, nextAllowedAt *time.Time, err error) {
 	res, err := r.queries.CanIssueNow(ctx, &dao.CanIssueNowParams{
 		Email:   email,
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrVersionConflict is returned by an update made against a version of a row
+// that is no longer the current one: someone else changed it in between.
+var ErrVersionConflict = errors.New("changed by someone else since it was read")
+
+// checkVersion compares the version an update was made against with the
+// current version of the row. getVersion is expected to lock the row until the
+// end of the database transaction, and to fail with sql.ErrNoRows when there is
+// no such row, which is reported as notFound.
+func checkVersion(expected int, notFound error, getVersion func() (int32, error)) error {
+	version, err := getVersion()
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	if err != nil {
+		return fmt.Errorf("getting current version: %w", err)
+	}
+
+	if int(version) != expected {
+		return ErrVersionConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chagnon.dev/budget-server/internal/logging"
+)
+
+type idempotencyRepository interface {
+	PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time) (int, error)
+}
+
+// Purger deletes the idempotency keys whose window is over. They are ignored
+// once expired anyway; this only keeps the table from growing.
+type Purger struct {
+	idempotencyRepository idempotencyRepository
+	window                time.Duration
+}
+
+func NewPurger(idempotencyRepository idempotencyRepository, window time.Duration) *Purger {
+	return &Purger{
+		idempotencyRepository: idempotencyRepository,
+		window:                window,
+	}
+}
+
+func (p *Purger) NewRunner(ctx context.Context) func() error {
+	return func() error {
+		logger := logging.FromContext(ctx)
+
+		purged, err := p.idempotencyRepository.PurgeIdempotencyKeys(ctx, time.Now().Add(-p.window))
+		if err != nil {
+			return fmt.Errorf("purging idempotency keys: %s", err)
+		}
+
+		logger.Info("Purged expired idempotency keys", "count", purged, "window", p.window.String())
+		return nil
+	}
+}
@@ -2,16 +2,21 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"chagnon.dev/budget-server/internal/domain/model"
 	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type accountRepository interface {
+	UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error)
 	GetAllAccountsWithCurrencyIDs(ctx context.Context, userId uuid.UUID) ([]model.Account, error)
 	CreateAccount(
 		ctx context.Context,
@@ -27,6 +32,15 @@ type accountRepository interface {
 		id model.AccountID,
 		fields repository.UpdateAccountFields,
 	) error
+	ReconcileAccount(
+		ctx context.Context,
+		userId uuid.UUID,
+		accountId model.AccountID,
+		statementDate time.Time,
+		statementBalances []model.Balance,
+		dryRun bool,
+	) (model.ReconciliationResult, error)
+	UndoReconciliation(ctx context.Context, userId uuid.UUID, accountId model.AccountID) (int, error)
 }
 
 type AccountHandler struct {
@@ -104,8 +118,15 @@ func (s *AccountHandler) UpdateAccount(
 			InitialsAmounts:      initialAmounts,
 			AccountType:          req.Fields.Type,
 			FinancialInstitution: req.Fields.FinancialInstitution,
+			ExpectedVersion:      expectedVersionFromDto(req.ExpectedVersion),
 		},
 	)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return nil, s.staleAccountError(ctx, user.ID, model.AccountID(req.Id))
+	}
+	if errors.Is(err, repository.ErrAccountNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +134,23 @@ func (s *AccountHandler) UpdateAccount(
 	return &dto.UpdateAccountResponse{}, nil
 }
 
+// staleAccountError rejects an update made against an older version of the
+// account, with the account as it is now.
+func (s *AccountHandler) staleAccountError(ctx context.Context, userId uuid.UUID, id model.AccountID) error {
+	accounts, err := s.accountService.GetAllAccountsWithCurrencyIDs(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		if account.ID == id {
+			return staleWriteError(accountToDto(account))
+		}
+	}
+
+	return status.Error(codes.NotFound, repository.ErrAccountNotFound.Error())
+}
+
 func (s *AccountHandler) GetAllAccounts(ctx context.Context, _ *dto.GetAllAccountsRequest) (
 	*dto.GetAllAccountsResponse,
 	error,
@@ -129,27 +167,118 @@ func (s *AccountHandler) GetAllAccounts(ctx context.Context, _ *dto.GetAllAccoun
 
 	accountsDto := make([]*dto.Account, len(accounts))
 	for i, account := range accounts {
-		balances := make([]*dto.CurrencyBalance, 0)
-		for _, balance := range account.InitialBalances {
-			balances = append(
-				balances, &dto.CurrencyBalance{
-					CurrencyId: int32(balance.CurrencyId),
-					Amount:     int32(balance.Value),
-				},
-			)
-		}
-
-		accountsDto[i] = &dto.Account{
-			Id:                   uint32(account.ID),
-			Name:                 account.Name,
-			Balances:             balances,
-			IsMine:               account.IsMine,
-			Type:                 account.Type,
-			FinancialInstitution: account.FinancialInstitution,
-		}
+		accountsDto[i] = accountToDto(account)
 	}
 
 	return &dto.GetAllAccountsResponse{
 		Accounts: accountsDto,
 	}, nil
 }
+
+func accountToDto(account model.Account) *dto.Account {
+	balances := make([]*dto.CurrencyBalance, 0)
+	for _, balance := range account.InitialBalances {
+		balances = append(
+			balances, &dto.CurrencyBalance{
+				CurrencyId: int32(balance.CurrencyId),
+				Amount:     int32(balance.Value),
+			},
+		)
+	}
+
+	return &dto.Account{
+		Id:                   uint32(account.ID),
+		Name:                 account.Name,
+		Balances:             balances,
+		IsMine:               account.IsMine,
+		Type:                 account.Type,
+		FinancialInstitution: account.FinancialInstitution,
+		Version:              uint32(account.Version),
+	}
+}
+
+func (s *AccountHandler) ReconcileAccount(
+	ctx context.Context,
+	req *dto.ReconcileAccountRequest,
+) (*dto.ReconcileAccountResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := s.accountService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	statementDate, err := parseDate(req.StatementDate, location)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid statement date")
+	}
+
+	statementBalances := make([]model.Balance, 0, len(req.StatementBalances))
+	for _, balance := range req.StatementBalances {
+		statementBalances = append(
+			statementBalances, model.Balance{
+				CurrencyId: int(balance.CurrencyId),
+				Value:      int(balance.Amount),
+			},
+		)
+	}
+
+	result, err := s.accountService.ReconcileAccount(
+		ctx,
+		user.ID,
+		model.AccountID(req.AccountId),
+		statementDate,
+		statementBalances,
+		req.DryRun,
+	)
+	if errors.Is(err, repository.ErrAccountNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]*dto.ReconciliationBalance, len(result.Balances))
+	for i, balance := range result.Balances {
+		balances[i] = &dto.ReconciliationBalance{
+			CurrencyId:       int32(balance.Currency),
+			StatementBalance: int64(balance.StatementBalance),
+			ClearedBalance:   int64(balance.ClearedBalance),
+			Difference:       int64(balance.Difference()),
+		}
+	}
+
+	return &dto.ReconcileAccountResponse{
+		Balances:                   balances,
+		Reconciled:                 result.Reconciled,
+		ReconciledTransactionCount: uint32(result.ReconciledTransactions),
+	}, nil
+}
+
+func (s *AccountHandler) UndoReconciliation(
+	ctx context.Context,
+	req *dto.UndoReconciliationRequest,
+) (*dto.UndoReconciliationResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	unlocked, err := s.accountService.UndoReconciliation(ctx, user.ID, model.AccountID(req.AccountId))
+	if errors.Is(err, repository.ErrAccountNotFound) || errors.Is(err, repository.ErrReconciliationNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, repository.ErrReconciledElsewhere) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UndoReconciliationResponse{
+		UnlockedTransactionCount: uint32(unlocked),
+	}, nil
+}
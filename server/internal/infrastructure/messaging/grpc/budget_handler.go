@@ -0,0 +1,501 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/domain/service"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type budgetService interface {
+	GetAllBudgets(ctx context.Context, userId uuid.UUID) ([]model.Budget, error)
+	SetBudget(ctx context.Context, userId uuid.UUID, budget model.Budget) (model.BudgetID, error)
+	DeleteBudget(ctx context.Context, userId uuid.UUID, id model.BudgetID) error
+	GetBudgetStatus(
+		ctx context.Context,
+		userId uuid.UUID,
+		period model.BudgetPeriod,
+		date time.Time,
+	) (model.PeriodBudgetStatus, error)
+	UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error)
+	GetEnvelopeSettings(ctx context.Context, userId uuid.UUID) (model.Optional[model.EnvelopeSettings], error)
+	SetEnvelopeSettings(ctx context.Context, userId uuid.UUID, settings model.EnvelopeSettings) error
+	DisableEnvelopes(ctx context.Context, userId uuid.UUID) error
+	GetEnvelopes(ctx context.Context, userId uuid.UUID) ([]model.Envelope, error)
+	SetEnvelope(ctx context.Context, userId uuid.UUID, envelope model.Envelope) error
+	RemoveEnvelope(ctx context.Context, userId uuid.UUID, category model.CategoryID) error
+	AssignToEnvelope(ctx context.Context, userId uuid.UUID, assignment model.EnvelopeAssignment) error
+	TransferBudget(ctx context.Context, userId uuid.UUID, transfer model.BudgetTransfer) (model.BudgetTransferID, error)
+	DeleteBudgetTransfer(ctx context.Context, userId uuid.UUID, id model.BudgetTransferID) error
+	GetEnvelopeMonth(ctx context.Context, userId uuid.UUID, date time.Time) (model.EnvelopeMonth, error)
+}
+
+type BudgetHandler struct {
+	dto.UnimplementedBudgetServiceServer
+
+	budgetService budgetService
+}
+
+func BudgetPeriodFromDto(period dto.BudgetPeriod) (model.BudgetPeriod, error) {
+	switch period {
+	case dto.BudgetPeriod_Monthly:
+		return model.BudgetPeriodMonthly, nil
+	case dto.BudgetPeriod_Weekly:
+		return model.BudgetPeriodWeekly, nil
+	case dto.BudgetPeriod_Yearly:
+		return model.BudgetPeriodYearly, nil
+	default:
+		return model.BudgetPeriodMonthly, fmt.Errorf("unknown BudgetPeriod %s", period)
+	}
+}
+
+func BudgetPeriodToDto(period model.BudgetPeriod) (dto.BudgetPeriod, error) {
+	switch period {
+	case model.BudgetPeriodMonthly:
+		return dto.BudgetPeriod_Monthly, nil
+	case model.BudgetPeriodWeekly:
+		return dto.BudgetPeriod_Weekly, nil
+	case model.BudgetPeriodYearly:
+		return dto.BudgetPeriod_Yearly, nil
+	default:
+		return dto.BudgetPeriod_Monthly, fmt.Errorf("unknown BudgetPeriod %d", period)
+	}
+}
+
+func (h *BudgetHandler) GetAllBudgets(ctx context.Context, _ *dto.GetAllBudgetsRequest) (*dto.GetAllBudgetsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	budgets, err := h.budgetService.GetAllBudgets(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	budgetsDto := make([]*dto.Budget, len(budgets))
+	for i, budget := range budgets {
+		budgetsDto[i], err = budgetToDto(budget)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dto.GetAllBudgetsResponse{
+		Budgets: budgetsDto,
+	}, nil
+}
+
+func (h *BudgetHandler) SetBudget(ctx context.Context, req *dto.SetBudgetRequest) (*dto.SetBudgetResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	period, err := BudgetPeriodFromDto(req.Period)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	location, err := h.budgetService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	startsOn, err := parseDate(req.StartsOn, location)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid starts_on: %s", err))
+	}
+
+	id, err := h.budgetService.SetBudget(ctx, user.ID, model.Budget{
+		Category: model.CategoryID(req.CategoryId),
+		Period:   period,
+		StartsOn: startsOn,
+		Amount:   int(req.Amount),
+		Currency: model.CurrencyID(req.CurrencyId),
+	})
+	if err != nil {
+		return nil, budgetError(err)
+	}
+
+	return &dto.SetBudgetResponse{
+		Id: uint32(id),
+	}, nil
+}
+
+func (h *BudgetHandler) DeleteBudget(ctx context.Context, req *dto.DeleteBudgetRequest) (*dto.DeleteBudgetResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := h.budgetService.DeleteBudget(ctx, user.ID, model.BudgetID(req.Id))
+	if err != nil {
+		return nil, budgetError(err)
+	}
+
+	return &dto.DeleteBudgetResponse{}, nil
+}
+
+func (h *BudgetHandler) GetBudgetStatus(
+	ctx context.Context,
+	req *dto.GetBudgetStatusRequest,
+) (*dto.GetBudgetStatusResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	period, err := BudgetPeriodFromDto(req.Period)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	location, err := h.budgetService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := dateOrToday(req.Date, location)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid date: %s", err))
+	}
+
+	periodStatus, err := h.budgetService.GetBudgetStatus(ctx, user.ID, period, date)
+	if err != nil {
+		return nil, budgetError(err)
+	}
+
+	budgetsDto := make([]*dto.BudgetStatus, len(periodStatus.Budgets))
+	for i, budgetStatus := range periodStatus.Budgets {
+		budgetDto, err := budgetToDto(budgetStatus.Budget)
+		if err != nil {
+			return nil, err
+		}
+
+		budgetsDto[i] = &dto.BudgetStatus{
+			Budget:              budgetDto,
+			Spent:               budgetStatus.Spent,
+			Remaining:           budgetStatus.Remaining,
+			MissingExchangeRate: budgetStatus.MissingExchangeRate,
+		}
+	}
+
+	return &dto.GetBudgetStatusResponse{
+		PeriodStart: periodStatus.Start.Format(layout),
+		PeriodEnd:   periodStatus.End.Format(layout),
+		Budgets:     budgetsDto,
+	}, nil
+}
+
+func (h *BudgetHandler) GetAllEnvelopes(
+	ctx context.Context,
+	_ *dto.GetAllEnvelopesRequest,
+) (*dto.GetAllEnvelopesResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	settings, err := h.budgetService.GetEnvelopeSettings(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes, err := h.budgetService.GetEnvelopes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.GetAllEnvelopesResponse{
+		Envelopes: make([]*dto.Envelope, len(envelopes)),
+	}
+	if value, isSome := settings.Value(); isSome {
+		response.Settings = &dto.EnvelopeSettings{
+			CurrencyId: uint32(value.Currency),
+			StartsOn:   value.StartsOn.Format(layout),
+		}
+	}
+	for i, envelope := range envelopes {
+		response.Envelopes[i] = &dto.Envelope{
+			CategoryId: uint32(envelope.Category),
+			Income:     envelope.Income,
+		}
+	}
+
+	return response, nil
+}
+
+func (h *BudgetHandler) SetEnvelopeSettings(
+	ctx context.Context,
+	req *dto.SetEnvelopeSettingsRequest,
+) (*dto.SetEnvelopeSettingsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := h.budgetService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	startsOn, err := parseDate(req.StartsOn, location)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid starts_on: %s", err))
+	}
+
+	err = h.budgetService.SetEnvelopeSettings(ctx, user.ID, model.EnvelopeSettings{
+		Currency: model.CurrencyID(req.CurrencyId),
+		StartsOn: startsOn,
+	})
+	if err != nil {
+		return nil, budgetError(err)
+	}
+
+	return &dto.SetEnvelopeSettingsResponse{}, nil
+}
+
+func (h *BudgetHandler) DisableEnvelopes(
+	ctx context.Context,
+	_ *dto.DisableEnvelopesRequest,
+) (*dto.DisableEnvelopesResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if err := h.budgetService.DisableEnvelopes(ctx, user.ID); err != nil {
+		return nil, budgetError(err)
+	}
+
+	return &dto.DisableEnvelopesResponse{}, nil
+}
+
+func (h *BudgetHandler) SetEnvelope(ctx context.Context, req *dto.SetEnvelopeRequest) (*dto.SetEnvelopeResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := h.budgetService.SetEnvelope(ctx, user.ID, model.Envelope{
+		Category: model.CategoryID(req.CategoryId),
+		Income:   req.Income,
+	})
+	if err != nil {
+		return nil, budgetError(err)
+	}
+
+	return &dto.SetEnvelopeResponse{}, nil
+}
+
+func (h *BudgetHandler) RemoveEnvelope(
+	ctx context.Context,
+	req *dto.RemoveEnvelopeRequest,
+) (*dto.RemoveEnvelopeResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if err := h.budgetService.RemoveEnvelope(ctx, user.ID, model.CategoryID(req.CategoryId)); err != nil {
+		return nil, budgetError(err)
+	}
+
+	return &dto.RemoveEnvelopeResponse{}, nil
+}
+
+func (h *BudgetHandler) AssignToEnvelope(
+	ctx context.Context,
+	req *dto.AssignToEnvelopeRequest,
+) (*dto.AssignToEnvelopeResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := h.budgetService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	month, err := parseDate(req.Month, location)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid month: %s", err))
+	}
+
+	err = h.budgetService.AssignToEnvelope(ctx, user.ID, model.EnvelopeAssignment{
+		Category: model.CategoryID(req.CategoryId),
+		Month:    month,
+		Amount:   int(req.Amount),
+	})
+	if err != nil {
+		return nil, budgetError(err)
+	}
+
+	return &dto.AssignToEnvelopeResponse{}, nil
+}
+
+func (h *BudgetHandler) TransferBudget(
+	ctx context.Context,
+	req *dto.TransferBudgetRequest,
+) (*dto.TransferBudgetResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := h.budgetService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	month, err := parseDate(req.Month, location)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid month: %s", err))
+	}
+
+	id, err := h.budgetService.TransferBudget(ctx, user.ID, model.BudgetTransfer{
+		Month:  month,
+		From:   model.CategoryID(req.FromCategoryId),
+		To:     model.CategoryID(req.ToCategoryId),
+		Amount: int(req.Amount),
+		Note:   req.Note,
+	})
+	if err != nil {
+		return nil, budgetError(err)
+	}
+
+	return &dto.TransferBudgetResponse{
+		Id: uint32(id),
+	}, nil
+}
+
+func (h *BudgetHandler) DeleteBudgetTransfer(
+	ctx context.Context,
+	req *dto.DeleteBudgetTransferRequest,
+) (*dto.DeleteBudgetTransferResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if err := h.budgetService.DeleteBudgetTransfer(ctx, user.ID, model.BudgetTransferID(req.Id)); err != nil {
+		return nil, budgetError(err)
+	}
+
+	return &dto.DeleteBudgetTransferResponse{}, nil
+}
+
+func (h *BudgetHandler) GetEnvelopeMonth(
+	ctx context.Context,
+	req *dto.GetEnvelopeMonthRequest,
+) (*dto.GetEnvelopeMonthResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := h.budgetService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := dateOrToday(req.Month, location)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid month: %s", err))
+	}
+
+	envelopeMonth, err := h.budgetService.GetEnvelopeMonth(ctx, user.ID, date)
+	if err != nil {
+		return nil, budgetError(err)
+	}
+
+	envelopesDto := make([]*dto.EnvelopeBalance, len(envelopeMonth.Envelopes))
+	for i, entry := range envelopeMonth.Envelopes {
+		envelopesDto[i] = &dto.EnvelopeBalance{
+			CategoryId:          uint32(entry.Category),
+			Income:              entry.Income,
+			Assigned:            entry.Assigned,
+			Transferred:         entry.Transferred,
+			Activity:            entry.Activity,
+			Balance:             entry.Balance,
+			MissingExchangeRate: entry.MissingExchangeRate,
+		}
+	}
+
+	transfersDto := make([]*dto.BudgetTransfer, len(envelopeMonth.Transfers))
+	for i, transfer := range envelopeMonth.Transfers {
+		transfersDto[i] = &dto.BudgetTransfer{
+			Id:             uint32(transfer.ID),
+			Month:          transfer.Month.Format(layout),
+			FromCategoryId: uint32(transfer.From),
+			ToCategoryId:   uint32(transfer.To),
+			Amount:         uint32(transfer.Amount),
+			Note:           transfer.Note,
+		}
+	}
+
+	return &dto.GetEnvelopeMonthResponse{
+		Month:             envelopeMonth.Month.Format(layout),
+		CurrencyId:        uint32(envelopeMonth.Currency),
+		AvailableToBudget: envelopeMonth.AvailableToBudget,
+		Envelopes:         envelopesDto,
+		Transfers:         transfersDto,
+	}, nil
+}
+
+// dateOrToday reads an optional date sent by a client, which defaults to the
+// current date in the user's timezone.
+func dateOrToday(value *string, location *time.Location) (time.Time, error) {
+	if value != nil {
+		return parseDate(*value, location)
+	}
+
+	today := time.Now().In(location)
+	return time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+func budgetToDto(budget model.Budget) (*dto.Budget, error) {
+	period, err := BudgetPeriodToDto(budget.Period)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.Budget{
+		Id:         uint32(budget.ID),
+		CategoryId: uint32(budget.Category),
+		Period:     period,
+		StartsOn:   budget.StartsOn.Format(layout),
+		Amount:     uint32(budget.Amount),
+		CurrencyId: uint32(budget.Currency),
+	}, nil
+}
+
+func budgetError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrBudgetNotFound),
+		errors.Is(err, repository.ErrEnvelopeNotFound),
+		errors.Is(err, repository.ErrBudgetTransferNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrEnvelopesDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, repository.ErrMonthBeforeEnvelopes):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, repository.ErrCategoryNotFound),
+		errors.Is(err, repository.ErrCurrencyNotFound),
+		errors.As(err, new(*service.ValidationError)):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
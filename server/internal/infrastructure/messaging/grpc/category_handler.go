@@ -3,10 +3,15 @@ package grpc
 import (
 	"chagnon.dev/budget-server/internal/domain/model"
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"chagnon.dev/budget-server/internal/domain/service"
 	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
@@ -28,6 +33,39 @@ type categoryRepository interface {
 		id model.CategoryID,
 		fields repository.UpdateCategoryFields,
 	) error
+	DeleteCategory(
+		ctx context.Context,
+		userId uuid.UUID,
+		id, replacementId model.CategoryID,
+		deleteChildren bool,
+	) error
+	MergeCategories(ctx context.Context, userId uuid.UUID, sourceId, targetId model.CategoryID) error
+	MoveCategory(
+		ctx context.Context,
+		userId uuid.UUID,
+		id, parentId model.CategoryID,
+		position repository.CategoryPosition,
+	) error
+	GetCategoryTemplates() []model.CategoryTemplate
+	GetCategoryTemplate(id string) (model.CategoryTemplate, error)
+	ApplyCategoryTemplate(
+		ctx context.Context,
+		userId uuid.UUID,
+		template model.CategoryTemplate,
+	) (model.CategoryTemplateResult, error)
+	ExportCategoryTemplate(
+		ctx context.Context,
+		userId uuid.UUID,
+		name, description string,
+	) (model.CategoryTemplate, error)
+	GetCategoryTotals(
+		ctx context.Context,
+		userId uuid.UUID,
+		from, through time.Time,
+		currency model.CurrencyID,
+		monthly bool,
+	) (model.CategoryTotalsReport, error)
+	UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error)
 }
 
 type CategoryHandler struct {
@@ -90,15 +128,25 @@ func (s *CategoryHandler) UpdateCategory(
 		user.ID,
 		model.CategoryID(req.Id),
 		repository.UpdateCategoryFields{
-			Name:           req.Fields.Name,
-			IconName:       req.Fields.IconName,
-			IconColor:      req.Fields.IconColor,
-			IconBackground: req.Fields.IconBackground,
-			ParentId:       parentId,
-			FixedCosts:     req.Fields.FixedCosts,
-			Ordering:       req.Fields.Ordering,
+			Name:            req.Fields.Name,
+			IconName:        req.Fields.IconName,
+			IconColor:       req.Fields.IconColor,
+			IconBackground:  req.Fields.IconBackground,
+			ParentId:        parentId,
+			FixedCosts:      req.Fields.FixedCosts,
+			Ordering:        req.Fields.Ordering,
+			ExpectedVersion: expectedVersionFromDto(req.ExpectedVersion),
 		},
 	)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return nil, s.staleCategoryError(ctx, user.ID, model.CategoryID(req.Id))
+	}
+	if errors.Is(err, repository.ErrCategoryNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, repository.ErrCategoryCycle) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -106,6 +154,23 @@ func (s *CategoryHandler) UpdateCategory(
 	return &dto.UpdateCategoryResponse{}, nil
 }
 
+// staleCategoryError rejects an update made against an older version of the
+// category, with the category as it is now.
+func (s *CategoryHandler) staleCategoryError(ctx context.Context, userId uuid.UUID, id model.CategoryID) error {
+	categories, err := s.categoryService.GetAllCategories(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, category := range categories {
+		if category.ID == id {
+			return staleWriteError(categoryToDto(category))
+		}
+	}
+
+	return status.Error(codes.NotFound, repository.ErrCategoryNotFound.Error())
+}
+
 func (s *CategoryHandler) GetAllCategories(
 	ctx context.Context,
 	_ *dto.GetAllCategoriesRequest,
@@ -122,19 +187,351 @@ func (s *CategoryHandler) GetAllCategories(
 
 	categoriesDto := make([]*dto.Category, len(categories))
 	for i, category := range categories {
-		categoriesDto[i] = &dto.Category{
-			Id:             uint32(category.ID),
-			Name:           category.Name,
-			ParentId:       uint32(category.ParentId),
-			IconName:       category.IconName,
-			IconColor:      category.IconColor,
-			IconBackground: category.IconBackground,
-			FixedCosts:     category.FixedCost,
-			Ordering:       category.Ordering,
-		}
+		categoriesDto[i] = categoryToDto(category)
 	}
 
 	return &dto.GetAllCategoriesResponse{
 		Categories: categoriesDto,
 	}, nil
 }
+
+func (s *CategoryHandler) DeleteCategory(
+	ctx context.Context,
+	req *dto.DeleteCategoryRequest,
+) (*dto.DeleteCategoryResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if req.ReplacementId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "a replacement category is required")
+	}
+
+	err := s.categoryService.DeleteCategory(
+		ctx,
+		user.ID,
+		model.CategoryID(req.Id),
+		model.CategoryID(req.ReplacementId),
+		req.DeleteChildren,
+	)
+	if err != nil {
+		return nil, categoryRemovalError(err)
+	}
+
+	return &dto.DeleteCategoryResponse{}, nil
+}
+
+func (s *CategoryHandler) MergeCategories(
+	ctx context.Context,
+	req *dto.MergeCategoriesRequest,
+) (*dto.MergeCategoriesResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := s.categoryService.MergeCategories(
+		ctx,
+		user.ID,
+		model.CategoryID(req.SourceId),
+		model.CategoryID(req.TargetId),
+	)
+	if err != nil {
+		return nil, categoryRemovalError(err)
+	}
+
+	return &dto.MergeCategoriesResponse{}, nil
+}
+
+func (s *CategoryHandler) MoveCategory(
+	ctx context.Context,
+	req *dto.MoveCategoryRequest,
+) (*dto.MoveCategoryResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if req.ParentId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "a parent category is required")
+	}
+
+	position := repository.CategoryPosition{
+		ExpectedVersion: expectedVersionFromDto(req.ExpectedVersion),
+	}
+	switch sibling := req.GetPosition().(type) {
+	case *dto.MoveCategoryRequest_BeforeId:
+		position.Before = model.Some(model.CategoryID(sibling.BeforeId))
+	case *dto.MoveCategoryRequest_AfterId:
+		position.After = model.Some(model.CategoryID(sibling.AfterId))
+	}
+
+	err := s.categoryService.MoveCategory(
+		ctx,
+		user.ID,
+		model.CategoryID(req.Id),
+		model.CategoryID(req.ParentId),
+		position,
+	)
+	switch {
+	case errors.Is(err, repository.ErrVersionConflict):
+		return nil, s.staleCategoryError(ctx, user.ID, model.CategoryID(req.Id))
+	case errors.Is(err, repository.ErrCategoryNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrRootCategory):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, repository.ErrCategoryCycle),
+		errors.Is(err, repository.ErrSiblingNotFound):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, err
+	}
+
+	return &dto.MoveCategoryResponse{}, nil
+}
+
+func (s *CategoryHandler) GetCategoryTemplates(
+	_ context.Context,
+	_ *dto.GetCategoryTemplatesRequest,
+) (*dto.GetCategoryTemplatesResponse, error) {
+	templates := s.categoryService.GetCategoryTemplates()
+
+	templatesDto := make([]*dto.CategoryTemplate, len(templates))
+	for i, template := range templates {
+		templatesDto[i] = categoryTemplateToDto(template)
+	}
+
+	return &dto.GetCategoryTemplatesResponse{
+		Templates: templatesDto,
+	}, nil
+}
+
+func (s *CategoryHandler) ApplyCategoryTemplate(
+	ctx context.Context,
+	req *dto.ApplyCategoryTemplateRequest,
+) (*dto.ApplyCategoryTemplateResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	var template model.CategoryTemplate
+	switch source := req.GetTemplate().(type) {
+	case *dto.ApplyCategoryTemplateRequest_TemplateId:
+		var err error
+		template, err = s.categoryService.GetCategoryTemplate(source.TemplateId)
+		if err != nil {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+	case *dto.ApplyCategoryTemplateRequest_Custom:
+		template = categoryTemplateFromDto(source.Custom)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "a template is required")
+	}
+
+	result, err := s.categoryService.ApplyCategoryTemplate(ctx, user.ID, template)
+	if errors.As(err, new(*service.ValidationError)) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.ApplyCategoryTemplateResponse{
+		CreatedIds:  make([]uint32, len(result.Created)),
+		ExistingIds: make([]uint32, len(result.Existing)),
+	}
+	for i, id := range result.Created {
+		response.CreatedIds[i] = uint32(id)
+	}
+	for i, id := range result.Existing {
+		response.ExistingIds[i] = uint32(id)
+	}
+
+	return response, nil
+}
+
+func (s *CategoryHandler) ExportCategoryTemplate(
+	ctx context.Context,
+	req *dto.ExportCategoryTemplateRequest,
+) (*dto.ExportCategoryTemplateResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	template, err := s.categoryService.ExportCategoryTemplate(ctx, user.ID, req.Name, req.Description)
+	if errors.As(err, new(*service.ValidationError)) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ExportCategoryTemplateResponse{
+		Template: categoryTemplateToDto(template),
+	}, nil
+}
+
+func (s *CategoryHandler) GetCategoryTotals(
+	ctx context.Context,
+	req *dto.GetCategoryTotalsRequest,
+) (*dto.GetCategoryTotalsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := s.categoryService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := parseDate(req.From, location)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid from: %s", err))
+	}
+
+	to, err := parseDate(req.To, location)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid to: %s", err))
+	}
+
+	report, err := s.categoryService.GetCategoryTotals(
+		ctx,
+		user.ID,
+		from,
+		to,
+		model.CurrencyID(req.CurrencyId),
+		req.Monthly,
+	)
+	if errors.As(err, new(*service.ValidationError)) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, repository.ErrCurrencyNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	monthsDto := make([]*dto.CategoryTotals, len(report.Months))
+	for i, month := range report.Months {
+		monthsDto[i] = categoryTotalsToDto(month)
+	}
+
+	return &dto.GetCategoryTotalsResponse{
+		CurrencyId: uint32(report.Currency),
+		Total:      categoryTotalsToDto(report.Total),
+		Months:     monthsDto,
+	}, nil
+}
+
+func categoryRemovalError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrCategoryNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrRootCategory),
+		errors.Is(err, repository.ErrBudgetsConflict):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, repository.ErrReplacedBySubcategory):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
+
+func categoryToDto(category model.Category) *dto.Category {
+	return &dto.Category{
+		Id:             uint32(category.ID),
+		Name:           category.Name,
+		ParentId:       uint32(category.ParentId),
+		IconName:       category.IconName,
+		IconColor:      category.IconColor,
+		IconBackground: category.IconBackground,
+		FixedCosts:     category.FixedCost,
+		Ordering:       category.Ordering,
+		Version:        uint32(category.Version),
+	}
+}
+
+func categoryTemplateToDto(template model.CategoryTemplate) *dto.CategoryTemplate {
+	return &dto.CategoryTemplate{
+		Id:          template.ID,
+		Name:        template.Name,
+		Description: template.Description,
+		Categories:  categoryTemplateNodesToDto(template.Categories),
+	}
+}
+
+func categoryTemplateNodesToDto(nodes []model.CategoryTemplateNode) []*dto.CategoryTemplateNode {
+	nodesDto := make([]*dto.CategoryTemplateNode, len(nodes))
+	for i, node := range nodes {
+		nodesDto[i] = &dto.CategoryTemplateNode{
+			Name:           node.Name,
+			IconName:       node.IconName,
+			IconColor:      node.IconColor,
+			IconBackground: node.IconBackground,
+			FixedCosts:     node.FixedCost,
+			Children:       categoryTemplateNodesToDto(node.Children),
+		}
+	}
+
+	return nodesDto
+}
+
+func categoryTemplateFromDto(template *dto.CategoryTemplate) model.CategoryTemplate {
+	return model.CategoryTemplate{
+		ID:          template.GetId(),
+		Name:        template.GetName(),
+		Description: template.GetDescription(),
+		Categories:  categoryTemplateNodesFromDto(template.GetCategories()),
+	}
+}
+
+func categoryTemplateNodesFromDto(nodesDto []*dto.CategoryTemplateNode) []model.CategoryTemplateNode {
+	nodes := make([]model.CategoryTemplateNode, len(nodesDto))
+	for i, nodeDto := range nodesDto {
+		nodes[i] = model.CategoryTemplateNode{
+			Name:           nodeDto.GetName(),
+			IconName:       nodeDto.GetIconName(),
+			IconColor:      nodeDto.GetIconColor(),
+			IconBackground: nodeDto.GetIconBackground(),
+			FixedCost:      nodeDto.GetFixedCosts(),
+			Children:       categoryTemplateNodesFromDto(nodeDto.GetChildren()),
+		}
+	}
+
+	return nodes
+}
+
+func categoryTotalsToDto(totals model.CategoryTotals) *dto.CategoryTotals {
+	categoriesDto := make([]*dto.CategoryTotal, len(totals.Categories))
+	for i, total := range totals.Categories {
+		categoriesDto[i] = &dto.CategoryTotal{
+			CategoryId: uint32(total.Category),
+			Own:        categoryAmountsToDto(total.Own),
+			Subtree:    categoryAmountsToDto(total.Subtree),
+		}
+	}
+
+	return &dto.CategoryTotals{
+		Start:               totals.Start.Format(layout),
+		End:                 totals.End.Format(layout),
+		Categories:          categoriesDto,
+		Uncategorized:       categoryAmountsToDto(totals.Uncategorized),
+		MissingExchangeRate: totals.MissingExchangeRate,
+	}
+}
+
+func categoryAmountsToDto(amounts model.CategoryAmounts) *dto.CategoryAmounts {
+	return &dto.CategoryAmounts{
+		Income:          amounts.Income(),
+		Expense:         amounts.Expense(),
+		FixedIncome:     amounts.FixedIncome,
+		VariableIncome:  amounts.VariableIncome,
+		FixedExpense:    amounts.FixedExpense,
+		VariableExpense: amounts.VariableExpense,
+	}
+}
@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+)
+
+type changeFeed interface {
+	Subscribe(userId uuid.UUID, email string) (<-chan model.Change, func())
+}
+
+type ChangeHandler struct {
+	dto.UnimplementedChangeServiceServer
+
+	changeFeed changeFeed
+}
+
+func (s *ChangeHandler) WatchChanges(
+	_ *dto.WatchChangesRequest,
+	stream dto.ChangeService_WatchChangesServer,
+) error {
+	ctx := stream.Context()
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return fmt.Errorf("getting user from context")
+	}
+
+	changes, unsubscribe := s.changeFeed.Subscribe(user.ID, user.Email)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, open := <-changes:
+			if !open {
+				return status.Error(codes.Unavailable, "change feed fell behind; reconnect and reload")
+			}
+
+			if err := stream.Send(changeToDto(change)); err != nil {
+				return fmt.Errorf("sending change event: %w", err)
+			}
+		}
+	}
+}
+
+func changeToDto(change model.Change) *dto.ChangeEvent {
+	event := &dto.ChangeEvent{
+		Id:             uint32(change.ID),
+		ResyncRequired: change.ResyncRequired,
+	}
+
+	switch change.Entity {
+	case model.ChangedEntityTransaction:
+		event.Entity = dto.ChangedEntity_TransactionEntity
+	case model.ChangedEntityAccount:
+		event.Entity = dto.ChangedEntity_AccountEntity
+	case model.ChangedEntityCategory:
+		event.Entity = dto.ChangedEntity_CategoryEntity
+	case model.ChangedEntityCurrency:
+		event.Entity = dto.ChangedEntity_CurrencyEntity
+	case model.ChangedEntityExchangeRate:
+		event.Entity = dto.ChangedEntity_ExchangeRateEntity
+	case model.ChangedEntityTransactionGroup:
+		event.Entity = dto.ChangedEntity_TransactionGroupEntity
+	case model.ChangedEntityTag:
+		event.Entity = dto.ChangedEntity_TagEntity
+	case model.ChangedEntityPayee:
+		event.Entity = dto.ChangedEntity_PayeeEntity
+	case model.ChangedEntityRule:
+		event.Entity = dto.ChangedEntity_RuleEntity
+	case model.ChangedEntityBudget:
+		event.Entity = dto.ChangedEntity_BudgetEntity
+	}
+
+	switch change.Type {
+	case model.ChangeTypeCreated:
+		event.Type = dto.ChangeType_Created
+	case model.ChangeTypeUpdated:
+		event.Type = dto.ChangeType_Updated
+	case model.ChangeTypeDeleted:
+		event.Type = dto.ChangeType_Deleted
+	}
+
+	if key, ok := change.ExchangeRate.Value(); ok {
+		event.ExchangeRate = &dto.ExchangeRateKey{
+			CurrencyA: uint32(key.CurrencyA),
+			CurrencyB: uint32(key.CurrencyB),
+			Date:      key.Date.Format(layout),
+		}
+	}
+
+	return event
+}
@@ -6,8 +6,11 @@ import (
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"context"
+	"errors"
 	"fmt"
 )
 
@@ -83,8 +86,15 @@ func (s *CurrencyHandler) UpdateCurrency(
 			DecimalPoints:         decimalPoints,
 			RateAutoUpdateScript:  req.Fields.AutoUpdateSettingsScript,
 			RateAutoUpdateEnabled: req.Fields.AutoUpdateSettingsEnabled,
+			ExpectedVersion:       expectedVersionFromDto(req.ExpectedVersion),
 		},
 	)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return nil, s.staleCurrencyError(ctx, user.ID, model.CurrencyID(req.Id))
+	}
+	if errors.Is(err, repository.ErrCurrencyNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -92,6 +102,23 @@ func (s *CurrencyHandler) UpdateCurrency(
 	return &dto.UpdateCurrencyResponse{}, nil
 }
 
+// staleCurrencyError rejects an update made against an older version of the
+// currency, with the currency as it is now.
+func (s *CurrencyHandler) staleCurrencyError(ctx context.Context, userId uuid.UUID, id model.CurrencyID) error {
+	currencies, err := s.currencyService.GetAllCurrencies(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, currency := range currencies {
+		if currency.ID == id {
+			return staleWriteError(currencyToDto(currency))
+		}
+	}
+
+	return status.Error(codes.NotFound, repository.ErrCurrencyNotFound.Error())
+}
+
 func (s *CurrencyHandler) GetAllCurrencies(
 	ctx context.Context,
 	_ *dto.GetAllCurrenciesRequest,
@@ -108,17 +135,7 @@ func (s *CurrencyHandler) GetAllCurrencies(
 
 	currenciesDto := make([]*dto.Currency, len(currencies))
 	for i, currency := range currencies {
-
-		currenciesDto[i] = &dto.Currency{
-			Id:                        uint32(currency.ID),
-			Name:                      currency.Name,
-			Symbol:                    currency.Symbol,
-			Risk:                      currency.Risk,
-			Type:                      currency.Type,
-			DecimalPoints:             uint32(currency.DecimalPoints),
-			AutoUpdateSettingsScript:  currency.RateAutoUpdateSettings.Script,
-			AutoUpdateSettingsEnabled: currency.RateAutoUpdateSettings.Enabled,
-		}
+		currenciesDto[i] = currencyToDto(currency)
 	}
 
 	return &dto.GetAllCurrenciesResponse{
@@ -142,3 +159,17 @@ func (s *CurrencyHandler) SetDefaultCurrency(
 
 	return &dto.SetDefaultCurrencyResponse{}, nil
 }
+
+func currencyToDto(currency model.Currency) *dto.Currency {
+	return &dto.Currency{
+		Id:                        uint32(currency.ID),
+		Name:                      currency.Name,
+		Symbol:                    currency.Symbol,
+		Risk:                      currency.Risk,
+		Type:                      currency.Type,
+		DecimalPoints:             uint32(currency.DecimalPoints),
+		AutoUpdateSettingsScript:  currency.RateAutoUpdateSettings.Script,
+		AutoUpdateSettingsEnabled: currency.RateAutoUpdateSettings.Enabled,
+		Version:                   uint32(currency.Version),
+	}
+}
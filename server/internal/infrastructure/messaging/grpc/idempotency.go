@@ -0,0 +1,202 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"chagnon.dev/budget-server/internal/logging"
+)
+
+// idempotencyKeyMetadata is the metadata a client can send the key in, for
+// the requests where it would rather not set the idempotency_key field.
+const idempotencyKeyMetadata = "idempotency-key"
+
+const maxIdempotencyKeyLength = 255
+
+type idempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, userId uuid.UUID, key, method string, requestHash []byte, expiredBefore time.Time) (bool, error)
+	GetIdempotentRequest(ctx context.Context, userId uuid.UUID, key string) (model.IdempotentRequest, error)
+	SaveIdempotentResponse(ctx context.Context, userId uuid.UUID, key string, response []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, userId uuid.UUID, key string) error
+}
+
+var idempotentMethods = map[string]bool{
+	dto.AccountService_CreateAccount_FullMethodName:                   true,
+	dto.CategoryService_CreateCategory_FullMethodName:                 true,
+	dto.CurrencyService_CreateCurrency_FullMethodName:                 true,
+	dto.TransactionService_CreateTransaction_FullMethodName:           true,
+	dto.TransactionGroupService_CreateTransactionGroup_FullMethodName: true,
+}
+
+// newIdempotencyInterceptor makes the create RPCs safe to retry. A request
+// carrying an idempotency key already used by the same user within window
+// gets the response of the first request back instead of creating the
+// entity a second time. Requests without a key are handled as usual.
+func newIdempotencyInterceptor(store idempotencyStore, window time.Duration) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if !idempotentMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		key := idempotencyKey(ctx, req)
+		if key == "" {
+			return handler(ctx, req)
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "idempotency key is longer than %d characters", maxIdempotencyKeyLength)
+		}
+
+		user, ok := shared.FromContext(ctx)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		requestHash, err := hashRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		reserved, err := store.ReserveIdempotencyKey(ctx, user.ID, key, info.FullMethod, requestHash, time.Now().Add(-window))
+		if err != nil {
+			return nil, err
+		}
+		if !reserved {
+			return replayIdempotentResponse(ctx, store, user.ID, key, info.FullMethod, requestHash)
+		}
+
+		// The bookkeeping below must happen even if the client went away
+		// while the handler ran.
+		bookkeepingCtx := context.WithoutCancel(ctx)
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			if releaseErr := store.ReleaseIdempotencyKey(bookkeepingCtx, user.ID, key); releaseErr != nil {
+				logging.FromContext(ctx).Error("releasing idempotency key", "method", info.FullMethod, "error", releaseErr)
+			}
+			return resp, err
+		}
+
+		// The entity exists by now, so the client gets its response either
+		// way. The key stays reserved if saving fails, which turns retries
+		// away rather than creating the entity twice.
+		if saveErr := saveIdempotentResponse(bookkeepingCtx, store, user.ID, key, resp); saveErr != nil {
+			logging.FromContext(ctx).Error("saving idempotent response", "method", info.FullMethod, "error", saveErr)
+		}
+
+		return resp, nil
+	}
+}
+
+func idempotencyKey(ctx context.Context, req interface{}) string {
+	if withKey, ok := req.(interface{ GetIdempotencyKey() string }); ok && withKey.GetIdempotencyKey() != "" {
+		return withKey.GetIdempotencyKey()
+	}
+
+	if values := metadata.ValueFromIncomingContext(ctx, idempotencyKeyMetadata); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// hashRequest fingerprints a request to tell a retry from a different request
+// reusing the key. The key itself is left out so that it can move between
+// the field and the metadata from one attempt to the next.
+func hashRequest(req interface{}) ([]byte, error) {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("hashing request: %T is not a protobuf message", req)
+	}
+
+	message = proto.Clone(message)
+	reflected := message.ProtoReflect()
+	if field := reflected.Descriptor().Fields().ByName("idempotency_key"); field != nil {
+		reflected.Clear(field)
+	}
+
+	encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("hashing request: %w", err)
+	}
+
+	hash := sha256.Sum256(encoded)
+	return hash[:], nil
+}
+
+func saveIdempotentResponse(ctx context.Context, store idempotencyStore, userId uuid.UUID, key string, resp interface{}) error {
+	message, ok := resp.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a protobuf message", resp)
+	}
+
+	wrapped, err := anypb.New(message)
+	if err != nil {
+		return fmt.Errorf("wrapping response: %w", err)
+	}
+
+	encoded, err := proto.Marshal(wrapped)
+	if err != nil {
+		return fmt.Errorf("encoding response: %w", err)
+	}
+
+	return store.SaveIdempotentResponse(ctx, userId, key, encoded)
+}
+
+func replayIdempotentResponse(
+	ctx context.Context,
+	store idempotencyStore,
+	userId uuid.UUID,
+	key, method string,
+	requestHash []byte,
+) (interface{}, error) {
+	previous, err := store.GetIdempotentRequest(ctx, userId, key)
+	if errors.Is(err, repository.ErrIdempotencyKeyNotFound) {
+		// The request holding the key failed and released it in between.
+		return nil, status.Error(codes.Aborted, "the previous request with this idempotency key failed, retry it")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if previous.Method != method || !bytes.Equal(previous.RequestHash, requestHash) {
+		return nil, status.Error(codes.InvalidArgument, "idempotency key was already used for a different request")
+	}
+
+	response, done := previous.Response.Value()
+	if !done {
+		return nil, status.Error(codes.Aborted, "a request with this idempotency key is still in progress")
+	}
+
+	var wrapped anypb.Any
+	if err = proto.Unmarshal(response, &wrapped); err != nil {
+		return nil, fmt.Errorf("decoding idempotent response: %w", err)
+	}
+
+	replayed, err := wrapped.UnmarshalNew()
+	if err != nil {
+		return nil, fmt.Errorf("decoding idempotent response: %w", err)
+	}
+
+	return replayed, nil
+}
@@ -45,3 +45,30 @@ func sanitizeErrorInterceptor(
 	)
 	return resp, status.Error(codes.Internal, "internal error")
 }
+
+// sanitizeErrorStreamInterceptor applies the same policy as
+// sanitizeErrorInterceptor to streaming handlers.
+func sanitizeErrorStreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	err := handler(srv, ss)
+	if err == nil {
+		return nil
+	}
+
+	st, _ := status.FromError(err)
+	if st.Code() != codes.Unknown && st.Code() != codes.Internal {
+		return err
+	}
+
+	logging.FromContext(ss.Context()).Error(
+		"grpc handler error",
+		"method", info.FullMethod,
+		"code", st.Code().String(),
+		"error", err,
+	)
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,176 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type payeeRepository interface {
+	GetAllPayees(ctx context.Context, userId uuid.UUID) ([]model.Payee, error)
+	CreatePayee(
+		ctx context.Context,
+		userId uuid.UUID,
+		name string,
+		defaultCategory model.Optional[model.CategoryID],
+		aliases []string,
+	) (model.PayeeID, error)
+	UpdatePayee(
+		ctx context.Context,
+		userId uuid.UUID,
+		id model.PayeeID,
+		name string,
+		defaultCategory model.Optional[model.CategoryID],
+		aliases []string,
+	) error
+	DeletePayee(ctx context.Context, userId uuid.UUID, id model.PayeeID) error
+	ResolvePayee(ctx context.Context, userId uuid.UUID, merchant string) (model.Optional[model.Payee], error)
+}
+
+type PayeeHandler struct {
+	dto.UnimplementedPayeeServiceServer
+
+	payeeService payeeRepository
+}
+
+func (s *PayeeHandler) GetAllPayees(ctx context.Context, _ *dto.GetAllPayeesRequest) (*dto.GetAllPayeesResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	payees, err := s.payeeService.GetAllPayees(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	payeesDto := make([]*dto.Payee, len(payees))
+	for i, payee := range payees {
+		payeesDto[i] = payeeToDto(payee)
+	}
+
+	return &dto.GetAllPayeesResponse{
+		Payees: payeesDto,
+	}, nil
+}
+
+func (s *PayeeHandler) CreatePayee(ctx context.Context, req *dto.CreatePayeeRequest) (*dto.CreatePayeeResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "a payee needs a name")
+	}
+
+	newId, err := s.payeeService.CreatePayee(ctx, user.ID, name, optionalCategoryFromDto(req.DefaultCategory), req.Aliases)
+	if err != nil {
+		return nil, payeeError(err)
+	}
+
+	return &dto.CreatePayeeResponse{
+		Id: uint32(newId),
+	}, nil
+}
+
+func (s *PayeeHandler) UpdatePayee(ctx context.Context, req *dto.UpdatePayeeRequest) (*dto.UpdatePayeeResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "a payee needs a name")
+	}
+
+	err := s.payeeService.UpdatePayee(
+		ctx, user.ID, model.PayeeID(req.Id), name, optionalCategoryFromDto(req.DefaultCategory), req.Aliases,
+	)
+	if err != nil {
+		return nil, payeeError(err)
+	}
+
+	return &dto.UpdatePayeeResponse{}, nil
+}
+
+func (s *PayeeHandler) DeletePayee(ctx context.Context, req *dto.DeletePayeeRequest) (*dto.DeletePayeeResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if err := s.payeeService.DeletePayee(ctx, user.ID, model.PayeeID(req.Id)); err != nil {
+		return nil, payeeError(err)
+	}
+
+	return &dto.DeletePayeeResponse{}, nil
+}
+
+func (s *PayeeHandler) ResolvePayee(ctx context.Context, req *dto.ResolvePayeeRequest) (*dto.ResolvePayeeResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	payee, err := s.payeeService.ResolvePayee(ctx, user.ID, req.Merchant)
+	if err != nil {
+		return nil, err
+	}
+
+	var payeeDto *dto.Payee
+	if value, isSome := payee.Value(); isSome {
+		payeeDto = payeeToDto(value)
+	}
+
+	return &dto.ResolvePayeeResponse{
+		Payee: payeeDto,
+	}, nil
+}
+
+func payeeToDto(payee model.Payee) *dto.Payee {
+	var defaultCategory *uint32
+	if value, isSome := payee.DefaultCategory.Value(); isSome {
+		id := uint32(value)
+		defaultCategory = &id
+	}
+
+	return &dto.Payee{
+		Id:              uint32(payee.ID),
+		Name:            payee.Name,
+		DefaultCategory: defaultCategory,
+		Aliases:         payee.Aliases,
+	}
+}
+
+func optionalCategoryFromDto(category *uint32) model.Optional[model.CategoryID] {
+	if category == nil {
+		return model.None[model.CategoryID]()
+	}
+
+	return model.Some(model.CategoryID(*category))
+}
+
+func payeeError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrPayeeNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrPayeeNameTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, repository.ErrCategoryNotFound):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
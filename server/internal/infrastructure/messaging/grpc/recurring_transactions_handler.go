@@ -0,0 +1,320 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"chagnon.dev/budget-server/internal/infrastructure/recurring"
+)
+
+type recurringTransactionRepository interface {
+	GetAllRecurringTransactions(ctx context.Context, userId uuid.UUID) ([]model.RecurringTransaction, error)
+	CreateRecurringTransaction(
+		ctx context.Context,
+		userId uuid.UUID,
+		amount, receiverAmount int,
+		currencyId, receiverCurrencyId int,
+		senderAccountId, receiverAccountId model.Optional[int],
+		categoryId model.Optional[int],
+		note string,
+		schedule string,
+		startsOn time.Time,
+		endsOn model.Optional[time.Time],
+	) (model.RecurringTransactionID, error)
+	UpdateRecurringTransaction(
+		ctx context.Context,
+		userId uuid.UUID,
+		id model.RecurringTransactionID,
+		fields repository.UpdateRecurringTransactionFields,
+	) error
+	SetRecurringTransactionPaused(ctx context.Context, userId uuid.UUID, id model.RecurringTransactionID, paused bool) error
+	DeleteRecurringTransaction(ctx context.Context, userId uuid.UUID, id model.RecurringTransactionID) error
+}
+
+func (s *TransactionHandler) GetAllRecurringTransactions(
+	ctx context.Context,
+	_ *dto.GetAllRecurringTransactionsRequest,
+) (*dto.GetAllRecurringTransactionsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	recurringTransactions, err := s.transactionService.GetAllRecurringTransactions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	recurringTransactionsDto := make([]*dto.RecurringTransaction, len(recurringTransactions))
+	for i, recurringTransaction := range recurringTransactions {
+		recurringTransactionsDto[i] = recurringTransactionToDto(recurringTransaction)
+	}
+
+	return &dto.GetAllRecurringTransactionsResponse{
+		RecurringTransactions: recurringTransactionsDto,
+	}, nil
+}
+
+func (s *TransactionHandler) CreateRecurringTransaction(
+	ctx context.Context,
+	req *dto.CreateRecurringTransactionRequest,
+) (*dto.CreateRecurringTransactionResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := s.transactionService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	startsOn, err := parseDate(req.StartsOn, location)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "parsing starts on date: %s", err)
+	}
+
+	endsOn := model.None[time.Time]()
+	if req.EndsOn != nil {
+		date, err := parseDate(*req.EndsOn, location)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "parsing ends on date: %s", err)
+		}
+		endsOn = model.Some(date)
+	}
+
+	if _, err := recurring.ParseSchedule(req.Schedule, startsOn); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid schedule: %s", err)
+	}
+
+	sender := model.None[int]()
+	if req.Sender != nil {
+		sender = model.Some(int(*req.Sender))
+	}
+
+	receiver := model.None[int]()
+	if req.Receiver != nil {
+		receiver = model.Some(int(*req.Receiver))
+	}
+
+	category := model.None[int]()
+	if req.Category != nil {
+		category = model.Some(int(*req.Category))
+	}
+
+	newId, err := s.transactionService.CreateRecurringTransaction(
+		ctx,
+		user.ID,
+		int(req.Amount),
+		int(req.ReceiverAmount),
+		int(req.Currency),
+		int(req.ReceiverCurrency),
+		sender,
+		receiver,
+		category,
+		req.Note,
+		req.Schedule,
+		startsOn,
+		endsOn,
+	)
+	if err != nil {
+		return nil, transactionWriteError(err)
+	}
+
+	return &dto.CreateRecurringTransactionResponse{
+		Id: uint32(newId),
+	}, nil
+}
+
+func (s *TransactionHandler) UpdateRecurringTransaction(
+	ctx context.Context,
+	req *dto.UpdateRecurringTransactionRequest,
+) (*dto.UpdateRecurringTransactionResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if req.Fields == nil {
+		return &dto.UpdateRecurringTransactionResponse{}, nil
+	}
+
+	fields := repository.UpdateRecurringTransactionFields{}
+
+	if req.Fields.Amount != nil {
+		fields.Amount = model.Some(int(*req.Fields.Amount))
+	}
+
+	if req.Fields.ReceiverAmount != nil {
+		fields.ReceiverAmount = model.Some(int(*req.Fields.ReceiverAmount))
+	}
+
+	if req.Fields.Currency != nil {
+		fields.CurrencyId = model.Some(int(*req.Fields.Currency))
+	}
+
+	if req.Fields.ReceiverCurrency != nil {
+		fields.ReceiverCurrencyId = model.Some(int(*req.Fields.ReceiverCurrency))
+	}
+
+	if req.Fields.UpdateSender {
+		newValue := model.None[int]()
+		if req.Fields.Sender != nil {
+			newValue = model.Some(int(*req.Fields.Sender))
+		}
+		fields.SenderAccountId = model.Some(newValue)
+	}
+
+	if req.Fields.UpdateReceiver {
+		newValue := model.None[int]()
+		if req.Fields.Receiver != nil {
+			newValue = model.Some(int(*req.Fields.Receiver))
+		}
+		fields.ReceiverAccountId = model.Some(newValue)
+	}
+
+	if req.Fields.UpdateCategory {
+		newValue := model.None[int]()
+		if req.Fields.Category != nil {
+			newValue = model.Some(int(*req.Fields.Category))
+		}
+		fields.CategoryId = model.Some(newValue)
+	}
+
+	if req.Fields.Note != nil {
+		fields.Note = model.Some(*req.Fields.Note)
+	}
+
+	if req.Fields.Schedule != nil {
+		if _, err := recurring.ParseSchedule(*req.Fields.Schedule, time.Now()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid schedule: %s", err)
+		}
+		fields.Schedule = model.Some(*req.Fields.Schedule)
+	}
+
+	if req.Fields.UpdateEndsOn {
+		newValue := model.None[time.Time]()
+		if req.Fields.EndsOn != nil {
+			location, err := s.transactionService.UserLocation(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			date, err := parseDate(*req.Fields.EndsOn, location)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "parsing ends on date: %s", err)
+			}
+			newValue = model.Some(date)
+		}
+		fields.EndsOn = model.Some(newValue)
+	}
+
+	err := s.transactionService.UpdateRecurringTransaction(ctx, user.ID, model.RecurringTransactionID(req.Id), fields)
+	if errors.Is(err, repository.ErrRecurringTransactionNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, transactionWriteError(err)
+	}
+
+	return &dto.UpdateRecurringTransactionResponse{}, nil
+}
+
+func (s *TransactionHandler) SetRecurringTransactionPaused(
+	ctx context.Context,
+	req *dto.SetRecurringTransactionPausedRequest,
+) (*dto.SetRecurringTransactionPausedResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := s.transactionService.SetRecurringTransactionPaused(ctx, user.ID, model.RecurringTransactionID(req.Id), req.Paused)
+	if errors.Is(err, repository.ErrRecurringTransactionNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.SetRecurringTransactionPausedResponse{}, nil
+}
+
+func (s *TransactionHandler) DeleteRecurringTransaction(
+	ctx context.Context,
+	req *dto.DeleteRecurringTransactionRequest,
+) (*dto.DeleteRecurringTransactionResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := s.transactionService.DeleteRecurringTransaction(ctx, user.ID, model.RecurringTransactionID(req.Id))
+	if errors.Is(err, repository.ErrRecurringTransactionNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.DeleteRecurringTransactionResponse{}, nil
+}
+
+func recurringTransactionToDto(recurringTransaction model.RecurringTransaction) *dto.RecurringTransaction {
+	var sender *uint32
+	if value, isSome := recurringTransaction.Sender.Value(); isSome {
+		id := uint32(value)
+		sender = &id
+	}
+
+	var receiver *uint32
+	if value, isSome := recurringTransaction.Receiver.Value(); isSome {
+		id := uint32(value)
+		receiver = &id
+	}
+
+	var category *uint32
+	if value, isSome := recurringTransaction.Category.Value(); isSome {
+		id := uint32(value)
+		category = &id
+	}
+
+	var endsOn *string
+	if value, isSome := recurringTransaction.EndsOn.Value(); isSome {
+		date := value.Format(layout)
+		endsOn = &date
+	}
+
+	var materializedThrough *string
+	if value, isSome := recurringTransaction.MaterializedThrough.Value(); isSome {
+		date := value.Format(layout)
+		materializedThrough = &date
+	}
+
+	return &dto.RecurringTransaction{
+		Id:                  uint32(recurringTransaction.ID),
+		Amount:              uint32(recurringTransaction.Amount),
+		Currency:            uint32(recurringTransaction.Currency),
+		Sender:              sender,
+		Receiver:            receiver,
+		Category:            category,
+		Note:                recurringTransaction.Note,
+		ReceiverCurrency:    uint32(recurringTransaction.ReceiverCurrency),
+		ReceiverAmount:      uint32(recurringTransaction.ReceiverAmount),
+		Schedule:            recurringTransaction.Schedule,
+		StartsOn:            recurringTransaction.StartsOn.Format(layout),
+		EndsOn:              endsOn,
+		Paused:              recurringTransaction.Paused,
+		MaterializedThrough: materializedThrough,
+	}
+}
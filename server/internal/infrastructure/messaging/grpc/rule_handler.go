@@ -0,0 +1,308 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ruleRepository interface {
+	GetAllRules(ctx context.Context, userId uuid.UUID) ([]model.Rule, error)
+	CreateRule(ctx context.Context, userId uuid.UUID, rule model.Rule) (model.RuleID, error)
+	UpdateRule(ctx context.Context, userId uuid.UUID, rule model.Rule) error
+	DeleteRule(ctx context.Context, userId uuid.UUID, id model.RuleID) error
+	ReorderRules(ctx context.Context, userId uuid.UUID, ids []model.RuleID) error
+	PreviewRules(ctx context.Context, userId uuid.UUID, ids []model.RuleID, overwrite bool) ([]model.RuleOutcome, error)
+	ApplyRules(ctx context.Context, userId uuid.UUID, ids []model.RuleID, overwrite bool) ([]model.RuleOutcome, error)
+}
+
+type RuleHandler struct {
+	dto.UnimplementedRuleServiceServer
+
+	ruleService ruleRepository
+}
+
+func (s *RuleHandler) GetAllRules(ctx context.Context, _ *dto.GetAllRulesRequest) (*dto.GetAllRulesResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	rules, err := s.ruleService.GetAllRules(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesDto := make([]*dto.Rule, len(rules))
+	for i, rule := range rules {
+		rulesDto[i] = ruleToDto(rule)
+	}
+
+	return &dto.GetAllRulesResponse{
+		Rules: rulesDto,
+	}, nil
+}
+
+func (s *RuleHandler) CreateRule(ctx context.Context, req *dto.CreateRuleRequest) (*dto.CreateRuleResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	rule, err := ruleFromDto(req.Rule)
+	if err != nil {
+		return nil, err
+	}
+
+	newId, err := s.ruleService.CreateRule(ctx, user.ID, rule)
+	if err != nil {
+		return nil, ruleError(err)
+	}
+
+	return &dto.CreateRuleResponse{
+		Id: uint32(newId),
+	}, nil
+}
+
+func (s *RuleHandler) UpdateRule(ctx context.Context, req *dto.UpdateRuleRequest) (*dto.UpdateRuleResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	rule, err := ruleFromDto(req.Rule)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.ruleService.UpdateRule(ctx, user.ID, rule); err != nil {
+		return nil, ruleError(err)
+	}
+
+	return &dto.UpdateRuleResponse{}, nil
+}
+
+func (s *RuleHandler) DeleteRule(ctx context.Context, req *dto.DeleteRuleRequest) (*dto.DeleteRuleResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if err := s.ruleService.DeleteRule(ctx, user.ID, model.RuleID(req.Id)); err != nil {
+		return nil, ruleError(err)
+	}
+
+	return &dto.DeleteRuleResponse{}, nil
+}
+
+func (s *RuleHandler) ReorderRules(ctx context.Context, req *dto.ReorderRulesRequest) (*dto.ReorderRulesResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if err := s.ruleService.ReorderRules(ctx, user.ID, ruleIdsFromDto(req.Ids)); err != nil {
+		return nil, ruleError(err)
+	}
+
+	return &dto.ReorderRulesResponse{}, nil
+}
+
+func (s *RuleHandler) PreviewRules(ctx context.Context, req *dto.PreviewRulesRequest) (*dto.PreviewRulesResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	outcomes, err := s.ruleService.PreviewRules(ctx, user.ID, ruleIdsFromDto(req.RuleIds), req.Overwrite)
+	if err != nil {
+		return nil, ruleError(err)
+	}
+
+	return &dto.PreviewRulesResponse{
+		Outcomes: ruleOutcomesToDto(outcomes),
+	}, nil
+}
+
+func (s *RuleHandler) ApplyRules(ctx context.Context, req *dto.ApplyRulesRequest) (*dto.ApplyRulesResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	outcomes, err := s.ruleService.ApplyRules(ctx, user.ID, ruleIdsFromDto(req.RuleIds), req.Overwrite)
+	if err != nil {
+		return nil, ruleError(err)
+	}
+
+	return &dto.ApplyRulesResponse{
+		Outcomes: ruleOutcomesToDto(outcomes),
+	}, nil
+}
+
+func ruleFromDto(ruleDto *dto.Rule) (model.Rule, error) {
+	if ruleDto == nil {
+		return model.Rule{}, status.Error(codes.InvalidArgument, "missing rule")
+	}
+
+	name := strings.TrimSpace(ruleDto.Name)
+	if name == "" {
+		return model.Rule{}, status.Error(codes.InvalidArgument, "a rule needs a name")
+	}
+
+	conditions := ruleDto.Conditions
+	if conditions == nil {
+		conditions = &dto.RuleConditions{}
+	}
+
+	actions := ruleDto.Actions
+	if actions == nil {
+		actions = &dto.RuleActions{}
+	}
+
+	tags := make([]model.TagID, len(actions.Tags))
+	for i, tagId := range actions.Tags {
+		tags[i] = model.TagID(tagId)
+	}
+
+	return model.Rule{
+		ID:             model.RuleID(ruleDto.Id),
+		Name:           name,
+		Priority:       int(ruleDto.Priority),
+		Enabled:        ruleDto.Enabled,
+		StopProcessing: ruleDto.StopProcessing,
+		Conditions: model.RuleConditions{
+			NotePattern: optionalFromDto(conditions.NotePattern, func(v string) string { return v }),
+			MinAmount:   optionalFromDto(conditions.MinAmount, func(v int32) int { return int(v) }),
+			MaxAmount:   optionalFromDto(conditions.MaxAmount, func(v int32) int { return int(v) }),
+			Account:     optionalFromDto(conditions.Account, func(v uint32) model.AccountID { return model.AccountID(v) }),
+			Currency:    optionalFromDto(conditions.Currency, func(v uint32) model.CurrencyID { return model.CurrencyID(v) }),
+			Payee:       optionalFromDto(conditions.Payee, func(v uint32) model.PayeeID { return model.PayeeID(v) }),
+		},
+		Actions: model.RuleActions{
+			Category:    optionalFromDto(actions.Category, func(v uint32) model.CategoryID { return model.CategoryID(v) }),
+			RewriteNote: optionalFromDto(actions.RewriteNote, func(v string) string { return v }),
+			Tags:        tags,
+			TransactionGroup: optionalFromDto(actions.TransactionGroup, func(v uint32) model.TransactionGroupID {
+				return model.TransactionGroupID(v)
+			}),
+		},
+	}, nil
+}
+
+func optionalFromDto[T, U any](value *T, convert func(T) U) model.Optional[U] {
+	if value == nil {
+		return model.None[U]()
+	}
+
+	return model.Some(convert(*value))
+}
+
+func optionalToDto[T, U any](value model.Optional[T], convert func(T) U) *U {
+	v, isSome := value.Value()
+	if !isSome {
+		return nil
+	}
+
+	converted := convert(v)
+	return &converted
+}
+
+func ruleToDto(rule model.Rule) *dto.Rule {
+	tags := make([]uint32, len(rule.Actions.Tags))
+	for i, tagId := range rule.Actions.Tags {
+		tags[i] = uint32(tagId)
+	}
+
+	conditions := rule.Conditions
+	actions := rule.Actions
+
+	return &dto.Rule{
+		Id:             uint32(rule.ID),
+		Name:           rule.Name,
+		Priority:       int32(rule.Priority),
+		Enabled:        rule.Enabled,
+		StopProcessing: rule.StopProcessing,
+		Conditions: &dto.RuleConditions{
+			NotePattern: optionalToDto(conditions.NotePattern, func(v string) string { return v }),
+			MinAmount:   optionalToDto(conditions.MinAmount, func(v int) int32 { return int32(v) }),
+			MaxAmount:   optionalToDto(conditions.MaxAmount, func(v int) int32 { return int32(v) }),
+			Account:     optionalToDto(conditions.Account, func(v model.AccountID) uint32 { return uint32(v) }),
+			Currency:    optionalToDto(conditions.Currency, func(v model.CurrencyID) uint32 { return uint32(v) }),
+			Payee:       optionalToDto(conditions.Payee, func(v model.PayeeID) uint32 { return uint32(v) }),
+		},
+		Actions: &dto.RuleActions{
+			Category:    optionalToDto(actions.Category, func(v model.CategoryID) uint32 { return uint32(v) }),
+			RewriteNote: optionalToDto(actions.RewriteNote, func(v string) string { return v }),
+			Tags:        tags,
+			TransactionGroup: optionalToDto(actions.TransactionGroup, func(v model.TransactionGroupID) uint32 {
+				return uint32(v)
+			}),
+		},
+	}
+}
+
+func ruleIdsFromDto(ids []uint32) []model.RuleID {
+	ruleIds := make([]model.RuleID, len(ids))
+	for i, id := range ids {
+		ruleIds[i] = model.RuleID(id)
+	}
+
+	return ruleIds
+}
+
+func ruleOutcomesToDto(outcomes []model.RuleOutcome) []*dto.RuleOutcome {
+	outcomesDto := make([]*dto.RuleOutcome, len(outcomes))
+	for i, outcome := range outcomes {
+		ruleIds := make([]uint32, len(outcome.Rules))
+		for j, ruleId := range outcome.Rules {
+			ruleIds[j] = uint32(ruleId)
+		}
+
+		changes := make([]*dto.RuleFieldChange, len(outcome.Changes))
+		for j, change := range outcome.Changes {
+			changes[j] = &dto.RuleFieldChange{
+				Field:  change.Field,
+				Before: change.Before,
+				After:  change.After,
+			}
+		}
+
+		outcomesDto[i] = &dto.RuleOutcome{
+			TransactionId: uint32(outcome.Transaction),
+			RuleIds:       ruleIds,
+			Changes:       changes,
+		}
+	}
+
+	return outcomesDto
+}
+
+func ruleError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrRuleNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrRuleListedTwice),
+		errors.Is(err, repository.ErrRuleWithoutCondition),
+		errors.Is(err, repository.ErrRuleWithoutAction),
+		errors.Is(err, repository.ErrInvalidNotePattern),
+		errors.Is(err, repository.ErrAccountNotFound),
+		errors.Is(err, repository.ErrCurrencyNotFound),
+		errors.Is(err, repository.ErrPayeeNotFound),
+		errors.Is(err, repository.ErrCategoryNotFound),
+		errors.Is(err, repository.ErrTransactionGroupNotFound),
+		errors.Is(err, repository.ErrTagNotFound):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
@@ -1,6 +1,8 @@
 package grpc
 
 import (
+	"time"
+
 	"google.golang.org/grpc"
 
 	"chagnon.dev/budget-server/internal/domain/service"
@@ -15,16 +17,35 @@ type Services struct {
 	Transaction      transactionRepository
 	ExchangeRate     exchangeRateRepository
 	TransactionGroup transactionGroupRepository
+	Tag              tagRepository
+	Payee            payeeRepository
+	Rule             ruleRepository
+	User             userRepository
+	Budget           budgetService
+	Changes          changeFeed
+	Idempotency      idempotencyStore
 }
 
-func NewServerWithHandlers(services Services) *grpc.Server {
-	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(sanitizeErrorInterceptor))
+func NewServerWithHandlers(services Services, idempotencyWindow time.Duration) *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			sanitizeErrorInterceptor,
+			newIdempotencyInterceptor(services.Idempotency, idempotencyWindow),
+		),
+		grpc.StreamInterceptor(sanitizeErrorStreamInterceptor),
+	)
 	dto.RegisterAccountServiceServer(grpcServer, &AccountHandler{accountService: services.Account})
 	dto.RegisterCategoryServiceServer(grpcServer, &CategoryHandler{categoryService: services.Category})
 	dto.RegisterCurrencyServiceServer(grpcServer, &CurrencyHandler{currencyService: services.Currency})
 	dto.RegisterTransactionServiceServer(grpcServer, &TransactionHandler{transactionService: services.Transaction})
 	dto.RegisterExchangeRateServiceServer(grpcServer, &ExchangeRateHandler{exchangeRateService: services.ExchangeRate, javascriptRunner: autoupdate.RunJavascript})
 	dto.RegisterTransactionGroupServiceServer(grpcServer, &TransactionGroupHandler{transactionGroupService: services.TransactionGroup})
+	dto.RegisterTagServiceServer(grpcServer, &TagHandler{tagService: services.Tag})
+	dto.RegisterPayeeServiceServer(grpcServer, &PayeeHandler{payeeService: services.Payee})
+	dto.RegisterRuleServiceServer(grpcServer, &RuleHandler{ruleService: services.Rule})
+	dto.RegisterUserServiceServer(grpcServer, &UserHandler{userService: services.User})
+	dto.RegisterBudgetServiceServer(grpcServer, &BudgetHandler{budgetService: services.Budget})
+	dto.RegisterChangeServiceServer(grpcServer, &ChangeHandler{changeFeed: services.Changes})
 
 	return grpcServer
 }
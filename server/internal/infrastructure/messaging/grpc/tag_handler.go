@@ -0,0 +1,135 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type tagRepository interface {
+	GetAllTags(ctx context.Context, userId uuid.UUID) ([]model.Tag, error)
+	CreateTag(ctx context.Context, userId uuid.UUID, name string) (model.TagID, error)
+	RenameTag(ctx context.Context, userId uuid.UUID, id model.TagID, name string) error
+	MergeTags(ctx context.Context, userId uuid.UUID, sourceIds []model.TagID, targetId model.TagID) error
+	DeleteTag(ctx context.Context, userId uuid.UUID, id model.TagID) error
+}
+
+type TagHandler struct {
+	dto.UnimplementedTagServiceServer
+
+	tagService tagRepository
+}
+
+func (s *TagHandler) GetAllTags(ctx context.Context, _ *dto.GetAllTagsRequest) (*dto.GetAllTagsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	tags, err := s.tagService.GetAllTags(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tagsDto := make([]*dto.Tag, len(tags))
+	for i, tag := range tags {
+		tagsDto[i] = &dto.Tag{
+			Id:   uint32(tag.ID),
+			Name: tag.Name,
+		}
+	}
+
+	return &dto.GetAllTagsResponse{
+		Tags: tagsDto,
+	}, nil
+}
+
+func (s *TagHandler) CreateTag(ctx context.Context, req *dto.CreateTagRequest) (*dto.CreateTagResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "a tag needs a name")
+	}
+
+	newId, err := s.tagService.CreateTag(ctx, user.ID, name)
+	if err != nil {
+		return nil, tagError(err)
+	}
+
+	return &dto.CreateTagResponse{
+		Id: uint32(newId),
+	}, nil
+}
+
+func (s *TagHandler) RenameTag(ctx context.Context, req *dto.RenameTagRequest) (*dto.RenameTagResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "a tag needs a name")
+	}
+
+	if err := s.tagService.RenameTag(ctx, user.ID, model.TagID(req.Id), name); err != nil {
+		return nil, tagError(err)
+	}
+
+	return &dto.RenameTagResponse{}, nil
+}
+
+func (s *TagHandler) MergeTags(ctx context.Context, req *dto.MergeTagsRequest) (*dto.MergeTagsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	sourceIds := make([]model.TagID, len(req.SourceIds))
+	for i, id := range req.SourceIds {
+		sourceIds[i] = model.TagID(id)
+	}
+
+	if err := s.tagService.MergeTags(ctx, user.ID, sourceIds, model.TagID(req.TargetId)); err != nil {
+		return nil, tagError(err)
+	}
+
+	return &dto.MergeTagsResponse{}, nil
+}
+
+func (s *TagHandler) DeleteTag(ctx context.Context, req *dto.DeleteTagRequest) (*dto.DeleteTagResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	if err := s.tagService.DeleteTag(ctx, user.ID, model.TagID(req.Id)); err != nil {
+		return nil, tagError(err)
+	}
+
+	return &dto.DeleteTagResponse{}, nil
+}
+
+func tagError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrTagNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrTagNameTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return err
+	}
+}
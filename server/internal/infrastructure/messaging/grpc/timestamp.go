@@ -0,0 +1,57 @@
+package grpc
+
+import (
+	"time"
+)
+
+// parseTimestamp reads a point in time sent by a client. RFC 3339 carries its
+// own offset; the older layout does not, and is read as a wall time in the
+// user's timezone.
+func parseTimestamp(value string, location *time.Location) (time.Time, error) {
+	if timestamp, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return timestamp, nil
+	}
+
+	return time.ParseInLocation(layout, value, location)
+}
+
+// parseDate reads a calendar date sent by a client in either format of
+// parseTimestamp. The date of a timestamp is the one it falls on in the
+// user's timezone.
+func parseDate(value string, location *time.Location) (time.Time, error) {
+	timestamp, err := parseTimestamp(value, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := timestamp.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// parseRangeBound reads a bound of a range of timestamps. A bound in the
+// older layout stands for a whole day of the user's timezone: the start of
+// the day for a lower bound, its last instant for an upper one.
+func parseRangeBound(value string, location *time.Location, upper bool) (time.Time, error) {
+	if timestamp, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return timestamp, nil
+	}
+
+	date, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, location)
+	if !upper {
+		return start, nil
+	}
+
+	// The database keeps microseconds.
+	return start.AddDate(0, 0, 1).Add(-time.Microsecond), nil
+}
+
+// formatTimestamp writes a point in time in the older layout, as a wall time
+// in the user's timezone.
+func formatTimestamp(timestamp time.Time, location *time.Location) string {
+	return timestamp.In(location).Format(layout)
+}
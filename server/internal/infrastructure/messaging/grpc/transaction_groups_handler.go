@@ -2,12 +2,15 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"chagnon.dev/budget-server/internal/domain/model"
 	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type transactionGroupRepository interface {
@@ -72,55 +75,62 @@ func (h *TransactionGroupHandler) GetAllTransactionGroups(ctx context.Context, r
 
 	transactionGroupsDto := make([]*dto.TransactionGroup, len(transactionGroups))
 	for i, transactionGroup := range transactionGroups {
-
-		splitTypeDto, err := SplitTypeToDto(transactionGroup.SplitType)
+		transactionGroupsDto[i], err = transactionGroupToDto(transactionGroup)
 		if err != nil {
-			return nil, fmt.Errorf("converting split type to dto")
+			return nil, err
 		}
+	}
 
-		membersDto := make([]*dto.TransactionGroupMember, len(transactionGroup.Members))
-		for j, member := range transactionGroup.Members {
-			var splitValue *uint32
+	return &dto.GetAllTransactionGroupsResponse{
+		TransactionGroups: transactionGroupsDto,
+	}, nil
+}
 
-			if tentativeValue, isSome := member.SplitValue.Value(); isSome {
-				value := uint32(tentativeValue)
-				splitValue = &value
-			}
+func transactionGroupToDto(transactionGroup model.TransactionGroup) (*dto.TransactionGroup, error) {
+	splitTypeDto, err := SplitTypeToDto(transactionGroup.SplitType)
+	if err != nil {
+		return nil, fmt.Errorf("converting split type to dto")
+	}
 
-			membersDto[j] = &dto.TransactionGroupMember{
-				Email:      string(member.Email),
-				Name:       member.Name,
-				SplitValue: splitValue,
-				Joined:     member.Joined,
-			}
-		}
+	membersDto := make([]*dto.TransactionGroupMember, len(transactionGroup.Members))
+	for j, member := range transactionGroup.Members {
+		var splitValue *uint32
 
-		var currency *uint32
-		if currencyValue, isSome := transactionGroup.Currency.Value(); isSome {
-			castedValue := uint32(currencyValue)
-			currency = &castedValue
+		if tentativeValue, isSome := member.SplitValue.Value(); isSome {
+			value := uint32(tentativeValue)
+			splitValue = &value
 		}
 
-		var category *uint32
-		if categoryValue, isSome := transactionGroup.Category.Value(); isSome {
-			castedValue := uint32(categoryValue)
-			category = &castedValue
+		membersDto[j] = &dto.TransactionGroupMember{
+			Email:      string(member.Email),
+			Name:       member.Name,
+			SplitValue: splitValue,
+			Joined:     member.Joined,
 		}
+	}
 
-		transactionGroupsDto[i] = &dto.TransactionGroup{
-			Id:              uint32(transactionGroup.ID),
-			Name:            transactionGroup.Name,
-			InitialCurrency: transactionGroup.OriginalCurrency,
-			SplitType:       splitTypeDto,
-			Members:         membersDto,
-			Currency:        currency,
-			Category:        category,
-			Hidden:          transactionGroup.Hidden,
-		}
+	var currency *uint32
+	if currencyValue, isSome := transactionGroup.Currency.Value(); isSome {
+		castedValue := uint32(currencyValue)
+		currency = &castedValue
 	}
 
-	return &dto.GetAllTransactionGroupsResponse{
-		TransactionGroups: transactionGroupsDto,
+	var category *uint32
+	if categoryValue, isSome := transactionGroup.Category.Value(); isSome {
+		castedValue := uint32(categoryValue)
+		category = &castedValue
+	}
+
+	return &dto.TransactionGroup{
+		Id:              uint32(transactionGroup.ID),
+		Name:            transactionGroup.Name,
+		InitialCurrency: transactionGroup.OriginalCurrency,
+		SplitType:       splitTypeDto,
+		Members:         membersDto,
+		Currency:        currency,
+		Category:        category,
+		Hidden:          transactionGroup.Hidden,
+		Version:         uint32(transactionGroup.Version),
 	}, nil
 }
 
@@ -206,10 +216,41 @@ func (h *TransactionGroupHandler) UpdateTransactionGroup(ctx context.Context, re
 		CategoryId: categoryId,
 		Members:    members,
 		Hidden:     hidden,
+		ExpectedVersion: optionalFromDto(request.ExpectedVersion, func(v uint32) int {
+			return int(v)
+		}),
 	})
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return nil, h.staleTransactionGroupError(ctx, user.Email, model.TransactionGroupID(request.Id))
+	}
+	if errors.Is(err, repository.ErrTransactionGroupNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
 	if err != nil {
 		return nil, fmt.Errorf("updating transaction group: %w", err)
 	}
 
 	return &dto.UpdateTransactionGroupResponse{}, err
 }
+
+// staleTransactionGroupError rejects an update made against an older version
+// of the transaction group, with the group as it is now.
+func (h *TransactionGroupHandler) staleTransactionGroupError(ctx context.Context, email string, id model.TransactionGroupID) error {
+	transactionGroups, err := h.transactionGroupService.GetUserTransactionGroups(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	for _, transactionGroup := range transactionGroups {
+		if transactionGroup.ID == id {
+			transactionGroupDto, err := transactionGroupToDto(transactionGroup)
+			if err != nil {
+				return err
+			}
+
+			return staleWriteError(transactionGroupDto)
+		}
+	}
+
+	return status.Error(codes.NotFound, repository.ErrTransactionGroupNotFound.Error())
+}
@@ -2,20 +2,53 @@ package grpc
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/domain/service"
 	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
 	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"chagnon.dev/budget-server/internal/logging"
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const layout = "2006-01-02 15:04:05"
 
+const (
+	defaultListTransactionsPageSize = 100
+	maxListTransactionsPageSize     = 1000
+
+	maxBatchMutations = 500
+
+	defaultSearchTransactionsPageSize = 50
+	maxSearchTransactionsPageSize     = 200
+)
+
 type transactionRepository interface {
+	recurringTransactionRepository
+
 	GetAllTransactions(ctx context.Context, userId uuid.UUID) ([]model.Transaction, error)
+	ListTransactions(
+		ctx context.Context,
+		userId uuid.UUID,
+		filter repository.TransactionFilter,
+		after model.Optional[model.TransactionCursor],
+		pageSize int,
+	) ([]model.Transaction, model.Optional[model.TransactionCursor], error)
+	SearchTransactions(
+		ctx context.Context,
+		userId uuid.UUID,
+		query string,
+		limit, offset int,
+	) ([]model.TransactionSearchResult, error)
 	CreateTransaction(
 		ctx context.Context,
 		userId uuid.UUID,
@@ -29,6 +62,12 @@ type transactionRepository interface {
 		note string,
 		financialIncomeData model.Optional[model.FinancialIncomeData],
 		transactionGroupData model.Optional[model.GroupedTransactionData],
+		lineItems []model.TransactionLineItem,
+		tags []model.TagID,
+		payeeId model.Optional[model.PayeeID],
+		merchant string,
+		status model.TransactionStatus,
+		allowDuplicates bool,
 	) (model.TransactionID, error)
 	UpdateTransaction(
 		ctx context.Context,
@@ -41,6 +80,26 @@ type transactionRepository interface {
 		userId uuid.UUID,
 		id model.TransactionID,
 	) error
+	GetTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) (model.Transaction, error)
+	ListDeletedTransactions(ctx context.Context, userId uuid.UUID) ([]model.DeletedTransaction, error)
+	RestoreTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error
+	PurgeTransaction(ctx context.Context, userId uuid.UUID, id model.TransactionID) error
+	GetTransactionHistory(ctx context.Context, userId uuid.UUID, id model.TransactionID) ([]model.TransactionHistoryEntry, error)
+	FindDuplicateTransactions(ctx context.Context, userId uuid.UUID) ([]model.DuplicatePair, error)
+	SetTransactionAttributionState(
+		ctx context.Context,
+		userId uuid.UUID,
+		id model.TransactionID,
+		state model.AttributionState,
+		disputeReason string,
+	) error
+	BatchMutateTransactions(
+		ctx context.Context,
+		userId uuid.UUID,
+		userEmail string,
+		mutations []repository.TransactionMutation,
+	) ([]model.TransactionID, error)
+	UserLocation(ctx context.Context, userId uuid.UUID) (*time.Location, error)
 }
 
 func SplitTypeOverrideFromDto(splitType dto.SplitTypeOverride) (model.SplitTypeOverride, error) {
@@ -88,6 +147,171 @@ func (s *TransactionHandler) CreateTransaction(
 		return nil, fmt.Errorf("getting user from context")
 	}
 
+	location, err := s.transactionService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := newTransactionFieldsFromDto(req, location)
+	if err != nil {
+		return nil, err
+	}
+
+	newId, err := s.transactionService.CreateTransaction(
+		ctx,
+		user.ID,
+		user.Email,
+		fields.OwnerEmail,
+		fields.Amount,
+		fields.ReceiverAmount,
+		fields.CurrencyId,
+		fields.ReceiverCurrencyId,
+		fields.SenderAccountId,
+		fields.ReceiverAccountId,
+		fields.CategoryId,
+		fields.Date,
+		fields.Note,
+		fields.FinancialIncomeData,
+		fields.TransactionGroupData,
+		fields.LineItems,
+		fields.Tags,
+		fields.PayeeId,
+		fields.Merchant,
+		fields.Status,
+		fields.AllowDuplicates,
+	)
+	var duplicateErr *repository.DuplicateTransactionError
+	if errors.As(err, &duplicateErr) {
+		return &dto.CreateTransactionResponse{
+			DuplicateWarning: duplicateWarningToDto(duplicateErr, location),
+		}, nil
+	}
+	if err != nil {
+		return nil, transactionWriteError(err)
+	}
+
+	return &dto.CreateTransactionResponse{
+		Id: uint32(newId),
+	}, nil
+}
+
+func (s *TransactionHandler) UpdateTransaction(
+	ctx context.Context,
+	req *dto.UpdateTransactionRequest,
+) (*dto.UpdateTransactionResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := s.transactionService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := updateTransactionFieldsFromDto(req.Fields, location)
+	if err != nil {
+		return nil, err
+	}
+	fields.ExpectedVersion = expectedTransactionVersionFromDto(req.ExpectedVersion)
+
+	err = s.transactionService.UpdateTransaction(
+		ctx,
+		user.ID,
+		model.TransactionID(req.Id),
+		fields,
+	)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		current, err := s.currentTransaction(ctx, user.ID, model.TransactionID(req.Id), location)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, staleWriteError(current)
+	}
+	if err != nil {
+		return nil, transactionWriteError(err)
+	}
+
+	return &dto.UpdateTransactionResponse{}, nil
+}
+
+func expectedTransactionVersionFromDto(version *uint32) model.Optional[int] {
+	return optionalFromDto(version, func(v uint32) int { return int(v) })
+}
+
+// currentTransaction returns the transaction as it is now, for the client to
+// redo a change it made against an older version.
+func (s *TransactionHandler) currentTransaction(
+	ctx context.Context,
+	userId uuid.UUID,
+	id model.TransactionID,
+	location *time.Location,
+) (*dto.Transaction, error) {
+	transaction, err := s.transactionService.GetTransaction(ctx, userId, id)
+	if errors.Is(err, repository.ErrTransactionNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return transactionToDto(transaction, location)
+}
+
+// transactionWriteError surfaces the validation failures of a create or an
+// update to the client. Anything else is left to the interceptor to sanitize.
+func transactionWriteError(err error) error {
+	switch {
+	case errors.As(err, new(*service.ValidationError)),
+		errors.Is(err, repository.ErrLineItemsMismatch),
+		errors.Is(err, repository.ErrTagNotFound),
+		errors.Is(err, repository.ErrReconciledStatus),
+		errors.Is(err, repository.ErrForeignOwnerFields),
+		errors.Is(err, repository.ErrForeignCurrency),
+		errors.Is(err, repository.ErrPayeeNotFound):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, repository.ErrForeignOwner):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, repository.ErrTransactionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrTransactionReconciled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return err
+	}
+}
+
+func TransactionStatusFromDto(status dto.TransactionStatus) (model.TransactionStatus, error) {
+	switch status {
+	case dto.TransactionStatus_StatusPending:
+		return model.TransactionStatusPending, nil
+	case dto.TransactionStatus_StatusCleared:
+		return model.TransactionStatusCleared, nil
+	case dto.TransactionStatus_StatusReconciled:
+		return model.TransactionStatusReconciled, nil
+	default:
+		return model.TransactionStatusPending, fmt.Errorf("unknown TransactionStatus %s", status)
+	}
+}
+
+func TransactionStatusToDto(status model.TransactionStatus) (dto.TransactionStatus, error) {
+	switch status {
+	case model.TransactionStatusPending:
+		return dto.TransactionStatus_StatusPending, nil
+	case model.TransactionStatusCleared:
+		return dto.TransactionStatus_StatusCleared, nil
+	case model.TransactionStatusReconciled:
+		return dto.TransactionStatus_StatusReconciled, nil
+	default:
+		return dto.TransactionStatus_StatusPending, fmt.Errorf("unknown TransactionStatus %d", status)
+	}
+}
+
+func newTransactionFieldsFromDto(
+	req *dto.CreateTransactionRequest,
+	location *time.Location,
+) (repository.NewTransactionFields, error) {
 	sender := model.None[int]()
 	if req.Sender != nil {
 		sender = model.Some(int(*req.Sender))
@@ -103,6 +327,11 @@ func (s *TransactionHandler) CreateTransaction(
 		category = model.Some(int(*req.Category))
 	}
 
+	payee := model.None[model.PayeeID]()
+	if req.Payee != nil {
+		payee = model.Some(model.PayeeID(*req.Payee))
+	}
+
 	financialIncomeData := model.None[model.FinancialIncomeData]()
 	if req.FinancialIncomeData != nil {
 		financialIncomeData = model.Some(model.FinancialIncomeData{
@@ -116,7 +345,7 @@ func (s *TransactionHandler) CreateTransaction(
 		if req.TransactionGroupData.SplitOverride != nil {
 			splitTypeOverride, err := SplitTypeOverrideFromDto(req.TransactionGroupData.SplitOverride.SplitTypeOverride)
 			if err != nil {
-				return nil, err
+				return repository.NewTransactionFields{}, err
 			}
 
 			members := make([]model.MemberSplitValue, len(req.TransactionGroupData.SplitOverride.MemberSplitValues))
@@ -144,83 +373,86 @@ func (s *TransactionHandler) CreateTransaction(
 		})
 	}
 
-	date, err := time.Parse(layout, req.Date)
+	date, err := parseTimestamp(req.Date, location)
 	if err != nil {
-		return nil, err
+		return repository.NewTransactionFields{}, err
 	}
 
-	newId, err := s.transactionService.CreateTransaction(
-		ctx,
-		user.ID,
-		user.Email,
-		req.Owner,
-		int(req.Amount),
-		int(req.ReceiverAmount),
-		int(req.Currency),
-		int(req.ReceiverCurrency),
-		sender,
-		receiver,
-		category,
-		date,
-		req.Note,
-		financialIncomeData,
-		transactionGroupData,
-	)
+	lineItems := lineItemsFromDto(req.LineItems)
+	tags := tagIdsFromDto(req.Tags)
+
+	transactionStatus, err := TransactionStatusFromDto(req.Status)
 	if err != nil {
-		return nil, err
+		return repository.NewTransactionFields{}, err
 	}
 
-	return &dto.CreateTransactionResponse{
-		Id: uint32(newId),
+	return repository.NewTransactionFields{
+		OwnerEmail:           req.Owner,
+		Amount:               int(req.Amount),
+		ReceiverAmount:       int(req.ReceiverAmount),
+		CurrencyId:           int(req.Currency),
+		ReceiverCurrencyId:   int(req.ReceiverCurrency),
+		SenderAccountId:      sender,
+		ReceiverAccountId:    receiver,
+		CategoryId:           category,
+		Date:                 date,
+		Note:                 req.Note,
+		FinancialIncomeData:  financialIncomeData,
+		TransactionGroupData: transactionGroupData,
+		LineItems:            lineItems,
+		Tags:                 tags,
+		PayeeId:              payee,
+		Merchant:             strings.TrimSpace(req.Merchant),
+		Status:               transactionStatus,
+		AllowDuplicates:      req.Force,
 	}, nil
 }
 
-func (s *TransactionHandler) UpdateTransaction(
-	ctx context.Context,
-	req *dto.UpdateTransactionRequest,
-) (*dto.UpdateTransactionResponse, error) {
-	user, ok := shared.FromContext(ctx)
-	if !ok {
-		return nil, fmt.Errorf("getting user from context")
+func updateTransactionFieldsFromDto(
+	req *dto.UpdateTransactionFields,
+	location *time.Location,
+) (repository.UpdateTransactionFields, error) {
+	if req == nil {
+		req = &dto.UpdateTransactionFields{}
 	}
 
 	receiver := model.None[model.Optional[int]]()
-	if req.Fields.UpdateReceiver {
+	if req.UpdateReceiver {
 		newValue := model.None[int]()
-		if req.Fields.Receiver != nil {
-			newValue = model.Some(int(*req.Fields.Receiver))
+		if req.Receiver != nil {
+			newValue = model.Some(int(*req.Receiver))
 		}
 
 		receiver = model.Some(newValue)
 	}
 
 	sender := model.None[model.Optional[int]]()
-	if req.Fields.UpdateSender {
+	if req.UpdateSender {
 		newValue := model.None[int]()
-		if req.Fields.Sender != nil {
-			newValue = model.Some(int(*req.Fields.Sender))
+		if req.Sender != nil {
+			newValue = model.Some(int(*req.Sender))
 		}
 
 		sender = model.Some(newValue)
 	}
 
 	category := model.None[model.Optional[int]]()
-	if req.Fields.UpdateCategory {
+	if req.UpdateCategory {
 		newValue := model.None[int]()
-		if req.Fields.Category != nil {
-			newValue = model.Some(int(*req.Fields.Category))
+		if req.Category != nil {
+			newValue = model.Some(int(*req.Category))
 		}
 
 		category = model.Some(newValue)
 	}
 
 	updateFinancialIncomeAdditionalData := model.None[model.Optional[repository.UpdateFinancialIncomeAdditionalData]]()
-	if req.Fields.UpdateFinancialIncome {
+	if req.UpdateFinancialIncome {
 		fields := model.None[repository.UpdateFinancialIncomeAdditionalData]()
-		if req.Fields.UpdateFinancialIncomeFields != nil {
+		if req.UpdateFinancialIncomeFields != nil {
 			relatedCurrency := model.None[int]()
-			if req.Fields.UpdateFinancialIncomeFields.RelatedCurrency != nil {
-				relatedCurrency = model.Some(int(*req.Fields.UpdateFinancialIncomeFields.RelatedCurrency))
+			if req.UpdateFinancialIncomeFields.RelatedCurrency != nil {
+				relatedCurrency = model.Some(int(*req.UpdateFinancialIncomeFields.RelatedCurrency))
 			}
 
 			fields = model.Some(repository.UpdateFinancialIncomeAdditionalData{
@@ -232,19 +464,19 @@ func (s *TransactionHandler) UpdateTransaction(
 	}
 
 	updateTransactionGroupAdditionalData := model.None[model.Optional[repository.UpdateTransactionGroupAdditionalData]]()
-	if req.Fields.UpdateTransactionGroup {
+	if req.UpdateTransactionGroup {
 		fields := model.None[repository.UpdateTransactionGroupAdditionalData]()
-		if req.Fields.UpdateTransactionGroupFields != nil {
+		if req.UpdateTransactionGroupFields != nil {
 			transactionGroupId := model.None[int]()
-			if req.Fields.UpdateTransactionGroupFields.TransactionGroupId != nil {
-				transactionGroupId = model.Some(int(*req.Fields.UpdateTransactionGroupFields.TransactionGroupId))
+			if req.UpdateTransactionGroupFields.TransactionGroupId != nil {
+				transactionGroupId = model.Some(int(*req.UpdateTransactionGroupFields.TransactionGroupId))
 			}
 
 			splitOverride := model.None[model.Optional[repository.UpdateTransactionGroupSplitOverride]]()
-			if req.Fields.UpdateTransactionGroupFields.UpdateSplitOverride {
+			if req.UpdateTransactionGroupFields.UpdateSplitOverride {
 				splitOverrideFields := model.None[repository.UpdateTransactionGroupSplitOverride]()
-				if req.Fields.UpdateTransactionGroupFields.UpdateSplitOverrideFields != nil {
-					updateFields := req.Fields.UpdateTransactionGroupFields.UpdateSplitOverrideFields
+				if req.UpdateTransactionGroupFields.UpdateSplitOverrideFields != nil {
+					updateFields := req.UpdateTransactionGroupFields.UpdateSplitOverrideFields
 
 					splitTypeOverride := model.None[model.SplitTypeOverride]()
 					if updateFields.SplitTypeOverride != nil {
@@ -288,62 +520,113 @@ func (s *TransactionHandler) UpdateTransaction(
 	}
 
 	amount := model.None[int]()
-	if req.Fields.Amount != nil {
-		amount = model.Some(int(*req.Fields.Amount))
+	if req.Amount != nil {
+		amount = model.Some(int(*req.Amount))
 	}
 
 	receiverAmount := model.None[int]()
-	if req.Fields.ReceiverAmount != nil {
-		receiverAmount = model.Some(int(*req.Fields.ReceiverAmount))
+	if req.ReceiverAmount != nil {
+		receiverAmount = model.Some(int(*req.ReceiverAmount))
 	}
 
 	currencyId := model.None[int]()
-	if req.Fields.Currency != nil {
-		currencyId = model.Some(int(*req.Fields.Currency))
+	if req.Currency != nil {
+		currencyId = model.Some(int(*req.Currency))
 	}
 
 	receiverCurrencyId := model.None[int]()
-	if req.Fields.ReceiverCurrency != nil {
-		receiverCurrencyId = model.Some(int(*req.Fields.ReceiverCurrency))
+	if req.ReceiverCurrency != nil {
+		receiverCurrencyId = model.Some(int(*req.ReceiverCurrency))
 	}
 
 	date := model.None[time.Time]()
-	if req.Fields.Date != nil {
-		computedDate, err := time.Parse(layout, *req.Fields.Date)
+	if req.Date != nil {
+		computedDate, err := parseTimestamp(*req.Date, location)
 		if err != nil {
-			return nil, err
+			return repository.UpdateTransactionFields{}, err
 		}
 		date = model.Some(computedDate)
 	}
 
 	note := model.None[string]()
-	if req.Fields.Note != nil {
-		note = model.Some(*req.Fields.Note)
+	if req.Note != nil {
+		note = model.Some(*req.Note)
 	}
 
-	err := s.transactionService.UpdateTransaction(
-		ctx,
-		user.ID,
-		model.TransactionID(req.Id),
-		repository.UpdateTransactionFields{
-			Amount:                               amount,
-			CurrencyId:                           currencyId,
-			SenderAccountId:                      sender,
-			ReceiverAccountId:                    receiver,
-			CategoryId:                           category,
-			Date:                                 date,
-			Note:                                 note,
-			ReceiverCurrencyId:                   receiverCurrencyId,
-			ReceiverAmount:                       receiverAmount,
-			UpdateFinancialIncomeAdditionalData:  updateFinancialIncomeAdditionalData,
-			UpdateTransactionGroupAdditionalData: updateTransactionGroupAdditionalData,
-		},
-	)
-	if err != nil {
-		return nil, err
+	lineItems := model.None[[]model.TransactionLineItem]()
+	if req.UpdateLineItems {
+		lineItems = model.Some(lineItemsFromDto(req.LineItems))
 	}
 
-	return &dto.UpdateTransactionResponse{}, nil
+	tags := model.None[[]model.TagID]()
+	if req.UpdateTags {
+		tags = model.Some(tagIdsFromDto(req.Tags))
+	}
+
+	payee := model.None[model.Optional[model.PayeeID]]()
+	if req.UpdatePayee {
+		newValue := model.None[model.PayeeID]()
+		if req.Payee != nil {
+			newValue = model.Some(model.PayeeID(*req.Payee))
+		}
+
+		payee = model.Some(newValue)
+	}
+
+	transactionStatus := model.None[model.TransactionStatus]()
+	if req.Status != nil {
+		value, err := TransactionStatusFromDto(*req.Status)
+		if err != nil {
+			return repository.UpdateTransactionFields{}, err
+		}
+
+		transactionStatus = model.Some(value)
+	}
+
+	return repository.UpdateTransactionFields{
+		Amount:                               amount,
+		CurrencyId:                           currencyId,
+		SenderAccountId:                      sender,
+		ReceiverAccountId:                    receiver,
+		CategoryId:                           category,
+		Date:                                 date,
+		Note:                                 note,
+		ReceiverCurrencyId:                   receiverCurrencyId,
+		ReceiverAmount:                       receiverAmount,
+		UpdateFinancialIncomeAdditionalData:  updateFinancialIncomeAdditionalData,
+		UpdateTransactionGroupAdditionalData: updateTransactionGroupAdditionalData,
+		LineItems:                            lineItems,
+		Tags:                                 tags,
+		Status:                               transactionStatus,
+		PayeeId:                              payee,
+	}, nil
+}
+
+func tagIdsFromDto(ids []uint32) []model.TagID {
+	tags := make([]model.TagID, len(ids))
+	for i, id := range ids {
+		tags[i] = model.TagID(id)
+	}
+
+	return tags
+}
+
+func lineItemsFromDto(lineItemsDto []*dto.TransactionLineItem) []model.TransactionLineItem {
+	lineItems := make([]model.TransactionLineItem, len(lineItemsDto))
+	for i, lineItem := range lineItemsDto {
+		category := model.None[model.CategoryID]()
+		if lineItem.Category != nil {
+			category = model.Some(model.CategoryID(*lineItem.Category))
+		}
+
+		lineItems[i] = model.TransactionLineItem{
+			Category: category,
+			Amount:   int(lineItem.Amount),
+			Note:     lineItem.Note,
+		}
+	}
+
+	return lineItems
 }
 
 func (s *TransactionHandler) DeleteTransaction(
@@ -356,12 +639,335 @@ func (s *TransactionHandler) DeleteTransaction(
 	}
 
 	if err := s.transactionService.DeleteTransaction(ctx, user.ID, model.TransactionID(req.Id)); err != nil {
-		return nil, err
+		return nil, transactionWriteError(err)
 	}
 
 	return &dto.DeleteTransactionResponse{}, nil
 }
 
+func (s *TransactionHandler) ListDeletedTransactions(
+	ctx context.Context,
+	_ *dto.ListDeletedTransactionsRequest,
+) (*dto.ListDeletedTransactionsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := s.transactionService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.transactionService.ListDeletedTransactions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	transactionsDto := make([]*dto.DeletedTransaction, len(transactions))
+	for i, transaction := range transactions {
+		transactionDto, err := transactionToDto(transaction.Transaction, location)
+		if err != nil {
+			return nil, err
+		}
+
+		transactionsDto[i] = &dto.DeletedTransaction{
+			Transaction: transactionDto,
+			DeletedAt:   transaction.DeletedAt.UTC().Format(layout),
+		}
+	}
+
+	return &dto.ListDeletedTransactionsResponse{
+		Transactions: transactionsDto,
+	}, nil
+}
+
+func (s *TransactionHandler) RestoreTransaction(
+	ctx context.Context,
+	req *dto.RestoreTransactionRequest,
+) (*dto.RestoreTransactionResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := s.transactionService.RestoreTransaction(ctx, user.ID, model.TransactionID(req.Id))
+	if errors.Is(err, repository.ErrTransactionNotFound) {
+		return nil, status.Error(codes.NotFound, "no such transaction in the trash")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RestoreTransactionResponse{}, nil
+}
+
+func (s *TransactionHandler) PurgeTransaction(
+	ctx context.Context,
+	req *dto.PurgeTransactionRequest,
+) (*dto.PurgeTransactionResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := s.transactionService.PurgeTransaction(ctx, user.ID, model.TransactionID(req.Id))
+	if errors.Is(err, repository.ErrTransactionNotFound) {
+		return nil, status.Error(codes.NotFound, "no such transaction in the trash")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.PurgeTransactionResponse{}, nil
+}
+
+func (s *TransactionHandler) AcceptTransaction(
+	ctx context.Context,
+	req *dto.AcceptTransactionRequest,
+) (*dto.AcceptTransactionResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := s.transactionService.SetTransactionAttributionState(
+		ctx, user.ID, model.TransactionID(req.Id), model.AttributionStateAccepted, "",
+	)
+	if err != nil {
+		return nil, transactionAttributionError(err)
+	}
+
+	return &dto.AcceptTransactionResponse{}, nil
+}
+
+func (s *TransactionHandler) DisputeTransaction(
+	ctx context.Context,
+	req *dto.DisputeTransactionRequest,
+) (*dto.DisputeTransactionResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := s.transactionService.SetTransactionAttributionState(
+		ctx, user.ID, model.TransactionID(req.Id), model.AttributionStateDisputed, strings.TrimSpace(req.Reason),
+	)
+	if err != nil {
+		return nil, transactionAttributionError(err)
+	}
+
+	return &dto.DisputeTransactionResponse{}, nil
+}
+
+func transactionAttributionError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrTransactionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrNotAttributed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return err
+	}
+}
+
+func transactionHistoryActionToDto(action model.TransactionHistoryAction) (dto.TransactionHistoryAction, error) {
+	switch action {
+	case model.TransactionHistoryActionCreated:
+		return dto.TransactionHistoryAction_HistoryCreated, nil
+	case model.TransactionHistoryActionUpdated:
+		return dto.TransactionHistoryAction_HistoryUpdated, nil
+	case model.TransactionHistoryActionDeleted:
+		return dto.TransactionHistoryAction_HistoryDeleted, nil
+	case model.TransactionHistoryActionRestored:
+		return dto.TransactionHistoryAction_HistoryRestored, nil
+	default:
+		return dto.TransactionHistoryAction_HistoryCreated, fmt.Errorf("unknown TransactionHistoryAction %d", action)
+	}
+}
+
+func (s *TransactionHandler) GetTransactionHistory(
+	ctx context.Context,
+	req *dto.GetTransactionHistoryRequest,
+) (*dto.GetTransactionHistoryResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	entries, err := s.transactionService.GetTransactionHistory(ctx, user.ID, model.TransactionID(req.Id))
+	if errors.Is(err, repository.ErrTransactionNotFound) {
+		return nil, status.Error(codes.NotFound, "transaction not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entriesDto := make([]*dto.TransactionHistoryEntry, len(entries))
+	for i, entry := range entries {
+		action, err := transactionHistoryActionToDto(entry.Action)
+		if err != nil {
+			return nil, err
+		}
+
+		changes := make([]*dto.TransactionFieldChange, len(entry.Changes))
+		for j, change := range entry.Changes {
+			changes[j] = &dto.TransactionFieldChange{
+				Field:  change.Field,
+				Before: change.Before,
+				After:  change.After,
+			}
+		}
+
+		var changedBy *string
+		if email, isSome := entry.ChangedBy.Value(); isSome {
+			changedByValue := string(email)
+			changedBy = &changedByValue
+		}
+
+		entriesDto[i] = &dto.TransactionHistoryEntry{
+			Id:        uint64(entry.ID),
+			ChangedBy: changedBy,
+			ChangedAt: entry.ChangedAt.UTC().Format(layout),
+			Action:    action,
+			Changes:   changes,
+		}
+	}
+
+	return &dto.GetTransactionHistoryResponse{
+		Entries: entriesDto,
+	}, nil
+}
+
+func (s *TransactionHandler) BatchMutateTransactions(
+	ctx context.Context,
+	req *dto.BatchMutateTransactionsRequest,
+) (*dto.BatchMutateTransactionsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := s.transactionService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Mutations) > maxBatchMutations {
+		return nil, status.Errorf(codes.InvalidArgument, "a batch holds at most %d mutations", maxBatchMutations)
+	}
+
+	mutations := make([]repository.TransactionMutation, len(req.Mutations))
+	for i, mutationDto := range req.Mutations {
+		mutation, err := transactionMutationFromDto(mutationDto, location)
+		if err != nil {
+			return failedBatchResponse(len(req.Mutations), i, err.Error()), nil
+		}
+		mutations[i] = mutation
+	}
+
+	ids, err := s.transactionService.BatchMutateTransactions(ctx, user.ID, user.Email, mutations)
+	var mutationErr *repository.TransactionMutationError
+	if errors.As(err, &mutationErr) {
+		message := mutationErr.Err.Error()
+		if !errors.Is(mutationErr.Err, repository.ErrTransactionNotFound) &&
+			!errors.Is(mutationErr.Err, repository.ErrForeignOwner) &&
+			!errors.Is(mutationErr.Err, repository.ErrForeignOwnerFields) &&
+			!errors.Is(mutationErr.Err, repository.ErrForeignCurrency) &&
+			!errors.Is(mutationErr.Err, repository.ErrPayeeNotFound) &&
+			!errors.Is(mutationErr.Err, repository.ErrLineItemsMismatch) &&
+			!errors.Is(mutationErr.Err, repository.ErrTagNotFound) &&
+			!errors.Is(mutationErr.Err, repository.ErrTransactionReconciled) &&
+			!errors.Is(mutationErr.Err, repository.ErrReconciledStatus) &&
+			!errors.Is(mutationErr.Err, repository.ErrVersionConflict) &&
+			!errors.As(mutationErr.Err, new(*repository.DuplicateTransactionError)) &&
+			!errors.As(mutationErr.Err, new(*service.ValidationError)) {
+			// Anything else may carry database details; keep them server-side.
+			logging.FromContext(ctx).Error("batch transaction mutation failed", "index", mutationErr.Index, "error", mutationErr.Err)
+			message = "internal error"
+		}
+		response := failedBatchResponse(len(mutations), mutationErr.Index, message)
+
+		var duplicateErr *repository.DuplicateTransactionError
+		if errors.As(mutationErr.Err, &duplicateErr) {
+			response.Results[mutationErr.Index].DuplicateWarning = duplicateWarningToDto(duplicateErr, location)
+		}
+
+		if update, isSome := mutations[mutationErr.Index].Update.Value(); isSome &&
+			errors.Is(mutationErr.Err, repository.ErrVersionConflict) {
+			current, err := s.currentTransaction(ctx, user.ID, update.ID, location)
+			if err != nil {
+				return nil, err
+			}
+			response.Results[mutationErr.Index].Current = current
+		}
+
+		return response, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*dto.TransactionMutationResult, len(ids))
+	for i, id := range ids {
+		results[i] = &dto.TransactionMutationResult{
+			Status: dto.TransactionMutationStatus_MutationApplied,
+			Id:     uint32(id),
+		}
+	}
+
+	return &dto.BatchMutateTransactionsResponse{
+		Committed: true,
+		Results:   results,
+	}, nil
+}
+
+func transactionMutationFromDto(
+	mutationDto *dto.TransactionMutation,
+	location *time.Location,
+) (repository.TransactionMutation, error) {
+	switch mutation := mutationDto.GetMutation().(type) {
+	case *dto.TransactionMutation_Create:
+		fields, err := newTransactionFieldsFromDto(mutation.Create, location)
+		if err != nil {
+			return repository.TransactionMutation{}, err
+		}
+		return repository.TransactionMutation{Create: model.Some(fields)}, nil
+	case *dto.TransactionMutation_Update:
+		fields, err := updateTransactionFieldsFromDto(mutation.Update.Fields, location)
+		if err != nil {
+			return repository.TransactionMutation{}, err
+		}
+		fields.ExpectedVersion = expectedTransactionVersionFromDto(mutation.Update.ExpectedVersion)
+		return repository.TransactionMutation{Update: model.Some(repository.TransactionUpdate{
+			ID:     model.TransactionID(mutation.Update.Id),
+			Fields: fields,
+		})}, nil
+	case *dto.TransactionMutation_Delete:
+		return repository.TransactionMutation{Delete: model.Some(model.TransactionID(mutation.Delete.Id))}, nil
+	default:
+		return repository.TransactionMutation{}, fmt.Errorf("empty mutation")
+	}
+}
+
+// failedBatchResponse reports the mutation that caused the batch to be rolled
+// back; every other mutation is reported as not applied.
+func failedBatchResponse(count, failedIndex int, message string) *dto.BatchMutateTransactionsResponse {
+	results := make([]*dto.TransactionMutationResult, count)
+	for i := range results {
+		results[i] = &dto.TransactionMutationResult{Status: dto.TransactionMutationStatus_MutationNotApplied}
+	}
+	results[failedIndex] = &dto.TransactionMutationResult{
+		Status: dto.TransactionMutationStatus_MutationFailed,
+		Error:  &message,
+	}
+
+	return &dto.BatchMutateTransactionsResponse{
+		Committed: false,
+		Results:   results,
+	}
+}
+
 func (s *TransactionHandler) GetAllTransactions(
 	ctx context.Context,
 	_ *dto.GetAllTransactionsRequest,
@@ -371,6 +977,11 @@ func (s *TransactionHandler) GetAllTransactions(
 		return nil, fmt.Errorf("getting user from context")
 	}
 
+	location, err := s.transactionService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	transactions, err := s.transactionService.GetAllTransactions(ctx, user.ID)
 	if err != nil {
 		return nil, err
@@ -378,84 +989,440 @@ func (s *TransactionHandler) GetAllTransactions(
 
 	transactionsDto := make([]*dto.Transaction, len(transactions))
 	for i, transaction := range transactions {
-		var sender *uint32
-		if value, isSome := transaction.Sender.Value(); isSome {
-			id := uint32(value)
-			sender = &id
+		transactionsDto[i], err = transactionToDto(transaction, location)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		var receiver *uint32
-		if value, isSome := transaction.Receiver.Value(); isSome {
-			id := uint32(value)
-			receiver = &id
+	return &dto.GetAllTransactionsResponse{
+		Transactions: transactionsDto,
+	}, nil
+}
+
+func (s *TransactionHandler) ListTransactions(
+	ctx context.Context,
+	req *dto.ListTransactionsRequest,
+) (*dto.ListTransactionsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := s.transactionService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repository.TransactionFilter{
+		IncludeCategorySubtrees: req.IncludeCategorySubtrees,
+	}
+
+	if req.From != nil {
+		from, err := parseRangeBound(*req.From, location, false)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "parsing from date: %s", err)
 		}
+		filter.From = model.Some(from)
+	}
 
-		var category *uint32
-		if value, isSome := transaction.Category.Value(); isSome {
-			id := uint32(value)
-			category = &id
+	if req.To != nil {
+		to, err := parseRangeBound(*req.To, location, true)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "parsing to date: %s", err)
+		}
+		filter.To = model.Some(to)
+	}
+
+	filter.AccountIds = make([]model.AccountID, len(req.AccountIds))
+	for i, id := range req.AccountIds {
+		filter.AccountIds[i] = model.AccountID(id)
+	}
+
+	filter.CategoryIds = make([]model.CategoryID, len(req.CategoryIds))
+	for i, id := range req.CategoryIds {
+		filter.CategoryIds[i] = model.CategoryID(id)
+	}
+
+	if req.Currency != nil {
+		filter.Currency = model.Some(model.CurrencyID(*req.Currency))
+	}
+
+	if req.TransactionGroup != nil {
+		filter.TransactionGroup = model.Some(model.TransactionGroupID(*req.TransactionGroup))
+	}
+
+	if req.MinAmount != nil {
+		filter.MinAmount = model.Some(int(*req.MinAmount))
+	}
+
+	if req.MaxAmount != nil {
+		filter.MaxAmount = model.Some(int(*req.MaxAmount))
+	}
+
+	if req.NoteContains != nil {
+		filter.NoteContains = model.Some(*req.NoteContains)
+	}
+
+	filter.TagIds = tagIdsFromDto(req.TagIds)
+
+	after := model.None[model.TransactionCursor]()
+	if req.Cursor != nil && *req.Cursor != "" {
+		cursor, err := decodeTransactionCursor(*req.Cursor)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid cursor: %s", err)
 		}
+		after = model.Some(cursor)
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = defaultListTransactionsPageSize
+	}
+	pageSize = min(pageSize, maxListTransactionsPageSize)
+
+	transactions, next, err := s.transactionService.ListTransactions(ctx, user.ID, filter, after, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	transactionsDto := make([]*dto.Transaction, len(transactions))
+	for i, transaction := range transactions {
+		transactionsDto[i], err = transactionToDto(transaction, location)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var nextCursor *string
+	if cursor, isSome := next.Value(); isSome {
+		encoded := encodeTransactionCursor(cursor)
+		nextCursor = &encoded
+	}
+
+	return &dto.ListTransactionsResponse{
+		Transactions: transactionsDto,
+		NextCursor:   nextCursor,
+	}, nil
+}
 
-		var financialIncomeData *dto.FinancialIncomeData
-		if data, isSome := transaction.FinancialIncomeData.Value(); isSome {
-			financialIncomeData = &dto.FinancialIncomeData{
-				RelatedCurrency: uint32(data.RelatedCurrency),
+func (s *TransactionHandler) SearchTransactions(
+	ctx context.Context,
+	req *dto.SearchTransactionsRequest,
+) (*dto.SearchTransactionsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := s.transactionService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return nil, status.Error(codes.InvalidArgument, "query must not be empty")
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = defaultSearchTransactionsPageSize
+	}
+	pageSize = min(pageSize, maxSearchTransactionsPageSize)
+
+	results, err := s.transactionService.SearchTransactions(ctx, user.ID, query, pageSize, int(req.Offset))
+	if err != nil {
+		return nil, err
+	}
+
+	resultsDto := make([]*dto.TransactionSearchResult, len(results))
+	for i, result := range results {
+		transactionDto, err := transactionToDto(result.Transaction, location)
+		if err != nil {
+			return nil, err
+		}
+
+		matches := make([]*dto.TransactionSearchMatch, len(result.Matches))
+		for j, match := range result.Matches {
+			matches[j] = &dto.TransactionSearchMatch{
+				Field:     transactionSearchFieldToDto(match.Field),
+				Highlight: match.Highlight,
 			}
 		}
 
-		var transactionGroupData *dto.TransactionGroupData
-		if data, isSome := transaction.GroupedTransactionData.Value(); isSome {
-			var splitOverride *dto.SplitOverride
-			if splitOverrideData, isSome := data.SplitOverride.Value(); isSome {
-				splitTypeOverride, err := SplitTypeOverrideToDto(splitOverrideData.SplitTypeOverride)
-				if err != nil {
-					return nil, err
-				}
+		resultsDto[i] = &dto.TransactionSearchResult{
+			Transaction: transactionDto,
+			Rank:        result.Rank,
+			Matches:     matches,
+		}
+	}
 
-				members := make([]*dto.MemberSplitValue, len(splitOverrideData.Members))
-				for j, member := range splitOverrideData.Members {
-					var splitValue *uint32
-					if value, isSome := member.SplitValue.Value(); isSome {
-						v := uint32(value)
-						splitValue = &v
-					}
+	return &dto.SearchTransactionsResponse{
+		Results: resultsDto,
+	}, nil
+}
 
-					members[j] = &dto.MemberSplitValue{
-						Email:      string(member.Email),
-						SplitValue: splitValue,
-					}
+func transactionSearchFieldToDto(field model.TransactionSearchField) dto.TransactionSearchField {
+	switch field {
+	case model.TransactionSearchFieldSender:
+		return dto.TransactionSearchField_SearchSender
+	case model.TransactionSearchFieldReceiver:
+		return dto.TransactionSearchField_SearchReceiver
+	case model.TransactionSearchFieldCategory:
+		return dto.TransactionSearchField_SearchCategory
+	case model.TransactionSearchFieldTransactionGroup:
+		return dto.TransactionSearchField_SearchTransactionGroup
+	default:
+		return dto.TransactionSearchField_SearchNote
+	}
+}
+
+// encodeTransactionCursor serializes a cursor into the opaque token handed to
+// clients. Clients must not rely on its format.
+func encodeTransactionCursor(cursor model.TransactionCursor) string {
+	raw := fmt.Sprintf("%s|%d", cursor.Date.Format(time.RFC3339Nano), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTransactionCursor(token string) (model.TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return model.TransactionCursor{}, err
+	}
+
+	datePart, idPart, found := strings.Cut(string(raw), "|")
+	if !found {
+		return model.TransactionCursor{}, fmt.Errorf("malformed cursor")
+	}
+
+	date, err := time.Parse(time.RFC3339Nano, datePart)
+	if err != nil {
+		return model.TransactionCursor{}, err
+	}
+
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return model.TransactionCursor{}, err
+	}
+
+	return model.TransactionCursor{Date: date, ID: model.TransactionID(id)}, nil
+}
+
+func transactionToDto(transaction model.Transaction, location *time.Location) (*dto.Transaction, error) {
+	var sender *uint32
+	if value, isSome := transaction.Sender.Value(); isSome {
+		id := uint32(value)
+		sender = &id
+	}
+
+	var receiver *uint32
+	if value, isSome := transaction.Receiver.Value(); isSome {
+		id := uint32(value)
+		receiver = &id
+	}
+
+	var category *uint32
+	if value, isSome := transaction.Category.Value(); isSome {
+		id := uint32(value)
+		category = &id
+	}
+
+	var financialIncomeData *dto.FinancialIncomeData
+	if data, isSome := transaction.FinancialIncomeData.Value(); isSome {
+		financialIncomeData = &dto.FinancialIncomeData{
+			RelatedCurrency: uint32(data.RelatedCurrency),
+		}
+	}
+
+	var transactionGroupData *dto.TransactionGroupData
+	if data, isSome := transaction.GroupedTransactionData.Value(); isSome {
+		var splitOverride *dto.SplitOverride
+		if splitOverrideData, isSome := data.SplitOverride.Value(); isSome {
+			splitTypeOverride, err := SplitTypeOverrideToDto(splitOverrideData.SplitTypeOverride)
+			if err != nil {
+				return nil, err
+			}
+
+			members := make([]*dto.MemberSplitValue, len(splitOverrideData.Members))
+			for j, member := range splitOverrideData.Members {
+				var splitValue *uint32
+				if value, isSome := member.SplitValue.Value(); isSome {
+					v := uint32(value)
+					splitValue = &v
 				}
 
-				splitOverride = &dto.SplitOverride{
-					SplitTypeOverride: splitTypeOverride,
-					MemberSplitValues: members,
+				members[j] = &dto.MemberSplitValue{
+					Email:      string(member.Email),
+					SplitValue: splitValue,
 				}
 			}
 
-			transactionGroupData = &dto.TransactionGroupData{
-				TransactionGroup: uint32(data.TransactionGroup),
-				SplitOverride:    splitOverride,
+			splitOverride = &dto.SplitOverride{
+				SplitTypeOverride: splitTypeOverride,
+				MemberSplitValues: members,
 			}
 		}
 
-		transactionsDto[i] = &dto.Transaction{
-			Id:                   uint32(transaction.ID),
-			Owner:                string(transaction.Owner),
-			Amount:               uint32(transaction.Amount),
-			Currency:             uint32(transaction.Currency),
-			Sender:               sender,
-			Receiver:             receiver,
-			Category:             category,
-			Date:                 transaction.Date.Format(layout),
-			Note:                 transaction.Note,
-			ReceiverCurrency:     uint32(transaction.ReceiverCurrency),
-			ReceiverAmount:       uint32(transaction.ReceiverAmount),
-			FinancialIncomeData:  financialIncomeData,
-			TransactionGroupData: transactionGroupData,
+		transactionGroupData = &dto.TransactionGroupData{
+			TransactionGroup: uint32(data.TransactionGroup),
+			SplitOverride:    splitOverride,
 		}
 	}
 
-	return &dto.GetAllTransactionsResponse{
-		Transactions: transactionsDto,
+	lineItems := make([]*dto.TransactionLineItem, len(transaction.LineItems))
+	for i, lineItem := range transaction.LineItems {
+		var lineItemCategory *uint32
+		if value, isSome := lineItem.Category.Value(); isSome {
+			id := uint32(value)
+			lineItemCategory = &id
+		}
+
+		lineItems[i] = &dto.TransactionLineItem{
+			Category: lineItemCategory,
+			Amount:   uint32(lineItem.Amount),
+			Note:     lineItem.Note,
+		}
+	}
+
+	attachments := make([]*dto.Attachment, len(transaction.Attachments))
+	for i, attachment := range transaction.Attachments {
+		attachments[i] = &dto.Attachment{
+			Id:        uint32(attachment.ID),
+			Name:      attachment.Name,
+			MimeType:  attachment.MimeType,
+			Size:      uint64(attachment.Size),
+			Sha256:    attachment.SHA256,
+			CreatedAt: attachment.CreatedAt.UTC().Format(layout),
+		}
+	}
+
+	tags := make([]uint32, len(transaction.Tags))
+	for i, tagId := range transaction.Tags {
+		tags[i] = uint32(tagId)
+	}
+
+	transactionStatus, err := TransactionStatusToDto(transaction.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	var payee *uint32
+	if value, isSome := transaction.Payee.Value(); isSome {
+		id := uint32(value)
+		payee = &id
+	}
+
+	var attribution *dto.TransactionAttribution
+	if value, isSome := transaction.Attribution.Value(); isSome {
+		attribution, err = transactionAttributionToDto(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dto.Transaction{
+		Id:                   uint32(transaction.ID),
+		Owner:                string(transaction.Owner),
+		Amount:               uint32(transaction.Amount),
+		Currency:             uint32(transaction.Currency),
+		Sender:               sender,
+		Receiver:             receiver,
+		Category:             category,
+		Date:                 formatTimestamp(transaction.Date, location),
+		Timestamp:            transaction.Date.In(location).Format(time.RFC3339),
+		Note:                 transaction.Note,
+		ReceiverCurrency:     uint32(transaction.ReceiverCurrency),
+		ReceiverAmount:       uint32(transaction.ReceiverAmount),
+		FinancialIncomeData:  financialIncomeData,
+		TransactionGroupData: transactionGroupData,
+		LineItems:            lineItems,
+		Tags:                 tags,
+		Attachments:          attachments,
+		Status:               transactionStatus,
+		Attribution:          attribution,
+		Payee:                payee,
+		Version:              uint32(transaction.Version),
+	}, nil
+}
+
+func transactionAttributionToDto(attribution model.TransactionAttribution) (*dto.TransactionAttribution, error) {
+	var state dto.AttributionState
+	switch attribution.State {
+	case model.AttributionStatePending:
+		state = dto.AttributionState_AttributionPending
+	case model.AttributionStateAccepted:
+		state = dto.AttributionState_AttributionAccepted
+	case model.AttributionStateDisputed:
+		state = dto.AttributionState_AttributionDisputed
+	default:
+		return nil, fmt.Errorf("unknown AttributionState %d", attribution.State)
+	}
+
+	var recordedBy *string
+	if value, isSome := attribution.RecordedBy.Value(); isSome {
+		email := string(value)
+		recordedBy = &email
+	}
+
+	return &dto.TransactionAttribution{
+		RecordedBy:    recordedBy,
+		State:         state,
+		DisputeReason: attribution.DisputeReason,
+	}, nil
+}
+
+func duplicateCandidateToDto(candidate model.DuplicateCandidate, location *time.Location) *dto.DuplicateCandidate {
+	return &dto.DuplicateCandidate{
+		Id:             uint32(candidate.ID),
+		Owner:          string(candidate.Owner),
+		Amount:         uint32(candidate.Amount),
+		Currency:       uint32(candidate.Currency),
+		Date:           formatTimestamp(candidate.Date, location),
+		Note:           candidate.Note,
+		NoteSimilarity: candidate.NoteSimilarity,
+	}
+}
+
+func duplicateWarningToDto(err *repository.DuplicateTransactionError, location *time.Location) *dto.DuplicateWarning {
+	candidates := make([]*dto.DuplicateCandidate, len(err.Candidates))
+	for i, candidate := range err.Candidates {
+		candidates[i] = duplicateCandidateToDto(candidate, location)
+	}
+
+	return &dto.DuplicateWarning{
+		Candidates: candidates,
+	}
+}
+
+func (s *TransactionHandler) FindDuplicateTransactions(
+	ctx context.Context,
+	_ *dto.FindDuplicateTransactionsRequest,
+) (*dto.FindDuplicateTransactionsResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	location, err := s.transactionService.UserLocation(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, err := s.transactionService.FindDuplicateTransactions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pairsDto := make([]*dto.DuplicatePair, len(pairs))
+	for i, pair := range pairs {
+		pairsDto[i] = &dto.DuplicatePair{
+			First:  duplicateCandidateToDto(pair.First, location),
+			Second: duplicateCandidateToDto(pair.Second, location),
+		}
+	}
+
+	return &dto.FindDuplicateTransactionsResponse{
+		Pairs: pairsDto,
 	}, nil
 }
@@ -0,0 +1,40 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/dto"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type userRepository interface {
+	SetUserTimezone(ctx context.Context, id uuid.UUID, timezone string) error
+}
+
+type UserHandler struct {
+	dto.UnimplementedUserServiceServer
+
+	userService userRepository
+}
+
+func (s *UserHandler) SetTimezone(ctx context.Context, req *dto.SetTimezoneRequest) (*dto.SetTimezoneResponse, error) {
+	user, ok := shared.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("getting user from context")
+	}
+
+	err := s.userService.SetUserTimezone(ctx, user.ID, req.Timezone)
+	if errors.Is(err, repository.ErrInvalidTimezone) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &dto.SetTimezoneResponse{}, nil
+}
@@ -0,0 +1,32 @@
+package grpc
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+)
+
+// staleWriteError rejects an update made against a version of an entity that
+// is no longer the current one. The entity as it is now goes in the details
+// of the status, so the client can redo its change on top of it.
+func staleWriteError(current protoadapt.MessageV1) error {
+	stale := status.New(codes.Aborted, repository.ErrVersionConflict.Error())
+
+	withCurrent, err := stale.WithDetails(current)
+	if err != nil {
+		return stale.Err()
+	}
+
+	return withCurrent.Err()
+}
+
+func expectedVersionFromDto(version *uint32) *int {
+	if version == nil {
+		return nil
+	}
+
+	expected := int(*version)
+	return &expected
+}
@@ -0,0 +1,292 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/blobstore"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
+	"chagnon.dev/budget-server/internal/logging"
+)
+
+// multipartOverhead is the room left for the multipart framing around the
+// uploaded file when capping the size of the request body.
+const multipartOverhead = 64 * 1024
+
+type attachmentRepository interface {
+	CreateAttachment(
+		ctx context.Context,
+		userId uuid.UUID,
+		transactionId model.TransactionID,
+		fields repository.NewAttachmentFields,
+		quota int64,
+	) (model.AttachmentID, error)
+	GetAttachment(ctx context.Context, userId uuid.UUID, id model.AttachmentID) (repository.StoredAttachment, error)
+	DetachAttachment(ctx context.Context, userId uuid.UUID, id model.AttachmentID) (string, error)
+	DeleteDetachedAttachment(ctx context.Context, id model.AttachmentID) error
+}
+
+// Attachments serves the files attached to transactions. Uploads are
+// multipart forms holding a single "file" part.
+type Attachments struct {
+	repository  attachmentRepository
+	store       blobstore.Store
+	maxFileSize int64
+	userQuota   int64
+}
+
+func NewAttachments(repository attachmentRepository, store blobstore.Store, maxFileSize, userQuota int64) *Attachments {
+	return &Attachments{
+		repository:  repository,
+		store:       store,
+		maxFileSize: maxFileSize,
+		userQuota:   userQuota,
+	}
+}
+
+func (a *Attachments) ServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /transactions/{transactionId}", a.uploadHandler)
+	mux.HandleFunc("GET /{attachmentId}", a.downloadHandler)
+	mux.HandleFunc("DELETE /{attachmentId}", a.deleteHandler)
+	return mux
+}
+
+type uploadResponse struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+func (a *Attachments) uploadHandler(resp http.ResponseWriter, req *http.Request) {
+	logger := logging.FromContext(req.Context())
+
+	user, ok := shared.FromContext(req.Context())
+	if !ok {
+		http.Error(resp, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	transactionId, err := strconv.Atoi(req.PathValue("transactionId"))
+	if err != nil {
+		http.Error(resp, "invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	if req.ContentLength > a.maxFileSize+multipartOverhead {
+		http.Error(resp, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	req.Body = http.MaxBytesReader(resp, req.Body, a.maxFileSize+multipartOverhead)
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		http.Error(resp, "expected a multipart form", http.StatusBadRequest)
+		return
+	}
+
+	part, err := reader.NextPart()
+	for err == nil && part.FormName() != "file" {
+		part, err = reader.NextPart()
+	}
+	if err != nil {
+		http.Error(resp, "missing file part", http.StatusBadRequest)
+		return
+	}
+	defer part.Close()
+
+	name := part.FileName()
+	if name == "" {
+		name = "attachment"
+	}
+
+	// Read one byte past the limit to tell a file of exactly the maximum size
+	// from a larger one.
+	body := bufio.NewReaderSize(io.LimitReader(part, a.maxFileSize+1), 512)
+
+	// Browsers fall back to a generic type for extensions they do not know;
+	// sniffing the content gives a more useful one in that case.
+	mimeType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if err != nil || mimeType == "application/octet-stream" {
+		head, _ := body.Peek(512)
+		mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	content := io.TeeReader(body, io.MultiWriter(hash, counter))
+
+	storageKey := uuid.NewString()
+	if err := a.store.Put(req.Context(), storageKey, content); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(resp, "file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		logger.Error("storing attachment", "error", err)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	discard := func() {
+		if err := a.store.Delete(context.WithoutCancel(req.Context()), storageKey); err != nil {
+			logger.Error("deleting rejected attachment", "error", err, "storageKey", storageKey)
+		}
+	}
+
+	if counter.n > a.maxFileSize {
+		discard()
+		http.Error(resp, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	fields := repository.NewAttachmentFields{
+		Name:       name,
+		MimeType:   mimeType,
+		Size:       counter.n,
+		SHA256:     hex.EncodeToString(hash.Sum(nil)),
+		StorageKey: storageKey,
+	}
+
+	id, err := a.repository.CreateAttachment(req.Context(), user.ID, model.TransactionID(transactionId), fields, a.userQuota)
+	if err != nil {
+		discard()
+		switch {
+		case errors.Is(err, repository.ErrTransactionNotFound):
+			http.Error(resp, "transaction not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrAttachmentQuotaExceeded):
+			http.Error(resp, err.Error(), http.StatusInsufficientStorage)
+		default:
+			logger.Error("recording attachment", "error", err)
+			http.Error(resp, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(resp).Encode(uploadResponse{
+		ID:       int(id),
+		Name:     fields.Name,
+		MimeType: fields.MimeType,
+		Size:     fields.Size,
+		SHA256:   fields.SHA256,
+	}); err != nil {
+		logger.Error("encoding response", "error", err)
+	}
+}
+
+func (a *Attachments) downloadHandler(resp http.ResponseWriter, req *http.Request) {
+	logger := logging.FromContext(req.Context())
+
+	user, ok := shared.FromContext(req.Context())
+	if !ok {
+		http.Error(resp, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	attachmentId, err := strconv.Atoi(req.PathValue("attachmentId"))
+	if err != nil {
+		http.Error(resp, "invalid attachment id", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := a.repository.GetAttachment(req.Context(), user.ID, model.AttachmentID(attachmentId))
+	if errors.Is(err, repository.ErrAttachmentNotFound) {
+		http.Error(resp, "attachment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("getting attachment", "error", err)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	etag := fmt.Sprintf("%q", attachment.SHA256)
+	if req.Header.Get("If-None-Match") == etag {
+		resp.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	content, err := a.store.Get(req.Context(), attachment.StorageKey)
+	if err != nil {
+		logger.Error("reading attachment", "error", err, "storageKey", attachment.StorageKey)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	// Uploaded files are served as downloads in a sandbox so that an HTML or
+	// SVG file cannot run scripts with the application's origin.
+	resp.Header().Set("Content-Type", attachment.MimeType)
+	resp.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	resp.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	resp.Header().Set("Content-Security-Policy", "sandbox; default-src 'none'")
+	resp.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	resp.Header().Set("ETag", etag)
+
+	if _, err := io.Copy(resp, content); err != nil {
+		logger.Error("sending attachment", "error", err)
+	}
+}
+
+func (a *Attachments) deleteHandler(resp http.ResponseWriter, req *http.Request) {
+	logger := logging.FromContext(req.Context())
+
+	user, ok := shared.FromContext(req.Context())
+	if !ok {
+		http.Error(resp, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	attachmentId, err := strconv.Atoi(req.PathValue("attachmentId"))
+	if err != nil {
+		http.Error(resp, "invalid attachment id", http.StatusBadRequest)
+		return
+	}
+
+	storageKey, err := a.repository.DetachAttachment(req.Context(), user.ID, model.AttachmentID(attachmentId))
+	if errors.Is(err, repository.ErrAttachmentNotFound) {
+		http.Error(resp, "attachment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("detaching attachment", "error", err)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// The attachment is gone for the user at this point. Should cleaning up
+	// fail, the attachment sweeper will try again later.
+	if err := a.store.Delete(req.Context(), storageKey); err != nil {
+		logger.Warn("deleting attachment blob", "error", err, "storageKey", storageKey)
+	} else if err := a.repository.DeleteDetachedAttachment(req.Context(), model.AttachmentID(attachmentId)); err != nil {
+		logger.Warn("deleting detached attachment", "error", err)
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
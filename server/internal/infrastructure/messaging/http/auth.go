@@ -332,6 +332,7 @@ type userInfoResponse struct {
 	Name                   string            `json:"name"`
 	DefaultCurrency        *int              `json:"default_currency"`
 	HiddenDefaultAccount   *int              `json:"hidden_default_account"`
+	Timezone               string            `json:"timezone"`
 	AuthentificationMethod shared.AuthMethod `json:"authentification_method"`
 }
 
@@ -449,6 +450,7 @@ func (auth *Auth) userInfoHandler(resp http.ResponseWriter, req *http.Request) {
 		Name:                   params.Name,
 		DefaultCurrency:        &defaultCurrency,
 		HiddenDefaultAccount:   &hiddenDefaultAccount,
+		Timezone:               params.Timezone,
 		AuthentificationMethod: user.AuthMethod,
 	}
 
@@ -507,6 +509,124 @@ func (auth *Auth) parseSessionToken(getCookie func(name string) (*http.Cookie, e
 	return &user, nil
 }
 
+// authenticate resolves the user behind the request from the session token,
+// falling back to the OIDC tokens and refreshing the cookies when needed. On
+// failure it writes the error response itself and returns false.
+func (auth *Auth) authenticate(resp http.ResponseWriter, req *http.Request) (*shared.User, bool) {
+	logger := logging.FromContext(req.Context())
+
+	user, err := auth.parseSessionToken(req.Cookie)
+	if err != nil {
+		if !errors.Is(err, jwt.ErrInvalidContentType) {
+			logger.DebugContext(req.Context(), "parsing session token", "error", err)
+		}
+
+		// Fall back to OIDC token verification
+		tokenSource, prevTokenCookie, err := auth.TokenSourceFromCookies(req.Context(), req.Cookie)
+		if err != nil {
+			logger.Error("reading tokens from request", "error", err)
+			http.Error(resp, "reading tokens from request", http.StatusInternalServerError)
+			return nil, false
+		}
+
+		rawToken, err := tokenSource.Token()
+		if err != nil {
+			logger.Error("getting valid access token", "error", err)
+			http.Error(resp, "getting valid access token", http.StatusUnauthorized)
+			return nil, false
+		}
+
+		token, err := auth.verifier.Verify(req.Context(), rawToken.AccessToken)
+		if err != nil {
+			logger.Error("verifying access token", "error", err)
+			http.Error(resp, "verifying access token", http.StatusUnauthorized)
+			return nil, false
+		}
+
+		var tokenClaims Claims
+		if err := token.Claims(&tokenClaims); err != nil {
+			logger.Error("parsing claims", "error", err)
+			http.Error(resp, "parsing claims", http.StatusInternalServerError)
+			return nil, false
+		}
+
+		// Refresh cookies if token was refreshed
+		if prevTokenCookie == nil || prevTokenCookie.Value != rawToken.AccessToken {
+			http.SetCookie(
+				resp, &http.Cookie{
+					Name:     "auth-token",
+					Value:    rawToken.AccessToken,
+					Path:     "/",
+					Expires:  rawToken.Expiry,
+					HttpOnly: true,
+					Secure:   true,
+					SameSite: http.SameSiteStrictMode,
+				},
+			)
+
+			http.SetCookie(
+				resp, &http.Cookie{
+					Name:     "refresh-token",
+					Value:    rawToken.RefreshToken,
+					Path:     "/",
+					Expires:  time.Now().Add(7 * 24 * time.Hour),
+					HttpOnly: true,
+					Secure:   true,
+					SameSite: http.SameSiteStrictMode,
+				},
+			)
+		}
+
+		userId, err := auth.UserService.UpsertOidcUser(req.Context(), tokenClaims.Sub, tokenClaims.Email, tokenClaims.Username)
+		if err != nil {
+			logger.Error("upserting oidc user", "error", err)
+			resp.WriteHeader(http.StatusInternalServerError)
+			return nil, false
+		}
+
+		sessionToken, err := auth.generateSessionToken(userId, tokenClaims.Email, shared.AuthMethodOidc)
+		if err != nil {
+			logger.Error("generating session token", "error", err)
+			resp.WriteHeader(http.StatusInternalServerError)
+			return nil, false
+		}
+
+		http.SetCookie(resp, &http.Cookie{
+			Name:     "session-token",
+			Value:    sessionToken,
+			Path:     "/",
+			Expires:  time.Now().Add(7 * 24 * time.Hour),
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		user = &shared.User{
+			ID:         userId,
+			Email:      tokenClaims.Email,
+			AuthMethod: shared.AuthMethodOidc,
+		}
+	}
+
+	return user, true
+}
+
+// requireUser only lets authenticated requests through to next, with the user
+// and a logger carrying their email in the request context.
+func (auth *Auth) requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		user, ok := auth.authenticate(resp, req)
+		if !ok {
+			return
+		}
+
+		logger := logging.FromContext(req.Context()).With("user", user.Email)
+
+		augmentedCtx := shared.NewContext(req.Context(), user)
+		next.ServeHTTP(resp, req.WithContext(logging.WithLogger(augmentedCtx, logger)))
+	})
+}
+
 func (auth *Auth) TokenSourceFromCookies(
 	ctx context.Context,
 	getCookie func(name string) (*http.Cookie, error),
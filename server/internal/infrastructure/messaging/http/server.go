@@ -2,7 +2,6 @@ package http
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -11,12 +10,10 @@ import (
 	"strings"
 	"time"
 
-	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/google/uuid"
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	"google.golang.org/grpc"
 
-	"chagnon.dev/budget-server/internal/infrastructure/messaging/shared"
 	"chagnon.dev/budget-server/internal/logging"
 )
 
@@ -26,16 +23,18 @@ const webDirectory = "/app/static/web"
 type GrpcWebServer struct {
 	wrappedGrpc *grpcweb.WrappedGrpcServer
 	auth        *Auth
+	attachments *Attachments
 }
 
 func (s *GrpcWebServer) Stop() {
 	panic("implement me")
 }
 
-func NewServer(grpcServer *grpc.Server, auth *Auth) *GrpcWebServer {
+func NewServer(grpcServer *grpc.Server, auth *Auth, attachments *Attachments) *GrpcWebServer {
 	return &GrpcWebServer{
 		wrappedGrpc: grpcweb.WrapServer(grpcServer),
 		auth:        auth,
+		attachments: attachments,
 	}
 }
 
@@ -44,6 +43,7 @@ func (s *GrpcWebServer) Serve(ctx context.Context) error {
 
 	mux.Handle("/auth/", http.StripPrefix("/auth", s.auth.ServeMux()))
 	mux.Handle("/api/", http.StripPrefix("/api", s.wrappedGrpc))
+	mux.Handle("/attachments/", http.StripPrefix("/attachments", s.auth.requireUser(s.attachments.ServeMux())))
 	mux.HandleFunc("/", s.catchAllHandler)
 
 	httpServer := &http.Server{
@@ -57,106 +57,7 @@ func (s *GrpcWebServer) Serve(ctx context.Context) error {
 
 func (s *GrpcWebServer) catchAllHandler(resp http.ResponseWriter, req *http.Request) {
 	if s.wrappedGrpc.IsGrpcWebRequest(req) {
-		logger := logging.FromContext(req.Context())
-
-		user, err := s.auth.parseSessionToken(req.Cookie)
-		if err != nil {
-			if !errors.Is(err, jwt.ErrInvalidContentType) {
-				logger.DebugContext(req.Context(), "parsing session token", "error", err)
-			}
-
-			// Fall back to OIDC token verification
-			tokenSource, prevTokenCookie, err := s.auth.TokenSourceFromCookies(req.Context(), req.Cookie)
-			if err != nil {
-				logger.Error("reading tokens from request", "error", err)
-				http.Error(resp, "reading tokens from request", http.StatusInternalServerError)
-				return
-			}
-
-			rawToken, err := tokenSource.Token()
-			if err != nil {
-				logger.Error("getting valid access token", "error", err)
-				http.Error(resp, "getting valid access token", http.StatusUnauthorized)
-				return
-			}
-
-			token, err := s.auth.verifier.Verify(req.Context(), rawToken.AccessToken)
-			if err != nil {
-				logger.Error("verifying access token", "error", err)
-				http.Error(resp, "verifying access token", http.StatusUnauthorized)
-				return
-			}
-
-			var tokenClaims Claims
-			if err := token.Claims(&tokenClaims); err != nil {
-				logger.Error("parsing claims", "error", err)
-				http.Error(resp, "parsing claims", http.StatusInternalServerError)
-				return
-			}
-
-			// Refresh cookies if token was refreshed
-			if prevTokenCookie == nil || prevTokenCookie.Value != rawToken.AccessToken {
-				http.SetCookie(
-					resp, &http.Cookie{
-						Name:     "auth-token",
-						Value:    rawToken.AccessToken,
-						Path:     "/",
-						Expires:  rawToken.Expiry,
-						HttpOnly: true,
-						Secure:   true,
-						SameSite: http.SameSiteStrictMode,
-					},
-				)
-
-				http.SetCookie(
-					resp, &http.Cookie{
-						Name:     "refresh-token",
-						Value:    rawToken.RefreshToken,
-						Path:     "/",
-						Expires:  time.Now().Add(7 * 24 * time.Hour),
-						HttpOnly: true,
-						Secure:   true,
-						SameSite: http.SameSiteStrictMode,
-					},
-				)
-			}
-
-			userId, err := s.auth.UserService.UpsertOidcUser(req.Context(), tokenClaims.Sub, tokenClaims.Email, tokenClaims.Username)
-			if err != nil {
-				logger.Error("upserting oidc user", "error", err)
-				resp.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-
-			sessionToken, err := s.auth.generateSessionToken(userId, tokenClaims.Email, shared.AuthMethodOidc)
-			if err != nil {
-				logger.Error("generating session token", "error", err)
-				resp.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-
-			http.SetCookie(resp, &http.Cookie{
-				Name:     "session-token",
-				Value:    sessionToken,
-				Path:     "/",
-				Expires:  time.Now().Add(7 * 24 * time.Hour),
-				HttpOnly: true,
-				Secure:   true,
-				SameSite: http.SameSiteStrictMode,
-			})
-
-			user = &shared.User{
-				ID:         userId,
-				Email:      tokenClaims.Email,
-				AuthMethod: shared.AuthMethodOidc,
-			}
-		}
-
-		logger = logger.With("user", user.Email)
-
-		augmentedCtx := shared.NewContext(req.Context(), user)
-		augmentedReq := req.WithContext(logging.WithLogger(augmentedCtx, logger))
-		s.wrappedGrpc.ServeHTTP(resp, augmentedReq)
+		s.auth.requireUser(s.wrappedGrpc).ServeHTTP(resp, req)
 		return
 	}
 
@@ -200,6 +101,19 @@ func (lrw *LoggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// Flush passes flushes on, which grpc-web needs to send the messages of a
+// stream as they come instead of when it ends.
+func (lrw *LoggingResponseWriter) Flush() {
+	if flusher, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (lrw *LoggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
 func withLogging(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,101 @@
+package recurring
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chagnon.dev/budget-server/internal/domain/model"
+	"chagnon.dev/budget-server/internal/infrastructure/db/repository"
+	"chagnon.dev/budget-server/internal/logging"
+)
+
+// maxOccurrencesPerRun bounds the back-fill of a single rule in one run, so
+// that a daily rule started years ago cannot stall the scheduler. The rest is
+// picked up by the following runs.
+const maxOccurrencesPerRun = 500
+
+type recurringTransactionRepository interface {
+	GetDueRecurringTransactions(ctx context.Context, now time.Time) ([]repository.DueRecurringTransaction, error)
+	MaterializeRecurringTransactionOccurrence(
+		ctx context.Context,
+		recurringTransaction repository.DueRecurringTransaction,
+		occurrence time.Time,
+	) (bool, error)
+	AdvanceRecurringTransaction(ctx context.Context, id model.RecurringTransactionID, materializedThrough time.Time) error
+}
+
+// Materializer turns the due occurrences of recurring transactions into
+// transactions, back-filling the ones missed while the server was down.
+type Materializer struct {
+	recurringTransactionRepository recurringTransactionRepository
+}
+
+func NewMaterializer(recurringTransactionRepository recurringTransactionRepository) *Materializer {
+	return &Materializer{recurringTransactionRepository: recurringTransactionRepository}
+}
+
+func (m *Materializer) NewRunner(ctx context.Context) func() error {
+	return func() error {
+		logger := logging.FromContext(ctx)
+		now := time.Now()
+
+		recurringTransactions, err := m.recurringTransactionRepository.GetDueRecurringTransactions(ctx, now)
+		if err != nil {
+			return fmt.Errorf("fetching due recurring transactions: %s", err)
+		}
+
+		for _, recurringTransaction := range recurringTransactions {
+			logger := logger.With("recurringTransactionID", recurringTransaction.ID)
+
+			// Occurrences fall on the days of the owner's timezone.
+			today := truncateToDate(now.In(recurringTransaction.Location))
+
+			created, err := m.materialize(ctx, recurringTransaction, today)
+			if err != nil {
+				logger.Error("materializing recurring transaction", "error", err)
+				continue
+			}
+
+			if created > 0 {
+				logger.Info("materialized recurring transaction", "created", created)
+			}
+		}
+
+		return nil
+	}
+}
+
+func (m *Materializer) materialize(ctx context.Context, recurringTransaction repository.DueRecurringTransaction, today time.Time) (int, error) {
+	schedule, err := ParseSchedule(recurringTransaction.Schedule, recurringTransaction.StartsOn)
+	if err != nil {
+		return 0, err
+	}
+
+	after := recurringTransaction.MaterializedThrough.ValueOr(recurringTransaction.StartsOn.AddDate(0, 0, -1))
+	until := today
+	if endsOn, isSome := recurringTransaction.EndsOn.Value(); isSome && endsOn.Before(until) {
+		until = endsOn
+	}
+
+	occurrences := schedule.Occurrences(after, until, maxOccurrencesPerRun)
+
+	created := 0
+	for _, occurrence := range occurrences {
+		isNew, err := m.recurringTransactionRepository.MaterializeRecurringTransactionOccurrence(ctx, recurringTransaction, occurrence)
+		if err != nil {
+			return created, fmt.Errorf("materializing occurrence of %s: %w", occurrence.Format(time.DateOnly), err)
+		}
+		if isNew {
+			created++
+		}
+	}
+
+	if len(occurrences) < maxOccurrencesPerRun {
+		if err := m.recurringTransactionRepository.AdvanceRecurringTransaction(ctx, recurringTransaction.ID, until); err != nil {
+			return created, err
+		}
+	}
+
+	return created, nil
+}
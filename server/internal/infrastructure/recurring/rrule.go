@@ -0,0 +1,298 @@
+package recurring
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rrule implements the subset of RFC 5545 recurrence rules that makes sense
+// for day-granular transactions: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY),
+// INTERVAL, COUNT, UNTIL, BYDAY (except for YEARLY, with ordinals for
+// MONTHLY, e.g. -1FR) and BYMONTHDAY (negative values count from the end of
+// the month). When both BYDAY and BYMONTHDAY are given, only the month days
+// falling on one of the weekdays match. Weeks start on Monday.
+type rrule struct {
+	dtstart    time.Time
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []weekdayNum
+	byMonthDay []int
+}
+
+type weekdayNum struct {
+	ordinal int // 0 means every such weekday of the period
+	weekday time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// maxPeriods bounds the search for rules that can never match, such as
+// BYMONTHDAY=31 on a yearly rule starting on the 30th.
+const maxPeriods = 100_000
+
+func parseRRule(expr string, dtstart time.Time) (*rrule, error) {
+	rule := &rrule{dtstart: dtstart, interval: 1}
+
+	for _, part := range strings.Split(expr, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("malformed part %q", part)
+		}
+		key = strings.ToUpper(key)
+		value = strings.ToUpper(value)
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				rule.freq = value
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			rule.interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", value)
+			}
+			rule.count = count
+		case "UNTIL":
+			until, err := parseRRuleDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q: %w", value, err)
+			}
+			rule.until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[day[max(len(day)-2, 0):]]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY %q", day)
+				}
+
+				ordinal := 0
+				if prefix := day[:len(day)-2]; prefix != "" {
+					var err error
+					ordinal, err = strconv.Atoi(prefix)
+					if err != nil || ordinal == 0 || ordinal < -5 || ordinal > 5 {
+						return nil, fmt.Errorf("invalid BYDAY %q", day)
+					}
+				}
+
+				rule.byDay = append(rule.byDay, weekdayNum{ordinal: ordinal, weekday: weekday})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %q", day)
+				}
+				rule.byMonthDay = append(rule.byMonthDay, monthDay)
+			}
+		case "WKST":
+			if value != "MO" {
+				return nil, fmt.Errorf("only WKST=MO is supported")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported part %s", key)
+		}
+	}
+
+	if rule.freq == "" {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if rule.count > 0 && !rule.until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL are mutually exclusive")
+	}
+	if rule.freq != "MONTHLY" && rule.freq != "YEARLY" && len(rule.byMonthDay) > 0 {
+		return nil, fmt.Errorf("BYMONTHDAY requires FREQ=MONTHLY or FREQ=YEARLY")
+	}
+	if rule.freq == "YEARLY" && len(rule.byDay) > 0 {
+		// Yearly BYDAY expands over the whole year, which is not worth
+		// supporting for transactions.
+		return nil, fmt.Errorf("BYDAY is not supported with FREQ=YEARLY")
+	}
+	for _, day := range rule.byDay {
+		if day.ordinal != 0 && rule.freq != "MONTHLY" {
+			return nil, fmt.Errorf("BYDAY ordinals require FREQ=MONTHLY")
+		}
+	}
+
+	return rule, nil
+}
+
+func parseRRuleDate(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return truncateToDate(date), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("expected YYYYMMDD or YYYYMMDDTHHMMSSZ")
+}
+
+func (r *rrule) Occurrences(after, until time.Time, limit int) []time.Time {
+	after = truncateToDate(after)
+	until = truncateToDate(until)
+	if !r.until.IsZero() && r.until.Before(until) {
+		until = r.until
+	}
+
+	occurrences := make([]time.Time, 0)
+	// COUNT is relative to DTSTART, so the expansion always starts there.
+	seen := 0
+	for period := 0; period < maxPeriods; period++ {
+		candidates := r.expand(period)
+		if len(candidates) == 0 {
+			continue
+		}
+
+		for _, date := range candidates {
+			if date.Before(r.dtstart) {
+				continue
+			}
+			if date.After(until) {
+				return occurrences
+			}
+
+			seen++
+			if r.count > 0 && seen > r.count {
+				return occurrences
+			}
+
+			if date.After(after) {
+				occurrences = append(occurrences, date)
+				if len(occurrences) >= limit {
+					return occurrences
+				}
+			}
+		}
+	}
+
+	return occurrences
+}
+
+// expand returns the sorted candidate dates of the n-th period (day, week,
+// month or year, depending on FREQ) since DTSTART.
+func (r *rrule) expand(n int) []time.Time {
+	step := n * r.interval
+
+	switch r.freq {
+	case "DAILY":
+		date := r.dtstart.AddDate(0, 0, step)
+		if len(r.byDay) > 0 && !slices.ContainsFunc(r.byDay, func(d weekdayNum) bool { return d.weekday == date.Weekday() }) {
+			return nil
+		}
+		return []time.Time{date}
+
+	case "WEEKLY":
+		weekStart := r.dtstart.AddDate(0, 0, -((int(r.dtstart.Weekday())+6)%7)+7*step)
+		if len(r.byDay) == 0 {
+			return []time.Time{weekStart.AddDate(0, 0, (int(r.dtstart.Weekday())+6)%7)}
+		}
+
+		dates := make([]time.Time, 0, len(r.byDay))
+		for _, day := range r.byDay {
+			dates = append(dates, weekStart.AddDate(0, 0, (int(day.weekday)+6)%7))
+		}
+		return sortedUnique(dates)
+
+	case "MONTHLY":
+		monthStart := time.Date(r.dtstart.Year(), r.dtstart.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		return r.expandMonth(monthStart)
+
+	case "YEARLY":
+		year := r.dtstart.Year() + step
+		if len(r.byMonthDay) == 0 {
+			date := time.Date(year, r.dtstart.Month(), r.dtstart.Day(), 0, 0, 0, 0, time.UTC)
+			if date.Month() != r.dtstart.Month() {
+				// Skips February 29th on non-leap years, as RFC 5545 does.
+				return nil
+			}
+			return []time.Time{date}
+		}
+		return r.expandMonth(time.Date(year, r.dtstart.Month(), 1, 0, 0, 0, 0, time.UTC))
+	}
+
+	return nil
+}
+
+func (r *rrule) expandMonth(monthStart time.Time) []time.Time {
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+
+	if len(r.byMonthDay) == 0 && len(r.byDay) == 0 {
+		if r.dtstart.Day() > daysInMonth {
+			return nil
+		}
+		return []time.Time{monthStart.AddDate(0, 0, r.dtstart.Day()-1)}
+	}
+
+	monthDays := make([]time.Time, 0, len(r.byMonthDay))
+	for _, monthDay := range r.byMonthDay {
+		if monthDay < 0 {
+			monthDay = daysInMonth + monthDay + 1
+		}
+		if monthDay < 1 || monthDay > daysInMonth {
+			continue
+		}
+		monthDays = append(monthDays, monthStart.AddDate(0, 0, monthDay-1))
+	}
+
+	dates := make([]time.Time, 0)
+	for _, day := range r.byDay {
+		matching := make([]time.Time, 0, 5)
+		for date := monthStart; date.Month() == monthStart.Month(); date = date.AddDate(0, 0, 1) {
+			if date.Weekday() == day.weekday {
+				matching = append(matching, date)
+			}
+		}
+
+		switch {
+		case day.ordinal == 0:
+			dates = append(dates, matching...)
+		case day.ordinal > 0 && day.ordinal <= len(matching):
+			dates = append(dates, matching[day.ordinal-1])
+		case day.ordinal < 0 && -day.ordinal <= len(matching):
+			dates = append(dates, matching[len(matching)+day.ordinal])
+		}
+	}
+
+	switch {
+	case len(r.byDay) == 0:
+		dates = monthDays
+	case len(r.byMonthDay) > 0:
+		// BYDAY limits BYMONTHDAY instead of adding to it.
+		dates = slices.DeleteFunc(monthDays, func(date time.Time) bool {
+			return !slices.ContainsFunc(dates, date.Equal)
+		})
+	}
+
+	return sortedUnique(dates)
+}
+
+func sortedUnique(dates []time.Time) []time.Time {
+	slices.SortFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(dates, func(a, b time.Time) bool { return a.Equal(b) })
+}
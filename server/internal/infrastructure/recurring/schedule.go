@@ -0,0 +1,90 @@
+package recurring
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule yields the dates on which a recurring transaction occurs. Only the
+// date matters: a cron expression firing several times a day still yields a
+// single occurrence for that day.
+type Schedule interface {
+	// Occurrences returns, in order, at most limit occurrence dates strictly
+	// after `after` and up to and including `until`.
+	Occurrences(after, until time.Time, limit int) []time.Time
+}
+
+// ParseSchedule accepts either a standard five-field cron expression (or a
+// descriptor such as @monthly) or an RFC 5545 RRULE, with or without its
+// "RRULE:" prefix. Occurrences never fall before startsOn, which is also the
+// DTSTART of an RRULE.
+func ParseSchedule(expr string, startsOn time.Time) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	startsOn = truncateToDate(startsOn)
+
+	if strings.HasPrefix(strings.ToUpper(expr), "RRULE:") || strings.Contains(strings.ToUpper(expr), "FREQ=") {
+		rule, err := parseRRule(strings.TrimPrefix(strings.TrimPrefix(expr, "RRULE:"), "rrule:"), startsOn)
+		if err != nil {
+			return nil, fmt.Errorf("parsing rrule: %w", err)
+		}
+		return rule, nil
+	}
+
+	// Occurrences are plain dates, already taken in the owner's timezone, so
+	// the expression is evaluated in UTC unless it pins its own time zone.
+	if !strings.HasPrefix(expr, "CRON_TZ=") && !strings.HasPrefix(expr, "TZ=") {
+		expr = "CRON_TZ=UTC " + expr
+	}
+
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cron expression: %w", err)
+	}
+
+	return &cronSchedule{schedule: schedule, startsOn: startsOn}, nil
+}
+
+type cronSchedule struct {
+	schedule cron.Schedule
+	startsOn time.Time
+}
+
+func (c *cronSchedule) Occurrences(after, until time.Time, limit int) []time.Time {
+	after = truncateToDate(after)
+	until = truncateToDate(until)
+	if before := c.startsOn.AddDate(0, 0, -1); after.Before(before) {
+		after = before
+	}
+
+	occurrences := make([]time.Time, 0)
+	// Searching from the last instant of a day skips whatever else fires that
+	// day, which keeps occurrences one per date.
+	cursor := endOfDay(after)
+	for len(occurrences) < limit {
+		next := c.schedule.Next(cursor)
+		if next.IsZero() {
+			break
+		}
+
+		date := truncateToDate(next)
+		if date.After(until) {
+			break
+		}
+
+		occurrences = append(occurrences, date)
+		cursor = endOfDay(date)
+	}
+
+	return occurrences
+}
+
+func truncateToDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func endOfDay(date time.Time) time.Time {
+	return date.AddDate(0, 0, 1).Add(-time.Second)
+}
@@ -0,0 +1,41 @@
+package trash
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chagnon.dev/budget-server/internal/logging"
+)
+
+type transactionRepository interface {
+	PurgeTrashedTransactions(ctx context.Context, trashedBefore time.Time) (int, error)
+}
+
+// Purger permanently deletes the transactions that have been sitting in the
+// trash for longer than the retention period.
+type Purger struct {
+	transactionRepository transactionRepository
+	retention             time.Duration
+}
+
+func NewPurger(transactionRepository transactionRepository, retention time.Duration) *Purger {
+	return &Purger{
+		transactionRepository: transactionRepository,
+		retention:             retention,
+	}
+}
+
+func (p *Purger) NewRunner(ctx context.Context) func() error {
+	return func() error {
+		logger := logging.FromContext(ctx)
+
+		purged, err := p.transactionRepository.PurgeTrashedTransactions(ctx, time.Now().Add(-p.retention))
+		if err != nil {
+			return fmt.Errorf("purging trashed transactions: %s", err)
+		}
+
+		logger.Info("Purged trashed transactions", "count", purged, "retention", p.retention.String())
+		return nil
+	}
+}
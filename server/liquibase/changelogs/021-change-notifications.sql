@@ -0,0 +1,155 @@
+-- liquibase formatted sql
+
+-- changeset ?:1765800000000-1 splitStatements:false
+CREATE OR REPLACE FUNCTION notify_change(entity TEXT, op TEXT, entity_id INTEGER, user_ids TEXT[], emails TEXT[], extra JSONB DEFAULT '{}'::JSONB) RETURNS VOID AS $$
+BEGIN
+    IF COALESCE(array_length(user_ids, 1), 0) = 0 AND COALESCE(array_length(emails, 1), 0) = 0 THEN
+        RETURN;
+    END IF;
+
+    PERFORM pg_notify('budgeteer_changes', (jsonb_build_object(
+        'entity', entity,
+        'op', lower(op),
+        'id', entity_id,
+        'user_ids', to_jsonb(user_ids),
+        'emails', to_jsonb(emails)
+    ) || extra)::TEXT);
+END;
+$$ LANGUAGE plpgsql;
+
+-- changeset ?:1765800000000-2 splitStatements:false
+CREATE OR REPLACE FUNCTION notify_owned_row_change() RETURNS TRIGGER AS $$
+DECLARE
+    row_data JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := to_jsonb(OLD);
+    ELSE
+        row_data := to_jsonb(NEW);
+    END IF;
+
+    PERFORM notify_change(TG_ARGV[0], TG_OP, (row_data ->> 'id')::INTEGER, ARRAY[row_data ->> 'user_id'], ARRAY[]::TEXT[]);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "accounts_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "accounts"
+    FOR EACH ROW EXECUTE FUNCTION notify_owned_row_change('account');
+CREATE TRIGGER "categories_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "categories"
+    FOR EACH ROW EXECUTE FUNCTION notify_owned_row_change('category');
+CREATE TRIGGER "currencies_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "currencies"
+    FOR EACH ROW EXECUTE FUNCTION notify_owned_row_change('currency');
+CREATE TRIGGER "transactions_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "transactions"
+    FOR EACH ROW EXECUTE FUNCTION notify_owned_row_change('transaction');
+
+-- changeset ?:1765800000000-3 splitStatements:false
+CREATE OR REPLACE FUNCTION notify_exchange_rate_change() RETURNS TRIGGER AS $$
+DECLARE
+    row_data "exchangerates";
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := OLD;
+    ELSE
+        row_data := NEW;
+    END IF;
+
+    PERFORM notify_change(
+        'exchange_rate', TG_OP, row_data.id,
+        ARRAY(SELECT DISTINCT c.user_id FROM "currencies" c WHERE c.id IN (row_data.a, row_data.b)),
+        ARRAY[]::TEXT[],
+        jsonb_build_object('a', row_data.a, 'b', row_data.b, 'date', row_data.date)
+    );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "exchangerates_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "exchangerates"
+    FOR EACH ROW EXECUTE FUNCTION notify_exchange_rate_change();
+
+-- changeset ?:1765800000000-4 splitStatements:false
+-- Group members other than the owner see a shared transaction through its
+-- link to the group, so linking, relinking and unlinking are reported to them
+-- as the transaction appearing, changing or disappearing. The owner is already
+-- notified by the trigger on "transactions".
+CREATE OR REPLACE FUNCTION transaction_group_member_emails(group_id INTEGER, transaction_id INTEGER) RETURNS TEXT[] AS $$
+    SELECT ARRAY(
+        SELECT utg.user_email
+        FROM "user_transaction_group" utg
+        WHERE utg.transaction_group_id = group_id
+          AND utg.user_email IS DISTINCT FROM (
+              SELECT u.email FROM "transactions" t JOIN "users" u ON u.id = t.user_id WHERE t.id = transaction_id
+          )
+    );
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION notify_transaction_group_link_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.transaction_group_id = NEW.transaction_group_id THEN
+        PERFORM notify_change('transaction', 'UPDATE', NEW.transaction_id,
+            ARRAY[]::TEXT[], transaction_group_member_emails(NEW.transaction_group_id, NEW.transaction_id));
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM notify_change('transaction', 'DELETE', OLD.transaction_id,
+            ARRAY[]::TEXT[], transaction_group_member_emails(OLD.transaction_group_id, OLD.transaction_id));
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'INSERT') THEN
+        PERFORM notify_change('transaction', 'INSERT', NEW.transaction_id,
+            ARRAY[]::TEXT[], transaction_group_member_emails(NEW.transaction_group_id, NEW.transaction_id));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "transaction_transaction_group_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "transaction_transaction_group"
+    FOR EACH ROW EXECUTE FUNCTION notify_transaction_group_link_change();
+
+-- changeset ?:1765800000000-5 splitStatements:false
+CREATE OR REPLACE FUNCTION notify_transaction_group_change() RETURNS TRIGGER AS $$
+DECLARE
+    group_id INTEGER;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        group_id := OLD.id;
+    ELSE
+        group_id := NEW.id;
+    END IF;
+
+    PERFORM notify_change('transaction_group', TG_OP, group_id, ARRAY[]::TEXT[],
+        ARRAY(SELECT utg.user_email FROM "user_transaction_group" utg WHERE utg.transaction_group_id = group_id));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "transaction_group_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "transaction_group"
+    FOR EACH ROW EXECUTE FUNCTION notify_transaction_group_change();
+
+-- A membership change alters the group for everyone in it, while the member
+-- who joined or left sees the whole group appear or disappear.
+CREATE OR REPLACE FUNCTION notify_transaction_group_membership_change() RETURNS TRIGGER AS $$
+DECLARE
+    membership "user_transaction_group";
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        membership := OLD;
+    ELSE
+        membership := NEW;
+    END IF;
+
+    PERFORM notify_change('transaction_group', 'UPDATE', membership.transaction_group_id, ARRAY[]::TEXT[],
+        ARRAY(
+            SELECT utg.user_email
+            FROM "user_transaction_group" utg
+            WHERE utg.transaction_group_id = membership.transaction_group_id
+              AND utg.user_email <> membership.user_email
+        ));
+    PERFORM notify_change('transaction_group', TG_OP, membership.transaction_group_id, ARRAY[]::TEXT[],
+        ARRAY[membership.user_email]);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "user_transaction_group_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "user_transaction_group"
+    FOR EACH ROW EXECUTE FUNCTION notify_transaction_group_membership_change();
@@ -0,0 +1,45 @@
+-- liquibase formatted sql
+
+-- changeset ?:1766000000000-1
+ALTER TABLE "transactions" ADD "deleted_at" TIMESTAMP WITH TIME ZONE;
+CREATE INDEX "transactions_user_id_deleted_at_index" ON "transactions"("user_id", "deleted_at") WHERE "deleted_at" IS NOT NULL;
+
+-- changeset ?:1766000000000-2 splitStatements:false
+-- Moving a transaction to the trash or restoring it is an update of the row,
+-- but clients see it as the transaction disappearing or reappearing, and so
+-- do the members of its group.
+CREATE OR REPLACE FUNCTION notify_transaction_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.deleted_at IS DISTINCT FROM NEW.deleted_at THEN
+        PERFORM notify_change(
+            'transaction',
+            CASE WHEN NEW.deleted_at IS NULL THEN 'INSERT' ELSE 'DELETE' END,
+            NEW.id,
+            ARRAY[NEW.user_id],
+            ARRAY(
+                SELECT utg.user_email
+                FROM "transaction_transaction_group" ttg
+                    JOIN "user_transaction_group" utg ON utg.transaction_group_id = ttg.transaction_group_id
+                WHERE ttg.transaction_id = NEW.id
+            )
+        );
+        RETURN NULL;
+    END IF;
+
+    -- Purging from the trash: clients were told when it was trashed.
+    IF TG_OP = 'DELETE' AND OLD.deleted_at IS NOT NULL THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        PERFORM notify_change('transaction', TG_OP, OLD.id, ARRAY[OLD.user_id], ARRAY[]::TEXT[]);
+    ELSE
+        PERFORM notify_change('transaction', TG_OP, NEW.id, ARRAY[NEW.user_id], ARRAY[]::TEXT[]);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "transactions_notify_change" ON "transactions";
+CREATE TRIGGER "transactions_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "transactions"
+    FOR EACH ROW EXECUTE FUNCTION notify_transaction_change();
@@ -0,0 +1,16 @@
+-- liquibase formatted sql
+
+-- changeset ?:1766200000000-1
+CREATE EXTENSION IF NOT EXISTS "unaccent";
+
+-- changeset ?:1766200000000-2
+-- French stemming on accent-folded words, so that "epicerie" finds
+-- "Épiceries". Being a regconfig rather than a function call, it keeps
+-- to_tsvector immutable and therefore indexable.
+CREATE TEXT SEARCH CONFIGURATION "budgeteer_search" (COPY = french);
+ALTER TEXT SEARCH CONFIGURATION "budgeteer_search"
+    ALTER MAPPING FOR hword, hword_part, word WITH unaccent, french_stem;
+
+-- changeset ?:1766200000000-3
+CREATE INDEX "transactions_note_search_index" ON "transactions"
+    USING GIN (to_tsvector('budgeteer_search', "note"));
@@ -0,0 +1,43 @@
+-- liquibase formatted sql
+
+-- changeset ?:1766400000000-1
+CREATE TABLE "recurring_transactions" (
+    "id" INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "amount" INTEGER NOT NULL,
+    "currency" INTEGER NOT NULL,
+    "sender" INTEGER,
+    "receiver" INTEGER,
+    "category" INTEGER,
+    "note" TEXT NOT NULL,
+    "receiver_currency" INTEGER NOT NULL,
+    "receiver_amount" INTEGER NOT NULL,
+    "schedule" TEXT NOT NULL,
+    "starts_on" DATE NOT NULL,
+    "ends_on" DATE,
+    "paused" BOOLEAN NOT NULL DEFAULT FALSE,
+    "materialized_through" DATE,
+    CONSTRAINT "recurring_transactions_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "recurring_transactions_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "recurring_transactions_currency_fkey" FOREIGN KEY ("currency") REFERENCES "currencies" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "recurring_transactions_receiver_currency_fkey" FOREIGN KEY ("receiver_currency") REFERENCES "currencies" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "recurring_transactions_sender_fkey" FOREIGN KEY ("sender") REFERENCES "accounts" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "recurring_transactions_receiver_fkey" FOREIGN KEY ("receiver") REFERENCES "accounts" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "recurring_transactions_category_fkey" FOREIGN KEY ("category") REFERENCES "categories" ("id") ON UPDATE NO ACTION ON DELETE SET NULL
+);
+CREATE INDEX "recurring_transactions_user_id_index" ON "recurring_transactions"("user_id");
+
+-- changeset ?:1766400000000-2
+-- One row per materialized occurrence. Claiming the row and creating the
+-- transaction happen in the same database transaction, so an occurrence is
+-- generated at most once even if the scheduler restarts midway. The row
+-- outlives the transaction it produced so that deleting that transaction does
+-- not make the scheduler generate it again.
+CREATE TABLE "recurring_transaction_occurrences" (
+    "recurring_transaction_id" INTEGER NOT NULL,
+    "occurrence_date" DATE NOT NULL,
+    "transaction_id" INTEGER,
+    CONSTRAINT "recurring_transaction_occurrences_pkey" PRIMARY KEY ("recurring_transaction_id", "occurrence_date"),
+    CONSTRAINT "recurring_transaction_occurrences_recurring_transaction_id_fkey" FOREIGN KEY ("recurring_transaction_id") REFERENCES "recurring_transactions" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "recurring_transaction_occurrences_transaction_id_fkey" FOREIGN KEY ("transaction_id") REFERENCES "transactions" ("id") ON UPDATE NO ACTION ON DELETE SET NULL
+);
@@ -0,0 +1,16 @@
+-- liquibase formatted sql
+
+-- changeset ?:1766600000000-1
+-- Splits a transaction across several categories. When a transaction has line
+-- items, their amounts add up to the transaction amount; the application
+-- enforces this when it writes them.
+CREATE TABLE "transaction_line_items" (
+    "transaction_id" INTEGER NOT NULL,
+    "position" INTEGER NOT NULL,
+    "category" INTEGER,
+    "amount" INTEGER NOT NULL,
+    "note" TEXT NOT NULL DEFAULT '',
+    CONSTRAINT "transaction_line_items_pkey" PRIMARY KEY ("transaction_id", "position"),
+    CONSTRAINT "transaction_line_items_transaction_id_fkey" FOREIGN KEY ("transaction_id") REFERENCES "transactions" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_line_items_category_fkey" FOREIGN KEY ("category") REFERENCES "categories" ("id") ON UPDATE NO ACTION ON DELETE SET NULL
+);
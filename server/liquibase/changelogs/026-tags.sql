@@ -0,0 +1,25 @@
+-- liquibase formatted sql
+
+-- changeset ?:1766800000000-1
+CREATE TABLE "tags" (
+    "id" INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    CONSTRAINT "tags_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "tags_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "tags_user_id_name_index" ON "tags"("user_id", lower("name"));
+
+-- changeset ?:1766800000000-2
+CREATE TABLE "transaction_tags" (
+    "transaction_id" INTEGER NOT NULL,
+    "tag_id" INTEGER NOT NULL,
+    CONSTRAINT "transaction_tags_pkey" PRIMARY KEY ("transaction_id", "tag_id"),
+    CONSTRAINT "transaction_tags_transaction_id_fkey" FOREIGN KEY ("transaction_id") REFERENCES "transactions" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_tags_tag_id_fkey" FOREIGN KEY ("tag_id") REFERENCES "tags" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE INDEX "transaction_tags_tag_id_index" ON "transaction_tags"("tag_id");
+
+-- changeset ?:1766800000000-3 splitStatements:false
+CREATE TRIGGER "tags_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "tags"
+    FOR EACH ROW EXECUTE FUNCTION notify_owned_row_change('tag');
@@ -0,0 +1,25 @@
+-- liquibase formatted sql
+
+-- changeset ?:1767000000000-1
+-- Metadata of the files attached to transactions. The bytes live in the blob
+-- store under "storage_key". Purging a transaction only detaches its files;
+-- the attachment sweeper then deletes the blobs before deleting the rows, so
+-- a row always exists for every blob it has to clean up.
+CREATE TABLE "transaction_attachments" (
+    "id" INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "transaction_id" INTEGER,
+    "name" TEXT NOT NULL,
+    "mime_type" TEXT NOT NULL,
+    "size" BIGINT NOT NULL,
+    "sha256" TEXT NOT NULL,
+    "storage_key" TEXT NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT "transaction_attachments_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "transaction_attachments_storage_key_key" UNIQUE ("storage_key"),
+    CONSTRAINT "transaction_attachments_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_attachments_transaction_id_fkey" FOREIGN KEY ("transaction_id") REFERENCES "transactions" ("id") ON UPDATE NO ACTION ON DELETE SET NULL
+);
+CREATE INDEX "transaction_attachments_transaction_id_index" ON "transaction_attachments"("transaction_id");
+CREATE INDEX "transaction_attachments_user_id_index" ON "transaction_attachments"("user_id");
+CREATE INDEX "transaction_attachments_detached_index" ON "transaction_attachments"("id") WHERE "transaction_id" IS NULL;
@@ -0,0 +1,28 @@
+-- liquibase formatted sql
+
+-- changeset ?:1767200000000-1
+CREATE TABLE "transaction_history" (
+    "id" BIGINT GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    "transaction_id" INTEGER NOT NULL,
+    "changed_by" TEXT,
+    "changed_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    "action" TEXT NOT NULL,
+    "changes" JSONB NOT NULL DEFAULT '[]'::jsonb,
+    CONSTRAINT "transaction_history_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "transaction_history_action_check" CHECK ("action" IN ('created', 'updated', 'deleted', 'restored')),
+    CONSTRAINT "transaction_history_transaction_id_fkey" FOREIGN KEY ("transaction_id") REFERENCES "transactions" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_history_changed_by_fkey" FOREIGN KEY ("changed_by") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE SET NULL
+);
+CREATE INDEX "transaction_history_transaction_id_index" ON "transaction_history"("transaction_id", "id");
+
+-- changeset ?:1767200000000-2 splitStatements:false
+-- History entries are never edited; they only go away with their transaction.
+CREATE OR REPLACE FUNCTION reject_transaction_history_update() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'transaction_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- changeset ?:1767200000000-3 splitStatements:false
+CREATE TRIGGER "transaction_history_append_only" BEFORE UPDATE ON "transaction_history"
+    FOR EACH ROW EXECUTE FUNCTION reject_transaction_history_update();
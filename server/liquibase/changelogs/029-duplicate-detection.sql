@@ -0,0 +1,5 @@
+-- liquibase formatted sql
+
+-- changeset ?:1767400000000-1
+-- Trigram similarity is used to compare the notes of likely duplicates.
+CREATE EXTENSION IF NOT EXISTS "pg_trgm";
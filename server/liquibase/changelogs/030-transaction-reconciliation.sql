@@ -0,0 +1,32 @@
+-- liquibase formatted sql
+
+-- changeset ?:1767600000000-1
+CREATE TYPE "transaction_status" AS ENUM ('PENDING', 'CLEARED', 'RECONCILED');
+ALTER TABLE "transactions" ADD "status" transaction_status NOT NULL DEFAULT 'PENDING';
+CREATE INDEX "transactions_sender_status_index" ON "transactions"("sender", "status");
+CREATE INDEX "transactions_receiver_status_index" ON "transactions"("receiver", "status");
+
+-- changeset ?:1767600000000-2
+-- A reconciliation records the statement an account was checked against.
+-- Its transactions are marked RECONCILED rather than linked to it.
+CREATE TABLE "reconciliations" (
+    "id" INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "account_id" INTEGER NOT NULL,
+    "statement_date" DATE NOT NULL,
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT "reconciliations_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "reconciliations_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "reconciliations_account_id_fkey" FOREIGN KEY ("account_id") REFERENCES "accounts" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE INDEX "reconciliations_account_id_index" ON "reconciliations"("account_id", "statement_date");
+
+-- changeset ?:1767600000000-3
+CREATE TABLE "reconciliation_balances" (
+    "reconciliation_id" INTEGER NOT NULL,
+    "currency_id" INTEGER NOT NULL,
+    "statement_balance" BIGINT NOT NULL,
+    CONSTRAINT "reconciliation_balances_pkey" PRIMARY KEY ("reconciliation_id", "currency_id"),
+    CONSTRAINT "reconciliation_balances_reconciliation_id_fkey" FOREIGN KEY ("reconciliation_id") REFERENCES "reconciliations" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "reconciliation_balances_currency_id_fkey" FOREIGN KEY ("currency_id") REFERENCES "currencies" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
@@ -0,0 +1,36 @@
+-- liquibase formatted sql
+
+-- changeset ?:1767800000000-1
+-- A transaction recorded by a group member on behalf of the member who paid
+-- it belongs to the payer, who can then accept or dispute it.
+CREATE TABLE "transaction_attributions" (
+    "transaction_id" INTEGER NOT NULL,
+    "recorded_by" TEXT,
+    "state" TEXT NOT NULL DEFAULT 'pending',
+    "dispute_reason" TEXT NOT NULL DEFAULT '',
+    "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CONSTRAINT "transaction_attributions_pkey" PRIMARY KEY ("transaction_id"),
+    CONSTRAINT "transaction_attributions_transaction_id_fkey" FOREIGN KEY ("transaction_id") REFERENCES "transactions" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_attributions_recorded_by_fkey" FOREIGN KEY ("recorded_by") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE SET NULL,
+    CONSTRAINT "transaction_attributions_state_check" CHECK ("state" IN ('pending', 'accepted', 'disputed'))
+);
+
+-- changeset ?:1767800000000-2 splitStatements:false
+-- The payer and the member who recorded the transaction both see its
+-- attribution, so a change to it is reported to them as a change to the
+-- transaction.
+CREATE OR REPLACE FUNCTION notify_transaction_attribution_change() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM notify_change('transaction', 'UPDATE', NEW.transaction_id,
+        ARRAY(
+            SELECT t.user_id FROM "transactions" t WHERE t.id = NEW.transaction_id
+            UNION
+            SELECT NEW.recorded_by WHERE NEW.recorded_by IS NOT NULL
+        ),
+        ARRAY[]::TEXT[]);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "transaction_attributions_notify_change" AFTER INSERT OR UPDATE ON "transaction_attributions"
+    FOR EACH ROW EXECUTE FUNCTION notify_transaction_attribution_change();
@@ -0,0 +1,64 @@
+-- liquibase formatted sql
+
+-- changeset ?:1768000000000-1 splitStatements:false
+-- Merchant names as printed on statements vary ("AMZN Mktp CA*2K3",
+-- "Amazon.ca"), so they are compared lowercased, with every run of
+-- punctuation and spaces collapsed to a single space.
+CREATE OR REPLACE FUNCTION normalize_payee_name(name TEXT) RETURNS TEXT AS $$
+    SELECT trim(regexp_replace(lower(name), '[^[:alnum:]]+', ' ', 'g'));
+$$ LANGUAGE sql IMMUTABLE;
+
+-- changeset ?:1768000000000-2
+CREATE TABLE "payees" (
+    "id" INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "default_category_id" INTEGER,
+    CONSTRAINT "payees_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "payees_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "payees_default_category_id_fkey" FOREIGN KEY ("default_category_id") REFERENCES "categories" ("id") ON UPDATE NO ACTION ON DELETE SET NULL
+);
+CREATE UNIQUE INDEX "payees_user_id_name_index" ON "payees"("user_id", lower("name"));
+
+-- changeset ?:1768000000000-3
+-- An alias matches a merchant name whose normalized form is the normalized
+-- pattern or starts with it followed by a space.
+CREATE TABLE "payee_aliases" (
+    "payee_id" INTEGER NOT NULL,
+    "pattern" TEXT NOT NULL,
+    "normalized_pattern" TEXT GENERATED ALWAYS AS (normalize_payee_name("pattern")) STORED,
+    CONSTRAINT "payee_aliases_pkey" PRIMARY KEY ("payee_id", "normalized_pattern"),
+    CONSTRAINT "payee_aliases_payee_id_fkey" FOREIGN KEY ("payee_id") REFERENCES "payees" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "payee_aliases_pattern_check" CHECK ("normalized_pattern" <> '')
+);
+
+-- changeset ?:1768000000000-4
+ALTER TABLE "transactions" ADD "payee_id" INTEGER;
+ALTER TABLE "transactions" ADD CONSTRAINT "transactions_payee_id_fkey" FOREIGN KEY ("payee_id") REFERENCES "payees" ("id") ON UPDATE NO ACTION ON DELETE SET NULL;
+CREATE INDEX "transactions_payee_id_index" ON "transactions"("payee_id");
+
+-- changeset ?:1768000000000-5 splitStatements:false
+CREATE TRIGGER "payees_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "payees"
+    FOR EACH ROW EXECUTE FUNCTION notify_owned_row_change('payee');
+
+-- Aliases are part of their payee, so changing them is reported as an update
+-- of the payee.
+CREATE OR REPLACE FUNCTION notify_payee_alias_change() RETURNS TRIGGER AS $$
+DECLARE
+    alias_payee_id INTEGER;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        alias_payee_id := OLD.payee_id;
+    ELSE
+        alias_payee_id := NEW.payee_id;
+    END IF;
+
+    PERFORM notify_change('payee', 'UPDATE', alias_payee_id,
+        ARRAY(SELECT p.user_id FROM "payees" p WHERE p.id = alias_payee_id),
+        ARRAY[]::TEXT[]);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "payee_aliases_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "payee_aliases"
+    FOR EACH ROW EXECUTE FUNCTION notify_payee_alias_change();
@@ -0,0 +1,69 @@
+-- liquibase formatted sql
+
+-- changeset ?:1768200000000-1
+-- A rule matches a transaction when every condition it sets holds, and then
+-- applies every action it sets. Rules run by ascending priority. A rule whose
+-- condition refers to a deleted account, currency or payee could never match
+-- again and goes with it, while a deleted category or group only drops the
+-- action using it.
+CREATE TABLE "transaction_rules" (
+    "id" INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "priority" INTEGER NOT NULL DEFAULT 0,
+    "enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "stop_processing" BOOLEAN NOT NULL DEFAULT FALSE,
+    "note_pattern" TEXT,
+    "min_amount" INTEGER,
+    "max_amount" INTEGER,
+    "account_id" INTEGER,
+    "currency_id" INTEGER,
+    "payee_id" INTEGER,
+    "set_category_id" INTEGER,
+    "rewrite_note" TEXT,
+    "set_transaction_group_id" INTEGER,
+    CONSTRAINT "transaction_rules_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "transaction_rules_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_rules_account_id_fkey" FOREIGN KEY ("account_id") REFERENCES "accounts" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_rules_currency_id_fkey" FOREIGN KEY ("currency_id") REFERENCES "currencies" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_rules_payee_id_fkey" FOREIGN KEY ("payee_id") REFERENCES "payees" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_rules_set_category_id_fkey" FOREIGN KEY ("set_category_id") REFERENCES "categories" ("id") ON UPDATE NO ACTION ON DELETE SET NULL,
+    CONSTRAINT "transaction_rules_set_transaction_group_id_fkey" FOREIGN KEY ("set_transaction_group_id") REFERENCES "transaction_group" ("id") ON UPDATE NO ACTION ON DELETE SET NULL
+);
+CREATE INDEX "transaction_rules_user_id_priority_index" ON "transaction_rules"("user_id", "priority", "id");
+
+-- changeset ?:1768200000000-2
+CREATE TABLE "transaction_rule_tags" (
+    "rule_id" INTEGER NOT NULL,
+    "tag_id" INTEGER NOT NULL,
+    CONSTRAINT "transaction_rule_tags_pkey" PRIMARY KEY ("rule_id", "tag_id"),
+    CONSTRAINT "transaction_rule_tags_rule_id_fkey" FOREIGN KEY ("rule_id") REFERENCES "transaction_rules" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "transaction_rule_tags_tag_id_fkey" FOREIGN KEY ("tag_id") REFERENCES "tags" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE INDEX "transaction_rule_tags_tag_id_index" ON "transaction_rule_tags"("tag_id");
+
+-- changeset ?:1768200000000-3 splitStatements:false
+CREATE TRIGGER "transaction_rules_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "transaction_rules"
+    FOR EACH ROW EXECUTE FUNCTION notify_owned_row_change('rule');
+
+-- The tags a rule adds are part of the rule, so changing them is reported as
+-- an update of the rule.
+CREATE OR REPLACE FUNCTION notify_transaction_rule_tag_change() RETURNS TRIGGER AS $$
+DECLARE
+    tag_rule_id INTEGER;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        tag_rule_id := OLD.rule_id;
+    ELSE
+        tag_rule_id := NEW.rule_id;
+    END IF;
+
+    PERFORM notify_change('rule', 'UPDATE', tag_rule_id,
+        ARRAY(SELECT r.user_id FROM "transaction_rules" r WHERE r.id = tag_rule_id),
+        ARRAY[]::TEXT[]);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "transaction_rule_tags_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "transaction_rule_tags"
+    FOR EACH ROW EXECUTE FUNCTION notify_transaction_rule_tag_change();
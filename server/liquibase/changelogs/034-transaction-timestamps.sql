@@ -0,0 +1,14 @@
+-- liquibase formatted sql
+
+-- changeset ?:1768400000000-1
+-- IANA name of the zone the user lives in. Transaction times are shown in it,
+-- and the days, months and years that reports and schedules work with begin
+-- and end in it.
+ALTER TABLE "users" ADD COLUMN "timezone" TEXT NOT NULL DEFAULT 'UTC';
+
+-- changeset ?:1768400000000-2
+-- Transactions keep their time of day. Existing dates carry none, so they are
+-- placed at noon UTC, which falls on the same calendar day in every zone
+-- within twelve hours of UTC.
+ALTER TABLE "transactions"
+    ALTER COLUMN "date" TYPE TIMESTAMPTZ USING ("date" + TIME '12:00') AT TIME ZONE 'UTC';
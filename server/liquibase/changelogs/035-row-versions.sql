@@ -0,0 +1,32 @@
+-- liquibase formatted sql
+
+-- changeset ?:1768600000000-1
+-- Clients send back the version they read with an update, so the update is
+-- refused when someone else changed the row in between instead of silently
+-- overwriting their change.
+ALTER TABLE "transactions" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "accounts" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "categories" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "currencies" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "transaction_group" ADD COLUMN "version" INTEGER NOT NULL DEFAULT 1;
+
+-- changeset ?:1768600000000-2 splitStatements:false
+-- Every update of a row moves it to the next version, whichever query makes
+-- it, so no write path can forget to.
+CREATE OR REPLACE FUNCTION bump_row_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "transactions_bump_version" BEFORE UPDATE ON "transactions"
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+CREATE TRIGGER "accounts_bump_version" BEFORE UPDATE ON "accounts"
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+CREATE TRIGGER "categories_bump_version" BEFORE UPDATE ON "categories"
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+CREATE TRIGGER "currencies_bump_version" BEFORE UPDATE ON "currencies"
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+CREATE TRIGGER "transaction_group_bump_version" BEFORE UPDATE ON "transaction_group"
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
@@ -0,0 +1,17 @@
+-- liquibase formatted sql
+
+-- changeset ?:1768800000000-1
+-- Keys clients send with create requests they may retry. The response of the
+-- first request is kept, so a retry gets it back instead of creating the
+-- same thing twice. It stays NULL while that first request is running.
+CREATE TABLE "idempotency_keys" (
+    "user_id" TEXT NOT NULL,
+    "key" TEXT NOT NULL,
+    "method" TEXT NOT NULL,
+    "request_hash" BYTEA NOT NULL,
+    "response" BYTEA,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT "idempotency_keys_pkey" PRIMARY KEY ("user_id", "key"),
+    CONSTRAINT "idempotency_keys_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE INDEX "idempotency_keys_created_at_index" ON "idempotency_keys"("created_at");
@@ -0,0 +1,30 @@
+-- liquibase formatted sql
+
+-- changeset ?:1769000000000-1
+CREATE TYPE "budget_period" AS ENUM ('MONTHLY', 'WEEKLY', 'YEARLY');
+
+-- changeset ?:1769000000000-2
+-- The amount planned to be spent on a category, its subcategories included,
+-- in every period from starts_on on, until a later budget of the same
+-- category and period takes over. starts_on is the first day of a period:
+-- the first of a month, a Monday, or the first of January.
+CREATE TABLE "budgets" (
+    "id" INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "category_id" INTEGER NOT NULL,
+    "period" budget_period NOT NULL,
+    "starts_on" DATE NOT NULL,
+    "amount" INTEGER NOT NULL,
+    "currency_id" INTEGER NOT NULL,
+    CONSTRAINT "budgets_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "budgets_category_period_starts_on_key" UNIQUE ("category_id", "period", "starts_on"),
+    CONSTRAINT "budgets_amount_check" CHECK ("amount" >= 0),
+    CONSTRAINT "budgets_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "budgets_category_id_fkey" FOREIGN KEY ("category_id") REFERENCES "categories" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "budgets_currency_id_fkey" FOREIGN KEY ("currency_id") REFERENCES "currencies" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+CREATE INDEX "budgets_user_id_index" ON "budgets"("user_id");
+
+-- changeset ?:1769000000000-3 splitStatements:false
+CREATE TRIGGER "budgets_notify_change" AFTER INSERT OR UPDATE OR DELETE ON "budgets"
+    FOR EACH ROW EXECUTE FUNCTION notify_owned_row_change('budget');
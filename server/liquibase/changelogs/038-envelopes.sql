@@ -0,0 +1,231 @@
+-- liquibase formatted sql
+
+-- changeset ?:1769200000000-1
+-- Turns on envelope budgeting for a user. The ledger is computed month by
+-- month from starts_on, the first day of a month, in the currency.
+-- stale_from is the first month of the ledger that no longer matches the
+-- transactions, envelopes and rates it was computed from; computed_through is
+-- the last month it holds.
+CREATE TABLE "envelope_budgets" (
+    "user_id" TEXT NOT NULL,
+    "currency_id" INTEGER NOT NULL,
+    "starts_on" DATE NOT NULL,
+    "stale_from" DATE,
+    "computed_through" DATE,
+    CONSTRAINT "envelope_budgets_pkey" PRIMARY KEY ("user_id"),
+    CONSTRAINT "envelope_budgets_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "envelope_budgets_currency_id_fkey" FOREIGN KEY ("currency_id") REFERENCES "currencies" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+
+-- changeset ?:1769200000000-2
+-- The categories with an envelope. What happens under a category goes to its
+-- own envelope or to that of its closest ancestor with one. Income envelopes
+-- feed the money available to budget instead of holding any.
+CREATE TABLE "envelopes" (
+    "category_id" INTEGER NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "income" BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT "envelopes_pkey" PRIMARY KEY ("category_id"),
+    CONSTRAINT "envelopes_category_id_fkey" FOREIGN KEY ("category_id") REFERENCES "categories" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "envelopes_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE INDEX "envelopes_user_id_index" ON "envelopes"("user_id");
+
+-- changeset ?:1769200000000-3
+-- The money given to an envelope out of the money available to budget, in a
+-- month. month is the first day of the month.
+CREATE TABLE "envelope_assignments" (
+    "category_id" INTEGER NOT NULL,
+    "month" DATE NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "amount" INTEGER NOT NULL,
+    CONSTRAINT "envelope_assignments_pkey" PRIMARY KEY ("category_id", "month"),
+    CONSTRAINT "envelope_assignments_category_id_fkey" FOREIGN KEY ("category_id") REFERENCES "envelopes" ("category_id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "envelope_assignments_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE INDEX "envelope_assignments_user_id_month_index" ON "envelope_assignments"("user_id", "month");
+
+-- changeset ?:1769200000000-4
+CREATE TABLE "budget_transfers" (
+    "id" INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "month" DATE NOT NULL,
+    "from_category_id" INTEGER NOT NULL,
+    "to_category_id" INTEGER NOT NULL,
+    "amount" INTEGER NOT NULL,
+    "note" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT "budget_transfers_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "budget_transfers_amount_check" CHECK ("amount" > 0),
+    CONSTRAINT "budget_transfers_envelopes_check" CHECK ("from_category_id" <> "to_category_id"),
+    CONSTRAINT "budget_transfers_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "budget_transfers_from_category_id_fkey" FOREIGN KEY ("from_category_id") REFERENCES "envelopes" ("category_id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "budget_transfers_to_category_id_fkey" FOREIGN KEY ("to_category_id") REFERENCES "envelopes" ("category_id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE INDEX "budget_transfers_user_id_month_index" ON "budget_transfers"("user_id", "month");
+
+-- changeset ?:1769200000000-5
+-- The state of each envelope at the end of each month, computed from the rest.
+CREATE TABLE "envelope_ledger" (
+    "category_id" INTEGER NOT NULL,
+    "month" DATE NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "income" BOOLEAN NOT NULL,
+    "assigned" BIGINT NOT NULL,
+    "transferred" BIGINT NOT NULL,
+    "activity" BIGINT NOT NULL,
+    "balance" BIGINT NOT NULL,
+    "missing_exchange_rate" BOOLEAN NOT NULL,
+    CONSTRAINT "envelope_ledger_pkey" PRIMARY KEY ("category_id", "month"),
+    CONSTRAINT "envelope_ledger_category_id_fkey" FOREIGN KEY ("category_id") REFERENCES "envelopes" ("category_id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "envelope_ledger_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE INDEX "envelope_ledger_user_id_month_index" ON "envelope_ledger"("user_id", "month");
+
+-- changeset ?:1769200000000-6 splitStatements:false
+-- Marks the envelope ledger of a user stale from the month of changed_on on,
+-- or from its first month when changed_on is NULL.
+CREATE OR REPLACE FUNCTION mark_envelope_ledger_stale(owner TEXT, changed_on DATE) RETURNS VOID AS $$
+BEGIN
+    UPDATE envelope_budgets
+    SET stale_from = GREATEST(
+        starts_on,
+        LEAST(
+            COALESCE(stale_from, 'infinity'::DATE),
+            COALESCE(date_trunc('month', changed_on)::DATE, starts_on)
+        )
+    )
+    WHERE user_id = owner;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Same, for a point in time, which falls in a month of the user's timezone.
+CREATE OR REPLACE FUNCTION mark_envelope_ledger_stale_at(owner TEXT, changed_at TIMESTAMPTZ) RETURNS VOID AS $$
+BEGIN
+    PERFORM mark_envelope_ledger_stale(
+        owner,
+        (changed_at AT TIME ZONE (SELECT u.timezone FROM users u WHERE u.id = owner))::DATE
+    );
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION mark_envelope_ledger_stale_for_transaction() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        PERFORM mark_envelope_ledger_stale_at(OLD.user_id, OLD.date);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        PERFORM mark_envelope_ledger_stale_at(NEW.user_id, NEW.date);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "transactions_mark_envelope_ledger_stale"
+    AFTER INSERT OR DELETE OR UPDATE OF "amount", "currency", "sender", "receiver", "category", "date", "receiver_currency", "receiver_amount", "deleted_at"
+    ON "transactions"
+    FOR EACH ROW EXECUTE FUNCTION mark_envelope_ledger_stale_for_transaction();
+
+CREATE OR REPLACE FUNCTION mark_envelope_ledger_stale_for_line_item() RETURNS TRIGGER AS $$
+DECLARE
+    item_transaction_id INTEGER;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        item_transaction_id := OLD.transaction_id;
+    ELSE
+        item_transaction_id := NEW.transaction_id;
+    END IF;
+
+    PERFORM mark_envelope_ledger_stale_at(t.user_id, t.date)
+    FROM transactions t
+    WHERE t.id = item_transaction_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "transaction_line_items_mark_envelope_ledger_stale"
+    AFTER INSERT OR UPDATE OR DELETE ON "transaction_line_items"
+    FOR EACH ROW EXECUTE FUNCTION mark_envelope_ledger_stale_for_line_item();
+
+-- Rates are interpolated between two dates, so a rate changes the conversions
+-- from the previous rate of the same currencies on.
+CREATE OR REPLACE FUNCTION mark_envelope_ledger_stale_for_exchange_rate() RETURNS TRIGGER AS $$
+DECLARE
+    rate exchangerates;
+    previous_date DATE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rate := OLD;
+    ELSE
+        rate := NEW;
+    END IF;
+
+    SELECT max(er.date) INTO previous_date
+    FROM exchangerates er
+    WHERE ((er.a = rate.a AND er.b = rate.b) OR (er.a = rate.b AND er.b = rate.a))
+      AND er.date < rate.date;
+
+    PERFORM mark_envelope_ledger_stale(c.user_id, previous_date)
+    FROM currencies c
+    WHERE c.id = rate.a;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "exchangerates_mark_envelope_ledger_stale"
+    AFTER INSERT OR UPDATE OR DELETE ON "exchangerates"
+    FOR EACH ROW EXECUTE FUNCTION mark_envelope_ledger_stale_for_exchange_rate();
+
+-- Which accounts are the user's, how categories nest and which of them have
+-- envelopes decide where every past transaction goes.
+CREATE OR REPLACE FUNCTION mark_envelope_ledger_stale_for_owned_row() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM mark_envelope_ledger_stale(OLD.user_id, NULL);
+    ELSE
+        PERFORM mark_envelope_ledger_stale(NEW.user_id, NULL);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "accounts_mark_envelope_ledger_stale"
+    AFTER UPDATE OF "is_mine" ON "accounts"
+    FOR EACH ROW EXECUTE FUNCTION mark_envelope_ledger_stale_for_owned_row();
+CREATE TRIGGER "categories_mark_envelope_ledger_stale"
+    AFTER DELETE OR UPDATE OF "parent" ON "categories"
+    FOR EACH ROW EXECUTE FUNCTION mark_envelope_ledger_stale_for_owned_row();
+CREATE TRIGGER "envelopes_mark_envelope_ledger_stale"
+    AFTER INSERT OR UPDATE OR DELETE ON "envelopes"
+    FOR EACH ROW EXECUTE FUNCTION mark_envelope_ledger_stale_for_owned_row();
+
+CREATE OR REPLACE FUNCTION mark_envelope_ledger_stale_for_month() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        PERFORM mark_envelope_ledger_stale(OLD.user_id, OLD.month);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        PERFORM mark_envelope_ledger_stale(NEW.user_id, NEW.month);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "envelope_assignments_mark_envelope_ledger_stale"
+    AFTER INSERT OR UPDATE OR DELETE ON "envelope_assignments"
+    FOR EACH ROW EXECUTE FUNCTION mark_envelope_ledger_stale_for_month();
+CREATE TRIGGER "budget_transfers_mark_envelope_ledger_stale"
+    AFTER INSERT OR UPDATE OR DELETE ON "budget_transfers"
+    FOR EACH ROW EXECUTE FUNCTION mark_envelope_ledger_stale_for_month();
+
+-- The timezone decides which month every transaction falls in.
+CREATE OR REPLACE FUNCTION mark_envelope_ledger_stale_for_user() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM mark_envelope_ledger_stale(NEW.id, NULL);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "users_mark_envelope_ledger_stale"
+    AFTER UPDATE OF "timezone" ON "users"
+    FOR EACH ROW EXECUTE FUNCTION mark_envelope_ledger_stale_for_user();
@@ -0,0 +1,16 @@
+-- liquibase formatted sql
+
+-- changeset ?:1769400000000-1 splitStatements:false
+-- Changes dated before the first month of a ledger do not affect it, so they
+-- leave it as it is instead of marking all of it stale.
+CREATE OR REPLACE FUNCTION mark_envelope_ledger_stale(owner TEXT, changed_on DATE) RETURNS VOID AS $$
+BEGIN
+    UPDATE envelope_budgets
+    SET stale_from = LEAST(
+        COALESCE(stale_from, 'infinity'::DATE),
+        COALESCE(date_trunc('month', changed_on)::DATE, starts_on)
+    )
+    WHERE user_id = owner
+      AND (changed_on IS NULL OR changed_on >= starts_on);
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,25 @@
+-- liquibase formatted sql
+
+-- changeset ?:1769500000000-1
+-- The transactions a reconciliation marked RECONCILED, so that undoing it
+-- knows which ones to unlock.
+CREATE TABLE "reconciliation_transactions" (
+    "reconciliation_id" INTEGER NOT NULL,
+    "transaction_id" INTEGER NOT NULL,
+    CONSTRAINT "reconciliation_transactions_pkey" PRIMARY KEY ("reconciliation_id", "transaction_id"),
+    CONSTRAINT "reconciliation_transactions_reconciliation_id_fkey" FOREIGN KEY ("reconciliation_id") REFERENCES "reconciliations" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+    CONSTRAINT "reconciliation_transactions_transaction_id_fkey" FOREIGN KEY ("transaction_id") REFERENCES "transactions" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+CREATE INDEX "reconciliation_transactions_transaction_id_index" ON "reconciliation_transactions"("transaction_id");
+
+-- changeset ?:1769500000000-2
+-- Reconciliations recorded before the links existed get the reconciled
+-- transactions they were the first to cover.
+INSERT INTO "reconciliation_transactions" ("reconciliation_id", "transaction_id")
+SELECT DISTINCT ON (t.id) r.id, t.id
+FROM transactions t
+    JOIN reconciliations r ON r.account_id IN (t.sender, t.receiver) AND r.user_id = t.user_id
+    JOIN users u ON u.id = r.user_id
+WHERE t.status = 'RECONCILED'
+  AND t.date < ((r.statement_date + 1)::timestamp AT TIME ZONE u.timezone)
+ORDER BY t.id, r.created_at, r.id;
@@ -0,0 +1,51 @@
+-- liquibase formatted sql
+
+-- changeset ?:1769600000000-1 splitStatements:false
+-- The owner of a transaction is now looked up once by the trigger on
+-- "transaction_transaction_group" and passed in, since the transaction is
+-- already gone when its link is removed by a purge.
+DROP FUNCTION transaction_group_member_emails(INTEGER, INTEGER);
+
+CREATE OR REPLACE FUNCTION transaction_group_member_emails(group_id INTEGER, owner_email TEXT) RETURNS TEXT[] AS $$
+    SELECT ARRAY(
+        SELECT utg.user_email
+        FROM "user_transaction_group" utg
+        WHERE utg.transaction_group_id = group_id
+          AND utg.user_email IS DISTINCT FROM owner_email
+    );
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION notify_transaction_group_link_change() RETURNS TRIGGER AS $$
+DECLARE
+    owner_email TEXT;
+BEGIN
+    SELECT u.email INTO owner_email
+    FROM "transactions" t
+        JOIN "users" u ON u.id = t.user_id
+    WHERE t.id = COALESCE(NEW.transaction_id, OLD.transaction_id);
+
+    -- The link went away along with its transaction, which is only ever
+    -- deleted when purged from the trash: everyone was told when it was
+    -- trashed, as the trigger on "transactions" assumes too.
+    IF NOT FOUND THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'UPDATE' AND OLD.transaction_group_id = NEW.transaction_group_id THEN
+        PERFORM notify_change('transaction', 'UPDATE', NEW.transaction_id,
+            ARRAY[]::TEXT[], transaction_group_member_emails(NEW.transaction_group_id, owner_email));
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM notify_change('transaction', 'DELETE', OLD.transaction_id,
+            ARRAY[]::TEXT[], transaction_group_member_emails(OLD.transaction_group_id, owner_email));
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'INSERT') THEN
+        PERFORM notify_change('transaction', 'INSERT', NEW.transaction_id,
+            ARRAY[]::TEXT[], transaction_group_member_emails(NEW.transaction_group_id, owner_email));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -5,8 +5,9 @@ import (
 	"database/sql"
 	"fmt"
 	"net/url"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"chagnon.dev/budget-server/internal/logging"
 )
@@ -26,19 +27,7 @@ func NewPostgresDatabase(ctx context.Context, host, user, pass, name string, por
 
 	logger.Debug("Attempting to connect to postgres database")
 
-	// Build the URL via url.URL so credentials with special characters are
-	// correctly percent-encoded rather than corrupting the connection string.
-	connectionUrl := url.URL{
-		Scheme: "postgresql",
-		User:   url.UserPassword(user, pass),
-		Host:   fmt.Sprintf("%s:%d", host, port),
-		Path:   "/" + name,
-	}
-	query := connectionUrl.Query()
-	query.Set("sslmode", sslMode)
-	connectionUrl.RawQuery = query.Encode()
-
-	db, err := sql.Open("postgres", connectionUrl.String())
+	db, err := sql.Open("postgres", connectionString(host, user, pass, name, port, sslMode))
 	if err != nil {
 		return nil, fmt.Errorf("connecting to postgres database: %w", err)
 	}
@@ -52,3 +41,44 @@ func NewPostgresDatabase(ctx context.Context, host, user, pass, name string, por
 
 	return db, nil
 }
+
+// NewListener opens a dedicated connection used for LISTEN/NOTIFY. The
+// listener reconnects on its own; a nil notification on its Notify channel
+// signals that the connection was re-established and notifications may have
+// been missed in between.
+func NewListener(ctx context.Context, host, user, pass, name string, port int, sslMode string) *pq.Listener {
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	logger := logging.FromContext(ctx).
+		With("host", host).
+		With("port", port).
+		With("database", name)
+
+	return pq.NewListener(
+		connectionString(host, user, pass, name, port, sslMode),
+		10*time.Second,
+		time.Minute,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				logger.Warn("Postgres listener connection event", "event", event, "error", err)
+			}
+		},
+	)
+}
+
+func connectionString(host, user, pass, name string, port int, sslMode string) string {
+	// Build the URL via url.URL so credentials with special characters are
+	// correctly percent-encoded rather than corrupting the connection string.
+	connectionUrl := url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword(user, pass),
+		Host:   fmt.Sprintf("%s:%d", host, port),
+		Path:   "/" + name,
+	}
+	query := connectionUrl.Query()
+	query.Set("sslmode", sslMode)
+	connectionUrl.RawQuery = query.Encode()
+	return connectionUrl.String()
+}